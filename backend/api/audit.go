@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"web-terminal/audit"
+	"web-terminal/preset"
+	"web-terminal/session"
+)
+
+// auditSessions records session exits and, if input is set, every line of
+// input written to sessions.
+func auditSessions(sm *session.Manager, al *audit.Logger, input bool) {
+	sm.OnExit(func(s *session.Session) {
+		al.Record(audit.Entry{Action: audit.ActionSessionExit, SessionID: s.ID, SessionName: s.Name})
+	})
+	if input {
+		sm.OnInput(func(s *session.Session, line string) {
+			al.Record(audit.Entry{Action: audit.ActionInput, SessionID: s.ID, SessionName: s.Name, Input: line})
+		})
+	}
+}
+
+// presetAudit is the detail of a presets.change entry.
+type presetAudit struct {
+	Version int64  `json:"version"`
+	Source  string `json:"source"`
+	preset.Diff
+}
+
+// auditPresets records every change to the preset list with its diff. Saves
+// that change no preset content, such as reordering, are not recorded.
+func auditPresets(pm *preset.Manager, al *audit.Logger) {
+	pm.OnDiff(func(c preset.Change, d preset.Diff) {
+		if d.Empty() {
+			return
+		}
+		al.Record(audit.Entry{Action: audit.ActionPresets, Detail: presetAudit{Version: c.Version, Source: c.Source, Diff: d}})
+	})
+}
+
+// auditRequests records API calls that may change state, with the client
+// that made them and the response status.
+func auditRequests(al *audit.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case !strings.HasPrefix(r.URL.Path, "/api/"),
+				r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			al.Record(audit.Entry{
+				Action:     audit.ActionRequest,
+				RemoteAddr: r.RemoteAddr,
+				UserAgent:  r.UserAgent(),
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Status:     ww.Status(),
+			})
+		})
+	}
+}
+
+// auditRequest records e as caused by the client of request r.
+func (h *handler) auditRequest(r *http.Request, e audit.Entry) {
+	e.RemoteAddr, e.UserAgent = r.RemoteAddr, r.UserAgent()
+	h.audit.Record(e)
+}
+
+// queryAudit returns audit log entries, newest first. Query parameters:
+// since and until (RFC 3339), session (ID or name), action (repeatable or
+// comma-separated) and limit.
+func (h *handler) queryAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var f audit.Filter
+	for name, t := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := q.Get(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, name+" must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+			*t = parsed
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > audit.MaxLimit {
+			http.Error(w, "limit must be 1-"+strconv.Itoa(audit.MaxLimit), http.StatusBadRequest)
+			return
+		}
+		f.Limit = n
+	}
+	f.Session = q.Get("session")
+	for _, v := range q["action"] {
+		for a := range strings.SplitSeq(v, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				f.Actions = append(f.Actions, a)
+			}
+		}
+	}
+
+	entries, err := h.audit.Query(f)
+	if err != nil {
+		http.Error(w, "failed to read audit log", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]audit.Entry{"entries": entries})
+}
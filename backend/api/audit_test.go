@@ -0,0 +1,141 @@
+package api_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"web-terminal/api"
+	"web-terminal/audit"
+)
+
+func queryAudit(t *testing.T, srv *httptest.Server, query string) []audit.Entry {
+	t.Helper()
+	resp, err := http.Get(srv.URL + "/api/audit?" + query)
+	if err != nil {
+		t.Fatalf("GET /api/audit: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var body struct {
+		Entries []audit.Entry `json:"entries"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return body.Entries
+}
+
+func TestAuditSessionActivity(t *testing.T) {
+	svc := newTestServices(t)
+	svc.AuditInput = true
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+	start := time.Now().Add(-time.Second)
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/sessions", strings.NewReader(`{"name":"shared"}`))
+	req.Header.Set("User-Agent", "ops-laptop")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /api/sessions: %v", err)
+	}
+	var s struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(resp.Body).Decode(&s)
+	resp.Body.Close()
+
+	conn, _, err := dialWS(t, srv, "/api/sessions/"+s.ID+"/ws")
+	if err != nil {
+		t.Fatalf("WS dial: %v", err)
+	}
+	for _, c := range "whoami\r" {
+		conn.WriteJSON(wsMsg{Type: "input", Data: base64.StdEncoding.EncodeToString([]byte(string(c)))})
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	conn.Close()
+
+	// Detaching is recorded once the server notices the closed connection.
+	var entries []audit.Entry
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entries = queryAudit(t, srv, "session=shared")
+		if len(entries) > 0 && entries[0].Action == audit.ActionDetach {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no detach entry in %+v", entries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, srv.URL+"/api/sessions/"+s.ID, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+
+	entries = queryAudit(t, srv, "session="+s.ID+"&since="+start.UTC().Format(time.RFC3339))
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	want := []string{audit.ActionSessionKill, audit.ActionSessionExit, audit.ActionDetach, audit.ActionInput, audit.ActionAttach, audit.ActionSessionCreate}
+	if strings.Join(actions, " ") != strings.Join(want, " ") {
+		t.Fatalf("got actions %v, want %v", actions, want)
+	}
+	if create := entries[5]; create.UserAgent != "ops-laptop" || create.RemoteAddr == "" {
+		t.Fatalf("expected who created the session, got %+v", create)
+	}
+	if input := entries[3]; input.Input != "whoami" {
+		t.Fatalf("expected the typed line, got %+v", input)
+	}
+
+	calls := queryAudit(t, srv, "action=api.request")
+	if len(calls) != 2 || calls[0].Method != http.MethodDelete || calls[1].Status != http.StatusCreated {
+		t.Fatalf("unexpected API call entries %+v", calls)
+	}
+}
+
+func TestAuditPresetDiffs(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	put := func(body string) {
+		req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/presets", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT /api/presets: %v", err)
+		}
+		resp.Body.Close()
+	}
+	put(`{"presets":[{"id":"a","title":"Deploy","content":"make deploy"}]}`)
+	put(`{"presets":[{"id":"a","title":"Deploy","content":"make deploy ENV=prod"}]}`)
+
+	entries := queryAudit(t, srv, "action=presets.change&limit=1")
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %+v", entries)
+	}
+	var detail struct {
+		Updated []struct {
+			Before struct{ Content string } `json:"before"`
+			After  struct{ Content string } `json:"after"`
+		} `json:"updated"`
+	}
+	raw, _ := json.Marshal(entries[0].Detail)
+	json.Unmarshal(raw, &detail)
+	if len(detail.Updated) != 1 || detail.Updated[0].Before.Content != "make deploy" || detail.Updated[0].After.Content != "make deploy ENV=prod" {
+		t.Fatalf("unexpected diff %s", raw)
+	}
+
+	resp, _ := http.Get(srv.URL + "/api/audit?since=yesterday")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad time, got %d", resp.StatusCode)
+	}
+}
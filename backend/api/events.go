@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"web-terminal/audit"
+	"web-terminal/events"
+	"web-terminal/preset"
+	"web-terminal/session"
+	"web-terminal/webhook"
+)
+
+// Event types published on the bus.
+const (
+	eventPresetsChanged = "presets.changed"
+	eventLayoutChanged  = "layout.changed"
+	eventNotification   = "notification"
+	eventTriggerMatched = "trigger.matched"
+
+	eventSessionCreated  = "session.created"
+	eventSessionExited   = "session.exited"
+	eventSessionAttached = "session.attached"
+	eventSessionDetached = "session.detached"
+)
+
+// sseKeepAlive is how often an idle event stream gets a comment line, so
+// proxies do not time it out.
+const sseKeepAlive = 25 * time.Second
+
+// publisher announces server events to browsers, through the event bus,
+// and to webhooks. Webhooks get them straight from the webhook manager,
+// which keeps and retries each delivery; the bus drops events for a
+// subscriber that falls behind.
+type publisher struct {
+	bus      *events.Bus
+	webhooks *webhook.Manager
+}
+
+func (p publisher) publish(typ string, data any) {
+	p.bus.Publish(events.Event{Type: typ, Data: data})
+	p.webhooks.Dispatch(typ, data)
+}
+
+// publishPresetChanges announces preset store changes.
+func publishPresetChanges(pm *preset.Manager, pub publisher) {
+	pm.OnChange(func(c preset.Change) {
+		pub.publish(eventPresetsChanged, c)
+	})
+}
+
+// sessionEvent is the data of session lifecycle events. RemoteAddr and
+// UserAgent describe the client of attach and detach events.
+type sessionEvent struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Backend    string   `json:"backend"`
+	Remote     string   `json:"remote,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	RemoteAddr string   `json:"remoteAddr,omitempty"`
+	UserAgent  string   `json:"userAgent,omitempty"`
+}
+
+func newSessionEvent(s *session.Session) sessionEvent {
+	return sessionEvent{ID: s.ID, Name: s.Name, Backend: s.Backend, Remote: s.Remote, Tags: s.Tags}
+}
+
+// publishSessionChanges announces session creation and exit.
+func publishSessionChanges(sm *session.Manager, pub publisher) {
+	sm.OnCreate(func(s *session.Session) {
+		pub.publish(eventSessionCreated, newSessionEvent(s))
+	})
+	sm.OnExit(func(s *session.Session) {
+		pub.publish(eventSessionExited, newSessionEvent(s))
+	})
+}
+
+// clientChanged announces that the client of request r attached to or
+// detached from s, and records it in the audit log.
+func (h *handler) clientChanged(r *http.Request, s *session.Session, attached bool) {
+	e := newSessionEvent(s)
+	e.RemoteAddr, e.UserAgent = r.RemoteAddr, r.UserAgent()
+	typ, action := eventSessionDetached, audit.ActionDetach
+	if attached {
+		typ, action = eventSessionAttached, audit.ActionAttach
+	}
+	h.publisher.publish(typ, e)
+	h.auditRequest(r, audit.Entry{Action: action, SessionID: s.ID, SessionName: s.Name})
+}
+
+// streamEvents sends bus events to the client as Server-Sent Events.
+func (h *handler) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch, cancel := h.events.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case e := <-ch:
+			data, err := json.Marshal(e.Data)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+		}
+		flusher.Flush()
+	}
+}
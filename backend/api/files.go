@@ -0,0 +1,291 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"web-terminal/files"
+)
+
+// maxUploadBytes bounds a single upload request.
+const maxUploadBytes = 1 << 30
+
+// sessionCwd returns the working directory of the session in the URL. On
+// failure it writes the error response and returns ok=false.
+func (h *handler) sessionCwd(w http.ResponseWriter, r *http.Request) (string, bool) {
+	s, ok := h.manager.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return "", false
+	}
+	cwd, err := s.Cwd()
+	if err != nil {
+		http.Error(w, "session working directory unknown", http.StatusConflict)
+		return "", false
+	}
+	return cwd, true
+}
+
+// sessionFile resolves the query parameter param against the session's
+// working directory. On failure it writes the error response.
+func (h *handler) sessionFile(w http.ResponseWriter, r *http.Request, param string) (files.Location, bool) {
+	cwd, ok := h.sessionCwd(w, r)
+	if !ok {
+		return files.Location{}, false
+	}
+	loc, err := h.files.Resolve(cwd, r.URL.Query().Get(param))
+	if err != nil {
+		writeFileError(w, err)
+		return files.Location{}, false
+	}
+	return loc, true
+}
+
+func (h *handler) listFiles(w http.ResponseWriter, r *http.Request) {
+	loc, ok := h.sessionFile(w, r, "path")
+	if !ok {
+		return
+	}
+	entries, err := h.files.List(loc)
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Path    string        `json:"path"`
+		Entries []files.Entry `json:"entries"`
+	}{loc.Abs(), entries})
+}
+
+func (h *handler) statFile(w http.ResponseWriter, r *http.Request) {
+	loc, ok := h.sessionFile(w, r, "path")
+	if !ok {
+		return
+	}
+	e, err := h.files.Stat(loc)
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+// readFile serves a file's content inline, with Range and conditional
+// request support.
+func (h *handler) readFile(w http.ResponseWriter, r *http.Request) {
+	h.serveFile(w, r, false)
+}
+
+// downloadFile serves a file as an attachment.
+func (h *handler) downloadFile(w http.ResponseWriter, r *http.Request) {
+	h.serveFile(w, r, true)
+}
+
+func (h *handler) serveFile(w http.ResponseWriter, r *http.Request, attachment bool) {
+	loc, ok := h.sessionFile(w, r, "path")
+	if !ok {
+		return
+	}
+	f, err := h.files.Open(loc)
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+
+	name := filepath.Base(loc.Abs())
+	if attachment {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	}
+	// Files from the host must never run as part of this app's origin.
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// uploadFiles stores every file part of a multipart body in the directory
+// given by path. Existing files are replaced only with overwrite=true.
+func (h *handler) uploadFiles(w http.ResponseWriter, r *http.Request) {
+	dir, ok := h.sessionFile(w, r, "path")
+	if !ok {
+		return
+	}
+	overwrite, _ := strconv.ParseBool(r.URL.Query().Get("overwrite"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "expected a multipart/form-data body", http.StatusBadRequest)
+		return
+	}
+	saved := []files.Entry{}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "invalid multipart body", http.StatusBadRequest)
+			return
+		}
+		if part.FileName() == "" {
+			continue
+		}
+		e, err := h.files.Save(dir, filepath.Base(part.FileName()), part, overwrite)
+		part.Close()
+		if err != nil {
+			writeFileError(w, err)
+			return
+		}
+		saved = append(saved, e)
+	}
+	if len(saved) == 0 {
+		http.Error(w, "no files in request", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string][]files.Entry{"files": saved})
+}
+
+func (h *handler) makeDir(w http.ResponseWriter, r *http.Request) {
+	loc, ok := h.sessionFile(w, r, "path")
+	if !ok {
+		return
+	}
+	e, err := h.files.Mkdir(loc)
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+func (h *handler) renameFile(w http.ResponseWriter, r *http.Request) {
+	from, ok := h.sessionFile(w, r, "from")
+	if !ok {
+		return
+	}
+	to, ok := h.sessionFile(w, r, "to")
+	if !ok {
+		return
+	}
+	e, err := h.files.Rename(from, to)
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+func (h *handler) deleteFile(w http.ResponseWriter, r *http.Request) {
+	loc, ok := h.sessionFile(w, r, "path")
+	if !ok {
+		return
+	}
+	recursive, _ := strconv.ParseBool(r.URL.Query().Get("recursive"))
+	if err := h.files.Remove(loc, recursive); err != nil {
+		writeFileError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// hostFile resolves the absolute path in the path query parameter. On
+// failure it writes the error response.
+func (h *handler) hostFile(w http.ResponseWriter, r *http.Request) (files.Location, bool) {
+	p := r.URL.Query().Get("path")
+	if !filepath.IsAbs(p) {
+		http.Error(w, "path must be absolute", http.StatusBadRequest)
+		return files.Location{}, false
+	}
+	loc, err := h.files.Resolve("/", p)
+	if err != nil {
+		writeFileError(w, err)
+		return files.Location{}, false
+	}
+	return loc, true
+}
+
+// getFileText returns a text file for editing, with its ETag.
+func (h *handler) getFileText(w http.ResponseWriter, r *http.Request) {
+	loc, ok := h.hostFile(w, r)
+	if !ok {
+		return
+	}
+	text, err := h.files.ReadText(loc)
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("ETag", text.ETag)
+	w.Header().Set("Last-Modified", text.ModTime.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(text.Content)
+}
+
+// putFileText stores the raw request body as the file's content.
+func (h *handler) putFileText(w http.ResponseWriter, r *http.Request) {
+	loc, ok := h.hostFile(w, r)
+	if !ok {
+		return
+	}
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, files.MaxTextBytes))
+	if err != nil {
+		writeFileError(w, files.ErrTooLarge)
+		return
+	}
+	text, err := h.files.WriteText(loc, content, r.Header.Get("If-Match"))
+	if err != nil {
+		writeFileError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", text.ETag)
+	_ = json.NewEncoder(w).Encode(text)
+}
+
+// writeFileError maps file browser errors to HTTP responses.
+func writeFileError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, files.ErrOutsideRoots):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, files.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, files.ErrExists), errors.Is(err, files.ErrVersionConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, files.ErrNotDir), errors.Is(err, files.ErrIsDir), errors.Is(err, files.ErrInvalidName):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, files.ErrNotText):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, files.ErrTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.As(err, &tooLarge):
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, "permission denied", http.StatusForbidden)
+	default:
+		http.Error(w, "file operation failed", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,161 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"web-terminal/api"
+	"web-terminal/files"
+	"web-terminal/session"
+)
+
+func TestSessionFileBrowser(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+	root := svc.Files.Roots()[0]
+	os.WriteFile(root+"/hello.txt", []byte("hello world"), 0644)
+	s, _ := svc.Sessions.CreateWithSpec("dev", session.Spec{Cwd: root})
+	base := srv.URL + "/api/sessions/" + s.ID + "/files"
+
+	resp, err := http.Get(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listing struct {
+		Path    string        `json:"path"`
+		Entries []files.Entry `json:"entries"`
+	}
+	json.NewDecoder(resp.Body).Decode(&listing)
+	resp.Body.Close()
+	if listing.Path != root || len(listing.Entries) != 1 || listing.Entries[0].Name != "hello.txt" {
+		t.Fatalf("unexpected listing: %+v", listing)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, base+"/content?path=hello.txt", nil)
+	req.Header.Set("Range", "bytes=6-")
+	resp, _ = http.DefaultClient.Do(req)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "world" {
+		t.Fatalf("unexpected range read: %d %q", resp.StatusCode, body)
+	}
+
+	resp, _ = http.Post(base+"/mkdir?path=up", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("mkdir: expected 201, got %d", resp.StatusCode)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, _ := mw.CreateFormFile("file", "notes.md")
+	fw.Write([]byte("# notes"))
+	mw.Close()
+	resp, _ = http.Post(base+"/upload?path=up", mw.FormDataContentType(), &buf)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload: expected 201, got %d", resp.StatusCode)
+	}
+	if data, _ := os.ReadFile(root + "/up/notes.md"); string(data) != "# notes" {
+		t.Fatalf("unexpected uploaded content %q", data)
+	}
+
+	resp, _ = http.Post(base+"/rename?from=up/notes.md&to=readme.md", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("rename: expected 200, got %d", resp.StatusCode)
+	}
+
+	resp, _ = http.Get(base + "/download?path=readme.md")
+	resp.Body.Close()
+	if cd := resp.Header.Get("Content-Disposition"); cd != "attachment; filename=readme.md" {
+		t.Fatalf("unexpected Content-Disposition %q", cd)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, base+"?path=up&recursive=true", nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionFileBrowserOutsideRoots(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+	s, _ := svc.Sessions.CreateWithSpec("dev", session.Spec{Cwd: svc.Files.Roots()[0]})
+	base := srv.URL + "/api/sessions/" + s.ID + "/files"
+
+	for _, url := range []string{base + "?path=..", base + "/content?path=/etc/passwd"} {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("%s: expected 403, got %d", url, resp.StatusCode)
+		}
+	}
+
+	resp, _ := http.Get(srv.URL + "/api/sessions/nope/files")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown session, got %d", resp.StatusCode)
+	}
+}
+
+func TestEditHostFile(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+	root := svc.Files.Roots()[0]
+	os.WriteFile(root+"/app.conf", []byte("debug = false\n"), 0640)
+	url := srv.URL + "/api/files/content?path=" + root + "/app.conf"
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || string(body) != "debug = false\n" || etag == "" {
+		t.Fatalf("unexpected GET: %d %q %q", resp.StatusCode, body, etag)
+	}
+
+	put := func(content, ifMatch string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPut, url, strings.NewReader(content))
+		req.Header.Set("If-Match", ifMatch)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	resp = put("debug = true\n", etag)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == etag {
+		t.Fatalf("unexpected PUT: %d %s", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	if resp := put("debug = maybe\n", etag); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 for a stale ETag, got %d", resp.StatusCode)
+	}
+	if info, _ := os.Stat(root + "/app.conf"); info.Mode().Perm() != 0640 {
+		t.Fatalf("write lost permissions: %v", info.Mode())
+	}
+
+	resp, _ = http.Get(srv.URL + "/api/files/content?path=app.conf")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a relative path, got %d", resp.StatusCode)
+	}
+}
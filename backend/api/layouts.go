@@ -0,0 +1,196 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"web-terminal/layout"
+	"web-terminal/session"
+)
+
+// maxLayoutBodyBytes bounds layout request bodies.
+const maxLayoutBodyBytes = 1 << 20
+
+// publishLayoutChanges announces layout changes.
+func publishLayoutChanges(lm *layout.Manager, pub publisher) {
+	lm.OnChange(func(id string) {
+		pub.publish(eventLayoutChanged, layoutChange{ID: id})
+	})
+}
+
+type layoutChange struct {
+	ID string `json:"id"`
+}
+
+func (h *handler) listLayouts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]layout.Layout{"layouts": h.layoutManager.List()})
+}
+
+func (h *handler) createLayout(w http.ResponseWriter, r *http.Request) {
+	var l layout.Layout
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLayoutBodyBytes)).Decode(&l); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	created, err := h.layoutManager.Create(l)
+	if err != nil {
+		h.writeLayoutError(w, "", err)
+		return
+	}
+	writeVersionedJSON(w, http.StatusCreated, created.Revision, created)
+}
+
+func (h *handler) getLayout(w http.ResponseWriter, r *http.Request) {
+	l, err := h.layoutManager.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		h.writeLayoutError(w, "", err)
+		return
+	}
+	writeVersionedJSON(w, http.StatusOK, l.Revision, l)
+}
+
+func (h *handler) putLayout(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	ifRevision, ok := parseIfMatch(w, r)
+	if !ok {
+		return
+	}
+	var l layout.Layout
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLayoutBodyBytes)).Decode(&l); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	saved, err := h.layoutManager.Update(id, l, ifRevision)
+	if err != nil {
+		h.writeLayoutError(w, id, err)
+		return
+	}
+	writeVersionedJSON(w, http.StatusOK, saved.Revision, saved)
+}
+
+func (h *handler) deleteLayout(w http.ResponseWriter, r *http.Request) {
+	if err := h.layoutManager.Delete(chi.URLParam(r, "id")); err != nil {
+		h.writeLayoutError(w, "", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeLayoutError maps layout manager errors to HTTP responses. A revision
+// conflict returns 409 with the current layout of id.
+func (h *handler) writeLayoutError(w http.ResponseWriter, id string, err error) {
+	switch {
+	case errors.Is(err, layout.ErrNotFound):
+		http.Error(w, "layout not found", http.StatusNotFound)
+	case errors.Is(err, layout.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, layout.ErrRevisionConflict):
+		current, _ := h.layoutManager.Get(id)
+		writeVersionedJSON(w, http.StatusConflict, current.Revision, struct {
+			Error  string        `json:"error"`
+			Layout layout.Layout `json:"layout"`
+		}{"layout was modified by another client", current})
+	default:
+		http.Error(w, "failed to save layout", http.StatusInternalServerError)
+	}
+}
+
+// layoutWS streams every pane of a layout over one WebSocket, using pane IDs
+// as channels. The current layout is sent as a "layout" message on connect
+// and whenever it changes, and the panes' sessions are attached and
+// detached to match.
+func (h *handler) layoutWS(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := h.layoutManager.Get(id); err != nil {
+		h.writeLayoutError(w, id, err)
+		return
+	}
+	changes, unsubscribe := h.events.Subscribe()
+	defer unsubscribe()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(pongWait)) //nolint:errcheck
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	mc := newMuxConn(conn)
+	mc.onClient = func(s *session.Session, attached bool) { h.clientChanged(r, s, attached) }
+	defer mc.close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go mc.run(stop)
+
+	// sync sends the layout and attaches its panes. It reports false once
+	// the layout is gone.
+	sync := func() bool {
+		l, err := h.layoutManager.Get(id)
+		if err != nil {
+			mc.write(wsMessage{Type: "closed"}) //nolint:errcheck
+			return false
+		}
+		if err := mc.write(wsMessage{Type: "layout", Layout: &l}); err != nil {
+			return false
+		}
+		panes := l.Panes()
+		keep := make(map[string]bool, len(panes))
+		for _, p := range panes {
+			keep[p.Pane] = true
+		}
+		mc.detachExcept(keep)
+		for _, p := range panes {
+			if s, ok := h.manager.Get(p.Session); ok {
+				mc.attach(p.Pane, s)
+			} else {
+				mc.write(wsMessage{Type: "closed", Channel: p.Pane}) //nolint:errcheck
+			}
+		}
+		return true
+	}
+	if !sync() {
+		return
+	}
+
+	// Client messages are read in the background so layout changes and
+	// pings can be handled here.
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		for {
+			var msg wsMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			mc.handle(msg)
+		}
+	}()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-readDone:
+			return
+		case <-ticker.C:
+			if err := mc.ping(); err != nil {
+				return
+			}
+		case e, ok := <-changes:
+			if !ok {
+				return
+			}
+			if c, isLayout := e.Data.(layoutChange); isLayout && c.ID == id && !sync() {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package api_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"web-terminal/api"
+	"web-terminal/layout"
+)
+
+type layoutWSMsg struct {
+	Type    string         `json:"type"`
+	Channel string         `json:"channel,omitempty"`
+	Data    string         `json:"data,omitempty"`
+	Layout  *layout.Layout `json:"layout,omitempty"`
+}
+
+func layoutBody(sessions ...string) string {
+	panes := make([]string, len(sessions))
+	for i, id := range sessions {
+		panes[i] = fmt.Sprintf(`{"session":%q}`, id)
+	}
+	if len(panes) == 1 {
+		return `{"name":"dev","root":` + panes[0] + `}`
+	}
+	return `{"name":"dev","root":{"split":"vertical","children":[` + strings.Join(panes, ",") + `]}}`
+}
+
+func TestLayoutCRUD(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/layouts", "application/json", strings.NewReader(layoutBody("s1", "s2")))
+	if err != nil {
+		t.Fatalf("POST /api/layouts: %v", err)
+	}
+	var created layout.Layout
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	if len(created.Panes()) != 2 || created.Panes()[0].Pane == "" {
+		t.Fatalf("expected two panes with IDs, got %+v", created)
+	}
+	etag := resp.Header.Get("ETag")
+
+	put := func(body, ifMatch string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/layouts/"+created.ID, strings.NewReader(body))
+		req.Header.Set("If-Match", ifMatch)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT: %v", err)
+		}
+		return resp
+	}
+	first := put(layoutBody("s1"), etag)
+	first.Body.Close()
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("first PUT: expected 200, got %d", first.StatusCode)
+	}
+	stale := put(layoutBody("s3", "s4"), etag)
+	var conflict struct {
+		Layout layout.Layout `json:"layout"`
+	}
+	json.NewDecoder(stale.Body).Decode(&conflict)
+	stale.Body.Close()
+	if stale.StatusCode != http.StatusConflict {
+		t.Fatalf("stale PUT: expected 409, got %d", stale.StatusCode)
+	}
+	if len(conflict.Layout.Panes()) != 1 || conflict.Layout.Revision != 2 {
+		t.Fatalf("conflict should report the current layout, got %+v", conflict.Layout)
+	}
+
+	invalid := put(`{"name":"dev","root":{"split":"diagonal"}}`, "*")
+	invalid.Body.Close()
+	if invalid.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid PUT: expected 400, got %d", invalid.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/api/layouts/"+created.ID, nil)
+	delResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	delResp.Body.Close()
+	if delResp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", delResp.StatusCode)
+	}
+	getResp, _ := http.Get(srv.URL + "/api/layouts/" + created.ID)
+	getResp.Body.Close()
+	if getResp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", getResp.StatusCode)
+	}
+}
+
+func TestLayoutWSMultiplexesPanes(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	s1, _ := svc.Sessions.Create("left")
+	s2, _ := svc.Sessions.Create("right")
+	l, err := svc.Layouts.Create(layout.Layout{
+		Name: "dev",
+		Root: layout.Node{Split: layout.SplitHorizontal, Children: []layout.Node{
+			{Pane: "a", Session: s1.ID},
+			{Pane: "b", Session: s2.ID},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Create layout: %v", err)
+	}
+
+	conn, _, err := dialWS(t, srv, "/api/layouts/"+l.ID+"/ws")
+	if err != nil {
+		t.Fatalf("WS dial: %v", err)
+	}
+	defer conn.Close()
+	read := func() layoutWSMsg {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var msg layoutWSMsg
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("ReadJSON: %v", err)
+		}
+		return msg
+	}
+
+	if msg := read(); msg.Type != "layout" || msg.Layout == nil || msg.Layout.ID != l.ID {
+		t.Fatalf("expected the layout first, got %+v", msg)
+	}
+
+	// Input on channel b reaches only the right-hand session, whose mock
+	// backend echoes it back on that channel.
+	conn.WriteJSON(layoutWSMsg{Type: "input", Channel: "b", Data: base64.StdEncoding.EncodeToString([]byte("pong"))})
+	for {
+		msg := read()
+		if msg.Type != "output" {
+			continue
+		}
+		data, _ := base64.StdEncoding.DecodeString(msg.Data)
+		if msg.Channel != "b" || string(data) != "pong" {
+			t.Fatalf("expected pong on channel b, got %q on %q", data, msg.Channel)
+		}
+		break
+	}
+
+	// Dropping pane b from the layout is pushed to the client.
+	l.Root = layout.Node{Pane: "a", Session: s1.ID}
+	if _, err := svc.Layouts.Update(l.ID, l, layout.AnyRevision); err != nil {
+		t.Fatalf("Update layout: %v", err)
+	}
+	if msg := read(); msg.Type != "layout" || len(msg.Layout.Panes()) != 1 {
+		t.Fatalf("expected the updated layout, got %+v", msg)
+	}
+
+	if err := svc.Layouts.Delete(l.ID); err != nil {
+		t.Fatalf("Delete layout: %v", err)
+	}
+	if msg := read(); msg.Type != "closed" || msg.Channel != "" {
+		t.Fatalf("expected closed after delete, got %+v", msg)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("expected the connection to close")
+	}
+}
@@ -0,0 +1,503 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"web-terminal/session"
+)
+
+// Flow control on multiplexed connections. Each channel may have muxWindow
+// bytes of session output in flight; the client returns credit with "ack"
+// messages as it processes output. A channel that falls more than
+// muxMaxPending bytes behind is resynchronised from the newest muxWindow
+// bytes of the scrollback, which leaves it room to catch up before it could
+// fall behind again. Transfer data cannot be dropped, so instead the channel
+// stops taking it from the session until it has caught up, which holds back
+// the program sending it.
+const (
+	muxWindow     = 256 << 10
+	muxChunk      = 16 << 10 // largest data message, for fairness
+	muxMaxPending = 1 << 20
+)
+
+// muxConn carries several sessions over one WebSocket. Each attached
+// session is a channel, and messages about it carry the channel's ID; apart
+// from that the messages are those of a single-session connection.
+//
+// Channel messages are queued and written by run, which takes one message
+// from each channel in turn so a busy session cannot starve the others.
+type muxConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	wake    chan struct{}
+
+	mu       sync.Mutex
+	channels map[string]*muxChannel
+	order    []*muxChannel // attach order, for round-robin
+	next     int           // position in order of the next channel to serve
+
+	// onClient, if set, is called as sessions are attached and detached.
+	onClient func(s *session.Session, attached bool)
+}
+
+type muxChannel struct {
+	id       string
+	s        *session.Session
+	out      chan session.Output
+	detached chan struct{} // closed by detach
+	room     chan struct{} // signalled (non-blocking) as queued data is written
+
+	// Guarded by muxConn.mu.
+	queue   []muxFrame
+	pending int // data bytes in queue
+	credit  int
+}
+
+// muxFrame is a queued message. Data frames carry session bytes and use
+// credit; the last frame of a channel detaches it once written.
+type muxFrame struct {
+	msg  wsMessage
+	data []byte
+	last bool
+}
+
+func (f muxFrame) isData() bool {
+	return f.msg.Type == "output" || f.msg.Type == "transfer-data"
+}
+
+func newMuxConn(conn *websocket.Conn) *muxConn {
+	return &muxConn{conn: conn, wake: make(chan struct{}, 1), channels: make(map[string]*muxChannel)}
+}
+
+// write sends msg; gorilla/websocket forbids concurrent writes.
+func (c *muxConn) write(msg wsMessage) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteJSON(msg)
+}
+
+// ping sends a WebSocket ping frame.
+func (c *muxConn) ping() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.conn.WriteMessage(websocket.PingMessage, nil)
+}
+
+func (c *muxConn) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run writes queued channel messages until a write fails or stop is closed.
+func (c *muxConn) run(stop <-chan struct{}) {
+	for {
+		ch, f, ok := c.dequeue()
+		if !ok {
+			select {
+			case <-c.wake:
+				continue
+			case <-stop:
+				return
+			}
+		}
+		msg := f.msg
+		if f.data != nil {
+			msg.Data = base64.StdEncoding.EncodeToString(f.data)
+		}
+		if err := c.write(msg); err != nil {
+			return
+		}
+		if f.last {
+			c.detach(ch)
+		}
+	}
+}
+
+// dequeue takes the next frame in round-robin order, skipping channels
+// whose next frame is data they have no credit for. Data frames are split
+// to at most muxChunk bytes.
+func (c *muxConn) dequeue() (*muxChannel, muxFrame, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for range c.order {
+		if c.next >= len(c.order) {
+			c.next = 0
+		}
+		ch := c.order[c.next]
+		c.next++
+		if len(ch.queue) == 0 {
+			continue
+		}
+		f := ch.queue[0]
+		if !f.isData() {
+			ch.queue = ch.queue[1:]
+			return ch, f, true
+		}
+		if ch.credit <= 0 {
+			continue
+		}
+		n := min(len(f.data), muxChunk, ch.credit)
+		if n < len(f.data) {
+			ch.queue[0].data = f.data[n:]
+			f.data = f.data[:n]
+		} else {
+			ch.queue = ch.queue[1:]
+		}
+		ch.pending -= n
+		ch.credit -= n
+		select {
+		case ch.room <- struct{}{}:
+		default:
+		}
+		return ch, f, true
+	}
+	return nil, muxFrame{}, false
+}
+
+// enqueue adds a frame to ch, merging data into a queued frame of the same
+// type. A channel too far behind drops its queued output and starts over
+// from the scrollback; transfer data is bounded by waitRoom instead.
+func (c *muxConn) enqueue(ch *muxChannel, f muxFrame) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.channels[ch.id] != ch {
+		return
+	}
+	if f.isData() {
+		if f.msg.Type == "output" && ch.pending+len(f.data) > muxMaxPending {
+			c.overrunLocked(ch, f)
+			c.signal()
+			return
+		}
+		ch.pending += len(f.data)
+		if n := len(ch.queue); n > 0 && ch.queue[n-1].msg.Type == f.msg.Type {
+			ch.queue[n-1].data = append(ch.queue[n-1].data, f.data...)
+			c.signal()
+			return
+		}
+	}
+	ch.queue = append(ch.queue, f)
+	c.signal()
+}
+
+// overrunLocked handles a channel whose queue would exceed muxMaxPending
+// with f. c.mu must be held.
+func (c *muxConn) overrunLocked(ch *muxChannel, f muxFrame) {
+	kept := ch.queue[:0]
+	for _, q := range ch.queue {
+		if !q.isData() {
+			kept = append(kept, q)
+		}
+	}
+	ch.queue, ch.pending = kept, 0
+	// The client clears the channel's terminal on "reset"; the scrollback
+	// already holds the output that was dropped. Only its end is resent,
+	// from a line start where possible.
+	snap := ch.s.ScrollbackSnapshot()
+	if len(snap) > muxWindow {
+		snap = snap[len(snap)-muxWindow:]
+		if i := bytes.IndexByte(snap, '\n'); i >= 0 {
+			snap = snap[i+1:]
+		}
+	}
+	ch.queue = append(ch.queue, muxFrame{msg: wsMessage{Type: "reset", Channel: ch.id}})
+	if len(snap) > 0 {
+		ch.queue = append(ch.queue, muxFrame{msg: wsMessage{Type: "output", Channel: ch.id}, data: snap})
+		ch.pending = len(snap)
+	}
+}
+
+// waitRoom waits until ch can queue n more bytes of data without falling
+// more than muxMaxPending bytes behind. It reports false if ch is detached,
+// displaced by kick or its session ends first.
+func (c *muxConn) waitRoom(ch *muxChannel, n int, kick <-chan struct{}) bool {
+	for {
+		c.mu.Lock()
+		ok := ch.pending == 0 || ch.pending+n <= muxMaxPending
+		c.mu.Unlock()
+		if ok {
+			return true
+		}
+		select {
+		case <-ch.room:
+		case <-ch.detached:
+			return false
+		case <-kick:
+			return false
+		case <-ch.s.Done():
+			return false
+		}
+	}
+}
+
+// ack returns n bytes of credit to channel id.
+func (c *muxConn) ack(id string, n int) {
+	if n <= 0 {
+		return
+	}
+	c.mu.Lock()
+	if ch, ok := c.channels[id]; ok {
+		ch.credit = min(ch.credit+n, muxWindow)
+	}
+	c.mu.Unlock()
+	c.signal()
+}
+
+// attach makes s the session of channel id, displacing any other client of
+// s, and replays its scrollback. Attaching the session a channel already has
+// does nothing.
+func (c *muxConn) attach(id string, s *session.Session) {
+	c.mu.Lock()
+	if old, ok := c.channels[id]; ok {
+		if old.s == s {
+			c.mu.Unlock()
+			return
+		}
+		c.detachLocked(old)
+	}
+	ch := &muxChannel{
+		id:       id,
+		s:        s,
+		out:      make(chan session.Output, 256),
+		detached: make(chan struct{}),
+		room:     make(chan struct{}, 1),
+		credit:   muxWindow,
+	}
+	c.channels[id] = ch
+	c.order = append(c.order, ch)
+	c.mu.Unlock()
+
+	kick := s.SetClient(ch.out)
+	if c.onClient != nil {
+		c.onClient(s, true)
+	}
+	if snap := s.ScrollbackSnapshot(); len(snap) > 0 {
+		c.enqueue(ch, muxFrame{msg: wsMessage{Type: "output", Channel: id}, data: snap})
+	}
+	go c.pump(ch, kick)
+}
+
+// pump queues a channel's output until the session ends, another client
+// displaces this one, or detach closes ch.out.
+func (c *muxConn) pump(ch *muxChannel, kick <-chan struct{}) {
+	var transfer *session.Transfer
+	queue := func(out session.Output) {
+		switch {
+		case out.Notification != nil:
+			c.enqueue(ch, muxFrame{msg: wsMessage{Type: "notification", Channel: ch.id, Notification: out.Notification}})
+		case out.Highlight != nil:
+			c.enqueue(ch, muxFrame{msg: wsMessage{Type: "highlight", Channel: ch.id, Highlight: out.Highlight}})
+		case out.Prompt != nil:
+			p := out.Prompt
+			c.enqueue(ch, muxFrame{msg: wsMessage{Type: "hostkey", Channel: ch.id, ID: p.ID, Host: p.Host, KeyType: p.KeyType, Fingerprint: p.Fingerprint}})
+		case out.Transfer != nil:
+			if out.Transfer != transfer {
+				transfer = out.Transfer
+				c.enqueue(ch, muxFrame{msg: wsMessage{Type: "transfer", Channel: ch.id, Protocol: transfer.Protocol, Direction: transfer.Direction}})
+			}
+			// Waiting here leaves the session's transfer data in ch.out,
+			// so the session stops reading until the client catches up.
+			if c.waitRoom(ch, len(out.Data), kick) {
+				c.enqueue(ch, muxFrame{msg: wsMessage{Type: "transfer-data", Channel: ch.id}, data: out.Data})
+			}
+		default:
+			c.enqueue(ch, muxFrame{msg: wsMessage{Type: "output", Channel: ch.id}, data: out.Data})
+		}
+	}
+	for {
+		select {
+		case out, ok := <-ch.out:
+			if !ok {
+				return
+			}
+			queue(out)
+		case <-ch.s.Done():
+			// Output read before the shell exited is still in ch.out.
+			for drained := false; !drained; {
+				select {
+				case out, ok := <-ch.out:
+					if !ok {
+						return
+					}
+					queue(out)
+				default:
+					drained = true
+				}
+			}
+			c.enqueue(ch, muxFrame{msg: wsMessage{Type: "closed", Channel: ch.id}, last: true})
+			return
+		case <-kick:
+			c.enqueue(ch, muxFrame{msg: wsMessage{Type: "displaced", Channel: ch.id}, last: true})
+			return
+		case <-ch.detached:
+			return
+		}
+	}
+}
+
+// detach drops ch if it is still attached.
+func (c *muxConn) detach(ch *muxChannel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.channels[ch.id] == ch {
+		c.detachLocked(ch)
+	}
+}
+
+// detachID drops channel id, if attached.
+func (c *muxConn) detachID(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ch, ok := c.channels[id]; ok {
+		c.detachLocked(ch)
+	}
+}
+
+// detachLocked drops ch and anything still queued for it. c.mu must be
+// held.
+func (c *muxConn) detachLocked(ch *muxChannel) {
+	delete(c.channels, ch.id)
+	for i, o := range c.order {
+		if o == ch {
+			c.order = append(c.order[:i:i], c.order[i+1:]...)
+			break
+		}
+	}
+	ch.queue = nil
+	close(ch.detached)
+	ch.s.ClearClient(ch.out)
+	if c.onClient != nil {
+		c.onClient(ch.s, false)
+	}
+}
+
+// detachExcept drops every channel not in keep.
+func (c *muxConn) detachExcept(keep map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, ch := range c.channels {
+		if !keep[id] {
+			c.detachLocked(ch)
+		}
+	}
+}
+
+// close drops every channel.
+func (c *muxConn) close() {
+	c.detachExcept(nil)
+}
+
+// handle applies a client message to the session of its channel.
+func (c *muxConn) handle(msg wsMessage) {
+	if msg.Type == "ack" {
+		c.ack(msg.Channel, msg.Bytes)
+		return
+	}
+	c.mu.Lock()
+	ch, ok := c.channels[msg.Channel]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	s := ch.s
+	switch msg.Type {
+	case "input":
+		data, err := base64.StdEncoding.DecodeString(msg.Data)
+		if err != nil {
+			return
+		}
+		if _, err := s.WriteToPTY(data); err != nil {
+			log.Printf("PTY write error: %v", err)
+		}
+	case "transfer-data":
+		data, err := base64.StdEncoding.DecodeString(msg.Data)
+		if err != nil || s.Transfer() == nil {
+			return
+		}
+		if _, err := s.WriteToPTY(data); err != nil {
+			log.Printf("PTY write error: %v", err)
+		}
+	case "transfer-end":
+		s.EndTransfer()
+	case "transfer-cancel":
+		s.CancelTransfer()
+	case "hostkey-reply":
+		s.AnswerHostKey(msg.ID, msg.Accept)
+	case "resize":
+		if msg.Cols > 0 && msg.Rows > 0 {
+			if err := s.Resize(msg.Cols, msg.Rows); err != nil {
+				log.Printf("PTY resize error: %v", err)
+			}
+		}
+	}
+}
+
+// muxWS serves /api/mux, which carries any number of sessions chosen by the
+// client. "attach" binds a channel to a session, replaying its scrollback,
+// and "detach" releases it; all other messages are those of a session
+// connection plus a "channel" field.
+func (h *handler) muxWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WS upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(pongWait)) //nolint:errcheck
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	mc := newMuxConn(conn)
+	mc.onClient = func(s *session.Session, attached bool) { h.clientChanged(r, s, attached) }
+	defer mc.close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go mc.run(stop)
+
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := mc.ping(); err != nil {
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		switch msg.Type {
+		case "attach":
+			if msg.Channel == "" {
+				continue
+			}
+			if s, ok := h.manager.Get(msg.Session); ok {
+				mc.attach(msg.Channel, s)
+			} else {
+				mc.write(wsMessage{Type: "closed", Channel: msg.Channel}) //nolint:errcheck
+			}
+		case "detach":
+			mc.detachID(msg.Channel)
+		default:
+			mc.handle(msg)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"web-terminal/api"
+)
+
+type muxMsg struct {
+	Type     string `json:"type"`
+	Channel  string `json:"channel,omitempty"`
+	Session  string `json:"session,omitempty"`
+	Data     string `json:"data,omitempty"`
+	Bytes    int    `json:"bytes,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+func readMux(t *testing.T, conn *websocket.Conn) muxMsg {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg muxMsg
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("ReadJSON: %v", err)
+	}
+	return msg
+}
+
+func muxInput(channel, text string) muxMsg {
+	return muxMsg{Type: "input", Channel: channel, Data: base64.StdEncoding.EncodeToString([]byte(text))}
+}
+
+func TestMuxAttachDetach(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	s1, _ := svc.Sessions.Create("one")
+	s2, _ := svc.Sessions.Create("two")
+
+	conn, _, err := dialWS(t, srv, "/api/mux")
+	if err != nil {
+		t.Fatalf("WS dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.WriteJSON(muxMsg{Type: "attach", Channel: "a", Session: s1.ID})
+	conn.WriteJSON(muxMsg{Type: "attach", Channel: "b", Session: s2.ID})
+	conn.WriteJSON(muxMsg{Type: "attach", Channel: "c", Session: "nonexistent"})
+	if msg := readMux(t, conn); msg.Type != "closed" || msg.Channel != "c" {
+		t.Fatalf("expected closed for unknown session, got %+v", msg)
+	}
+
+	for _, ch := range []string{"a", "b"} {
+		conn.WriteJSON(muxInput(ch, "hello "+ch))
+		msg := readMux(t, conn)
+		data, _ := base64.StdEncoding.DecodeString(msg.Data)
+		if msg.Type != "output" || msg.Channel != ch || string(data) != "hello "+ch {
+			t.Fatalf("expected echo on %s, got %+v (%q)", ch, msg, data)
+		}
+	}
+
+	// Once detached, input on the channel is ignored and the session is
+	// free for other clients.
+	conn.WriteJSON(muxMsg{Type: "detach", Channel: "a"})
+	conn.WriteJSON(muxInput("a", "ignored"))
+	conn.WriteJSON(muxInput("b", "still here"))
+	msg := readMux(t, conn)
+	data, _ := base64.StdEncoding.DecodeString(msg.Data)
+	if msg.Channel != "b" || string(data) != "still here" {
+		t.Fatalf("expected only channel b output, got %+v (%q)", msg, data)
+	}
+
+	svc.Sessions.Kill(s2.ID)
+	if msg := readMux(t, conn); msg.Type != "closed" || msg.Channel != "b" {
+		t.Fatalf("expected closed on b after kill, got %+v", msg)
+	}
+}
+
+func TestMuxFlowControl(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	busy, _ := svc.Sessions.Create("busy")
+	quiet, _ := svc.Sessions.Create("quiet")
+
+	conn, _, err := dialWS(t, srv, "/api/mux")
+	if err != nil {
+		t.Fatalf("WS dial: %v", err)
+	}
+	defer conn.Close()
+	conn.WriteJSON(muxMsg{Type: "attach", Channel: "busy", Session: busy.ID})
+	conn.WriteJSON(muxMsg{Type: "attach", Channel: "quiet", Session: quiet.ID})
+	time.Sleep(50 * time.Millisecond)
+
+	const window = 256 << 10
+	const total = 300 << 10
+	go busy.WriteToPTY(bytes.Repeat([]byte("x"), total))
+
+	// Without acks the busy channel stops after one window.
+	received := 0
+	for received < window {
+		msg := readMux(t, conn)
+		if msg.Channel != "busy" {
+			t.Fatalf("unexpected message %+v", msg)
+		}
+		data, _ := base64.StdEncoding.DecodeString(msg.Data)
+		received += len(data)
+	}
+	if received != window {
+		t.Fatalf("expected exactly %d bytes before credit ran out, got %d", window, received)
+	}
+
+	// The quiet channel is not held up by the stalled one.
+	conn.WriteJSON(muxInput("quiet", "ping"))
+	msg := readMux(t, conn)
+	data, _ := base64.StdEncoding.DecodeString(msg.Data)
+	if msg.Channel != "quiet" || string(data) != "ping" {
+		t.Fatalf("expected quiet output next, got %+v (%q)", msg, data)
+	}
+
+	conn.WriteJSON(muxMsg{Type: "ack", Channel: "busy", Bytes: window})
+	for received < total {
+		msg := readMux(t, conn)
+		data, _ := base64.StdEncoding.DecodeString(msg.Data)
+		received += len(data)
+	}
+	if received != total {
+		t.Fatalf("expected %d bytes in total, got %d", total, received)
+	}
+}
+
+func TestMuxOverrunResyncs(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	busy, _ := svc.Sessions.Create("busy")
+	conn, _, err := dialWS(t, srv, "/api/mux")
+	if err != nil {
+		t.Fatalf("WS dial: %v", err)
+	}
+	defer conn.Close()
+	conn.WriteJSON(muxMsg{Type: "attach", Channel: "busy", Session: busy.ID})
+	time.Sleep(50 * time.Millisecond)
+
+	// Far more output than the channel may queue while the client sends
+	// no acks: numbered lines, then a marker.
+	const window = 256 << 10
+	var out bytes.Buffer
+	for i := 0; out.Len() < 1500<<10; i++ {
+		fmt.Fprintf(&out, "line %07d %s\n", i, bytes.Repeat([]byte("x"), 100))
+	}
+	out.WriteString("END\n")
+	go busy.WriteToPTY(out.Bytes())
+
+	received := 0
+	for received < window {
+		msg := readMux(t, conn)
+		data, _ := base64.StdEncoding.DecodeString(msg.Data)
+		received += len(data)
+	}
+	if msg := readMux(t, conn); msg.Type != "reset" {
+		t.Fatalf("expected a reset once the channel fell behind, got %+v", msg)
+	}
+
+	// After the reset the channel gets the end of the scrollback and the
+	// output that follows, without falling behind again.
+	conn.WriteJSON(muxMsg{Type: "ack", Channel: "busy", Bytes: window})
+	var resent bytes.Buffer
+	for !bytes.HasSuffix(resent.Bytes(), []byte("END\n")) {
+		msg := readMux(t, conn)
+		if msg.Type != "output" {
+			t.Fatalf("expected only output after the reset, got %+v", msg)
+		}
+		data, _ := base64.StdEncoding.DecodeString(msg.Data)
+		resent.Write(data)
+		conn.WriteJSON(muxMsg{Type: "ack", Channel: "busy", Bytes: len(data)})
+	}
+	if !bytes.HasPrefix(resent.Bytes(), []byte("line ")) {
+		t.Fatalf("expected the resync to start at a line, got %q", resent.Bytes()[:20])
+	}
+	if resent.Len() >= out.Len()-window {
+		t.Fatalf("expected only the end of the output to be resent, got %d bytes", resent.Len())
+	}
+}
+
+func TestMuxTransferWaitsForAcks(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	s, _ := svc.Sessions.Create("sz")
+	conn, _, err := dialWS(t, srv, "/api/mux")
+	if err != nil {
+		t.Fatalf("WS dial: %v", err)
+	}
+	defer conn.Close()
+	conn.WriteJSON(muxMsg{Type: "attach", Channel: "sz", Session: s.ID})
+	time.Sleep(50 * time.Millisecond)
+
+	// A ZMODEM download of far more than the channel may queue while the
+	// client sends no acks. The mock backend echoes it back as output.
+	const window = 256 << 10
+	var sent bytes.Buffer
+	sent.WriteString("**\x18B00000000000000\r\x8a\x11")
+	for i := 0; sent.Len() < 1500<<10; i++ {
+		fmt.Fprintf(&sent, "block %07d %s\n", i, bytes.Repeat([]byte("z"), 100))
+	}
+	go s.WriteToPTY(sent.Bytes())
+
+	if msg := readMux(t, conn); msg.Type != "transfer" || msg.Protocol != "zmodem" {
+		t.Fatalf("expected a transfer to start, got %+v", msg)
+	}
+	var got bytes.Buffer
+	for got.Len() < window {
+		msg := readMux(t, conn)
+		if msg.Type != "transfer-data" {
+			t.Fatalf("expected transfer data, got %+v", msg)
+		}
+		data, _ := base64.StdEncoding.DecodeString(msg.Data)
+		got.Write(data)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Nothing was dropped while the client was behind.
+	conn.WriteJSON(muxMsg{Type: "ack", Channel: "sz", Bytes: got.Len()})
+	for got.Len() < sent.Len() {
+		msg := readMux(t, conn)
+		if msg.Type != "transfer-data" {
+			t.Fatalf("expected only transfer data, got %+v", msg)
+		}
+		data, _ := base64.StdEncoding.DecodeString(msg.Data)
+		got.Write(data)
+		conn.WriteJSON(muxMsg{Type: "ack", Channel: "sz", Bytes: len(data)})
+	}
+	if !bytes.Equal(got.Bytes(), sent.Bytes()) {
+		t.Fatalf("transfer data differs: got %d bytes, sent %d", got.Len(), sent.Len())
+	}
+}
+
+func TestMuxTransferReplyBeforeAck(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	s, _ := svc.Sessions.Create("sz")
+	conn, _, err := dialWS(t, srv, "/api/mux")
+	if err != nil {
+		t.Fatalf("WS dial: %v", err)
+	}
+	defer conn.Close()
+	conn.WriteJSON(muxMsg{Type: "attach", Channel: "sz", Session: s.ID})
+	time.Sleep(50 * time.Millisecond)
+
+	// Written a read's worth at a time, the mock backend hands the session
+	// whole 4 KiB chunks. 590 of them fill the channel's window and queue
+	// and leave the session waiting to send one, while all of them still
+	// fit in the mock's pipe, so that the reply below can be written.
+	const window, chunk = 256 << 10, 4096
+	var sent bytes.Buffer
+	sent.WriteString("**\x18B00000000000000\r\x8a\x11")
+	for i := 0; sent.Len() < 590*chunk; i++ {
+		fmt.Fprintf(&sent, "block %07d %s\n", i, bytes.Repeat([]byte("z"), 100))
+	}
+	sent.Truncate(590 * chunk)
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for p := sent.Bytes(); len(p) > 0; p = p[chunk:] {
+			s.WriteToPTY(p[:chunk])
+		}
+	}()
+
+	if msg := readMux(t, conn); msg.Type != "transfer" {
+		t.Fatalf("expected a transfer to start, got %+v", msg)
+	}
+	var got bytes.Buffer
+	for got.Len() < window {
+		msg := readMux(t, conn)
+		data, _ := base64.StdEncoding.DecodeString(msg.Data)
+		got.Write(data)
+	}
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("transfer data not read from the session")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// The client answers before acking, as a protocol handler would.
+	reply := []byte("ZRINIT")
+	conn.WriteJSON(muxMsg{Type: "transfer-data", Channel: "sz", Data: base64.StdEncoding.EncodeToString(reply)})
+	conn.WriteJSON(muxMsg{Type: "ack", Channel: "sz", Bytes: got.Len()})
+	want := append(sent.Bytes(), reply...)
+	for got.Len() < len(want) {
+		msg := readMux(t, conn)
+		if msg.Type != "transfer-data" {
+			t.Fatalf("expected only transfer data, got %+v", msg)
+		}
+		data, _ := base64.StdEncoding.DecodeString(msg.Data)
+		got.Write(data)
+		conn.WriteJSON(muxMsg{Type: "ack", Channel: "sz", Bytes: len(data)})
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Fatalf("transfer data differs: got %d bytes, want %d", got.Len(), len(want))
+	}
+}
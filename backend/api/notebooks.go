@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+
+	"web-terminal/notebook"
+)
+
+// listNotebooks lists notebooks, or searches documents when q is given.
+func (h *handler) listNotebooks(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	if qs.Has("q") {
+		results, err := h.notebookManager.Search(qs.Get("q"), qs.Get("notebook"))
+		if err != nil {
+			writeNotebookError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string][]notebook.SearchResult{"results": results})
+		return
+	}
+
+	list, err := h.notebookManager.Notebooks()
+	if err != nil {
+		writeNotebookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]notebook.Notebook{"notebooks": list})
+}
+
+func (h *handler) listNotebookDocs(w http.ResponseWriter, r *http.Request) {
+	docs, err := h.notebookManager.Docs(urlParam(r, "notebook"))
+	if err != nil {
+		writeNotebookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]notebook.Doc{"docs": docs})
+}
+
+func (h *handler) deleteNotebook(w http.ResponseWriter, r *http.Request) {
+	if err := h.notebookManager.DeleteNotebook(urlParam(r, "notebook")); err != nil {
+		writeNotebookError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getNotebookDoc returns a document as Markdown, or with ?format=html as an
+// HTML fragment.
+func (h *handler) getNotebookDoc(w http.ResponseWriter, r *http.Request) {
+	content, doc, err := h.notebookManager.Read(urlParam(r, "notebook"), urlParam(r, "doc"))
+	if err != nil {
+		writeNotebookError(w, err)
+		return
+	}
+	w.Header().Set("ETag", doc.ETag)
+	w.Header().Set("Last-Modified", doc.UpdatedAt.UTC().Format(http.TimeFormat))
+
+	switch r.URL.Query().Get("format") {
+	case "", "markdown":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		_, _ = w.Write(content)
+	case "html":
+		html, err := notebook.RenderHTML(content)
+		if err != nil {
+			http.Error(w, "failed to render document", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// The fragment is meant to be embedded; opened directly it may not run scripts.
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src * data:; style-src 'unsafe-inline'")
+		_, _ = w.Write(html)
+	default:
+		http.Error(w, "format must be markdown or html", http.StatusBadRequest)
+	}
+}
+
+// putNotebookDoc stores the raw request body as the document's Markdown.
+func (h *handler) putNotebookDoc(w http.ResponseWriter, r *http.Request) {
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, notebook.MaxDocBytes))
+	if err != nil {
+		writeNotebookError(w, notebook.ErrTooLarge)
+		return
+	}
+	doc, err := h.notebookManager.Write(urlParam(r, "notebook"), urlParam(r, "doc"), content, r.Header.Get("If-Match"))
+	if err != nil {
+		writeNotebookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", doc.ETag)
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+func (h *handler) deleteNotebookDoc(w http.ResponseWriter, r *http.Request) {
+	if err := h.notebookManager.Delete(urlParam(r, "notebook"), urlParam(r, "doc")); err != nil {
+		writeNotebookError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// urlParam returns a decoded URL parameter, for names that may contain
+// escaped characters such as spaces.
+func urlParam(r *http.Request, key string) string {
+	v := chi.URLParam(r, key)
+	if decoded, err := url.PathUnescape(v); err == nil {
+		return decoded
+	}
+	return v
+}
+
+// writeNotebookError maps notebook manager errors to HTTP responses.
+func writeNotebookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, notebook.ErrNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, notebook.ErrInvalidName):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, notebook.ErrTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, notebook.ErrVersionConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "notebook storage error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,81 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"web-terminal/notebook"
+)
+
+func TestNotebookDocLifecycle(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+	url := srv.URL + "/api/notebooks/ops/k8s%20tips"
+
+	req, _ := http.NewRequest(http.MethodPut, url, strings.NewReader("# Pods\n`kubectl get pods`\n"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc notebook.Doc
+	json.NewDecoder(resp.Body).Decode(&doc)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || doc.Name != "k8s tips" || doc.ETag == "" {
+		t.Fatalf("unexpected PUT response: %d %+v", resp.StatusCode, doc)
+	}
+
+	resp, _ = http.Get(url + "?format=html")
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") || !strings.Contains(string(body), "<code>kubectl get pods</code>") {
+		t.Fatalf("unexpected HTML: %s %s", resp.Header.Get("Content-Type"), body)
+	}
+
+	// A stale If-Match is rejected.
+	req, _ = http.NewRequest(http.MethodPut, url, strings.NewReader("changed"))
+	req.Header.Set("If-Match", `"stale"`)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", resp.StatusCode)
+	}
+
+	resp, _ = http.Get(srv.URL + "/api/notebooks?q=kubectl")
+	var out struct {
+		Results []notebook.SearchResult `json:"results"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if len(out.Results) != 1 || out.Results[0].Doc != "k8s tips" {
+		t.Fatalf("unexpected search results: %+v", out.Results)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, url, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+	resp, _ = http.Get(url)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", resp.StatusCode)
+	}
+}
+
+func TestNotebookInvalidName(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/notebooks/ops/..%2Fescape", strings.NewReader("x"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"web-terminal/notes"
+	"web-terminal/session"
+)
+
+// maxNotesBodyBytes bounds PUT /api/sessions/{id}/notes bodies.
+const maxNotesBodyBytes = 16 << 20
+
+// retireNotes drops or detaches a session's notes when the session exits.
+func retireNotes(sessions *session.Manager, nm *notes.Manager) {
+	sessions.OnExit(func(s *session.Session) {
+		if err := nm.SessionEnded(s.ID); err != nil {
+			log.Printf("session %s notes: %v", s.ID, err)
+		}
+	})
+}
+
+func (h *handler) getNotes(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.manager.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	n, err := h.notesManager.Get(s.ID, s.Name)
+	if err != nil {
+		http.Error(w, "failed to load notes", http.StatusInternalServerError)
+		return
+	}
+	writeVersionedJSON(w, http.StatusOK, n.Revision, n)
+}
+
+func (h *handler) putNotes(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.manager.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	ifRevision, ok := parseIfMatch(w, r)
+	if !ok {
+		return
+	}
+	var n notes.Notes
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxNotesBodyBytes)).Decode(&n); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	saved, err := h.notesManager.Put(s.ID, s.Name, n, ifRevision)
+	switch {
+	case err == nil:
+		writeVersionedJSON(w, http.StatusOK, saved.Revision, saved)
+	case errors.Is(err, notes.ErrRevisionConflict):
+		current, _ := h.notesManager.Get(s.ID, s.Name)
+		writeVersionedJSON(w, http.StatusConflict, current.Revision, struct {
+			Error string      `json:"error"`
+			Notes notes.Notes `json:"notes"`
+		}{"notes were modified by another client", current})
+	case errors.Is(err, notes.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "failed to save notes", http.StatusInternalServerError)
+	}
+}
+
+func (h *handler) deleteNotes(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.manager.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	if err := h.notesManager.Delete(s.ID); err != nil {
+		http.Error(w, "failed to delete notes", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
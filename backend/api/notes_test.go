@@ -0,0 +1,103 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"web-terminal/api"
+	"web-terminal/notes"
+)
+
+func putNotes(t *testing.T, url, ifMatch, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT notes: %v", err)
+	}
+	return resp
+}
+
+func TestSessionNotesRoundTrip(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+	s, _ := svc.Sessions.Create("dev")
+	url := srv.URL + "/api/sessions/" + s.ID + "/notes"
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `"0"` {
+		t.Fatalf("expected empty notes at revision 0, got %d %s", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+
+	body := `{"tabs":[{"id":"t1","name":"first","content":"hello"}],"activeTabId":"t1"}`
+	resp = putNotes(t, url, `"0"`, body)
+	var n notes.Notes
+	json.NewDecoder(resp.Body).Decode(&n)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || n.Revision != 1 || n.Tabs[0].Content != "hello" {
+		t.Fatalf("unexpected PUT response: %d %+v", resp.StatusCode, n)
+	}
+
+	// A second client still holding revision 0 gets 409 with the current notes.
+	resp = putNotes(t, url, `"0"`, `{"tabs":[]}`)
+	var conflict struct {
+		Notes notes.Notes `json:"notes"`
+	}
+	json.NewDecoder(resp.Body).Decode(&conflict)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict || conflict.Notes.Revision != 1 {
+		t.Fatalf("expected 409 with current notes, got %d %+v", resp.StatusCode, conflict)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, url, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionNotesNotFound(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+	resp, _ := http.Get(srv.URL + "/api/sessions/missing/notes")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestKeptNotesFollowSessionName(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	first, _ := svc.Sessions.Create("dev")
+	resp := putNotes(t, srv.URL+"/api/sessions/"+first.ID+"/notes", "",
+		`{"tabs":[{"id":"t1","name":"todo","content":"ship it"}],"keep":true}`)
+	resp.Body.Close()
+	svc.Sessions.Kill(first.ID)
+
+	second, _ := svc.Sessions.Create("dev")
+	resp, err := http.Get(srv.URL + "/api/sessions/" + second.ID + "/notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n notes.Notes
+	json.NewDecoder(resp.Body).Decode(&n)
+	resp.Body.Close()
+	if n.SessionID != second.ID || len(n.Tabs) != 1 || n.Tabs[0].Content != "ship it" {
+		t.Fatalf("kept notes not reattached: %+v", n)
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"web-terminal/session"
+)
+
+// publishNotifications announces session notifications.
+func publishNotifications(sm *session.Manager, pub publisher) {
+	sm.OnNotify(func(n session.Notification) {
+		pub.publish(eventNotification, n)
+	})
+}
+
+func (h *handler) getNotifySettings(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.manager.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.NotifySettings())
+}
+
+func (h *handler) putNotifySettings(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.manager.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	var ns session.NotifySettings
+	if err := json.NewDecoder(r.Body).Decode(&ns); err != nil || ns.MinCommandSeconds < 0 {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	s.SetNotifySettings(ns)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ns)
+}
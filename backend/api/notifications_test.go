@@ -0,0 +1,111 @@
+package api_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"web-terminal/api"
+	"web-terminal/session"
+	"web-terminal/webhook"
+)
+
+func TestNotificationsStreamedAndPosted(t *testing.T) {
+	posted := make(chan session.Notification, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p struct{ Data session.Notification }
+		json.NewDecoder(r.Body).Decode(&p)
+		posted <- p.Data
+	}))
+	defer hook.Close()
+
+	svc := newTestServices(t)
+	if _, err := svc.Webhooks.Create(webhook.Webhook{Name: "notify", URL: hook.URL, Events: []string{"notification"}}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	s, _ := svc.Sessions.Create("build")
+	s.WriteToPTY([]byte("\x1b]9;done\x07"))
+
+	lines := bufio.NewReader(resp.Body)
+	event, _ := lines.ReadString('\n')
+	if event != "event: session.created\n" {
+		t.Fatalf("unexpected event line %q", event)
+	}
+	lines.ReadString('\n')
+	lines.ReadString('\n')
+	event, _ = lines.ReadString('\n')
+	data, _ := lines.ReadString('\n')
+	if event != "event: notification\n" {
+		t.Fatalf("unexpected event line %q", event)
+	}
+	var n session.Notification
+	json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &n)
+	if n.SessionID != s.ID || n.Body != "done" {
+		t.Fatalf("unexpected notification %+v", n)
+	}
+
+	select {
+	case n := <-posted:
+		if n.SessionID != s.ID || n.Kind != session.NotifyMessage {
+			t.Fatalf("unexpected webhook notification %+v", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestNotifySettingsAPI(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+	s, _ := svc.Sessions.Create("quiet")
+	url := srv.URL + "/api/sessions/" + s.ID + "/notifications"
+
+	put := func(body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+	if resp := put(`{"muted":true,"minCommandSeconds":30}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT: expected 200, got %d", resp.StatusCode)
+	}
+	if resp := put(`{"minCommandSeconds":-1}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("PUT negative duration: expected 400, got %d", resp.StatusCode)
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ns session.NotifySettings
+	json.NewDecoder(resp.Body).Decode(&ns)
+	resp.Body.Close()
+	if !ns.Muted || ns.MinCommandSeconds != 30 {
+		t.Fatalf("settings not saved: %+v", ns)
+	}
+
+	resp, _ = http.Get(srv.URL + "/api/sessions/nonexistent/notifications")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown session, got %d", resp.StatusCode)
+	}
+}
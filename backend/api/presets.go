@@ -1,24 +1,74 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"web-terminal/preset"
+	"web-terminal/session"
 )
 
 func (h *handler) getPresets(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	if qs.Has("q") || qs.Has("tag") || qs.Has("folder") {
+		results := h.presetManager.Search(preset.Query{
+			Text:   qs.Get("q"),
+			Tags:   qs["tag"],
+			Folder: qs.Get("folder"),
+		})
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string][]preset.SearchResult{"results": results})
+		return
+	}
+
 	store := h.presetManager.Get()
+	writeVersionedJSON(w, http.StatusOK, store.Version, store)
+}
+
+// defaultRecentLimit matches the length of the recentlyUsed list.
+const defaultRecentLimit = 10
+
+func (h *handler) recentPresets(w http.ResponseWriter, r *http.Request) {
+	by := r.URL.Query().Get("by")
+	if by == "" {
+		by = preset.ByRecency
+	}
+	if by != preset.ByRecency && by != preset.ByFrequency {
+		http.Error(w, "by must be recency or frequency", http.StatusBadRequest)
+		return
+	}
+	limit := defaultRecentLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(store)
+	_ = json.NewEncoder(w).Encode(map[string][]preset.Preset{"presets": h.presetManager.Recent(by, limit)})
 }
 
 func (h *handler) putPresets(w http.ResponseWriter, r *http.Request) {
+	ifVersion, ok := parseIfMatch(w, r)
+	if !ok {
+		return
+	}
 	var store preset.PresetStore
-	if err := json.NewDecoder(r.Body).Decode(&store); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+	if !decodePresetBody(w, r, &store) {
 		return
 	}
 
@@ -35,14 +85,148 @@ func (h *handler) putPresets(w http.ResponseWriter, r *http.Request) {
 	}
 	store.RecentlyUsed = filtered
 
-	if err := h.presetManager.Save(store); err != nil {
-		http.Error(w, "failed to save presets", http.StatusInternalServerError)
+	updated, err := h.presetManager.SaveIf(store, ifVersion)
+	if err != nil {
+		h.writePresetError(w, err)
+		return
+	}
+	writeVersionedJSON(w, http.StatusOK, updated.Version, updated)
+}
+
+func (h *handler) createPreset(w http.ResponseWriter, r *http.Request) {
+	ifVersion, ok := parseIfMatch(w, r)
+	if !ok {
+		return
+	}
+	var p preset.Preset
+	if !decodePresetBody(w, r, &p) {
 		return
 	}
 
-	updated := h.presetManager.Get()
+	created, store, err := h.presetManager.Add(p, ifVersion)
+	if err != nil {
+		h.writePresetError(w, err)
+		return
+	}
+	writeVersionedJSON(w, http.StatusCreated, store.Version, created)
+}
+
+func (h *handler) updatePreset(w http.ResponseWriter, r *http.Request) {
+	ifVersion, ok := parseIfMatch(w, r)
+	if !ok {
+		return
+	}
+	var p preset.Preset
+	if !decodePresetBody(w, r, &p) {
+		return
+	}
+	p.ID = chi.URLParam(r, "id")
+
+	store, err := h.presetManager.Update(p, ifVersion)
+	if err != nil {
+		h.writePresetError(w, err)
+		return
+	}
+	// Answer with the preset as stored, with its timestamps and use count
+	// rather than whatever the client sent for them.
+	i := slices.IndexFunc(store.Presets, func(q preset.Preset) bool { return q.ID == p.ID })
+	writeVersionedJSON(w, http.StatusOK, store.Version, store.Presets[i])
+}
+
+func (h *handler) deletePreset(w http.ResponseWriter, r *http.Request) {
+	ifVersion, ok := parseIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	store, err := h.presetManager.Delete(chi.URLParam(r, "id"), ifVersion)
+	if err != nil {
+		h.writePresetError(w, err)
+		return
+	}
+	w.Header().Set("ETag", versionETag(store.Version))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// maxPresetBodyBytes bounds request bodies for the preset endpoints.
+const maxPresetBodyBytes = 8 << 20
+
+// decodePresetBody decodes a JSON request body into v, rejecting malformed
+// JSON and unknown fields with 400, and bodies over maxPresetBodyBytes with
+// 413. It writes the error response itself and returns false on failure.
+func decodePresetBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPresetBodyBytes))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+	default:
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+	}
+	return false
+}
+
+// versionETag formats a store version or notes revision as a strong ETag.
+func versionETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// parseIfMatch reads the expected version from the If-Match header. A missing
+// header or "*" means the write is unconditional and returns -1
+// (preset.AnyVersion, notes.AnyRevision): the check is opt-in, so scripts and
+// clients that never read an ETag can still write, and only a client that
+// sends the version it last saw is protected from overwriting a newer one. On
+// a malformed header it writes 400 and returns ok=false.
+func parseIfMatch(w http.ResponseWriter, r *http.Request) (version int64, ok bool) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" || v == "*" {
+		return preset.AnyVersion, true
+	}
+	unquoted, err := strconv.Unquote(strings.TrimPrefix(v, "W/"))
+	if err == nil {
+		version, err = strconv.ParseInt(unquoted, 10, 64)
+	}
+	if err != nil || version < 0 {
+		http.Error(w, "invalid If-Match header", http.StatusBadRequest)
+		return 0, false
+	}
+	return version, true
+}
+
+// writeVersionedJSON writes v as JSON with version as the ETag.
+func writeVersionedJSON(w http.ResponseWriter, status int, version int64, v any) {
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(updated)
+	w.Header().Set("ETag", versionETag(version))
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writePresetError maps preset manager errors to HTTP responses. Invalid input
+// returns 422 with field errors; a version conflict returns 409 with the
+// current store so the client can merge.
+func (h *handler) writePresetError(w http.ResponseWriter, err error) {
+	var invalid *preset.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeFieldErrors(w, invalid.Errors)
+	case errors.Is(err, preset.ErrVersionConflict):
+		current := h.presetManager.Get()
+		writeVersionedJSON(w, http.StatusConflict, current.Version, struct {
+			Error string             `json:"error"`
+			Store preset.PresetStore `json:"store"`
+		}{"presets were modified by another client", current})
+	case errors.Is(err, preset.ErrNotFound):
+		http.Error(w, "preset not found", http.StatusNotFound)
+	case errors.Is(err, preset.ErrDuplicateID):
+		http.Error(w, "preset id already exists", http.StatusConflict)
+	default:
+		http.Error(w, "failed to save presets", http.StatusInternalServerError)
+	}
 }
 
 func (h *handler) usePreset(w http.ResponseWriter, r *http.Request) {
@@ -57,3 +241,300 @@ func (h *handler) usePreset(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string][]string{"recentlyUsed": store.RecentlyUsed})
 }
+
+func (h *handler) renderPreset(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Variables map[string]string `json:"variables"`
+		SessionID string            `json:"sessionId"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	p, err := h.presetManager.Find(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "preset not found", http.StatusNotFound)
+		return
+	}
+	var s *session.Session
+	if req.SessionID != "" {
+		var ok bool
+		if s, ok = h.manager.Get(req.SessionID); !ok {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	content, errs := preset.Render(p, req.Variables, presetBuiltins(r.Context(), s))
+	if errs != nil {
+		writeFieldErrors(w, errs)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"content": content})
+}
+
+// writeFieldErrors responds 422 with structured validation errors.
+func writeFieldErrors(w http.ResponseWriter, errs []preset.FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	_ = json.NewEncoder(w).Encode(map[string][]preset.FieldError{"errors": errs})
+}
+
+// gitTimeout bounds the git lookup behind the {{branch}} placeholder.
+const gitTimeout = 2 * time.Second
+
+// presetBuiltins resolves the built-in placeholders: date, time, and — when a
+// session is given — session.id, session.name, cwd and branch.
+func presetBuiltins(ctx context.Context, s *session.Session) preset.Builtins {
+	now := time.Now()
+	return func(name string) (string, bool) {
+		switch name {
+		case "date":
+			return now.Format(time.DateOnly), true
+		case "time":
+			return now.Format("15:04"), true
+		}
+		if s == nil {
+			return "", false
+		}
+		switch name {
+		case "session.id":
+			return s.ID, true
+		case "session.name":
+			return s.Name, true
+		case "cwd":
+			cwd, err := s.Cwd()
+			return cwd, err == nil
+		case "branch":
+			cwd, err := s.Cwd()
+			if err != nil {
+				return "", false
+			}
+			ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+			defer cancel()
+			out, err := exec.CommandContext(ctx, "git", "-C", cwd, "rev-parse", "--abbrev-ref", "HEAD").Output()
+			if err != nil {
+				return "", false
+			}
+			return strings.TrimSpace(string(out)), true
+		}
+		return "", false
+	}
+}
+
+// maxLineDelay caps the per-line delay accepted by sendPreset so a request
+// cannot hold its connection open indefinitely.
+const maxLineDelay = 10 * time.Second
+
+func (h *handler) sendPreset(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Variables      map[string]string `json:"variables"`
+		BracketedPaste bool              `json:"bracketedPaste"`
+		Enter          bool              `json:"enter"`
+		LineDelayMs    int               `json:"lineDelayMs"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	lineDelay := time.Duration(req.LineDelayMs) * time.Millisecond
+	if lineDelay < 0 || lineDelay > maxLineDelay {
+		http.Error(w, "lineDelayMs out of range", http.StatusBadRequest)
+		return
+	}
+
+	s, ok := h.manager.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	p, err := h.presetManager.Find(chi.URLParam(r, "presetId"))
+	if err != nil {
+		http.Error(w, "preset not found", http.StatusNotFound)
+		return
+	}
+
+	content, errs := preset.Render(p, req.Variables, presetBuiltins(r.Context(), s))
+	if errs != nil {
+		writeFieldErrors(w, errs)
+		return
+	}
+	err = s.Send(r.Context(), content, session.SendOptions{
+		BracketedPaste: req.BracketedPaste,
+		Enter:          req.Enter,
+		LineDelay:      lineDelay,
+	})
+	if err != nil {
+		http.Error(w, "failed to write to session", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.presetManager.MarkUsed(p.ID); err != nil {
+		http.Error(w, "failed to update recently used", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// exportContentTypes maps export formats to their response Content-Type.
+var exportContentTypes = map[string]string{
+	preset.FormatJSON:     "application/json",
+	preset.FormatYAML:     "application/yaml",
+	preset.FormatMarkdown: "text/markdown; charset=utf-8",
+}
+
+var exportExtensions = map[string]string{
+	preset.FormatJSON:     "json",
+	preset.FormatYAML:     "yaml",
+	preset.FormatMarkdown: "md",
+}
+
+func (h *handler) exportPresets(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	format := qs.Get("format")
+	if format == "" {
+		format = preset.FormatJSON
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		http.Error(w, "format must be json, yaml or markdown", http.StatusBadRequest)
+		return
+	}
+
+	presets := h.presetManager.Get().Presets
+	if qs.Has("tag") || qs.Has("folder") {
+		presets = presets[:0:0]
+		for _, res := range h.presetManager.Search(preset.Query{Tags: qs["tag"], Folder: qs.Get("folder")}) {
+			presets = append(presets, res.Preset)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := preset.EncodePack(&buf, preset.NewPack(presets), format); err != nil {
+		http.Error(w, "failed to export presets", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="presets.`+exportExtensions[format]+`"`)
+	_, _ = w.Write(buf.Bytes())
+}
+
+func (h *handler) importPresets(w http.ResponseWriter, r *http.Request) {
+	ifVersion, ok := parseIfMatch(w, r)
+	if !ok {
+		return
+	}
+	qs := r.URL.Query()
+	strategy := qs.Get("strategy")
+	if strategy == "" {
+		strategy = preset.StrategySkip
+	}
+	dryRun := false
+	if v := qs.Get("dryRun"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "invalid dryRun", http.StatusBadRequest)
+			return
+		}
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPresetBodyBytes))
+	if err != nil {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	format := qs.Get("format")
+	if format == "" {
+		format = sniffPresetFormat(data)
+	}
+	pack, err := preset.DecodePack(data, format)
+	if errors.Is(err, preset.ErrUnknownFormat) {
+		http.Error(w, "format must be json, yaml or markdown", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "invalid "+format+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.presetManager.Import(pack, strategy, dryRun, ifVersion)
+	if errors.Is(err, preset.ErrUnknownStrategy) {
+		http.Error(w, "strategy must be skip, overwrite or duplicate", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.writePresetError(w, err)
+		return
+	}
+	writeVersionedJSON(w, http.StatusOK, h.presetManager.Get().Version, report)
+}
+
+// sniffPresetFormat guesses the format of an uploaded pack when the client
+// did not say: JSON starts with a brace, Markdown with a heading, and
+// anything else is treated as YAML.
+func sniffPresetFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return preset.FormatJSON
+	case bytes.HasPrefix(trimmed, []byte("#")):
+		return preset.FormatMarkdown
+	}
+	return preset.FormatYAML
+}
+
+func (h *handler) presetHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]preset.Revision{"revisions": h.presetManager.History()})
+}
+
+func (h *handler) presetRevision(w http.ResponseWriter, r *http.Request) {
+	rev, ok := parseRev(w, r)
+	if !ok {
+		return
+	}
+	revision, err := h.presetManager.Revision(rev)
+	if err != nil {
+		http.Error(w, "revision not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(revision)
+}
+
+func (h *handler) restorePresetRevision(w http.ResponseWriter, r *http.Request) {
+	ifVersion, ok := parseIfMatch(w, r)
+	if !ok {
+		return
+	}
+	rev, ok := parseRev(w, r)
+	if !ok {
+		return
+	}
+	store, err := h.presetManager.Restore(rev, ifVersion)
+	if errors.Is(err, preset.ErrNoRevision) {
+		http.Error(w, "revision not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.writePresetError(w, err)
+		return
+	}
+	writeVersionedJSON(w, http.StatusOK, store.Version, store)
+}
+
+// parseRev reads the {rev} URL parameter, writing 400 if it is not a number.
+func parseRev(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	rev, err := strconv.ParseInt(chi.URLParam(r, "rev"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid revision", http.StatusBadRequest)
+		return 0, false
+	}
+	return rev, true
+}
@@ -2,10 +2,14 @@ package api_test
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"web-terminal/api"
 	"web-terminal/preset"
 )
 
@@ -138,3 +142,476 @@ func TestUsePresetNonExistent(t *testing.T) {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
 }
+
+func TestPresetCRUD(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/presets", "application/json",
+		strings.NewReader(`{"title":"Deploy","content":"make deploy"}`))
+	if err != nil {
+		t.Fatalf("POST /api/presets: %v", err)
+	}
+	var created preset.Preset
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || created.ID == "" {
+		t.Fatalf("expected 201 with an id, got %d %+v", resp.StatusCode, created)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("expected ETag header")
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/presets/"+created.ID,
+		strings.NewReader(`{"title":"Deploy prod","content":"make deploy ENV=prod",`+
+			`"createdAt":"2000-01-01T00:00:00Z","useCount":99}`))
+	req.Header.Set("If-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT /api/presets/{id}: %v", err)
+	}
+	var updated preset.Preset
+	json.NewDecoder(resp.Body).Decode(&updated)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	// The response is the stored preset, not the request echoed back.
+	if created.CreatedAt.IsZero() || !updated.CreatedAt.Equal(created.CreatedAt) || updated.UseCount != 0 ||
+		updated.Title != "Deploy prod" {
+		t.Fatalf("expected the stored preset, created %+v, got %+v", created, updated)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, srv.URL+"/api/presets/"+created.ID, nil)
+	req.Header.Set("If-Match", resp.Header.Get("ETag"))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE /api/presets/{id}: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, srv.URL+"/api/presets/"+created.ID, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for deleted preset, got %d", resp.StatusCode)
+	}
+}
+
+func TestPresetStaleWriteConflict(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	// Two tabs load the same version.
+	getResp, _ := http.Get(srv.URL + "/api/presets")
+	getResp.Body.Close()
+	etag := getResp.Header.Get("ETag")
+
+	put := func(body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/presets", strings.NewReader(body))
+		req.Header.Set("If-Match", etag)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT /api/presets: %v", err)
+		}
+		return resp
+	}
+
+	first := put(`{"presets":[{"id":"a","title":"tab one","content":""}]}`)
+	first.Body.Close()
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("first write: expected 200, got %d", first.StatusCode)
+	}
+
+	second := put(`{"presets":[{"id":"b","title":"tab two","content":""}]}`)
+	defer second.Body.Close()
+	if second.StatusCode != http.StatusConflict {
+		t.Fatalf("stale write: expected 409, got %d", second.StatusCode)
+	}
+	var conflict struct {
+		Store preset.PresetStore `json:"store"`
+	}
+	json.NewDecoder(second.Body).Decode(&conflict)
+	if len(conflict.Store.Presets) != 1 || conflict.Store.Presets[0].ID != "a" {
+		t.Fatalf("conflict should report the current store, got %+v", conflict.Store)
+	}
+}
+
+func TestPresetWriteWithoutIfMatch(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	// Without If-Match a write is unconditional, whatever the version.
+	for i, title := range []string{"one", "two"} {
+		req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/presets",
+			strings.NewReader(`{"presets":[{"id":"a","title":"`+title+`","content":""}]}`))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("write %d: expected 200, got %d", i+1, resp.StatusCode)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/api/presets/a", nil)
+	req.Header.Set("If-Match", `"1"`)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("stale If-Match: expected 409, got %d", resp.StatusCode)
+	}
+}
+
+func TestPresetBadIfMatch(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/presets", strings.NewReader(`{"title":"x"}`))
+	req.Header.Set("If-Match", "bogus")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestRenderPreset(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	body := `{"presets":[{"id":"deploy","title":"Deploy","content":"deploy {{session.name}} to {{env}}",` +
+		`"variables":[{"name":"env","type":"enum","options":["staging","prod"],"required":true}]}]}`
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/presets", strings.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT /api/presets: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, _ = http.Post(srv.URL+"/api/sessions", "application/json", strings.NewReader(`{"name":"web"}`))
+	var s map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&s)
+	resp.Body.Close()
+
+	resp, err = http.Post(srv.URL+"/api/presets/deploy/render", "application/json",
+		strings.NewReader(`{"variables":{"env":"prod"},"sessionId":"`+s["id"].(string)+`"}`))
+	if err != nil {
+		t.Fatalf("POST .../render: %v", err)
+	}
+	var out map[string]string
+	json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || out["content"] != "deploy web to prod" {
+		t.Fatalf("expected rendered content, got %d %q", resp.StatusCode, out["content"])
+	}
+
+	resp, _ = http.Post(srv.URL+"/api/presets/deploy/render", "application/json",
+		strings.NewReader(`{"variables":{"env":"qa"}}`))
+	var verr struct {
+		Errors []preset.FieldError `json:"errors"`
+	}
+	json.NewDecoder(resp.Body).Decode(&verr)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity || len(verr.Errors) != 1 || verr.Errors[0].Field != "env" {
+		t.Fatalf("expected 422 for env only, got %d %+v", resp.StatusCode, verr.Errors)
+	}
+}
+
+func TestRenderPresetNotFound(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/presets/missing/render", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestSendPresetToSession(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	svc.Presets.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "ls", Title: "List", Content: "ls {{dir}}"}}})
+	s, err := svc.Sessions.Create("target")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	resp, err := http.Post(srv.URL+"/api/sessions/"+s.ID+"/presets/ls/send", "application/json",
+		strings.NewReader(`{"variables":{"dir":"/tmp"},"enter":true}`))
+	if err != nil {
+		t.Fatalf("POST .../send: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+
+	// The mock backend echoes PTY input into the scrollback.
+	deadline := time.Now().Add(2 * time.Second)
+	for string(s.ScrollbackSnapshot()) != "ls /tmp\r" {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected preset in scrollback, got %q", s.ScrollbackSnapshot())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ru := svc.Presets.Get().RecentlyUsed; len(ru) != 1 || ru[0] != "ls" {
+		t.Fatalf("expected preset marked used, got %v", ru)
+	}
+}
+
+func TestSendPresetNotFound(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	s, _ := svc.Sessions.Create("target")
+	for _, path := range []string{
+		"/api/sessions/nope/presets/ls/send",
+		"/api/sessions/" + s.ID + "/presets/nope/send",
+	} {
+		resp, err := http.Post(srv.URL+path, "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestSearchPresets(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	svc.Presets.Save(preset.PresetStore{Presets: []preset.Preset{
+		{ID: "1", Title: "Deploy", Content: "make deploy", Tags: []string{"ops"}},
+		{ID: "2", Title: "Review", Content: "review the deploy diff"},
+		{ID: "3", Title: "Lint", Content: "make lint", Tags: []string{"ops"}},
+	}})
+
+	resp, err := http.Get(srv.URL + "/api/presets?q=deploy&tag=ops")
+	if err != nil {
+		t.Fatalf("GET /api/presets?q=: %v", err)
+	}
+	var out struct {
+		Results []preset.SearchResult `json:"results"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if len(out.Results) != 1 || out.Results[0].ID != "1" {
+		t.Fatalf("unexpected results: %+v", out.Results)
+	}
+}
+
+func TestRecentPresetsByFrequency(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	svc.Presets.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "a"}, {ID: "b", Title: "b"}}})
+	svc.Presets.MarkUsed("a")
+	svc.Presets.MarkUsed("a")
+	svc.Presets.MarkUsed("b")
+
+	resp, err := http.Get(srv.URL + "/api/presets/recent?by=frequency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Presets []preset.Preset `json:"presets"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if len(out.Presets) != 2 || out.Presets[0].ID != "a" {
+		t.Fatalf("expected a first, got %+v", out.Presets)
+	}
+
+	resp, _ = http.Get(srv.URL + "/api/presets/recent?by=alphabet")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown ordering, got %d", resp.StatusCode)
+	}
+}
+
+func TestExportImportPresets(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	svc.Presets.Save(preset.PresetStore{Presets: []preset.Preset{
+		{ID: "1", Title: "Deploy", Content: "make deploy", Folder: "ops"},
+		{ID: "2", Title: "Review", Content: "review the diff"},
+	}})
+
+	resp, err := http.Get(srv.URL + "/api/presets/export?format=markdown&folder=ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Disposition"), "presets.md") {
+		t.Fatalf("unexpected export response: %d %v", resp.StatusCode, resp.Header)
+	}
+	if !strings.Contains(string(body), "## Deploy") || strings.Contains(string(body), "Review") {
+		t.Fatalf("unexpected export body:\n%s", body)
+	}
+
+	// Re-importing the same pack with a changed preset reports an overwrite.
+	edited := strings.Replace(string(body), "make deploy", "make deploy-all", 1)
+	resp, err = http.Post(srv.URL+"/api/presets/import?strategy=overwrite&dryRun=true", "text/markdown", strings.NewReader(edited))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report preset.ImportReport
+	json.NewDecoder(resp.Body).Decode(&report)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !report.DryRun || report.Summary["overwrite"] != 1 {
+		t.Fatalf("unexpected dry-run report: %d %+v", resp.StatusCode, report)
+	}
+	if p, _ := svc.Presets.Find("1"); p.Content != "make deploy" {
+		t.Fatalf("dry run changed preset: %+v", p)
+	}
+
+	resp, _ = http.Post(srv.URL+"/api/presets/import?strategy=overwrite", "text/markdown", strings.NewReader(edited))
+	resp.Body.Close()
+	if p, _ := svc.Presets.Find("1"); p.Content != "make deploy-all" {
+		t.Fatalf("import did not overwrite preset: %+v", p)
+	}
+
+	resp, _ = http.Post(srv.URL+"/api/presets/import?strategy=merge", "application/json", strings.NewReader(`{"presets":[]}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown strategy, got %d", resp.StatusCode)
+	}
+}
+
+func TestPresetHistoryRestore(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	svc.Presets.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "A"}}})
+	svc.Presets.Save(preset.PresetStore{})
+
+	resp, err := http.Get(srv.URL + "/api/presets/history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Revisions []preset.Revision `json:"revisions"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if len(out.Revisions) != 3 || out.Revisions[0].Summary != "removed 1" {
+		t.Fatalf("unexpected history: %+v", out.Revisions)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/presets/history/1/restore", nil)
+	req.Header.Set("If-Match", `"2"`)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var store preset.PresetStore
+	json.NewDecoder(resp.Body).Decode(&store)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(store.Presets) != 1 || resp.Header.Get("ETag") != `"3"` {
+		t.Fatalf("unexpected restore response: %d %+v", resp.StatusCode, store)
+	}
+
+	resp, _ = http.Post(srv.URL+"/api/presets/history/99/restore", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown revision, got %d", resp.StatusCode)
+	}
+}
+
+func TestPresetChangesStreamedAsEvents(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	svc.Presets.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "a"}}})
+
+	buf := make([]byte, 256)
+	n, _ := resp.Body.Read(buf)
+	want := "event: presets.changed\ndata: {\"version\":1,\"source\":\"api\"}\n\n"
+	if string(buf[:n]) != want {
+		t.Fatalf("got %q, want %q", buf[:n], want)
+	}
+}
+
+func TestPutPresetsValidation(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	put := func(body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/presets", strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := put(`{"presets":[{"id":"a","title":"A"},{"id":"a","title":""}]}`)
+	var out struct {
+		Errors []preset.FieldError `json:"errors"`
+	}
+	json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity || len(out.Errors) != 2 ||
+		out.Errors[0].Field != "presets[1].title" || out.Errors[1].Field != "presets[1].id" {
+		t.Fatalf("unexpected response: %d %+v", resp.StatusCode, out.Errors)
+	}
+
+	resp = put(`{"presets":[{"id":"a","title":"A","colour":"red"}]}`)
+	msg, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(msg), `"colour"`) {
+		t.Fatalf("expected 400 naming the unknown field, got %d %s", resp.StatusCode, msg)
+	}
+
+	resp = put(`{"presets":[{"title":"A","content":"` + strings.Repeat("x", 9<<20) + `"}]}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", resp.StatusCode)
+	}
+
+	resp = put(`{"presets":[{"title":"No ID"}]}`)
+	var store preset.PresetStore
+	json.NewDecoder(resp.Body).Decode(&store)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || store.Presets[0].ID == "" {
+		t.Fatalf("expected generated ID, got %d %+v", resp.StatusCode, store)
+	}
+}
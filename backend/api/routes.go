@@ -7,29 +7,175 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 
+	"web-terminal/audit"
+	"web-terminal/events"
+	"web-terminal/files"
+	"web-terminal/layout"
+	"web-terminal/notebook"
+	"web-terminal/notes"
 	"web-terminal/preset"
 	"web-terminal/session"
+	"web-terminal/sshkeys"
+	"web-terminal/template"
+	"web-terminal/trigger"
+	"web-terminal/webhook"
+	"web-terminal/workspace"
 )
 
-func RegisterRoutes(manager *session.Manager, pm *preset.Manager, staticFS fs.FS) http.Handler {
+// Services bundles the stores and managers the HTTP handlers operate on.
+type Services struct {
+	Sessions   *session.Manager
+	Presets    *preset.Manager
+	Templates  *template.Manager
+	Workspaces *workspace.Manager
+	Layouts    *layout.Manager
+	Triggers   *trigger.Manager
+	Webhooks   *webhook.Manager
+	Notes      *notes.Manager
+	Notebooks  *notebook.Manager
+	Files      *files.Browser
+	KnownHosts *sshkeys.KnownHosts
+	Keys       *sshkeys.KeyStore
+	Events     *events.Bus
+	Audit      *audit.Logger
+	// AuditInput records every line of input to sessions in the audit log.
+	AuditInput bool
+}
+
+func RegisterRoutes(svc Services, staticFS fs.FS) http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	r.Use(auditRequests(svc.Audit))
+
+	h := &handler{
+		manager:          svc.Sessions,
+		presetManager:    svc.Presets,
+		templateManager:  svc.Templates,
+		workspaceManager: svc.Workspaces,
+		layoutManager:    svc.Layouts,
+		triggerManager:   svc.Triggers,
+		webhookManager:   svc.Webhooks,
+		notesManager:     svc.Notes,
+		notebookManager:  svc.Notebooks,
+		files:            svc.Files,
+		knownHosts:       svc.KnownHosts,
+		keys:             svc.Keys,
+		events:           svc.Events,
+		publisher:        publisher{bus: svc.Events, webhooks: svc.Webhooks},
+		audit:            svc.Audit,
+	}
+	publishPresetChanges(svc.Presets, h.publisher)
+	publishLayoutChanges(svc.Layouts, h.publisher)
+	publishNotifications(svc.Sessions, h.publisher)
+	publishTriggerMatches(svc.Triggers, h.publisher)
+	publishSessionChanges(svc.Sessions, h.publisher)
+	auditSessions(svc.Sessions, svc.Audit, svc.AuditInput)
+	auditPresets(svc.Presets, svc.Audit)
+	retireNotes(svc.Sessions, svc.Notes)
 
-	h := &handler{manager: manager, presetManager: pm}
+	// Server-Sent Events
+	r.Get("/api/events", h.streamEvents)
+
+	// Audit log
+	r.Get("/api/audit", h.queryAudit)
 
 	// REST API
 	r.Get("/api/sessions", h.listSessions)
 	r.Post("/api/sessions", h.createSession)
 	r.Delete("/api/sessions/{id}", h.killSession)
+	r.Get("/api/sessions/{id}/commands", h.sessionCommands)
+	r.Get("/api/sessions/{id}/notifications", h.getNotifySettings)
+	r.Put("/api/sessions/{id}/notifications", h.putNotifySettings)
+	r.Get("/api/shell-integration/{shell}", h.shellIntegration)
+	r.Post("/api/sessions/{id}/presets/{presetId}/send", h.sendPreset)
+	r.Get("/api/sessions/{id}/notes", h.getNotes)
+	r.Put("/api/sessions/{id}/notes", h.putNotes)
+	r.Delete("/api/sessions/{id}/notes", h.deleteNotes)
+	r.Post("/api/sessions/{id}/agent-forwarding", h.setAgentForwarding)
+
+	// Session file browser, relative to the shell's working directory
+	r.Get("/api/sessions/{id}/files", h.listFiles)
+	r.Delete("/api/sessions/{id}/files", h.deleteFile)
+	r.Get("/api/sessions/{id}/files/stat", h.statFile)
+	r.Get("/api/sessions/{id}/files/content", h.readFile)
+	r.Get("/api/sessions/{id}/files/download", h.downloadFile)
+	r.Post("/api/sessions/{id}/files/upload", h.uploadFiles)
+	r.Post("/api/sessions/{id}/files/mkdir", h.makeDir)
+	r.Post("/api/sessions/{id}/files/rename", h.renameFile)
+
+	// Text file editing by absolute path
+	r.Get("/api/files/content", h.getFileText)
+	r.Put("/api/files/content", h.putFileText)
+
+	// SSH identities and trusted host keys
+	r.Get("/api/ssh/keys", h.listKeys)
+	r.Post("/api/ssh/keys", h.createKey)
+	r.Delete("/api/ssh/keys/{id}", h.deleteKey)
+	r.Get("/api/ssh/known_hosts", h.listKnownHosts)
+	r.Delete("/api/ssh/known_hosts/{host}", h.deleteKnownHost)
 
 	// WebSocket
 	r.Get("/api/sessions/{id}/ws", h.handleWS)
+	r.Get("/api/mux", h.muxWS)
 
 	// Presets API
 	r.Get("/api/presets", h.getPresets)
+	r.Get("/api/presets/recent", h.recentPresets)
+	r.Get("/api/presets/export", h.exportPresets)
+	r.Post("/api/presets/import", h.importPresets)
+	r.Get("/api/presets/history", h.presetHistory)
+	r.Get("/api/presets/history/{rev}", h.presetRevision)
+	r.Post("/api/presets/history/{rev}/restore", h.restorePresetRevision)
 	r.Put("/api/presets", h.putPresets)
+	r.Post("/api/presets", h.createPreset)
+	r.Put("/api/presets/{id}", h.updatePreset)
+	r.Delete("/api/presets/{id}", h.deletePreset)
 	r.Post("/api/presets/{id}/use", h.usePreset)
+	r.Post("/api/presets/{id}/render", h.renderPreset)
+
+	// Session templates API
+	r.Get("/api/templates", h.getTemplates)
+	r.Put("/api/templates", h.putTemplates)
+
+	// Workspaces API
+	r.Get("/api/workspaces", h.listWorkspaces)
+	r.Put("/api/workspaces", h.putWorkspaces)
+	r.Get("/api/workspaces/{id}", h.getWorkspace)
+	r.Post("/api/workspaces/{id}/start", h.startWorkspace)
+	r.Post("/api/workspaces/{id}/stop", h.stopWorkspace)
+
+	// Layouts API
+	r.Get("/api/layouts", h.listLayouts)
+	r.Post("/api/layouts", h.createLayout)
+	r.Get("/api/layouts/{id}", h.getLayout)
+	r.Put("/api/layouts/{id}", h.putLayout)
+	r.Delete("/api/layouts/{id}", h.deleteLayout)
+	r.Get("/api/layouts/{id}/ws", h.layoutWS)
+
+	// Trigger rules API
+	r.Get("/api/triggers", h.listTriggers)
+	r.Post("/api/triggers", h.createTrigger)
+	r.Get("/api/triggers/{id}", h.getTrigger)
+	r.Put("/api/triggers/{id}", h.putTrigger)
+	r.Delete("/api/triggers/{id}", h.deleteTrigger)
+
+	// Webhooks API
+	r.Get("/api/webhooks", h.listWebhooks)
+	r.Post("/api/webhooks", h.createWebhook)
+	r.Get("/api/webhooks/{id}", h.getWebhook)
+	r.Put("/api/webhooks/{id}", h.putWebhook)
+	r.Delete("/api/webhooks/{id}", h.deleteWebhook)
+	r.Get("/api/webhooks/{id}/deliveries", h.webhookDeliveries)
+	r.Post("/api/webhooks/{id}/ping", h.pingWebhook)
+
+	// Notebooks API
+	r.Get("/api/notebooks", h.listNotebooks)
+	r.Get("/api/notebooks/{notebook}", h.listNotebookDocs)
+	r.Delete("/api/notebooks/{notebook}", h.deleteNotebook)
+	r.Get("/api/notebooks/{notebook}/{doc}", h.getNotebookDoc)
+	r.Put("/api/notebooks/{notebook}/{doc}", h.putNotebookDoc)
+	r.Delete("/api/notebooks/{notebook}/{doc}", h.deleteNotebookDoc)
 
 	// Static sub-FS: strip the "static/" prefix present in the embed.FS.
 	// In dev mode staticFS is already rooted at frontend/, so Sub returns a
@@ -47,6 +193,7 @@ func RegisterRoutes(manager *session.Manager, pm *preset.Manager, staticFS fs.FS
 	// Go's built-in redirect to "./" — avoid that by reading the file manually.
 	r.Get("/", serveFile(staticSub, "index.html"))
 	r.Get("/session/{id}", serveFile(staticSub, "session.html"))
+	r.Get("/layout/{id}", serveFile(staticSub, "layout.html"))
 
 	// Static assets — use standard file server
 	fileServer := http.FileServer(http.FS(staticSub))
@@ -72,6 +219,19 @@ func serveFile(fsys fs.FS, name string) http.HandlerFunc {
 }
 
 type handler struct {
-	manager       *session.Manager
-	presetManager *preset.Manager
+	manager          *session.Manager
+	presetManager    *preset.Manager
+	templateManager  *template.Manager
+	workspaceManager *workspace.Manager
+	layoutManager    *layout.Manager
+	triggerManager   *trigger.Manager
+	webhookManager   *webhook.Manager
+	notesManager     *notes.Manager
+	notebookManager  *notebook.Manager
+	files            *files.Browser
+	knownHosts       *sshkeys.KnownHosts
+	keys             *sshkeys.KeyStore
+	events           *events.Bus
+	publisher        publisher
+	audit            *audit.Logger
 }
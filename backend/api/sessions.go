@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"web-terminal/audit"
 	"web-terminal/session"
+	"web-terminal/template"
 )
 
 func (h *handler) listSessions(w http.ResponseWriter, r *http.Request) {
@@ -16,32 +19,103 @@ func (h *handler) listSessions(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(sessions)
 }
 
+// maxTemplateInstances caps how many numbered names are tried when starting a
+// session from a template whose name pattern contains {n}.
+const maxTemplateInstances = 100
+
 func (h *handler) createSession(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Name string `json:"name"`
+		Name       string             `json:"name"`
+		TemplateID string             `json:"templateId"`
+		Backend    string             `json:"backend"`
+		Target     *session.Target    `json:"target"`
+		Namespace  *session.Namespace `json:"namespace"`
+		Tags       []string           `json:"tags"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Name == "" && req.TemplateID == "") {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	s, err := h.manager.Create(req.Name)
+	var s *session.Session
+	var err error
+	if req.TemplateID != "" {
+		tpl, findErr := h.templateManager.Find(req.TemplateID)
+		if findErr != nil {
+			http.Error(w, "template not found", http.StatusBadRequest)
+			return
+		}
+		s, err = h.createFromTemplate(tpl, req.Name)
+	} else if req.Backend != "" || req.Target != nil || req.Namespace != nil || len(req.Tags) > 0 {
+		s, err = h.manager.CreateWithSpec(req.Name, session.Spec{Backend: req.Backend, Target: req.Target, Namespace: req.Namespace, Tags: req.Tags})
+	} else {
+		s, err = h.manager.Create(req.Name)
+	}
 	if err != nil {
-		if errors.Is(err, session.ErrNameTaken) {
+		switch {
+		case errors.Is(err, session.ErrNameTaken):
 			http.Error(w, "session name already in use", http.StatusConflict)
-			return
+		case errors.Is(err, session.ErrInvalidTarget), errors.Is(err, session.ErrUnknownBackend),
+			errors.Is(err, session.ErrInvalidNamespace):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case req.Target != nil:
+			http.Error(w, "failed to connect to "+req.Target.String()+": "+err.Error(), http.StatusBadGateway)
+		default:
+			http.Error(w, "failed to create session", http.StatusInternalServerError)
 		}
-		http.Error(w, "failed to create session", http.StatusInternalServerError)
 		return
 	}
 
+	h.auditRequest(r, audit.Entry{Action: audit.ActionSessionCreate, SessionID: s.ID, SessionName: s.Name, Detail: newSessionEvent(s)})
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	_ = json.NewEncoder(w).Encode(s)
 }
 
+// createFromTemplate starts a session from tpl. An explicit name wins over the
+// template's pattern; numbered patterns try successive counters until a free
+// name is found.
+func (h *handler) createFromTemplate(tpl template.Template, name string) (*session.Session, error) {
+	if name != "" {
+		return h.manager.CreateWithSpec(name, tpl.Spec())
+	}
+	now := time.Now()
+	for n := 1; ; n++ {
+		s, err := h.manager.CreateWithSpec(tpl.FormatSessionName(n, now), tpl.Spec())
+		if !errors.Is(err, session.ErrNameTaken) || !tpl.Numbered() || n == maxTemplateInstances {
+			return s, err
+		}
+	}
+}
+
+// sessionCommands returns what shell integration has reported about a
+// session: its working directory and the commands run in it.
+func (h *handler) sessionCommands(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.manager.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.ShellState())
+}
+
+// shellIntegration serves the integration script for a shell, for use on
+// hosts reached over SSH.
+func (h *handler) shellIntegration(w http.ResponseWriter, r *http.Request) {
+	script, ok := session.IntegrationScript(chi.URLParam(r, "shell"))
+	if !ok {
+		http.Error(w, "unknown shell", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write(script)
+}
+
 func (h *handler) killSession(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	s, _ := h.manager.Get(id)
 	if err := h.manager.Kill(id); err != nil {
 		if errors.Is(err, session.ErrNotFound) {
 			http.Error(w, "session not found", http.StatusNotFound)
@@ -50,5 +124,6 @@ func (h *handler) killSession(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to kill session", http.StatusInternalServerError)
 		return
 	}
+	h.auditRequest(r, audit.Entry{Action: audit.ActionSessionKill, SessionID: s.ID, SessionName: s.Name})
 	w.WriteHeader(http.StatusNoContent)
 }
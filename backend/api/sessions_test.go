@@ -2,15 +2,29 @@ package api_test
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	"web-terminal/api"
+	"web-terminal/audit"
+	"web-terminal/events"
+	"web-terminal/files"
+	"web-terminal/layout"
+	"web-terminal/notebook"
+	"web-terminal/notes"
 	"web-terminal/preset"
 	"web-terminal/session"
+	"web-terminal/sshkeys"
+	"web-terminal/template"
+	"web-terminal/trigger"
+	"web-terminal/webhook"
+	"web-terminal/workspace"
 )
 
 // newTestPresetManager creates an in-memory preset manager backed by a temp file.
@@ -24,15 +38,81 @@ func newTestPresetManager(t *testing.T) *preset.Manager {
 	return pm
 }
 
-func newTestServer(t *testing.T) *httptest.Server {
+// newTestServices wires a mock session manager and temp-file backed stores.
+func newTestServices(t *testing.T) api.Services {
 	t.Helper()
-	mgr := session.NewManagerWithSpawnFn(session.MockSpawnFn)
-	pm := newTestPresetManager(t)
-	staticFS := fstest.MapFS{
-		"index.html":   {Data: []byte("<html></html>")},
-		"session.html": {Data: []byte("<html></html>")},
+	dir := t.TempDir()
+	mgr := session.NewManagerWithBackend(session.BackendMock)
+	tm, err := template.NewManager(dir + "/templates.json")
+	if err != nil {
+		t.Fatalf("newTestServices: %v", err)
+	}
+	wm, err := workspace.NewManager(dir+"/workspaces.json", mgr)
+	if err != nil {
+		t.Fatalf("newTestServices: %v", err)
+	}
+	lm, err := layout.NewManager(dir + "/layouts.json")
+	if err != nil {
+		t.Fatalf("newTestServices: %v", err)
+	}
+	whm, err := webhook.NewManager(dir+"/webhooks.json", dir+"/webhooks-dead-letter.jsonl")
+	if err != nil {
+		t.Fatalf("newTestServices: %v", err)
+	}
+	trm, err := trigger.NewManager(dir+"/triggers.json", mgr, whm)
+	if err != nil {
+		t.Fatalf("newTestServices: %v", err)
+	}
+	al, err := audit.NewLogger(dir+"/audit.jsonl", audit.DefaultMaxBytes, audit.DefaultKeep)
+	if err != nil {
+		t.Fatalf("newTestServices: %v", err)
+	}
+	t.Cleanup(func() { al.Close() })
+	nm, err := notes.NewManager(dir + "/notes.json")
+	if err != nil {
+		t.Fatalf("newTestServices: %v", err)
 	}
-	return httptest.NewServer(api.RegisterRoutes(mgr, pm, staticFS))
+	nbm, err := notebook.NewManager(dir + "/notebooks")
+	if err != nil {
+		t.Fatalf("newTestServices: %v", err)
+	}
+	os.Mkdir(dir+"/files", 0755)
+	fb, err := files.New([]string{dir + "/files"})
+	if err != nil {
+		t.Fatalf("newTestServices: %v", err)
+	}
+	// Locked: deriving a key from a passphrase is slow.
+	keys, err := sshkeys.NewKeyStore(dir+"/ssh_keys.json", "")
+	if err != nil {
+		t.Fatalf("newTestServices: %v", err)
+	}
+	return api.Services{
+		Sessions:   mgr,
+		Presets:    newTestPresetManager(t),
+		Templates:  tm,
+		Workspaces: wm,
+		Layouts:    lm,
+		Triggers:   trm,
+		Webhooks:   whm,
+		Notes:      nm,
+		Notebooks:  nbm,
+		Files:      fb,
+		KnownHosts: sshkeys.NewKnownHosts(dir + "/known_hosts"),
+		Keys:       keys,
+		Events:     events.NewBus(),
+		Audit:      al,
+	}
+}
+
+var testStaticFS = fstest.MapFS{
+	"index.html":   {Data: []byte("<html></html>")},
+	"session.html": {Data: []byte("<html></html>")},
+	"layout.html":  {Data: []byte("<html></html>")},
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(api.RegisterRoutes(newTestServices(t), testStaticFS))
 }
 
 func TestListSessionsEmpty(t *testing.T) {
@@ -192,3 +272,85 @@ func TestListSessionsAfterCreate(t *testing.T) {
 		t.Fatalf("expected 2 sessions, got %d", len(sessions))
 	}
 }
+
+func TestCreateSSHSessionInvalidTarget(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/sessions", "application/json",
+		strings.NewReader(`{"name":"remote","target":{"host":"example.com"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a target without user or credentials, got %d", resp.StatusCode)
+	}
+}
+
+func TestCreateSessionUnknownBackend(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/sessions", "application/json",
+		strings.NewReader(`{"name":"odd","backend":"telnet"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown backend, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionCommands(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	s, _ := svc.Sessions.Create("integrated")
+	// The mock backend echoes what a shell with integration would print.
+	s.WriteToPTY([]byte("\x1b]7;file://host/srv\x07\x1b]633;E;make\x07\x1b]133;C\x07ok\r\n\x1b]133;D;0\x07"))
+
+	var st session.ShellState
+	deadline := time.Now().Add(2 * time.Second)
+	for len(st.Commands) == 0 && time.Now().Before(deadline) {
+		resp, err := http.Get(srv.URL + "/api/sessions/" + s.ID + "/commands")
+		if err != nil {
+			t.Fatalf("GET commands: %v", err)
+		}
+		json.NewDecoder(resp.Body).Decode(&st)
+		resp.Body.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if st.Cwd != "/srv" || len(st.Commands) != 1 || st.Commands[0].Command != "make" || *st.Commands[0].ExitCode != 0 {
+		t.Fatalf("unexpected shell state %+v", st)
+	}
+
+	resp, _ := http.Get(srv.URL + "/api/sessions/nonexistent/commands")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown session, got %d", resp.StatusCode)
+	}
+}
+
+func TestShellIntegrationScript(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/shell-integration/zsh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "133;C") {
+		t.Fatalf("expected the zsh script, got %d %q", resp.StatusCode, body)
+	}
+
+	resp, _ = http.Get(srv.URL + "/api/shell-integration/fish")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unsupported shell, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"web-terminal/session"
+	"web-terminal/sshkeys"
+)
+
+// maxKeyBodyBytes bounds POST /api/ssh/keys bodies; an RSA-16384 PEM is
+// well under it.
+const maxKeyBodyBytes = 64 << 10
+
+func (h *handler) listKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Locked bool          `json:"locked"`
+		Keys   []sshkeys.Key `json:"keys"`
+	}{h.keys.Locked(), h.keys.List()})
+}
+
+// createKey generates a key, or imports one when privateKey is given.
+func (h *handler) createKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name       string `json:"name"`
+		Type       string `json:"type"`
+		PrivateKey string `json:"privateKey"`
+		Passphrase string `json:"passphrase"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxKeyBodyBytes)).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	var k sshkeys.Key
+	var err error
+	if req.PrivateKey != "" {
+		k, err = h.keys.Import(req.Name, req.PrivateKey, req.Passphrase)
+	} else {
+		k, err = h.keys.Generate(req.Name, req.Type)
+	}
+	if err != nil {
+		writeKeyError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(k)
+}
+
+func (h *handler) deleteKey(w http.ResponseWriter, r *http.Request) {
+	if err := h.keys.Delete(chi.URLParam(r, "id")); err != nil {
+		writeKeyError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sshkeys.ErrNotFound):
+		http.Error(w, "key not found", http.StatusNotFound)
+	case errors.Is(err, sshkeys.ErrInvalidKey):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, sshkeys.ErrDuplicate):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, sshkeys.ErrLocked):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, "failed to save key", http.StatusInternalServerError)
+	}
+}
+
+func (h *handler) listKnownHosts(w http.ResponseWriter, r *http.Request) {
+	hosts, err := h.knownHosts.List()
+	if err != nil {
+		http.Error(w, "failed to read known hosts", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(hosts)
+}
+
+// deleteKnownHost forgets a host's keys, so the next connection asks again.
+func (h *handler) deleteKnownHost(w http.ResponseWriter, r *http.Request) {
+	err := h.knownHosts.Remove(chi.URLParam(r, "host"))
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, sshkeys.ErrHostNotFound):
+		http.Error(w, "host not found", http.StatusNotFound)
+	default:
+		http.Error(w, "failed to update known hosts", http.StatusInternalServerError)
+	}
+}
+
+// setAgentForwarding turns agent forwarding of an SSH session on or off.
+func (h *handler) setAgentForwarding(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.manager.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := s.SetAgentForwarding(req.Enabled); err != nil {
+		if errors.Is(err, session.ErrNotSSH) || errors.Is(err, session.ErrNoForwarding) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to change agent forwarding", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s)
+}
@@ -0,0 +1,143 @@
+package api_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"web-terminal/api"
+	"web-terminal/sshkeys"
+)
+
+func TestSSHKeys(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	// The shared test store is locked.
+	resp, _ := http.Post(srv.URL+"/api/ssh/keys", "application/json", strings.NewReader(`{"name":"laptop"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("generate with a locked store: expected 503, got %d", resp.StatusCode)
+	}
+
+	keys, err := sshkeys.NewKeyStore(t.TempDir()+"/ssh_keys.json", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Keys = keys
+	srv.Close()
+	srv = httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	resp, _ = http.Post(srv.URL+"/api/ssh/keys", "application/json", strings.NewReader(`{"name":"laptop","type":"ecdsa"}`))
+	var gen sshkeys.Key
+	json.NewDecoder(resp.Body).Decode(&gen)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || gen.Type != "ecdsa-sha2-nistp256" {
+		t.Fatalf("generate: %d %+v", resp.StatusCode, gen)
+	}
+
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	block, _ := ssh.MarshalPrivateKey(priv, "")
+	body, _ := json.Marshal(map[string]string{"name": "imported", "privateKey": string(pem.EncodeToMemory(block))})
+	resp, _ = http.Post(srv.URL+"/api/ssh/keys", "application/json", strings.NewReader(string(body)))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("import: expected 201, got %d", resp.StatusCode)
+	}
+	resp, _ = http.Post(srv.URL+"/api/ssh/keys", "application/json", strings.NewReader(string(body)))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("duplicate import: expected 409, got %d", resp.StatusCode)
+	}
+	resp, _ = http.Post(srv.URL+"/api/ssh/keys", "application/json", strings.NewReader(`{"name":"bad","privateKey":"nope"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid key: expected 400, got %d", resp.StatusCode)
+	}
+
+	resp, _ = http.Get(srv.URL + "/api/ssh/keys")
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	raw := string(data)
+	if strings.Contains(raw, "PRIVATE") || strings.Contains(raw, "sealed") {
+		t.Fatalf("listing leaks private keys: %s", raw)
+	}
+	var listing struct {
+		Locked bool          `json:"locked"`
+		Keys   []sshkeys.Key `json:"keys"`
+	}
+	json.Unmarshal([]byte(raw), &listing)
+	if listing.Locked || len(listing.Keys) != 2 {
+		t.Fatalf("unexpected listing %s", raw)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/api/ssh/keys/"+gen.ID, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", resp.StatusCode)
+	}
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("delete again: expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestKnownHostsAPI(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	key, _ := ssh.NewPublicKey(pub)
+	svc.KnownHosts.Add("build.example:2222", key)
+
+	resp, _ := http.Get(srv.URL + "/api/ssh/known_hosts")
+	var hosts []sshkeys.HostKey
+	json.NewDecoder(resp.Body).Decode(&hosts)
+	resp.Body.Close()
+	if len(hosts) != 1 || hosts[0].Fingerprint != ssh.FingerprintSHA256(key) {
+		t.Fatalf("unexpected known hosts %+v", hosts)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/api/ssh/known_hosts/build.example:2222", nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", resp.StatusCode)
+	}
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("delete again: expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestAgentForwardingLocalSession(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, _ := http.Post(srv.URL+"/api/sessions", "application/json", strings.NewReader(`{"name":"local"}`))
+	var s struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(resp.Body).Decode(&s)
+	resp.Body.Close()
+
+	resp, _ = http.Post(srv.URL+"/api/sessions/"+s.ID+"/agent-forwarding", "application/json", strings.NewReader(`{"enabled":true}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 for a local session, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"web-terminal/template"
+)
+
+func (h *handler) getTemplates(w http.ResponseWriter, r *http.Request) {
+	store := h.templateManager.Get()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(store)
+}
+
+func (h *handler) putTemplates(w http.ResponseWriter, r *http.Request) {
+	var store template.TemplateStore
+	if err := json.NewDecoder(r.Body).Decode(&store); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	for _, t := range store.Templates {
+		if t.ID == "" {
+			http.Error(w, "template id is required", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.templateManager.Save(store); err != nil {
+		http.Error(w, "failed to save templates", http.StatusInternalServerError)
+		return
+	}
+
+	updated := h.templateManager.Get()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(updated)
+}
@@ -0,0 +1,96 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"web-terminal/template"
+)
+
+func putTestTemplates(t *testing.T, url, body string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPut, url+"/api/templates", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT /api/templates: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /api/templates: expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestPutTemplatesAndGet(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	putTestTemplates(t, srv.URL, `{"templates":[{"id":"api-dev","name":"api-dev","cwd":"/srv/api","commands":["make dev"]}]}`)
+
+	resp, err := http.Get(srv.URL + "/api/templates")
+	if err != nil {
+		t.Fatalf("GET /api/templates: %v", err)
+	}
+	defer resp.Body.Close()
+	var store template.TemplateStore
+	json.NewDecoder(resp.Body).Decode(&store)
+	if len(store.Templates) != 1 || store.Templates[0].Cwd != "/srv/api" {
+		t.Fatalf("unexpected templates: %+v", store.Templates)
+	}
+}
+
+func TestPutTemplatesMissingID(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/templates", strings.NewReader(`{"templates":[{"name":"x"}]}`))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestCreateSessionFromTemplate(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	putTestTemplates(t, srv.URL, `{"templates":[{"id":"api-dev","name":"api","sessionName":"{name}-{n}"}]}`)
+
+	for _, want := range []string{"api-1", "api-2"} {
+		resp, err := http.Post(srv.URL+"/api/sessions", "application/json",
+			strings.NewReader(`{"templateId":"api-dev"}`))
+		if err != nil {
+			t.Fatalf("POST /api/sessions: %v", err)
+		}
+		var s map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&s)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201, got %d", resp.StatusCode)
+		}
+		if s["name"] != want {
+			t.Fatalf("expected name %q, got %v", want, s["name"])
+		}
+	}
+}
+
+func TestCreateSessionUnknownTemplate(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/sessions", "application/json",
+		strings.NewReader(`{"templateId":"nope"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+}
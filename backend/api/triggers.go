@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"web-terminal/trigger"
+)
+
+// maxTriggerBodyBytes bounds trigger request bodies.
+const maxTriggerBodyBytes = 64 << 10
+
+// publishTriggerMatches announces rule matches.
+func publishTriggerMatches(tm *trigger.Manager, pub publisher) {
+	tm.OnMatch(func(m trigger.Match) {
+		pub.publish(eventTriggerMatched, m)
+	})
+}
+
+func (h *handler) listTriggers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]trigger.Rule{"rules": h.triggerManager.List()})
+}
+
+func (h *handler) createTrigger(w http.ResponseWriter, r *http.Request) {
+	var rule trigger.Rule
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTriggerBodyBytes)).Decode(&rule); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	created, err := h.triggerManager.Create(rule)
+	if err != nil {
+		writeTriggerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(created)
+}
+
+func (h *handler) getTrigger(w http.ResponseWriter, r *http.Request) {
+	rule, err := h.triggerManager.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		writeTriggerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rule)
+}
+
+func (h *handler) putTrigger(w http.ResponseWriter, r *http.Request) {
+	var rule trigger.Rule
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTriggerBodyBytes)).Decode(&rule); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	saved, err := h.triggerManager.Update(chi.URLParam(r, "id"), rule)
+	if err != nil {
+		writeTriggerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(saved)
+}
+
+func (h *handler) deleteTrigger(w http.ResponseWriter, r *http.Request) {
+	if err := h.triggerManager.Delete(chi.URLParam(r, "id")); err != nil {
+		writeTriggerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeTriggerError maps trigger manager errors to HTTP responses.
+func writeTriggerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, trigger.ErrNotFound):
+		http.Error(w, "trigger not found", http.StatusNotFound)
+	case errors.Is(err, trigger.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "failed to save trigger", http.StatusInternalServerError)
+	}
+}
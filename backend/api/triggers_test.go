@@ -0,0 +1,112 @@
+package api_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"web-terminal/api"
+	"web-terminal/trigger"
+)
+
+func TestTriggersAPI(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, _ := http.Post(srv.URL+"/api/triggers", "application/json", strings.NewReader(`{"name":"bad","pattern":"(","action":"notify"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad pattern, got %d", resp.StatusCode)
+	}
+
+	resp, err := http.Post(srv.URL+"/api/triggers", "application/json", strings.NewReader(`{"name":"oom","pattern":"Out of memory","action":"notify"}`))
+	if err != nil {
+		t.Fatalf("POST /api/triggers: %v", err)
+	}
+	var created trigger.Rule
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || created.ID == "" {
+		t.Fatalf("expected 201 with an ID, got %d %+v", resp.StatusCode, created)
+	}
+
+	created.Scope.Tag = "db"
+	body, _ := json.Marshal(created)
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/triggers/"+created.ID, strings.NewReader(string(body)))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	resp, _ = http.Get(srv.URL + "/api/triggers")
+	var list struct {
+		Rules []trigger.Rule `json:"rules"`
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if len(list.Rules) != 1 || list.Rules[0].Scope.Tag != "db" {
+		t.Fatalf("unexpected rules %+v", list.Rules)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, srv.URL+"/api/triggers/"+created.ID, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+	resp, _ = http.Get(srv.URL + "/api/triggers/" + created.ID)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", resp.StatusCode)
+	}
+}
+
+func TestTriggerMatchesStreamed(t *testing.T) {
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+
+	if _, err := svc.Triggers.Create(trigger.Rule{Name: "oom", Pattern: "Out of memory", Scope: trigger.Scope{Tag: "db"}, Action: trigger.ActionHighlight}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	resp, err := http.Post(srv.URL+"/api/sessions", "application/json", strings.NewReader(`{"name":"pg","tags":["db"]}`))
+	if err != nil {
+		t.Fatalf("POST /api/sessions: %v", err)
+	}
+	var created struct {
+		ID   string   `json:"id"`
+		Tags []string `json:"tags"`
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if len(created.Tags) != 1 || created.Tags[0] != "db" {
+		t.Fatalf("expected the session's tags, got %+v", created)
+	}
+
+	events, err := http.Get(srv.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Body.Close()
+	s, _ := svc.Sessions.Get(created.ID)
+	s.WriteToPTY([]byte("FATAL: Out of memory\r\n"))
+
+	lines := bufio.NewReader(events.Body)
+	event, _ := lines.ReadString('\n')
+	data, _ := lines.ReadString('\n')
+	if event != "event: trigger.matched\n" {
+		t.Fatalf("unexpected event line %q", event)
+	}
+	var match trigger.Match
+	json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &match)
+	if match.SessionID != created.ID || match.Line != "FATAL: Out of memory" {
+		t.Fatalf("unexpected match %+v", match)
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"web-terminal/webhook"
+)
+
+// maxWebhookBodyBytes bounds webhook request bodies.
+const maxWebhookBodyBytes = 64 << 10
+
+func (h *handler) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]webhook.Webhook{"webhooks": h.webhookManager.List()})
+}
+
+func (h *handler) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var hook webhook.Webhook
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes)).Decode(&hook); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	created, err := h.webhookManager.Create(hook)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(created)
+}
+
+func (h *handler) getWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, err := h.webhookManager.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(hook)
+}
+
+func (h *handler) putWebhook(w http.ResponseWriter, r *http.Request) {
+	var hook webhook.Webhook
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes)).Decode(&hook); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	saved, err := h.webhookManager.Update(chi.URLParam(r, "id"), hook)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(saved)
+}
+
+func (h *handler) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := h.webhookManager.Delete(chi.URLParam(r, "id")); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// webhookDeliveries lists a webhook's recent deliveries and their attempts,
+// newest first.
+func (h *handler) webhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := h.webhookManager.Deliveries(chi.URLParam(r, "id"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]webhook.Delivery{"deliveries": deliveries})
+}
+
+// pingWebhook sends a test event to a webhook.
+func (h *handler) pingWebhook(w http.ResponseWriter, r *http.Request) {
+	d, err := h.webhookManager.Ping(chi.URLParam(r, "id"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(d)
+}
+
+// writeWebhookError maps webhook manager errors to HTTP responses.
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		http.Error(w, "webhook not found", http.StatusNotFound)
+	case errors.Is(err, webhook.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "failed to save webhook", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,191 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"web-terminal/api"
+	"web-terminal/webhook"
+)
+
+func TestWebhooksAPI(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, _ := http.Post(srv.URL+"/api/webhooks", "application/json", strings.NewReader(`{"name":"ci","url":"not a url"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad URL, got %d", resp.StatusCode)
+	}
+
+	resp, err := http.Post(srv.URL+"/api/webhooks", "application/json", strings.NewReader(`{"name":"ci","url":"https://example.com/hook"}`))
+	if err != nil {
+		t.Fatalf("POST /api/webhooks: %v", err)
+	}
+	var created webhook.Webhook
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || created.ID == "" || created.Secret == "" {
+		t.Fatalf("expected 201 with an ID and secret, got %d %+v", resp.StatusCode, created)
+	}
+
+	created.Disabled = true
+	body, _ := json.Marshal(created)
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/webhooks/"+created.ID, strings.NewReader(string(body)))
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	resp, _ = http.Get(srv.URL + "/api/webhooks")
+	var list struct {
+		Webhooks []webhook.Webhook `json:"webhooks"`
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	resp.Body.Close()
+	if len(list.Webhooks) != 1 || !list.Webhooks[0].Disabled {
+		t.Fatalf("unexpected webhooks %+v", list.Webhooks)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, srv.URL+"/api/webhooks/"+created.ID, nil)
+	resp, _ = http.DefaultClient.Do(req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+	resp, _ = http.Get(srv.URL + "/api/webhooks/" + created.ID + "/deliveries")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionEventsDelivered(t *testing.T) {
+	type received struct {
+		event, signature string
+		body             []byte
+	}
+	got := make(chan received, 8)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{r.Header.Get(webhook.HeaderEvent), r.Header.Get(webhook.HeaderSignature), body}
+	}))
+	defer hook.Close()
+
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+	w, err := svc.Webhooks.Create(webhook.Webhook{Name: "audit", URL: hook.URL, Events: []string{"session.created"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	resp, err := http.Post(srv.URL+"/api/sessions", "application/json", strings.NewReader(`{"name":"deploy","tags":["prod"]}`))
+	if err != nil {
+		t.Fatalf("POST /api/sessions: %v", err)
+	}
+	var s struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(resp.Body).Decode(&s)
+	resp.Body.Close()
+
+	var r received
+	select {
+	case r = <-got:
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not called")
+	}
+	if r.event != "session.created" || r.signature != webhook.Sign(w.Secret, r.body) {
+		t.Fatalf("unexpected delivery %q %q", r.event, r.signature)
+	}
+	var p struct {
+		Data struct {
+			ID   string   `json:"id"`
+			Name string   `json:"name"`
+			Tags []string `json:"tags"`
+		} `json:"data"`
+	}
+	json.Unmarshal(r.body, &p)
+	if p.Data.ID != s.ID || p.Data.Name != "deploy" || len(p.Data.Tags) != 1 {
+		t.Fatalf("unexpected payload %s", r.body)
+	}
+
+	resp, _ = http.Post(srv.URL+"/api/webhooks/"+w.ID+"/ping", "application/json", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202 from ping, got %d", resp.StatusCode)
+	}
+	select {
+	case r = <-got:
+		if r.event != webhook.EventPing {
+			t.Fatalf("expected a ping, got %q", r.event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ping not delivered")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, _ = http.Get(srv.URL + "/api/webhooks/" + w.ID + "/deliveries")
+		var list struct {
+			Deliveries []webhook.Delivery `json:"deliveries"`
+		}
+		json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if len(list.Deliveries) == 2 && list.Deliveries[0].Event == webhook.EventPing && list.Deliveries[0].Status == webhook.StatusDelivered {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected deliveries %+v", list.Deliveries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebhookDeliveriesSurviveBursts(t *testing.T) {
+	got := make(chan string, 256)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p struct {
+			Data struct {
+				Name string `json:"name"`
+			} `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&p)
+		got <- p.Data.Name
+	}))
+	defer hook.Close()
+
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	defer srv.Close()
+	if _, err := svc.Webhooks.Create(webhook.Webhook{Name: "burst", URL: hook.URL, Events: []string{"session.created"}}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Far more events at once than a bus subscriber may fall behind by.
+	const n = 100
+	for i := 0; i < n; i++ {
+		s, err := svc.Sessions.Create(fmt.Sprintf("burst-%d", i))
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		defer svc.Sessions.Kill(s.ID)
+	}
+	seen := map[string]bool{}
+	for len(seen) < n {
+		select {
+		case name := <-got:
+			seen[name] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of %d deliveries arrived", len(seen), n)
+		}
+	}
+}
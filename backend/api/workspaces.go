@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"web-terminal/workspace"
+)
+
+func (h *handler) listWorkspaces(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string][]workspace.Status{"workspaces": h.workspaceManager.List()})
+}
+
+func (h *handler) putWorkspaces(w http.ResponseWriter, r *http.Request) {
+	var store workspace.WorkspaceStore
+	if err := json.NewDecoder(r.Body).Decode(&store); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := store.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.workspaceManager.Save(store); err != nil {
+		http.Error(w, "failed to save workspaces", http.StatusInternalServerError)
+		return
+	}
+	h.listWorkspaces(w, r)
+}
+
+func (h *handler) getWorkspace(w http.ResponseWriter, r *http.Request) {
+	st, err := h.workspaceManager.Status(chi.URLParam(r, "id"))
+	writeWorkspaceStatus(w, st, err)
+}
+
+func (h *handler) startWorkspace(w http.ResponseWriter, r *http.Request) {
+	st, err := h.workspaceManager.Start(chi.URLParam(r, "id"))
+	writeWorkspaceStatus(w, st, err)
+}
+
+func (h *handler) stopWorkspace(w http.ResponseWriter, r *http.Request) {
+	st, err := h.workspaceManager.Stop(chi.URLParam(r, "id"))
+	writeWorkspaceStatus(w, st, err)
+}
+
+// writeWorkspaceStatus writes the outcome of a workspace operation, mapping
+// workspace.ErrNotFound to 404.
+func writeWorkspaceStatus(w http.ResponseWriter, st workspace.Status, err error) {
+	if err != nil {
+		if errors.Is(err, workspace.ErrNotFound) {
+			http.Error(w, "workspace not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "workspace operation failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(st)
+}
@@ -0,0 +1,86 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"web-terminal/workspace"
+)
+
+func TestWorkspaceStartStop(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	body := `{"workspaces":[{"id":"proj","name":"proj","members":[{"name":"server"},{"name":"db","cwd":"/tmp"}]}]}`
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/workspaces", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	putResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT /api/workspaces: %v", err)
+	}
+	putResp.Body.Close()
+	if putResp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", putResp.StatusCode)
+	}
+
+	resp, err := http.Post(srv.URL+"/api/workspaces/proj/start", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST .../start: %v", err)
+	}
+	var st workspace.Status
+	json.NewDecoder(resp.Body).Decode(&st)
+	resp.Body.Close()
+	if st.State != workspace.StateRunning || len(st.Members) != 2 {
+		t.Fatalf("unexpected status after start: %+v", st)
+	}
+
+	sessResp, _ := http.Get(srv.URL + "/api/sessions")
+	var sessions []map[string]interface{}
+	json.NewDecoder(sessResp.Body).Decode(&sessions)
+	sessResp.Body.Close()
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(sessions))
+	}
+
+	resp, err = http.Post(srv.URL+"/api/workspaces/proj/stop", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST .../stop: %v", err)
+	}
+	json.NewDecoder(resp.Body).Decode(&st)
+	resp.Body.Close()
+	if st.State != workspace.StateStopped {
+		t.Fatalf("expected stopped, got %q", st.State)
+	}
+}
+
+func TestPutWorkspacesInvalid(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	body := `{"workspaces":[{"id":"","members":[]}]}`
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/workspaces", strings.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestWorkspaceStartNotFound(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/api/workspaces/missing/start", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
@@ -7,9 +7,11 @@ import (
 	"sync"
 	"time"
 
-	"github.com/creack/pty"
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
+
+	"web-terminal/layout"
+	"web-terminal/session"
 )
 
 const (
@@ -26,9 +28,37 @@ var upgrader = websocket.Upgrader{
 
 type wsMessage struct {
 	Type string `json:"type"`
-	Data string `json:"data,omitempty"`
-	Cols uint16 `json:"cols,omitempty"`
-	Rows uint16 `json:"rows,omitempty"`
+	// Channel names the session a message is about on connections that
+	// carry several, such as a layout's.
+	Channel string `json:"channel,omitempty"`
+	Data    string `json:"data,omitempty"`
+	Cols    uint16 `json:"cols,omitempty"`
+	Rows    uint16 `json:"rows,omitempty"`
+
+	// Set on "transfer" messages.
+	Protocol  string `json:"protocol,omitempty"`
+	Direction string `json:"direction,omitempty"`
+
+	// Set on "hostkey" messages and their "hostkey-reply".
+	ID          string `json:"id,omitempty"`
+	Host        string `json:"host,omitempty"`
+	KeyType     string `json:"keyType,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Accept      bool   `json:"accept,omitempty"`
+
+	// Set on multiplexed connections: the session an "attach" binds to its
+	// channel, and the output bytes an "ack" returns as credit.
+	Session string `json:"session,omitempty"`
+	Bytes   int    `json:"bytes,omitempty"`
+
+	// Set on "layout" messages.
+	Layout *layout.Layout `json:"layout,omitempty"`
+
+	// Set on "notification" messages.
+	Notification *session.Notification `json:"notification,omitempty"`
+
+	// Set on "highlight" messages.
+	Highlight *session.Highlight `json:"highlight,omitempty"`
 }
 
 func (h *handler) handleWS(w http.ResponseWriter, r *http.Request) {
@@ -61,9 +91,11 @@ func (h *handler) handleWS(w http.ResponseWriter, r *http.Request) {
 		return conn.WriteJSON(msg)
 	}
 
-	outChan := make(chan []byte, 256)
+	outChan := make(chan session.Output, 256)
 	kick := s.SetClient(outChan)        // also sets s.Connected = true; kicks any prior client
 	defer s.ClearClient(outChan)        // closes outChan + clears session state if still owner
+	h.clientChanged(r, s, true)
+	defer h.clientChanged(r, s, false)
 
 	// Replay scrollback
 	if snap := s.ScrollbackSnapshot(); len(snap) > 0 {
@@ -79,11 +111,45 @@ func (h *handler) handleWS(w http.ResponseWriter, r *http.Request) {
 
 	// Goroutine: pump live PTY output to client.
 	// Exits when ClearClient closes outChan.
+	// Transfer data is sent as "transfer-data", announced by a "transfer"
+	// message when a new transfer starts. Host key questions are sent as
+	// "hostkey".
 	go func() {
-		for data := range outChan {
+		var transfer *session.Transfer
+		for out := range outChan {
+			if p := out.Prompt; p != nil {
+				msg := wsMessage{Type: "hostkey", ID: p.ID, Host: p.Host, KeyType: p.KeyType, Fingerprint: p.Fingerprint}
+				if err := writeMsg(msg); err != nil {
+					return
+				}
+				continue
+			}
+			if n := out.Notification; n != nil {
+				if err := writeMsg(wsMessage{Type: "notification", Notification: n}); err != nil {
+					return
+				}
+				continue
+			}
+			if hl := out.Highlight; hl != nil {
+				if err := writeMsg(wsMessage{Type: "highlight", Highlight: hl}); err != nil {
+					return
+				}
+				continue
+			}
+			msgType := "output"
+			if out.Transfer != nil {
+				msgType = "transfer-data"
+				if out.Transfer != transfer {
+					transfer = out.Transfer
+					start := wsMessage{Type: "transfer", Protocol: transfer.Protocol, Direction: transfer.Direction}
+					if err := writeMsg(start); err != nil {
+						return
+					}
+				}
+			}
 			msg := wsMessage{
-				Type: "output",
-				Data: base64.StdEncoding.EncodeToString(data),
+				Type: msgType,
+				Data: base64.StdEncoding.EncodeToString(out.Data),
 			}
 			if err := writeMsg(msg); err != nil {
 				return
@@ -147,12 +213,26 @@ func (h *handler) handleWS(w http.ResponseWriter, r *http.Request) {
 				log.Printf("PTY write error: %v", err)
 				return
 			}
+		case "transfer-data":
+			// Transfer bytes from the browser go to the PTY verbatim, but
+			// only while a transfer is in progress.
+			data, err := base64.StdEncoding.DecodeString(msg.Data)
+			if err != nil || s.Transfer() == nil {
+				continue
+			}
+			if _, err := s.WriteToPTY(data); err != nil {
+				log.Printf("PTY write error: %v", err)
+				return
+			}
+		case "transfer-end":
+			s.EndTransfer()
+		case "transfer-cancel":
+			s.CancelTransfer()
+		case "hostkey-reply":
+			s.AnswerHostKey(msg.ID, msg.Accept)
 		case "resize":
 			if msg.Cols > 0 && msg.Rows > 0 {
-				if err := pty.Setsize(s.PTY(), &pty.Winsize{
-					Rows: msg.Rows,
-					Cols: msg.Cols,
-				}); err != nil {
+				if err := s.Resize(msg.Cols, msg.Rows); err != nil {
 					log.Printf("PTY resize error: %v", err)
 				}
 			}
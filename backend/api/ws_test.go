@@ -1,12 +1,12 @@
 package api_test
 
 import (
+	"bytes"
 	"encoding/base64"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
-	"testing/fstest"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -20,18 +20,16 @@ type wsMsg struct {
 	Data string `json:"data,omitempty"`
 	Cols uint16 `json:"cols,omitempty"`
 	Rows uint16 `json:"rows,omitempty"`
+
+	Protocol  string `json:"protocol,omitempty"`
+	Direction string `json:"direction,omitempty"`
 }
 
 func newWSTestServer(t *testing.T) (*httptest.Server, *session.Manager) {
 	t.Helper()
-	mgr := session.NewManagerWithSpawnFn(session.MockSpawnFn)
-	pm := newTestPresetManager(t)
-	staticFS := fstest.MapFS{
-		"index.html":   {Data: []byte("<html></html>")},
-		"session.html": {Data: []byte("<html></html>")},
-	}
-	srv := httptest.NewServer(api.RegisterRoutes(mgr, pm, staticFS))
-	return srv, mgr
+	svc := newTestServices(t)
+	srv := httptest.NewServer(api.RegisterRoutes(svc, testStaticFS))
+	return srv, svc.Sessions
 }
 
 func dialWS(t *testing.T, srv *httptest.Server, path string) (*websocket.Conn, *http.Response, error) {
@@ -62,7 +60,7 @@ func TestWSScrollbackReplay(t *testing.T) {
 		t.Fatalf("Create: %v", err)
 	}
 
-	// Write data through the PTY; MockSpawnFn echoes it into the scrollback.
+	// Write data through the PTY; the mock backend echoes it into the scrollback.
 	s.WriteToPTY([]byte("hello scrollback"))
 	time.Sleep(50 * time.Millisecond)
 
@@ -101,7 +99,7 @@ func TestWSClosedOnSessionEnd(t *testing.T) {
 	}
 	defer conn.Close()
 
-	// Kill closes ptmx; the MockSpawnFn goroutine reads EOF and closes s.done.
+	// Kill closes the mock backend; the read loop sees EOF and closes s.done.
 	mgr.Kill(s.ID)
 
 	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
@@ -133,7 +131,7 @@ func TestWSEchoRoundTrip(t *testing.T) {
 	}
 	defer conn.Close()
 
-	// Send input; MockSpawnFn echoes it back as output.
+	// Send input; the mock backend echoes it back as output.
 	input := "ping"
 	if err := conn.WriteJSON(wsMsg{Type: "input", Data: base64.StdEncoding.EncodeToString([]byte(input))}); err != nil {
 		t.Fatalf("WriteJSON: %v", err)
@@ -213,3 +211,56 @@ func TestWSResizeDoesNotPanic(t *testing.T) {
 		t.Fatalf("second WriteJSON resize: %v", err)
 	}
 }
+
+func TestWSTransferSubProtocol(t *testing.T) {
+	srv, mgr := newWSTestServer(t)
+	defer srv.Close()
+
+	s, err := mgr.Create("transfer-test")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	conn, _, err := dialWS(t, srv, "/api/sessions/"+s.ID+"/ws")
+	if err != nil {
+		t.Fatalf("WS dial: %v", err)
+	}
+	defer conn.Close()
+
+	send := func(msgType string, data string) {
+		t.Helper()
+		msg := wsMsg{Type: msgType, Data: base64.StdEncoding.EncodeToString([]byte(data))}
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatalf("WriteJSON: %v", err)
+		}
+	}
+	read := func() wsMsg {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var msg wsMsg
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("ReadJSON: %v", err)
+		}
+		return msg
+	}
+
+	// The mock backend echoes input, so this looks like `sz` starting.
+	send("input", "**\x18B00000000000000\r\x8a\x11")
+	if msg := read(); msg.Type != "transfer" || msg.Protocol != "zmodem" || msg.Direction != "download" {
+		t.Fatalf("expected a zmodem download to start, got %+v", msg)
+	}
+	if msg := read(); msg.Type != "transfer-data" {
+		t.Fatalf("expected transfer-data, got %+v", msg)
+	}
+
+	send("transfer-end", "")
+	time.Sleep(50 * time.Millisecond)
+	send("input", "back")
+	msg := read()
+	decoded, _ := base64.StdEncoding.DecodeString(msg.Data)
+	if msg.Type != "output" || string(decoded) != "back" {
+		t.Fatalf("expected terminal output after transfer-end, got %+v %q", msg, decoded)
+	}
+	if bytes.Contains(s.ScrollbackSnapshot(), []byte("B00")) {
+		t.Fatal("transfer bytes leaked into the scrollback")
+	}
+}
@@ -0,0 +1,67 @@
+// Package audit keeps an append-only log of who did what on the server:
+// sessions created, killed and attached to, preset edits, API calls and,
+// optionally, every line of input. Entries are JSON lines in a file that is
+// rotated when it grows too large.
+package audit
+
+import (
+	"slices"
+	"time"
+)
+
+// Actions recorded in Entry.Action.
+const (
+	ActionSessionCreate = "session.create" // through the API, with who asked
+	ActionSessionKill   = "session.kill"
+	ActionSessionExit   = "session.exit" // the shell ended, however it happened
+	ActionAttach        = "session.attach"
+	ActionDetach        = "session.detach"
+	ActionInput         = "session.input"
+	ActionPresets       = "presets.change" // Detail is the preset.Diff
+	ActionRequest       = "api.request"    // any other API call that changes state
+)
+
+// Entry is a line of the audit log. RemoteAddr and UserAgent identify the
+// client that caused it, where there was one.
+type Entry struct {
+	Time        time.Time `json:"time"`
+	Action      string    `json:"action"`
+	SessionID   string    `json:"sessionId,omitempty"`
+	SessionName string    `json:"sessionName,omitempty"`
+	RemoteAddr  string    `json:"remoteAddr,omitempty"`
+	UserAgent   string    `json:"userAgent,omitempty"`
+	Method      string    `json:"method,omitempty"`
+	Path        string    `json:"path,omitempty"`
+	Status      int       `json:"status,omitempty"`
+	Input       string    `json:"input,omitempty"`
+	Detail      any       `json:"detail,omitempty"`
+}
+
+// Filter selects entries in Query. Zero fields match everything.
+type Filter struct {
+	Since   time.Time // inclusive
+	Until   time.Time // exclusive
+	Session string    // session ID or name
+	Actions []string
+	Limit   int // the newest Limit matches; 0 means DefaultLimit
+}
+
+// DefaultLimit and MaxLimit bound the entries Query returns.
+const (
+	DefaultLimit = 500
+	MaxLimit     = 10000
+)
+
+func (f Filter) match(e Entry) bool {
+	switch {
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	case f.Session != "" && e.SessionID != f.Session && e.SessionName != f.Session:
+		return false
+	case len(f.Actions) > 0 && !slices.Contains(f.Actions, e.Action):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,233 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Defaults for NewLogger.
+const (
+	DefaultMaxBytes = 10 << 20
+	DefaultKeep     = 5
+)
+
+// Logger appends entries to a JSON-lines file. When the file would grow past
+// maxBytes it is renamed to path.1, path.1 to path.2 and so on, and the
+// oldest of the keep rotated files is removed. A nil Logger records
+// nothing.
+type Logger struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	keep     int
+	f        *os.File
+	size     int64
+}
+
+// NewLogger opens the log at path for appending, creating it if needed.
+func NewLogger(path string, maxBytes int64, keep int) (*Logger, error) {
+	l := &Logger{path: path, maxBytes: maxBytes, keep: keep}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open opens the current file. l.mu must be held, or l not yet shared.
+func (l *Logger) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, info.Size()
+	return nil
+}
+
+// Record appends e, stamping its time if unset. Failures are logged rather
+// than returned: the action being audited has already happened.
+func (l *Logger) Record(e Entry) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("audit: encode %s: %v", e.Action, err)
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return
+	}
+	if l.size > 0 && l.size+int64(len(line)) > l.maxBytes {
+		if err := l.rotate(); err != nil {
+			log.Printf("audit: rotate %s: %v", l.path, err)
+			if l.f == nil {
+				return
+			}
+		}
+	}
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		log.Printf("audit: write %s: %v", l.path, err)
+	}
+}
+
+// rotate shifts the rotated files up by one, moves the current file to
+// path.1 and starts a new one. l.mu must be held.
+func (l *Logger) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	l.f = nil
+	if l.keep > 0 {
+		os.Remove(l.rotated(l.keep))
+		for i := l.keep - 1; i >= 1; i-- {
+			if err := os.Rename(l.rotated(i), l.rotated(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		if err := os.Rename(l.path, l.rotated(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(l.path); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// rotated returns the name of the n-th rotated file, n from 1 (newest).
+func (l *Logger) rotated(n int) string {
+	return fmt.Sprintf("%s.%d", l.path, n)
+}
+
+// Query returns the newest entries matching f, newest first, reading the
+// rotated files as well as the current one.
+func (l *Logger) Query(f Filter) ([]Entry, error) {
+	if l == nil {
+		return []Entry{}, nil
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	files, err := l.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, sf := range files {
+			sf.f.Close()
+		}
+	}()
+	// Files newest to oldest; within each, keep the newest matches.
+	out := []Entry{}
+	for _, sf := range files {
+		matches, err := scan(io.LimitReader(sf.f, sf.size), f)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(matches) - 1; i >= 0 && len(out) < limit; i-- {
+			out = append(out, matches[i])
+		}
+		if len(out) >= limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// snapshotFile is a log file opened by Query with its size at the time.
+type snapshotFile struct {
+	f    *os.File
+	size int64
+}
+
+// snapshot opens the current and rotated files, newest first, holding l.mu
+// only while it does so. Open files keep their contents through later
+// rotations, and reading the current one no further than its size now
+// leaves out entries appended meanwhile, so Query can scan them without
+// holding up Record.
+func (l *Logger) snapshot() ([]snapshotFile, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var files []snapshotFile
+	for i := 0; i <= l.keep; i++ {
+		name := l.path
+		if i > 0 {
+			name = l.rotated(i)
+		}
+		f, err := os.Open(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err == nil {
+			var info os.FileInfo
+			if info, err = f.Stat(); err == nil {
+				files = append(files, snapshotFile{f: f, size: info.Size()})
+				continue
+			}
+			f.Close()
+		}
+		for _, sf := range files {
+			sf.f.Close()
+		}
+		return nil, err
+	}
+	return files, nil
+}
+
+// scan reads the entries in r that match f, oldest first. Lines that are
+// not valid entries are skipped.
+func scan(r io.Reader, f Filter) ([]Entry, error) {
+	var matches []Entry
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		var e Entry
+		if len(line) > 0 && json.Unmarshal(line, &e) == nil && f.match(e) {
+			matches = append(matches, e)
+		}
+		if errors.Is(err, io.EOF) {
+			return matches, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Close closes the log. Later entries are dropped.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
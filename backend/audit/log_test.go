@@ -0,0 +1,123 @@
+package audit_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"web-terminal/audit"
+)
+
+func TestRecordAndQuery(t *testing.T) {
+	path := t.TempDir() + "/audit.jsonl"
+	l, err := audit.NewLogger(path, audit.DefaultMaxBytes, audit.DefaultKeep)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	defer l.Close()
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l.Record(audit.Entry{Time: start, Action: audit.ActionSessionCreate, SessionID: "s1", SessionName: "build", RemoteAddr: "10.0.0.1:5000"})
+	l.Record(audit.Entry{Time: start.Add(time.Minute), Action: audit.ActionInput, SessionID: "s1", SessionName: "build", Input: "make"})
+	l.Record(audit.Entry{Time: start.Add(2 * time.Minute), Action: audit.ActionSessionCreate, SessionID: "s2", SessionName: "db"})
+	l.Record(audit.Entry{Time: start.Add(3 * time.Minute), Action: audit.ActionSessionKill, SessionID: "s1", SessionName: "build"})
+
+	got, err := l.Query(audit.Filter{Session: "build"})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(got) != 3 || got[0].Action != audit.ActionSessionKill || got[2].RemoteAddr != "10.0.0.1:5000" {
+		t.Fatalf("expected the build session's entries newest first, got %+v", got)
+	}
+
+	got, _ = l.Query(audit.Filter{Since: start.Add(time.Minute), Until: start.Add(3 * time.Minute)})
+	if len(got) != 2 || got[0].SessionID != "s2" || got[1].Input != "make" {
+		t.Fatalf("unexpected entries in the time range: %+v", got)
+	}
+
+	got, _ = l.Query(audit.Filter{Actions: []string{audit.ActionSessionCreate}, Limit: 1})
+	if len(got) != 1 || got[0].SessionID != "s2" {
+		t.Fatalf("expected the newest create, got %+v", got)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	path := t.TempDir() + "/audit.jsonl"
+	l, err := audit.NewLogger(path, 400, 2)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	defer l.Close()
+
+	for i := range 20 {
+		l.Record(audit.Entry{Action: audit.ActionInput, SessionID: "s1", Input: strings.Repeat("x", i)})
+	}
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+		if info.Size() > 400 {
+			t.Fatalf("%s grew to %d bytes", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Fatal("expected at most 2 rotated files")
+	}
+
+	got, err := l.Query(audit.Filter{Limit: audit.MaxLimit})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(got) < 4 || got[0].Input != strings.Repeat("x", 19) {
+		t.Fatalf("expected the newest entries across files, got %d, first %+v", len(got), got[0])
+	}
+	for i := 1; i < len(got); i++ {
+		if len(got[i].Input) != len(got[i-1].Input)-1 {
+			t.Fatalf("entries out of order at %d: %q after %q", i, got[i].Input, got[i-1].Input)
+		}
+	}
+}
+
+func TestNilLogger(t *testing.T) {
+	var l *audit.Logger
+	l.Record(audit.Entry{Action: audit.ActionSessionKill})
+	if got, err := l.Query(audit.Filter{}); err != nil || len(got) != 0 {
+		t.Fatalf("expected no entries, got %+v %v", got, err)
+	}
+}
+
+func TestQueryWhileRecording(t *testing.T) {
+	path := t.TempDir() + "/audit.jsonl"
+	l, err := audit.NewLogger(path, 2000, 3)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	defer l.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 500 {
+			l.Record(audit.Entry{Action: audit.ActionInput, SessionID: "s1", Input: strings.Repeat("x", i%50)})
+		}
+	}()
+	// Queries see whole entries in order while appends and rotations go on.
+	for {
+		got, err := l.Query(audit.Filter{Limit: audit.MaxLimit})
+		if err != nil {
+			t.Fatalf("Query: %v", err)
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i].Time.After(got[i-1].Time) || got[i].Action != audit.ActionInput {
+				t.Fatalf("entry %d out of order or damaged: %+v after %+v", i, got[i], got[i-1])
+			}
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
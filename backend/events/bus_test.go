@@ -0,0 +1,41 @@
+package events_test
+
+import (
+	"testing"
+
+	"web-terminal/events"
+)
+
+func TestPublishReachesSubscribers(t *testing.T) {
+	bus := events.NewBus()
+	a, cancelA := bus.Subscribe()
+	b, cancelB := bus.Subscribe()
+	defer cancelB()
+
+	bus.Publish(events.Event{Type: "ping"})
+	if e := <-a; e.Type != "ping" {
+		t.Fatalf("a got %+v", e)
+	}
+	if e := <-b; e.Type != "ping" {
+		t.Fatalf("b got %+v", e)
+	}
+
+	cancelA()
+	cancelA() // idempotent
+	if _, ok := <-a; ok {
+		t.Fatal("expected channel closed after cancel")
+	}
+	bus.Publish(events.Event{Type: "after"})
+	if e := <-b; e.Type != "after" {
+		t.Fatalf("b got %+v", e)
+	}
+}
+
+func TestPublishDoesNotBlockOnSlowSubscriber(t *testing.T) {
+	bus := events.NewBus()
+	_, cancel := bus.Subscribe()
+	defer cancel()
+	for range 1000 {
+		bus.Publish(events.Event{Type: "flood"})
+	}
+}
@@ -0,0 +1,338 @@
+// Package files gives HTTP handlers confined access to the host filesystem.
+// Every path is resolved against a set of configured root directories and
+// all operations go through os.Root, so neither ".." nor symbolic links can
+// reach outside them.
+package files
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrOutsideRoots = errors.New("path is outside the allowed roots")
+	ErrNotFound     = errors.New("file not found")
+	ErrExists       = errors.New("file already exists")
+	ErrNotDir       = errors.New("not a directory")
+	ErrIsDir        = errors.New("is a directory")
+	ErrInvalidName  = errors.New("invalid file name")
+)
+
+// Entry describes a file or directory.
+type Entry struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"` // absolute
+	Dir     bool      `json:"dir"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"` // e.g. "-rw-r--r--"
+	ModTime time.Time `json:"modTime"`
+	Symlink bool      `json:"symlink,omitempty"`
+}
+
+// Location is a path inside one of the roots.
+type Location struct {
+	Root string // absolute root directory
+	Rel  string // relative to Root, never starting with ".."; "." for Root itself
+}
+
+// Abs returns the absolute path of l.
+func (l Location) Abs() string {
+	return filepath.Join(l.Root, l.Rel)
+}
+
+// Browser resolves and operates on paths within its roots.
+type Browser struct {
+	mu    sync.Mutex // serializes conditional writes
+	roots []string   // absolute and cleaned, longest first
+}
+
+// New returns a Browser confined to roots. Relative roots are made absolute.
+func New(roots []string) (*Browser, error) {
+	b := &Browser{}
+	for _, r := range roots {
+		if r == "" {
+			continue
+		}
+		abs, err := filepath.Abs(r)
+		if err != nil {
+			return nil, err
+		}
+		b.roots = append(b.roots, abs)
+	}
+	if len(b.roots) == 0 {
+		return nil, errors.New("files: no roots configured")
+	}
+	// Longest first, so the most specific root wins in Resolve.
+	slices.SortFunc(b.roots, func(a, c string) int { return len(c) - len(a) })
+	return b, nil
+}
+
+// Roots returns the configured root directories.
+func (b *Browser) Roots() []string {
+	return slices.Clone(b.roots)
+}
+
+// Resolve maps p to a location inside a root. A relative p is taken relative
+// to base, which must itself be absolute; an empty p means base.
+func (b *Browser) Resolve(base, p string) (Location, error) {
+	if !filepath.IsAbs(p) {
+		if !filepath.IsAbs(base) {
+			return Location{}, ErrOutsideRoots
+		}
+		p = filepath.Join(base, p)
+	}
+	p = filepath.Clean(p)
+	for _, root := range b.roots {
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return Location{Root: root, Rel: rel}, nil
+	}
+	return Location{}, ErrOutsideRoots
+}
+
+// withRoot opens l's root and calls fn with it.
+func withRoot[T any](l Location, fn func(r *os.Root) (T, error)) (T, error) {
+	r, err := os.OpenRoot(l.Root)
+	if err != nil {
+		var zero T
+		return zero, mapErr(err)
+	}
+	defer r.Close()
+	v, err := fn(r)
+	return v, mapErr(err)
+}
+
+// mapErr translates filesystem errors into this package's errors.
+func mapErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrNotFound
+	case errors.Is(err, fs.ErrExist):
+		return ErrExists
+	case strings.Contains(err.Error(), "path escapes from parent"):
+		// os.Root does not export this error; it means a symlink or ".."
+		// pointed outside the root.
+		return ErrOutsideRoots
+	}
+	return err
+}
+
+// entry builds an Entry for the file at l from its Lstat info, following a
+// symlink to report what it points at.
+func entry(r *os.Root, l Location, info fs.FileInfo) Entry {
+	e := Entry{
+		Name:    info.Name(),
+		Path:    l.Abs(),
+		Size:    info.Size(),
+		Mode:    info.Mode().String(),
+		ModTime: info.ModTime(),
+	}
+	if l.Rel == "." {
+		e.Name = filepath.Base(l.Root)
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		e.Symlink = true
+		if target, err := r.Stat(l.Rel); err == nil {
+			info = target
+			e.Size = target.Size()
+		}
+	}
+	e.Dir = info.IsDir()
+	return e
+}
+
+// Stat describes the file at l.
+func (b *Browser) Stat(l Location) (Entry, error) {
+	return withRoot(l, func(r *os.Root) (Entry, error) {
+		info, err := r.Lstat(l.Rel)
+		if err != nil {
+			return Entry{}, err
+		}
+		return entry(r, l, info), nil
+	})
+}
+
+// List returns the entries of the directory at l, directories first.
+func (b *Browser) List(l Location) ([]Entry, error) {
+	return withRoot(l, func(r *os.Root) ([]Entry, error) {
+		dir, err := r.Open(l.Rel)
+		if err != nil {
+			return nil, err
+		}
+		defer dir.Close()
+		if info, err := dir.Stat(); err != nil {
+			return nil, err
+		} else if !info.IsDir() {
+			return nil, ErrNotDir
+		}
+		infos, err := dir.Readdir(-1)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]Entry, 0, len(infos))
+		for _, info := range infos {
+			list = append(list, entry(r, Location{l.Root, filepath.Join(l.Rel, info.Name())}, info))
+		}
+		slices.SortFunc(list, func(a, c Entry) int {
+			if a.Dir != c.Dir {
+				if a.Dir {
+					return -1
+				}
+				return 1
+			}
+			return strings.Compare(a.Name, c.Name)
+		})
+		return list, nil
+	})
+}
+
+// Open opens the regular file at l for reading. The caller closes it.
+func (b *Browser) Open(l Location) (*os.File, error) {
+	return withRoot(l, func(r *os.Root) (*os.File, error) {
+		f, err := r.Open(l.Rel)
+		if err != nil {
+			return nil, err
+		}
+		if info, err := f.Stat(); err != nil || info.IsDir() {
+			f.Close()
+			if err == nil {
+				err = ErrIsDir
+			}
+			return nil, err
+		}
+		return f, nil
+	})
+}
+
+// Mkdir creates the directory at l, including missing parents.
+func (b *Browser) Mkdir(l Location) (Entry, error) {
+	return withRoot(l, func(r *os.Root) (Entry, error) {
+		if info, err := r.Stat(l.Rel); err == nil {
+			if !info.IsDir() {
+				return Entry{}, ErrExists
+			}
+		} else if err := r.MkdirAll(l.Rel, 0755); err != nil {
+			return Entry{}, err
+		}
+		info, err := r.Lstat(l.Rel)
+		if err != nil {
+			return Entry{}, err
+		}
+		return entry(r, l, info), nil
+	})
+}
+
+// Save writes src to a file called name in the directory dir. The data goes
+// to a temporary file that is renamed into place, so readers never see a
+// partial file. An existing file keeps its permissions; without overwrite it
+// is ErrExists.
+func (b *Browser) Save(dir Location, name string, src io.Reader, overwrite bool) (Entry, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return Entry{}, ErrInvalidName
+	}
+	target := Location{dir.Root, filepath.Join(dir.Rel, name)}
+	return withRoot(dir, func(r *os.Root) (Entry, error) {
+		if info, err := r.Stat(dir.Rel); err != nil {
+			return Entry{}, err
+		} else if !info.IsDir() {
+			return Entry{}, ErrNotDir
+		}
+		perm := fs.FileMode(0644)
+		if existing, err := r.Stat(target.Rel); err == nil {
+			if !overwrite {
+				return Entry{}, ErrExists
+			}
+			if existing.IsDir() {
+				return Entry{}, ErrIsDir
+			}
+			perm = existing.Mode().Perm()
+		}
+		if err := writeAtomic(r, target.Rel, src, perm); err != nil {
+			return Entry{}, err
+		}
+		info, err := r.Lstat(target.Rel)
+		if err != nil {
+			return Entry{}, err
+		}
+		return entry(r, target, info), nil
+	})
+}
+
+// writeAtomic copies src to a temp file next to rel and renames it over rel.
+// The temp file is created with perm, so the result has exactly those
+// permissions regardless of the umask.
+func writeAtomic(r *os.Root, rel string, src io.Reader, perm fs.FileMode) error {
+	tmp := filepath.Join(filepath.Dir(rel), "."+filepath.Base(rel)+".tmp-"+strconv.FormatInt(time.Now().UnixNano(), 36))
+	f, err := r.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, src)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = r.Rename(tmp, rel)
+	}
+	if err != nil {
+		_ = r.Remove(tmp)
+	}
+	return err
+}
+
+// Rename moves from to to. Both must be in the same root and to must not
+// exist.
+func (b *Browser) Rename(from, to Location) (Entry, error) {
+	if from.Root != to.Root {
+		return Entry{}, ErrOutsideRoots
+	}
+	if from.Rel == "." || to.Rel == "." {
+		return Entry{}, ErrInvalidName
+	}
+	return withRoot(from, func(r *os.Root) (Entry, error) {
+		if _, err := r.Lstat(to.Rel); err == nil {
+			return Entry{}, ErrExists
+		}
+		if err := r.Rename(from.Rel, to.Rel); err != nil {
+			return Entry{}, err
+		}
+		info, err := r.Lstat(to.Rel)
+		if err != nil {
+			return Entry{}, err
+		}
+		return entry(r, to, info), nil
+	})
+}
+
+// Remove deletes the file or empty directory at l, or with recursive any
+// directory. A root itself cannot be removed.
+func (b *Browser) Remove(l Location, recursive bool) error {
+	if l.Rel == "." {
+		return ErrInvalidName
+	}
+	_, err := withRoot(l, func(r *os.Root) (struct{}, error) {
+		if _, err := r.Lstat(l.Rel); err != nil {
+			return struct{}{}, err
+		}
+		if recursive {
+			return struct{}{}, r.RemoveAll(l.Rel)
+		}
+		return struct{}{}, r.Remove(l.Rel)
+	})
+	return err
+}
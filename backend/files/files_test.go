@@ -0,0 +1,114 @@
+package files_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"web-terminal/files"
+)
+
+func newBrowser(t *testing.T) (*files.Browser, string) {
+	t.Helper()
+	root := t.TempDir()
+	b, err := files.New([]string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, root
+}
+
+func TestResolveStaysInsideRoots(t *testing.T) {
+	b, root := newBrowser(t)
+
+	loc, err := b.Resolve(root+"/src", "../docs/a.txt")
+	if err != nil || loc.Rel != "docs/a.txt" {
+		t.Fatalf("unexpected resolve: %+v %v", loc, err)
+	}
+	for _, p := range []string{"..", "../../etc/passwd", "/etc/passwd"} {
+		if _, err := b.Resolve(root, p); !errors.Is(err, files.ErrOutsideRoots) {
+			t.Fatalf("%q: expected ErrOutsideRoots, got %v", p, err)
+		}
+	}
+	if _, err := b.Resolve("/", ""); !errors.Is(err, files.ErrOutsideRoots) {
+		t.Fatalf("expected a cwd outside the roots to be rejected, got %v", err)
+	}
+}
+
+func TestSymlinkEscapeRejected(t *testing.T) {
+	b, root := newBrowser(t)
+	outside := t.TempDir()
+	os.WriteFile(outside+"/secret", []byte("x"), 0600)
+	if err := os.Symlink(outside, root+"/link"); err != nil {
+		t.Skip("symlinks unsupported:", err)
+	}
+
+	loc, err := b.Resolve(root, "link/secret")
+	if err != nil {
+		t.Fatalf("lexical resolve should succeed: %v", err)
+	}
+	if _, err := b.Open(loc); !errors.Is(err, files.ErrOutsideRoots) {
+		t.Fatalf("expected ErrOutsideRoots through symlink, got %v", err)
+	}
+}
+
+func TestSaveListRenameRemove(t *testing.T) {
+	b, root := newBrowser(t)
+	dir, _ := b.Resolve(root, "sub")
+	if _, err := b.Mkdir(dir); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if _, err := b.Save(dir, "a.txt", strings.NewReader("hello"), false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := b.Save(dir, "a.txt", strings.NewReader("again"), false); !errors.Is(err, files.ErrExists) {
+		t.Fatalf("expected ErrExists, got %v", err)
+	}
+	if _, err := b.Save(dir, "../b.txt", strings.NewReader("x"), false); !errors.Is(err, files.ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+	os.Chmod(root+"/sub/a.txt", 0640)
+	if _, err := b.Save(dir, "a.txt", strings.NewReader("replaced"), true); err != nil {
+		t.Fatalf("Save overwrite: %v", err)
+	}
+	if info, _ := os.Stat(root + "/sub/a.txt"); info.Mode().Perm() != 0640 {
+		t.Fatalf("overwrite lost permissions: %v", info.Mode())
+	}
+
+	b.Mkdir(files.Location{Root: root, Rel: "sub/inner"})
+	entries, err := b.List(dir)
+	if err != nil || len(entries) != 2 || !entries[0].Dir || entries[1].Name != "a.txt" {
+		t.Fatalf("unexpected listing: %+v %v", entries, err)
+	}
+
+	from := files.Location{Root: root, Rel: "sub/a.txt"}
+	to := files.Location{Root: root, Rel: "b.txt"}
+	if _, err := b.Rename(from, to); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	f, err := b.Open(to)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "replaced" {
+		t.Fatalf("unexpected content %q", data)
+	}
+
+	if err := b.Remove(dir, false); err == nil {
+		t.Fatal("expected non-recursive remove of a non-empty directory to fail")
+	}
+	if err := b.Remove(dir, true); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := b.Remove(files.Location{Root: root, Rel: "."}, true); err == nil {
+		t.Fatal("expected removing the root to fail")
+	}
+	if _, err := os.Stat(filepath.Join(root, "sub")); !os.IsNotExist(err) {
+		t.Fatalf("expected sub to be gone, got %v", err)
+	}
+}
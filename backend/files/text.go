@@ -0,0 +1,169 @@
+package files
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unicode/utf8"
+)
+
+// MaxTextBytes is the largest file ReadText and WriteText handle.
+const MaxTextBytes = 4 << 20
+
+// maxLinks bounds how many symbolic links WriteText follows.
+const maxLinks = 8
+
+var (
+	ErrTooLarge        = errors.New("file is too large to edit")
+	ErrNotText         = errors.New("file is not UTF-8 text")
+	ErrVersionConflict = errors.New("file was modified")
+)
+
+// Text is the content of a text file together with its version.
+type Text struct {
+	Entry
+	Content []byte `json:"-"`
+	ETag    string `json:"etag"` // changes whenever the mtime or content does
+}
+
+// textETag derives a strong ETag from a file's mtime and content.
+func textETag(info fs.FileInfo, data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + strconv.FormatInt(info.ModTime().UnixNano(), 36) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// readText reads the file at rel, following symlinks, and returns it with its
+// Stat info.
+func readText(r *os.Root, rel string) ([]byte, fs.FileInfo, error) {
+	f, err := r.Open(rel)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		return nil, nil, ErrIsDir
+	}
+	if info.Size() > MaxTextBytes {
+		return nil, nil, ErrTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(f, MaxTextBytes+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) > MaxTextBytes {
+		return nil, nil, ErrTooLarge
+	}
+	return data, info, nil
+}
+
+// isText reports whether data looks like something a text editor can
+// round-trip unchanged.
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
+
+// ReadText returns the content and ETag of the text file at l.
+func (b *Browser) ReadText(l Location) (Text, error) {
+	return withRoot(l, func(r *os.Root) (Text, error) {
+		data, info, err := readText(r, l.Rel)
+		if err != nil {
+			return Text{}, err
+		}
+		if !isText(data) {
+			return Text{}, ErrNotText
+		}
+		linfo, err := r.Lstat(l.Rel)
+		if err != nil {
+			return Text{}, err
+		}
+		return Text{Entry: entry(r, l, linfo), Content: data, ETag: textETag(info, data)}, nil
+	})
+}
+
+// WriteText replaces the content of the text file at l, creating it if
+// needed. Unless ifMatch is empty or "*", it must equal the file's current
+// ETag, otherwise ErrVersionConflict is returned; a file that does not exist
+// yet never matches. The write is atomic and keeps the file's permissions.
+// A symbolic link is followed, so the file it points at is updated rather
+// than replaced by a regular file.
+func (b *Browser) WriteText(l Location, content []byte, ifMatch string) (Text, error) {
+	if len(content) > MaxTextBytes {
+		return Text{}, ErrTooLarge
+	}
+	if l.Rel == "." {
+		return Text{}, ErrIsDir
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	l, err := b.followLinks(l)
+	if err != nil {
+		return Text{}, err
+	}
+	return withRoot(l, func(r *os.Root) (Text, error) {
+		perm := fs.FileMode(0644)
+		current, info, err := readText(r, l.Rel)
+		switch {
+		case err == nil:
+			perm = info.Mode().Perm()
+			if ifMatch != "" && ifMatch != "*" && textETag(info, current) != ifMatch {
+				return Text{}, ErrVersionConflict
+			}
+		case errors.Is(err, fs.ErrNotExist):
+			if ifMatch != "" && ifMatch != "*" {
+				return Text{}, ErrVersionConflict
+			}
+		case errors.Is(err, ErrTooLarge):
+			// Too large to compare, so only an unconditional write may
+			// replace it.
+			if ifMatch != "" && ifMatch != "*" {
+				return Text{}, ErrVersionConflict
+			}
+			if info, err := r.Stat(l.Rel); err == nil {
+				perm = info.Mode().Perm()
+			}
+		default:
+			return Text{}, err
+		}
+
+		if err := writeAtomic(r, l.Rel, bytes.NewReader(content), perm); err != nil {
+			return Text{}, err
+		}
+		info, err = r.Lstat(l.Rel)
+		if err != nil {
+			return Text{}, err
+		}
+		return Text{Entry: entry(r, l, info), Content: content, ETag: textETag(info, content)}, nil
+	})
+}
+
+// followLinks resolves l through symbolic links, keeping every hop inside
+// the roots.
+func (b *Browser) followLinks(l Location) (Location, error) {
+	for range maxLinks {
+		target, err := withRoot(l, func(r *os.Root) (string, error) {
+			info, err := r.Lstat(l.Rel)
+			if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+				return "", nil
+			}
+			return r.Readlink(l.Rel)
+		})
+		if err != nil || target == "" {
+			return l, err
+		}
+		if l, err = b.Resolve(filepath.Dir(l.Abs()), target); err != nil {
+			return Location{}, err
+		}
+	}
+	return Location{}, errors.New("too many levels of symbolic links")
+}
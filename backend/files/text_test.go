@@ -0,0 +1,74 @@
+package files_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"web-terminal/files"
+)
+
+func TestWriteTextConflictAndPermissions(t *testing.T) {
+	b, root := newBrowser(t)
+	os.WriteFile(root+"/app.conf", []byte("port = 80\n"), 0600)
+	loc, _ := b.Resolve(root, "app.conf")
+
+	text, err := b.ReadText(loc)
+	if err != nil || string(text.Content) != "port = 80\n" || text.ETag == "" {
+		t.Fatalf("ReadText: %+v %v", text, err)
+	}
+
+	saved, err := b.WriteText(loc, []byte("port = 8080\n"), text.ETag)
+	if err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	if info, _ := os.Stat(root + "/app.conf"); info.Mode().Perm() != 0600 {
+		t.Fatalf("write lost permissions: %v", info.Mode())
+	}
+	if saved.ETag == text.ETag {
+		t.Fatal("expected a new ETag after writing")
+	}
+
+	// Someone else edits the file; the stale ETag no longer matches.
+	os.WriteFile(root+"/app.conf", []byte("port = 9090\n"), 0600)
+	os.Chtimes(root+"/app.conf", time.Time{}, time.Now().Add(time.Second))
+	if _, err := b.WriteText(loc, []byte("mine"), saved.ETag); !errors.Is(err, files.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+
+	missing, _ := b.Resolve(root, "new.conf")
+	if _, err := b.WriteText(missing, []byte("x"), saved.ETag); !errors.Is(err, files.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict for a missing file, got %v", err)
+	}
+	if _, err := b.WriteText(missing, []byte("x"), ""); err != nil {
+		t.Fatalf("unconditional create: %v", err)
+	}
+}
+
+func TestWriteTextFollowsSymlink(t *testing.T) {
+	b, root := newBrowser(t)
+	os.WriteFile(root+"/real", []byte("a"), 0644)
+	if err := os.Symlink("real", root+"/link"); err != nil {
+		t.Skip("symlinks unsupported:", err)
+	}
+	loc, _ := b.Resolve(root, "link")
+	if _, err := b.WriteText(loc, []byte("b"), ""); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	if info, _ := os.Lstat(root + "/link"); info.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("symlink was replaced by a regular file")
+	}
+	if data, _ := os.ReadFile(root + "/real"); string(data) != "b" {
+		t.Fatalf("expected the link target to be updated, got %q", data)
+	}
+}
+
+func TestReadTextRejectsBinary(t *testing.T) {
+	b, root := newBrowser(t)
+	os.WriteFile(root+"/blob", []byte{0x7f, 'E', 'L', 'F', 0}, 0644)
+	loc, _ := b.Resolve(root, "blob")
+	if _, err := b.ReadText(loc); !errors.Is(err, files.ErrNotText) {
+		t.Fatalf("expected ErrNotText, got %v", err)
+	}
+}
@@ -7,4 +7,11 @@ require (
 	github.com/go-chi/chi/v5 v5.1.0
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.3
+	github.com/yuin/goldmark v1.8.2
+	go.yaml.in/yaml/v3 v3.0.4
+)
+
+require (
+	golang.org/x/crypto v0.54.0
+	golang.org/x/sys v0.47.0 // indirect
 )
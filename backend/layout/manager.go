@@ -0,0 +1,163 @@
+package layout
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"web-terminal/internal/atomicfile"
+)
+
+// Manager persists layouts and tells subscribers about changes.
+type Manager struct {
+	mu       sync.RWMutex
+	filePath string
+	store    LayoutStore
+	onChange []func(id string)
+}
+
+// NewManager loads the layout store from filePath, or starts empty if the
+// file does not exist.
+func NewManager(filePath string) (*Manager, error) {
+	m := &Manager{filePath: filePath, store: LayoutStore{Layouts: []Layout{}}}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return m, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m.store); err != nil {
+		return nil, err
+	}
+	if m.store.Layouts == nil {
+		m.store.Layouts = []Layout{}
+	}
+	return m, nil
+}
+
+// OnChange registers fn to be called with the ID of every layout that is
+// created, updated or deleted. fn runs with the manager locked, so it must
+// not block or call back into m.
+func (m *Manager) OnChange(fn func(id string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = append(m.onChange, fn)
+}
+
+// List returns every layout.
+func (m *Manager) List() []Layout {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.store.Layouts)
+}
+
+// Get returns the layout with the given ID.
+func (m *Manager) Get(id string) (Layout, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if i := m.indexOf(id); i >= 0 {
+		return m.store.Layouts[i], nil
+	}
+	return Layout{}, ErrNotFound
+}
+
+// Create stores l under a new ID.
+func (m *Manager) Create(l Layout) (Layout, error) {
+	l.ID = uuid.New().String()
+	l.Revision = 0
+	if err := prepare(&l); err != nil {
+		return Layout{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	next := append(slices.Clone(m.store.Layouts), l)
+	if err := m.commit(next, l.ID); err != nil {
+		return Layout{}, err
+	}
+	return l, nil
+}
+
+// Update replaces layout id with l if its revision is still ifRevision, or
+// unconditionally with AnyRevision.
+func (m *Manager) Update(id string, l Layout, ifRevision int64) (Layout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.indexOf(id)
+	if i < 0 {
+		return Layout{}, ErrNotFound
+	}
+	cur := m.store.Layouts[i]
+	if ifRevision != AnyRevision && ifRevision != cur.Revision {
+		return Layout{}, ErrRevisionConflict
+	}
+	l.ID, l.Revision = id, cur.Revision
+	if err := prepare(&l); err != nil {
+		return Layout{}, err
+	}
+	next := slices.Clone(m.store.Layouts)
+	next[i] = l
+	if err := m.commit(next, id); err != nil {
+		return Layout{}, err
+	}
+	return l, nil
+}
+
+// Delete removes layout id.
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.indexOf(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	next := slices.Delete(slices.Clone(m.store.Layouts), i, i+1)
+	return m.commit(next, id)
+}
+
+// prepare validates l, assigns missing pane IDs and stamps a new revision.
+func prepare(l *Layout) error {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+	assignPanes(&l.Root)
+	l.Revision++
+	l.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func assignPanes(n *Node) {
+	if n.Split == "" {
+		if n.Pane == "" {
+			n.Pane = uuid.New().String()[:8]
+		}
+		return
+	}
+	for i := range n.Children {
+		assignPanes(&n.Children[i])
+	}
+}
+
+// commit persists layouts and notifies subscribers that id changed. m.mu
+// must be held.
+func (m *Manager) commit(layouts []Layout, id string) error {
+	if err := atomicfile.WriteJSON(m.filePath, LayoutStore{Layouts: layouts}); err != nil {
+		return err
+	}
+	m.store.Layouts = layouts
+	for _, fn := range m.onChange {
+		fn(id)
+	}
+	return nil
+}
+
+// indexOf returns the position of layout id, or -1. m.mu must be held.
+func (m *Manager) indexOf(id string) int {
+	return slices.IndexFunc(m.store.Layouts, func(l Layout) bool { return l.ID == id })
+}
@@ -0,0 +1,107 @@
+package layout_test
+
+import (
+	"errors"
+	"testing"
+
+	"web-terminal/layout"
+)
+
+func twoPanes() layout.Layout {
+	return layout.Layout{
+		Name: "dev",
+		Root: layout.Node{
+			Split:    layout.SplitHorizontal,
+			Sizes:    []float64{2, 1},
+			Children: []layout.Node{{Session: "s1"}, {Session: "s2"}},
+		},
+	}
+}
+
+func TestCreateAssignsPanesAndPersists(t *testing.T) {
+	path := t.TempDir() + "/layouts.json"
+	m, err := layout.NewManager(path)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	var changed []string
+	m.OnChange(func(id string) { changed = append(changed, id) })
+
+	l, err := m.Create(twoPanes())
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if l.ID == "" || l.Revision != 1 {
+		t.Fatalf("expected an ID and revision 1, got %+v", l)
+	}
+	panes := l.Panes()
+	if len(panes) != 2 || panes[0].Pane == "" || panes[0].Pane == panes[1].Pane {
+		t.Fatalf("expected two distinct pane IDs, got %+v", panes)
+	}
+	if len(changed) != 1 || changed[0] != l.ID {
+		t.Fatalf("expected one change for %s, got %v", l.ID, changed)
+	}
+
+	reloaded, err := layout.NewManager(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	got, err := reloaded.Get(l.ID)
+	if err != nil {
+		t.Fatalf("Get after reload: %v", err)
+	}
+	if got.Panes()[1].Pane != panes[1].Pane || got.Root.Sizes[0] != 2 {
+		t.Fatalf("layout not persisted: %+v", got)
+	}
+}
+
+func TestUpdateRevisionConflict(t *testing.T) {
+	m, _ := layout.NewManager(t.TempDir() + "/layouts.json")
+	l, _ := m.Create(twoPanes())
+
+	l.Focus = l.Panes()[1].Pane
+	saved, err := m.Update(l.ID, l, l.Revision)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if saved.Revision != 2 || saved.Focus != l.Focus {
+		t.Fatalf("unexpected update result: %+v", saved)
+	}
+	if _, err := m.Update(l.ID, l, 1); !errors.Is(err, layout.ErrRevisionConflict) {
+		t.Fatalf("expected ErrRevisionConflict, got %v", err)
+	}
+	if _, err := m.Update(l.ID, l, layout.AnyRevision); err != nil {
+		t.Fatalf("unconditional Update: %v", err)
+	}
+	if err := m.Delete(l.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := m.Get(l.ID); !errors.Is(err, layout.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]func(l *layout.Layout){
+		"empty name":     func(l *layout.Layout) { l.Name = "" },
+		"unknown split":  func(l *layout.Layout) { l.Root.Split = "diagonal" },
+		"one child":      func(l *layout.Layout) { l.Root.Children = l.Root.Children[:1]; l.Root.Sizes = nil },
+		"size mismatch":  func(l *layout.Layout) { l.Root.Sizes = []float64{1} },
+		"zero size":      func(l *layout.Layout) { l.Root.Sizes = []float64{1, 0} },
+		"no session":     func(l *layout.Layout) { l.Root.Children[0].Session = "" },
+		"duplicate pane": func(l *layout.Layout) { l.Root.Children[0].Pane = "a"; l.Root.Children[1].Pane = "a" },
+		"unknown focus":  func(l *layout.Layout) { l.Focus = "nope" },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := twoPanes()
+			mutate(&l)
+			if err := l.Validate(); !errors.Is(err, layout.ErrInvalid) {
+				t.Fatalf("expected ErrInvalid, got %v", err)
+			}
+		})
+	}
+	if err := twoPanes().Validate(); err != nil {
+		t.Fatalf("valid layout rejected: %v", err)
+	}
+}
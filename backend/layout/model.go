@@ -0,0 +1,144 @@
+// Package layout stores the pane arrangements of multi-pane session pages:
+// trees of horizontal and vertical splits whose leaves show sessions.
+package layout
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Split directions.
+const (
+	SplitHorizontal = "horizontal" // children side by side
+	SplitVertical   = "vertical"   // children stacked
+)
+
+// Limits enforced by Validate.
+const (
+	MaxPanes   = 16
+	MaxDepth   = 8
+	MaxNameLen = 100
+)
+
+// AnyRevision disables the revision check in Update.
+const AnyRevision int64 = -1
+
+var (
+	ErrNotFound         = errors.New("layout not found")
+	ErrInvalid          = errors.New("invalid layout")
+	ErrRevisionConflict = errors.New("layout revision conflict")
+)
+
+// Node is a split, with Split and Children set, or a pane showing a session.
+type Node struct {
+	Split    string `json:"split,omitempty"`
+	Children []Node `json:"children,omitempty"`
+	// Sizes are the children's shares of the split. Empty means equal
+	// shares.
+	Sizes []float64 `json:"sizes,omitempty"`
+
+	// Pane identifies a pane within its layout and is its channel on the
+	// layout's WebSocket. Left empty, it is assigned when the layout is
+	// saved.
+	Pane    string `json:"pane,omitempty"`
+	Session string `json:"session,omitempty"` // session ID
+}
+
+// Layout is a named pane arrangement.
+type Layout struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Root      Node      `json:"root"`
+	Focus     string    `json:"focus,omitempty"` // pane with keyboard focus
+	Revision  int64     `json:"revision"`        // increments on every save
+	UpdatedAt time.Time `json:"updatedAt,omitzero"`
+}
+
+// LayoutStore is the full persistent state.
+type LayoutStore struct {
+	Layouts []Layout `json:"layouts"`
+}
+
+// Panes returns the layout's panes in depth-first order.
+func (l Layout) Panes() []Node {
+	var panes []Node
+	var walk func(n Node)
+	walk = func(n Node) {
+		if n.Split == "" {
+			panes = append(panes, n)
+			return
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(l.Root)
+	return panes
+}
+
+// Validate checks the layout's name and tree: splits have a known direction,
+// at least two children and matching positive sizes; panes name a session
+// and have unique IDs; the tree stays within MaxDepth and MaxPanes. Pane IDs
+// may still be empty.
+func (l Layout) Validate() error {
+	if l.Name == "" || len(l.Name) > MaxNameLen {
+		return fmt.Errorf("%w: name must be 1-%d characters", ErrInvalid, MaxNameLen)
+	}
+	panes := 0
+	seen := map[string]bool{}
+	var check func(n Node, depth int) error
+	check = func(n Node, depth int) error {
+		if depth > MaxDepth {
+			return fmt.Errorf("%w: splits nest deeper than %d", ErrInvalid, MaxDepth)
+		}
+		if n.Split == "" {
+			if len(n.Children) > 0 {
+				return fmt.Errorf("%w: pane %q has children", ErrInvalid, n.Pane)
+			}
+			if n.Session == "" {
+				return fmt.Errorf("%w: pane %q has no session", ErrInvalid, n.Pane)
+			}
+			if n.Pane != "" {
+				if seen[n.Pane] {
+					return fmt.Errorf("%w: duplicate pane %q", ErrInvalid, n.Pane)
+				}
+				seen[n.Pane] = true
+			}
+			if panes++; panes > MaxPanes {
+				return fmt.Errorf("%w: at most %d panes are allowed", ErrInvalid, MaxPanes)
+			}
+			return nil
+		}
+		if n.Split != SplitHorizontal && n.Split != SplitVertical {
+			return fmt.Errorf("%w: unknown split %q", ErrInvalid, n.Split)
+		}
+		if len(n.Children) < 2 {
+			return fmt.Errorf("%w: a split needs at least two children", ErrInvalid)
+		}
+		if len(n.Sizes) > 0 {
+			if len(n.Sizes) != len(n.Children) {
+				return fmt.Errorf("%w: split has %d sizes for %d children", ErrInvalid, len(n.Sizes), len(n.Children))
+			}
+			for _, s := range n.Sizes {
+				if !(s > 0) || math.IsInf(s, 0) {
+					return fmt.Errorf("%w: split sizes must be positive", ErrInvalid)
+				}
+			}
+		}
+		for _, c := range n.Children {
+			if err := check(c, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := check(l.Root, 0); err != nil {
+		return err
+	}
+	if l.Focus != "" && !seen[l.Focus] {
+		return fmt.Errorf("%w: focus %q is not a pane", ErrInvalid, l.Focus)
+	}
+	return nil
+}
@@ -1,17 +1,35 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"time"
 
 	"web-terminal/api"
+	"web-terminal/audit"
+	"web-terminal/events"
+	"web-terminal/files"
+	"web-terminal/layout"
+	"web-terminal/notebook"
+	"web-terminal/notes"
 	"web-terminal/preset"
 	"web-terminal/session"
+	"web-terminal/sshkeys"
+	"web-terminal/template"
+	"web-terminal/trigger"
+	"web-terminal/webhook"
+	"web-terminal/workspace"
 )
 
+// presetPollInterval is how often the presets file is checked for edits made
+// outside the server.
+const presetPollInterval = 2 * time.Second
+
 func main() {
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -26,9 +44,177 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to load presets: %v", err)
 	}
+	go pm.Watch(context.Background(), presetPollInterval)
+
+	templateFile := os.Getenv("TEMPLATE_FILE")
+	if templateFile == "" {
+		templateFile = "/data/templates.json"
+	}
+	tm, err := template.NewManager(templateFile)
+	if err != nil {
+		log.Fatalf("failed to load templates: %v", err)
+	}
 
 	manager := session.NewManager()
-	router := api.RegisterRoutes(manager, pm, staticFiles)
+
+	workspaceFile := os.Getenv("WORKSPACE_FILE")
+	if workspaceFile == "" {
+		workspaceFile = "/data/workspaces.json"
+	}
+	wm, err := workspace.NewManager(workspaceFile, manager)
+	if err != nil {
+		log.Fatalf("failed to load workspaces: %v", err)
+	}
+
+	layoutFile := os.Getenv("LAYOUT_FILE")
+	if layoutFile == "" {
+		layoutFile = "/data/layouts.json"
+	}
+	lm, err := layout.NewManager(layoutFile)
+	if err != nil {
+		log.Fatalf("failed to load layouts: %v", err)
+	}
+
+	webhookFile := os.Getenv("WEBHOOK_FILE")
+	if webhookFile == "" {
+		webhookFile = "/data/webhooks.json"
+	}
+	deadLetterFile := os.Getenv("WEBHOOK_DEAD_LETTER_FILE")
+	if deadLetterFile == "" {
+		deadLetterFile = "/data/webhooks-dead-letter.jsonl"
+	}
+	whm, err := webhook.NewManager(webhookFile, deadLetterFile)
+	if err != nil {
+		log.Fatalf("failed to load webhooks: %v", err)
+	}
+	if url := os.Getenv("NOTIFY_WEBHOOK_URL"); url != "" {
+		hook := webhook.Webhook{Name: "NOTIFY_WEBHOOK_URL", URL: url, Events: []string{"notification"}}
+		if _, err := whm.Ensure(hook); err != nil {
+			log.Fatalf("failed to add NOTIFY_WEBHOOK_URL webhook: %v", err)
+		}
+	}
+
+	triggerFile := os.Getenv("TRIGGER_FILE")
+	if triggerFile == "" {
+		triggerFile = "/data/triggers.json"
+	}
+	trm, err := trigger.NewManager(triggerFile, manager, whm)
+	if err != nil {
+		log.Fatalf("failed to load triggers: %v", err)
+	}
+
+	notesFile := os.Getenv("NOTES_FILE")
+	if notesFile == "" {
+		notesFile = "/data/notes.json"
+	}
+	nm, err := notes.NewManager(notesFile)
+	if err != nil {
+		log.Fatalf("failed to load notes: %v", err)
+	}
+	// Sessions do not survive a restart; detach kept notes and drop the rest.
+	if err := nm.Prune(func(id string) bool { _, ok := manager.Get(id); return ok }); err != nil {
+		log.Fatalf("failed to prune notes: %v", err)
+	}
+
+	notebookDir := os.Getenv("NOTEBOOK_DIR")
+	if notebookDir == "" {
+		notebookDir = "/data/notebooks"
+	}
+	nbm, err := notebook.NewManager(notebookDir)
+	if err != nil {
+		log.Fatalf("failed to open notebooks: %v", err)
+	}
+
+	fileRoots := filepath.SplitList(os.Getenv("FILE_ROOTS"))
+	if len(fileRoots) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			log.Fatalf("FILE_ROOTS not set and no home directory: %v", err)
+		}
+		fileRoots = []string{home}
+	}
+	fb, err := files.New(fileRoots)
+	if err != nil {
+		log.Fatalf("failed to configure file roots: %v", err)
+	}
+
+	knownHostsFile := os.Getenv("KNOWN_HOSTS_FILE")
+	if knownHostsFile == "" {
+		knownHostsFile = "/data/known_hosts"
+	}
+	knownHosts := sshkeys.NewKnownHosts(knownHostsFile)
+	keysFile := os.Getenv("SSH_KEYS_FILE")
+	if keysFile == "" {
+		keysFile = "/data/ssh_keys.json"
+	}
+	keys, err := sshkeys.NewKeyStore(keysFile, os.Getenv("SSH_KEY_PASSPHRASE"))
+	if err != nil {
+		log.Fatalf("failed to open ssh keys: %v", err)
+	}
+	if keys.Locked() {
+		log.Printf("SSH_KEY_PASSPHRASE not set; stored ssh keys cannot be added or used")
+	}
+	manager.SetHostKeys(knownHosts)
+	manager.SetKeyStore(keys)
+
+	if v := os.Getenv("NOTIFY_MIN_COMMAND_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs < 0 {
+			log.Fatalf("invalid NOTIFY_MIN_COMMAND_SECONDS %q", v)
+		}
+		manager.SetNotifyDefaults(session.NotifySettings{MinCommandSeconds: secs})
+	}
+
+	if v := os.Getenv("SHELL_INTEGRATION"); v != "0" && v != "false" {
+		if err := manager.SetShellIntegration(true); err != nil {
+			log.Printf("shell integration disabled: %v", err)
+		}
+	}
+
+	auditFile := os.Getenv("AUDIT_FILE")
+	if auditFile == "" {
+		auditFile = "/data/audit.jsonl"
+	}
+	auditMaxBytes := int64(audit.DefaultMaxBytes)
+	if v := os.Getenv("AUDIT_MAX_MB"); v != "" {
+		mb, err := strconv.Atoi(v)
+		if err != nil || mb < 1 {
+			log.Fatalf("invalid AUDIT_MAX_MB %q", v)
+		}
+		auditMaxBytes = int64(mb) << 20
+	}
+	auditKeep := audit.DefaultKeep
+	if v := os.Getenv("AUDIT_KEEP"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("invalid AUDIT_KEEP %q", v)
+		}
+		auditKeep = n
+	}
+	al, err := audit.NewLogger(auditFile, auditMaxBytes, auditKeep)
+	if err != nil {
+		log.Fatalf("failed to open audit log: %v", err)
+	}
+	auditInput := os.Getenv("AUDIT_INPUT")
+
+	router := api.RegisterRoutes(api.Services{
+		Sessions:   manager,
+		Presets:    pm,
+		Templates:  tm,
+		Workspaces: wm,
+		Layouts:    lm,
+		Triggers:   trm,
+		Webhooks:   whm,
+		Notes:      nm,
+		Notebooks:  nbm,
+		Files:      fb,
+		KnownHosts: knownHosts,
+		Keys:       keys,
+		Events:     events.NewBus(),
+		Audit:      al,
+
+		AuditInput: auditInput == "1" || auditInput == "true",
+	}, staticFiles)
 
 	addr := fmt.Sprintf(":%s", port)
 	log.Printf("web-terminal listening on %s", addr)
@@ -0,0 +1,201 @@
+package notebook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"web-terminal/internal/atomicfile"
+)
+
+// docExt is the file extension of stored documents.
+const docExt = ".md"
+
+// Manager reads and writes notebooks under a root directory. Reads go to disk
+// every time, so edits made outside the server are picked up immediately.
+type Manager struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+// NewManager returns a Manager storing notebooks under dir, creating it if
+// needed.
+func NewManager(dir string) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Manager{dir: dir}, nil
+}
+
+// Notebooks lists the notebooks, sorted by name.
+func (m *Manager) Notebooks() ([]Notebook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil, err
+	}
+	list := []Notebook{}
+	for _, e := range entries {
+		if !e.IsDir() || !ValidName(e.Name()) {
+			continue
+		}
+		docs, err := m.docs(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		nb := Notebook{Name: e.Name(), Docs: len(docs)}
+		for _, d := range docs {
+			if d.UpdatedAt.After(nb.UpdatedAt) {
+				nb.UpdatedAt = d.UpdatedAt
+			}
+		}
+		list = append(list, nb)
+	}
+	return list, nil
+}
+
+// Docs lists the documents in a notebook, sorted by name.
+func (m *Manager) Docs(notebook string) ([]Doc, error) {
+	if !ValidName(notebook) {
+		return nil, ErrInvalidName
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.docs(notebook)
+}
+
+// Read returns a document's content and metadata.
+func (m *Manager) Read(notebook, name string) ([]byte, Doc, error) {
+	path, err := m.path(notebook, name)
+	if err != nil {
+		return nil, Doc{}, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.read(notebook, name, path)
+}
+
+// Write stores a document, creating the notebook if needed. Unless ifMatch
+// is empty or "*", it must equal the document's current ETag, otherwise
+// ErrVersionConflict is returned; a document that does not exist yet never
+// matches.
+func (m *Manager) Write(notebook, name string, content []byte, ifMatch string) (Doc, error) {
+	path, err := m.path(notebook, name)
+	if err != nil {
+		return Doc{}, err
+	}
+	if len(content) > MaxDocBytes {
+		return Doc{}, ErrTooLarge
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ifMatch != "" && ifMatch != "*" {
+		_, current, err := m.read(notebook, name, path)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return Doc{}, err
+		}
+		if current.ETag != ifMatch {
+			return Doc{}, ErrVersionConflict
+		}
+	}
+	if err := atomicfile.Write(path, content, 0600); err != nil {
+		return Doc{}, err
+	}
+	_, doc, err := m.read(notebook, name, path)
+	return doc, err
+}
+
+// Delete removes a document.
+func (m *Manager) Delete(notebook, name string) error {
+	path, err := m.path(notebook, name)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteNotebook removes a notebook and all of its documents.
+func (m *Manager) DeleteNotebook(notebook string) error {
+	if !ValidName(notebook) {
+		return ErrInvalidName
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir := filepath.Join(m.dir, notebook)
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return os.RemoveAll(dir)
+}
+
+// path returns the file a document is stored in.
+func (m *Manager) path(notebook, name string) (string, error) {
+	if !ValidName(notebook) || !ValidName(name) {
+		return "", ErrInvalidName
+	}
+	return filepath.Join(m.dir, notebook, name+docExt), nil
+}
+
+// read loads a document. Caller must hold m.mu.
+func (m *Manager) read(notebook, name, path string) ([]byte, Doc, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, Doc{}, ErrNotFound
+		}
+		return nil, Doc{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, Doc{}, err
+	}
+	sum := sha256.Sum256(data)
+	return data, Doc{
+		Notebook:  notebook,
+		Name:      name,
+		Size:      int64(len(data)),
+		UpdatedAt: info.ModTime(),
+		ETag:      `"` + hex.EncodeToString(sum[:8]) + `"`,
+	}, nil
+}
+
+// docs lists a notebook's documents. Caller must hold m.mu.
+func (m *Manager) docs(notebook string) ([]Doc, error) {
+	entries, err := os.ReadDir(filepath.Join(m.dir, notebook))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	list := []Doc{}
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), docExt)
+		if e.IsDir() || !ok || !ValidName(name) {
+			continue
+		}
+		_, doc, err := m.read(notebook, name, filepath.Join(m.dir, notebook, e.Name()))
+		if err != nil {
+			continue // removed while listing
+		}
+		list = append(list, doc)
+	}
+	slices.SortFunc(list, func(a, b Doc) int { return strings.Compare(a.Name, b.Name) })
+	return list, nil
+}
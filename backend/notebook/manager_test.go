@@ -0,0 +1,121 @@
+package notebook_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"web-terminal/notebook"
+)
+
+func TestWriteReadList(t *testing.T) {
+	dir := t.TempDir()
+	m, err := notebook.NewManager(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := m.Write("ops", "k8s cheatsheet", []byte("# kubectl\n"), "")
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ops", "k8s cheatsheet.md")); err != nil {
+		t.Fatalf("document not stored as a Markdown file: %v", err)
+	}
+
+	content, got, err := m.Read("ops", "k8s cheatsheet")
+	if err != nil || string(content) != "# kubectl\n" || got.ETag != doc.ETag {
+		t.Fatalf("Read = %q %+v %v", content, got, err)
+	}
+
+	m.Write("global", "runbook", []byte("steps"), "")
+	nbs, _ := m.Notebooks()
+	if len(nbs) != 2 || nbs[0].Name != "global" || nbs[1].Docs != 1 {
+		t.Fatalf("unexpected notebooks: %+v", nbs)
+	}
+}
+
+func TestWriteIfMatch(t *testing.T) {
+	m, _ := notebook.NewManager(t.TempDir())
+	first, _ := m.Write("ops", "doc", []byte("v1"), "")
+	second, err := m.Write("ops", "doc", []byte("v2"), first.ETag)
+	if err != nil {
+		t.Fatalf("Write with current ETag: %v", err)
+	}
+	if _, err := m.Write("ops", "doc", []byte("v3"), first.ETag); !errors.Is(err, notebook.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if _, err := m.Write("ops", "new", []byte("x"), second.ETag); !errors.Is(err, notebook.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict for a missing document, got %v", err)
+	}
+}
+
+func TestInvalidNames(t *testing.T) {
+	m, _ := notebook.NewManager(t.TempDir())
+	for _, name := range []string{"", "..", "../etc", "a/b", ".hidden", strings.Repeat("x", 101)} {
+		if _, err := m.Write("ops", name, nil, ""); !errors.Is(err, notebook.ErrInvalidName) {
+			t.Errorf("Write(%q): expected ErrInvalidName, got %v", name, err)
+		}
+		if _, err := m.Docs(name); !errors.Is(err, notebook.ErrInvalidName) {
+			t.Errorf("Docs(%q): expected ErrInvalidName, got %v", name, err)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	m, _ := notebook.NewManager(t.TempDir())
+	m.Write("ops", "a", []byte("a"), "")
+	if err := m.Delete("ops", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete("ops", "a"); !errors.Is(err, notebook.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := m.DeleteNotebook("ops"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Docs("ops"); !errors.Is(err, notebook.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after DeleteNotebook, got %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	m, _ := notebook.NewManager(t.TempDir())
+	m.Write("ops", "deploy", []byte("# Rollback\nhelm rollback api 3\n"), "")
+	m.Write("ops", "postgres", []byte("vacuum analyze\nrollback a transaction with ROLLBACK;\n"), "")
+	m.Write("dev", "git", []byte("git reset --hard\n"), "")
+
+	results, err := m.Search("rollback", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The heading hit outranks two plain-line hits.
+	if len(results) != 2 || results[0].Doc != "deploy" || results[0].Line != 1 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if results[1].Snippet != "rollback a transaction with ROLLBACK;" {
+		t.Fatalf("snippet = %q", results[1].Snippet)
+	}
+
+	if results, _ := m.Search("rollback helm", "ops"); len(results) != 1 {
+		t.Fatalf("expected every term to be required, got %+v", results)
+	}
+	if results, _ := m.Search("git", "ops"); len(results) != 0 {
+		t.Fatalf("expected search limited to notebook, got %+v", results)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	html, err := notebook.RenderHTML([]byte("# Title\n\n| a | b |\n|---|---|\n| 1 | 2 |\n\n<script>alert(1)</script>\n\n[x](javascript:alert(1))\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(html)
+	if !strings.Contains(s, `<h1 id="title">Title</h1>`) || !strings.Contains(s, "<table>") {
+		t.Fatalf("missing heading or table:\n%s", s)
+	}
+	if strings.Contains(s, "<script>") || strings.Contains(s, "javascript:") {
+		t.Fatalf("unsafe HTML passed through:\n%s", s)
+	}
+}
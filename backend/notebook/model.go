@@ -0,0 +1,54 @@
+// Package notebook stores Markdown documents, such as cheat sheets and
+// runbooks, that are not tied to any session. Documents are grouped into
+// notebooks, one directory per notebook, and kept as plain .md files so they
+// can also be edited or synced outside the server.
+package notebook
+
+import (
+	"errors"
+	"regexp"
+	"time"
+)
+
+// Doc describes a stored document.
+type Doc struct {
+	Notebook  string    `json:"notebook"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	ETag      string    `json:"etag"` // changes whenever the content does
+}
+
+// Notebook summarises a notebook.
+type Notebook struct {
+	Name      string    `json:"name"`
+	Docs      int       `json:"docs"`
+	UpdatedAt time.Time `json:"updatedAt,omitzero"` // newest document
+}
+
+// SearchResult is a document matching a search, with the first matching line.
+type SearchResult struct {
+	Notebook string  `json:"notebook"`
+	Doc      string  `json:"doc"`
+	Line     int     `json:"line,omitempty"` // 1-based; 0 if only the name matched
+	Snippet  string  `json:"snippet,omitempty"`
+	Score    float64 `json:"score"`
+}
+
+// MaxDocBytes bounds the size of a single document.
+const MaxDocBytes = 1 << 20
+
+// nameRe restricts notebook and document names to a single safe path element.
+var nameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 ._-]{0,99}$`)
+
+// ValidName reports whether name can be used for a notebook or document.
+func ValidName(name string) bool {
+	return nameRe.MatchString(name)
+}
+
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrInvalidName     = errors.New("invalid name: use letters, digits, space, . _ - (max 100)")
+	ErrTooLarge        = errors.New("document too large")
+	ErrVersionConflict = errors.New("document was modified")
+)
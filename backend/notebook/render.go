@@ -0,0 +1,26 @@
+package notebook
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+)
+
+// markdown renders GitHub-flavoured Markdown. Raw HTML in documents is not
+// passed through and dangerous link schemes are dropped, so the output is
+// safe to embed in the app's pages.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+)
+
+// RenderHTML converts a Markdown document to an HTML fragment.
+func RenderHTML(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert(src, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,119 @@
+package notebook
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSnippet bounds the length of SearchResult.Snippet in runes.
+const maxSnippet = 160
+
+// Search finds documents containing every whitespace-separated term of q
+// (case-insensitive) in their name or content, best match first. An empty
+// notebook searches all notebooks.
+func (m *Manager) Search(q, notebook string) ([]SearchResult, error) {
+	terms := strings.Fields(strings.ToLower(q))
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+	if notebook != "" && !ValidName(notebook) {
+		return nil, ErrInvalidName
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var notebooks []string
+	if notebook != "" {
+		notebooks = []string{notebook}
+	} else {
+		entries, err := os.ReadDir(m.dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() && ValidName(e.Name()) {
+				notebooks = append(notebooks, e.Name())
+			}
+		}
+	}
+
+	results := []SearchResult{}
+	for _, nb := range notebooks {
+		docs, err := m.docs(nb)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range docs {
+			data, err := os.ReadFile(filepath.Join(m.dir, nb, d.Name+docExt))
+			if err != nil {
+				continue
+			}
+			if r, ok := match(terms, d, data); ok {
+				results = append(results, r)
+			}
+		}
+	}
+	slices.SortStableFunc(results, func(a, b SearchResult) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(a.Notebook, b.Notebook); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Doc, b.Doc)
+	})
+	return results, nil
+}
+
+// match scores one document: a term in the name counts 3, in a heading 2 and
+// elsewhere 1 per occurrence. Every term must appear somewhere.
+func match(terms []string, d Doc, data []byte) (SearchResult, bool) {
+	r := SearchResult{Notebook: d.Notebook, Doc: d.Name}
+	name := strings.ToLower(d.Name)
+	found := make([]bool, len(terms))
+	for i, t := range terms {
+		if strings.Contains(name, t) {
+			found[i] = true
+			r.Score += 3
+		}
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), MaxDocBytes+1)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		lower := strings.ToLower(line)
+		weight := 1.0
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			weight = 2
+		}
+		hit := false
+		for i, t := range terms {
+			if c := strings.Count(lower, t); c > 0 {
+				found[i] = true
+				hit = true
+				r.Score += weight * float64(c)
+			}
+		}
+		if hit && r.Line == 0 {
+			r.Line, r.Snippet = n, snippet(line)
+		}
+	}
+	return r, !slices.Contains(found, false)
+}
+
+func snippet(line string) string {
+	line = strings.TrimSpace(line)
+	if utf8.RuneCountInString(line) <= maxSnippet {
+		return line
+	}
+	return string([]rune(line)[:maxSnippet]) + "…"
+}
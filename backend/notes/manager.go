@@ -0,0 +1,207 @@
+package notes
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"web-terminal/internal/atomicfile"
+)
+
+// Manager handles loading, saving, and attaching session notes.
+type Manager struct {
+	mu       sync.RWMutex
+	filePath string
+	store    NotesStore
+}
+
+// NewManager loads the notes store from filePath, or creates an empty store
+// if the file does not exist. Returns an error only on unexpected I/O failures.
+func NewManager(filePath string) (*Manager, error) {
+	m := &Manager{filePath: filePath, store: NotesStore{Notes: []Notes{}}}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return m, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &m.store); err != nil {
+		return nil, err
+	}
+	if m.store.Notes == nil {
+		m.store.Notes = []Notes{}
+	}
+	return m, nil
+}
+
+// Get returns the notes of the session with the given id and name. If the
+// session has none yet but kept notes were left by an earlier session of the
+// same name, those are attached to it first. A session without notes gets
+// empty Notes with Revision 0.
+func (m *Manager) Get(sessionID, sessionName string) (Notes, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, err := m.attach(sessionID, sessionName)
+	if err != nil {
+		return Notes{}, err
+	}
+	if i < 0 {
+		return Notes{SessionID: sessionID, SessionName: sessionName, Tabs: []Tab{}}, nil
+	}
+	return copyNotes(m.store.Notes[i]), nil
+}
+
+// Put replaces the session's notes if their revision equals ifRevision (or
+// ifRevision is AnyRevision), and returns them with the new revision.
+func (m *Manager) Put(sessionID, sessionName string, n Notes, ifRevision int64) (Notes, error) {
+	if n.Tabs == nil {
+		n.Tabs = []Tab{}
+	}
+	if err := n.Validate(); err != nil {
+		return Notes{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, err := m.attach(sessionID, sessionName)
+	if err != nil {
+		return Notes{}, err
+	}
+	var current int64
+	if i >= 0 {
+		current = m.store.Notes[i].Revision
+	}
+	if ifRevision != AnyRevision && ifRevision != current {
+		return Notes{}, ErrRevisionConflict
+	}
+
+	n.SessionID, n.SessionName = sessionID, sessionName
+	n.Revision = current + 1
+	n.UpdatedAt = time.Now()
+	store := copyStore(m.store)
+	if i >= 0 {
+		store.Notes[i] = n
+	} else {
+		store.Notes = append(store.Notes, n)
+	}
+	if err := m.save(store); err != nil {
+		return Notes{}, err
+	}
+	return copyNotes(n), nil
+}
+
+// Delete removes the session's notes. Deleting notes that do not exist is not
+// an error.
+func (m *Manager) Delete(sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.indexOf(sessionID)
+	if i < 0 {
+		return nil
+	}
+	store := copyStore(m.store)
+	store.Notes = slices.Delete(store.Notes, i, i+1)
+	return m.save(store)
+}
+
+// SessionEnded drops the notes of an exited session, or detaches them if they
+// are marked Keep. Older kept notes with the same session name are replaced.
+func (m *Manager) SessionEnded(sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.indexOf(sessionID)
+	if i < 0 {
+		return nil
+	}
+	n := m.store.Notes[i]
+	store := NotesStore{Notes: make([]Notes, 0, len(m.store.Notes))}
+	for _, other := range m.store.Notes {
+		if other.SessionID == sessionID || (n.Keep && other.SessionID == "" && other.SessionName == n.SessionName) {
+			continue
+		}
+		store.Notes = append(store.Notes, other)
+	}
+	if n.Keep {
+		n.SessionID = ""
+		store.Notes = append(store.Notes, n)
+	}
+	return m.save(store)
+}
+
+// Prune ends the notes of every session for which alive returns false, as
+// SessionEnded does. It is used at startup, when no earlier session survives.
+func (m *Manager) Prune(alive func(sessionID string) bool) error {
+	m.mu.RLock()
+	var gone []string
+	for _, n := range m.store.Notes {
+		if n.SessionID != "" && !alive(n.SessionID) {
+			gone = append(gone, n.SessionID)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, id := range gone {
+		if err := m.SessionEnded(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attach returns the index of the session's notes, first reattaching kept
+// notes left under sessionName if the session has none. Returns -1 if there
+// are no notes. Caller must hold m.mu for writing.
+func (m *Manager) attach(sessionID, sessionName string) (int, error) {
+	if i := m.indexOf(sessionID); i >= 0 {
+		return i, nil
+	}
+	for i, n := range m.store.Notes {
+		if n.SessionID == "" && n.SessionName == sessionName {
+			store := copyStore(m.store)
+			store.Notes[i].SessionID = sessionID
+			if err := m.save(store); err != nil {
+				return -1, err
+			}
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// indexOf returns the position of the notes for sessionID, or -1.
+// Caller must hold m.mu.
+func (m *Manager) indexOf(sessionID string) int {
+	for i, n := range m.store.Notes {
+		if n.SessionID == sessionID {
+			return i
+		}
+	}
+	return -1
+}
+
+// save atomically writes store to disk, then makes it current.
+// Caller must hold m.mu for writing.
+func (m *Manager) save(store NotesStore) error {
+	if err := atomicfile.WriteJSON(m.filePath, store); err != nil {
+		return err
+	}
+	m.store = store
+	return nil
+}
+
+func copyNotes(n Notes) Notes {
+	n.Tabs = slices.Clone(n.Tabs)
+	return n
+}
+
+func copyStore(s NotesStore) NotesStore {
+	notes := make([]Notes, len(s.Notes))
+	copy(notes, s.Notes)
+	return NotesStore{Notes: notes}
+}
@@ -0,0 +1,94 @@
+package notes_test
+
+import (
+	"errors"
+	"testing"
+
+	"web-terminal/notes"
+)
+
+func tabs(ids ...string) []notes.Tab {
+	out := make([]notes.Tab, len(ids))
+	for i, id := range ids {
+		out[i] = notes.Tab{ID: id, Name: id, Content: "note " + id}
+	}
+	return out
+}
+
+func TestGetWithoutNotes(t *testing.T) {
+	nm, err := notes.NewManager(t.TempDir() + "/notes.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := nm.Get("s1", "dev")
+	if err != nil || n.Revision != 0 || len(n.Tabs) != 0 || n.SessionName != "dev" {
+		t.Fatalf("unexpected empty notes: %+v, %v", n, err)
+	}
+}
+
+func TestPutRevisionsAndReload(t *testing.T) {
+	path := t.TempDir() + "/notes.json"
+	nm, _ := notes.NewManager(path)
+
+	n, err := nm.Put("s1", "dev", notes.Notes{Tabs: tabs("a"), ActiveTabID: "a"}, 0)
+	if err != nil || n.Revision != 1 {
+		t.Fatalf("first Put: %+v, %v", n, err)
+	}
+	if _, err := nm.Put("s1", "dev", notes.Notes{Tabs: tabs("b")}, 0); !errors.Is(err, notes.ErrRevisionConflict) {
+		t.Fatalf("expected ErrRevisionConflict, got %v", err)
+	}
+	if n, err = nm.Put("s1", "dev", notes.Notes{Tabs: tabs("a", "b")}, 1); err != nil || n.Revision != 2 {
+		t.Fatalf("second Put: %+v, %v", n, err)
+	}
+
+	nm2, _ := notes.NewManager(path)
+	got, _ := nm2.Get("s1", "dev")
+	if got.Revision != 2 || len(got.Tabs) != 2 {
+		t.Fatalf("notes not persisted: %+v", got)
+	}
+}
+
+func TestPutRejectsInvalidTabs(t *testing.T) {
+	nm, _ := notes.NewManager(t.TempDir() + "/notes.json")
+	if _, err := nm.Put("s1", "dev", notes.Notes{Tabs: tabs("a", "a")}, notes.AnyRevision); !errors.Is(err, notes.ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for duplicate tab IDs, got %v", err)
+	}
+}
+
+func TestSessionEndedDropsOrKeeps(t *testing.T) {
+	nm, _ := notes.NewManager(t.TempDir() + "/notes.json")
+	nm.Put("s1", "scratch", notes.Notes{Tabs: tabs("a")}, notes.AnyRevision)
+	nm.Put("s2", "dev", notes.Notes{Tabs: tabs("b"), Keep: true}, notes.AnyRevision)
+
+	nm.SessionEnded("s1")
+	nm.SessionEnded("s2")
+
+	if n, _ := nm.Get("s3", "scratch"); n.Revision != 0 {
+		t.Fatalf("notes without keep should be dropped, got %+v", n)
+	}
+	n, _ := nm.Get("s4", "dev")
+	if n.Revision != 1 || n.SessionID != "s4" || len(n.Tabs) != 1 {
+		t.Fatalf("kept notes not reattached by name: %+v", n)
+	}
+	// Once reattached they belong to s4 and are not offered to another session.
+	if other, _ := nm.Get("s5", "dev"); other.Revision != 0 {
+		t.Fatalf("notes attached twice: %+v", other)
+	}
+}
+
+func TestKeptNotesReplaceOlderOnes(t *testing.T) {
+	nm, _ := notes.NewManager(t.TempDir() + "/notes.json")
+	nm.Put("s1", "dev", notes.Notes{Tabs: tabs("old"), Keep: true}, notes.AnyRevision)
+	nm.SessionEnded("s1")
+	nm.Put("s2", "other", notes.Notes{Tabs: tabs("x")}, notes.AnyRevision)
+	nm.Put("s3", "dev", notes.Notes{Tabs: tabs("new"), Keep: true}, notes.AnyRevision) // reattaches s1's notes first
+
+	nm.Prune(func(id string) bool { return id == "s2" })
+	n, _ := nm.Get("s9", "dev")
+	if len(n.Tabs) != 1 || n.Tabs[0].ID != "new" {
+		t.Fatalf("expected newest kept notes, got %+v", n)
+	}
+	if other, _ := nm.Get("s2", "other"); other.Revision != 1 {
+		t.Fatalf("Prune dropped notes of a live session: %+v", other)
+	}
+}
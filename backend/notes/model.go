@@ -0,0 +1,71 @@
+// Package notes stores the tabbed notes kept alongside each terminal session.
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Tab is one tab of the session note editor.
+type Tab struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Content   string `json:"content"`
+	Readonly  bool   `json:"readonly"`
+	CreatedAt int64  `json:"createdAt"` // Unix milliseconds, set by the browser
+}
+
+// Notes are the tabs belonging to one session. Notes with Keep set outlive
+// their session: they are detached (SessionID cleared) when it exits and
+// reattached to the next session with the same name.
+type Notes struct {
+	SessionID   string    `json:"sessionId,omitempty"`
+	SessionName string    `json:"sessionName"`
+	Tabs        []Tab     `json:"tabs"`
+	ActiveTabID string    `json:"activeTabId"`
+	Keep        bool      `json:"keep"`
+	Revision    int64     `json:"revision"` // increments on every Put; 0 means no notes yet
+	UpdatedAt   time.Time `json:"updatedAt,omitzero"`
+}
+
+// NotesStore is the full persistent state.
+type NotesStore struct {
+	Notes []Notes `json:"notes"`
+}
+
+// AnyRevision disables the revision check in Put.
+const AnyRevision int64 = -1
+
+// Limits enforced by Validate.
+const (
+	MaxTabs         = 500
+	MaxContentBytes = 1 << 20
+)
+
+var (
+	ErrRevisionConflict = errors.New("notes revision conflict")
+	ErrInvalid          = errors.New("invalid notes")
+)
+
+// Validate checks that tab IDs are present and unique and that the notes stay
+// within MaxTabs and MaxContentBytes per tab.
+func (n Notes) Validate() error {
+	if len(n.Tabs) > MaxTabs {
+		return fmt.Errorf("%w: at most %d tabs are allowed", ErrInvalid, MaxTabs)
+	}
+	seen := make(map[string]bool, len(n.Tabs))
+	for _, t := range n.Tabs {
+		if t.ID == "" {
+			return fmt.Errorf("%w: tab id is required", ErrInvalid)
+		}
+		if seen[t.ID] {
+			return fmt.Errorf("%w: duplicate tab id %q", ErrInvalid, t.ID)
+		}
+		if len(t.Content) > MaxContentBytes {
+			return fmt.Errorf("%w: tab %q is larger than %d KiB", ErrInvalid, t.ID, MaxContentBytes>>10)
+		}
+		seen[t.ID] = true
+	}
+	return nil
+}
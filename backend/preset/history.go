@@ -0,0 +1,202 @@
+package preset
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"web-terminal/internal/atomicfile"
+)
+
+// maxHistory bounds the number of store versions kept for undo.
+const maxHistory = 50
+
+// ErrNoRevision is returned by Restore for a revision not in the history.
+var ErrNoRevision = errors.New("revision not found")
+
+// Revision is a saved version of the preset list.
+type Revision struct {
+	Rev     int64     `json:"rev"` // store version
+	Time    time.Time `json:"time"`
+	Summary string    `json:"summary"` // e.g. "added 1, removed 2"
+	Presets []Preset  `json:"presets,omitempty"`
+}
+
+// historyPath returns the file the history for a store at path is kept in.
+func historyPath(path string) string {
+	return strings.TrimSuffix(path, ".json") + ".history.json"
+}
+
+// loadHistory reads the history file. A missing or unreadable history never
+// stops the presets themselves from loading.
+func loadHistory(path string) []Revision {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("preset history: %v", err)
+		}
+		return nil
+	}
+	var revs []Revision
+	if err := json.Unmarshal(data, &revs); err != nil {
+		log.Printf("preset history %s: %v", path, err)
+		return nil
+	}
+	return revs
+}
+
+// History returns the saved revisions, newest first, without their presets.
+func (m *Manager) History() []Revision {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	revs := make([]Revision, 0, len(m.history))
+	for i := len(m.history) - 1; i >= 0; i-- {
+		r := m.history[i]
+		r.Presets = nil
+		revs = append(revs, r)
+	}
+	return revs
+}
+
+// Revision returns the revision rev including its presets, or ErrNoRevision.
+func (m *Manager) Revision(rev int64) (Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, r := range m.history {
+		if r.Rev == rev {
+			return r, nil
+		}
+	}
+	return Revision{}, ErrNoRevision
+}
+
+// Restore saves the presets of revision rev as a new version. The MRU list is
+// kept, minus presets the revision does not have.
+func (m *Manager) Restore(rev int64, ifVersion int64) (PresetStore, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkVersion(ifVersion); err != nil {
+		return PresetStore{}, err
+	}
+	var snapshot []Preset
+	found := false
+	for _, r := range m.history {
+		if r.Rev == rev {
+			snapshot, found = r.Presets, true
+		}
+	}
+	if !found {
+		return PresetStore{}, ErrNoRevision
+	}
+
+	store := copyStore(m.store)
+	store.Presets = make([]Preset, len(snapshot))
+	copy(store.Presets, snapshot)
+	ids := make(map[string]bool, len(snapshot))
+	for _, p := range snapshot {
+		ids[p.ID] = true
+	}
+	ru := store.RecentlyUsed[:0]
+	for _, id := range store.RecentlyUsed {
+		if ids[id] {
+			ru = append(ru, id)
+		}
+	}
+	store.RecentlyUsed = ru
+	return m.commitWithNote(store, fmt.Sprintf("restored rev %d", rev))
+}
+
+// record appends a revision for store, the version that just replaced prev.
+// If the history does not already end with prev (first save, or the history
+// file was lost), prev is recorded first so the change can still be undone.
+// Caller must hold m.mu.
+func (m *Manager) record(prev, store PresetStore, note string, now time.Time) {
+	if n := len(m.history); n == 0 || m.history[n-1].Rev != prev.Version {
+		m.history = append(m.history, Revision{
+			Rev: prev.Version, Time: now, Summary: "baseline", Presets: slices.Clone(prev.Presets),
+		})
+	}
+	summary := summarize(prev.Presets, store.Presets)
+	if note != "" {
+		summary = note + ": " + summary
+	}
+	m.history = append(m.history, Revision{
+		Rev: store.Version, Time: now, Summary: summary, Presets: slices.Clone(store.Presets),
+	})
+	if len(m.history) > maxHistory {
+		m.history = append([]Revision(nil), m.history[len(m.history)-maxHistory:]...)
+	}
+	if err := atomicfile.WriteJSON(historyPath(m.filePath), m.history); err != nil {
+		log.Printf("preset history: %v", err)
+	}
+}
+
+// Diff is the difference between two versions of the preset list.
+type Diff struct {
+	Added   []Preset       `json:"added,omitempty"`
+	Removed []Preset       `json:"removed,omitempty"`
+	Updated []PresetUpdate `json:"updated,omitempty"`
+}
+
+// PresetUpdate is a preset whose content changed between two versions.
+type PresetUpdate struct {
+	Before Preset `json:"before"`
+	After  Preset `json:"after"`
+}
+
+// Empty reports whether d has no changes.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Updated) == 0
+}
+
+// DiffPresets compares two preset lists by ID. Only user-editable fields
+// count: a preset whose use count or timestamps changed is not updated.
+func DiffPresets(prev, next []Preset) Diff {
+	old := make(map[string]Preset, len(prev))
+	for _, p := range prev {
+		old[p.ID] = p
+	}
+	var d Diff
+	kept := make(map[string]bool, len(next))
+	for _, p := range next {
+		o, ok := old[p.ID]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, p)
+		case !sameContent(o, p):
+			d.Updated = append(d.Updated, PresetUpdate{Before: o, After: p})
+		}
+		kept[p.ID] = true
+	}
+	for _, p := range prev {
+		if !kept[p.ID] {
+			d.Removed = append(d.Removed, p)
+		}
+	}
+	return d
+}
+
+// summarize describes the difference between two preset lists, e.g.
+// "added 1, removed 2, updated 3".
+func summarize(prev, next []Preset) string {
+	d := DiffPresets(prev, next)
+	var parts []string
+	if len(d.Added) > 0 {
+		parts = append(parts, fmt.Sprintf("added %d", len(d.Added)))
+	}
+	if len(d.Removed) > 0 {
+		parts = append(parts, fmt.Sprintf("removed %d", len(d.Removed)))
+	}
+	if len(d.Updated) > 0 {
+		parts = append(parts, fmt.Sprintf("updated %d", len(d.Updated)))
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,81 @@
+package preset_test
+
+import (
+	"fmt"
+	"testing"
+
+	"web-terminal/preset"
+)
+
+func TestHistoryRecordsSummaries(t *testing.T) {
+	pm, _ := preset.NewManager(t.TempDir() + "/presets.json")
+	pm.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "A"}, {ID: "b", Title: "B"}}})
+	pm.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "A2"}, {ID: "c", Title: "C"}}})
+
+	revs := pm.History()
+	want := []string{"added 1, removed 1, updated 1", "added 2", "baseline"}
+	if len(revs) != len(want) {
+		t.Fatalf("expected %d revisions, got %+v", len(want), revs)
+	}
+	for i, w := range want {
+		if revs[i].Summary != w {
+			t.Errorf("revision %d summary = %q, want %q", i, revs[i].Summary, w)
+		}
+		if revs[i].Presets != nil {
+			t.Errorf("History should omit presets, got %+v", revs[i].Presets)
+		}
+	}
+	if revs[0].Rev != 2 || revs[2].Rev != 0 {
+		t.Fatalf("unexpected revision numbers: %+v", revs)
+	}
+}
+
+func TestRestoreCreatesNewRevision(t *testing.T) {
+	path := t.TempDir() + "/presets.json"
+	pm, _ := preset.NewManager(path)
+	pm.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "A"}, {ID: "b", Title: "B"}}})
+	pm.MarkUsed("a")
+	pm.Save(preset.PresetStore{Presets: []preset.Preset{}, RecentlyUsed: []string{}}) // buggy client wipes everything
+
+	store, err := pm.Restore(1, preset.AnyVersion)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if store.Version != 3 || len(store.Presets) != 2 {
+		t.Fatalf("unexpected restored store: %+v", store)
+	}
+	if got := pm.History()[0].Summary; got != "restored rev 1: added 2" {
+		t.Fatalf("restore summary = %q", got)
+	}
+
+	// History survives a restart.
+	pm2, _ := preset.NewManager(path)
+	if n := len(pm2.History()); n != 4 {
+		t.Fatalf("expected 4 revisions after reload, got %d", n)
+	}
+	if _, err := pm2.Revision(1); err != nil {
+		t.Fatalf("Revision(1): %v", err)
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	pm, _ := preset.NewManager(t.TempDir() + "/presets.json")
+	pm.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "a"}}})
+	if _, err := pm.Restore(42, preset.AnyVersion); err != preset.ErrNoRevision {
+		t.Fatalf("expected ErrNoRevision, got %v", err)
+	}
+	if _, err := pm.Restore(0, 0); err != preset.ErrVersionConflict {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	pm, _ := preset.NewManager(t.TempDir() + "/presets.json")
+	for i := range 60 {
+		pm.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: fmt.Sprint(i)}}})
+	}
+	revs := pm.History()
+	if len(revs) != 50 || revs[0].Rev != 60 {
+		t.Fatalf("expected the 50 newest revisions, got %d starting at %d", len(revs), revs[0].Rev)
+	}
+}
@@ -3,9 +3,17 @@ package preset
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"os"
-	"path/filepath"
+	"reflect"
+	"slices"
 	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"web-terminal/internal/atomicfile"
 )
 
 // Manager handles loading, saving, and updating the preset store.
@@ -13,12 +21,23 @@ type Manager struct {
 	mu       sync.RWMutex
 	filePath string
 	store    PresetStore
+	history  []Revision // oldest first, at most maxHistory
+
+	disk          diskState // store file as last read or written, see refresh
+	listeners     []func(Change)
+	diffListeners []func(Change, Diff)
 }
 
 // NewManager loads the preset store from filePath, or creates an empty store
-// if the file does not exist. Returns an error only on unexpected I/O failures.
+// if the file does not exist. A file that is not valid JSON is moved aside
+// (see quarantine) and the manager starts empty. Returns an error only on
+// unexpected I/O failures.
 func NewManager(filePath string) (*Manager, error) {
-	m := &Manager{filePath: filePath}
+	m := &Manager{
+		filePath: filePath,
+		store:    PresetStore{Presets: []Preset{}, RecentlyUsed: []string{}},
+		history:  loadHistory(historyPath(filePath)),
+	}
 
 	data, err := os.ReadFile(filePath)
 	if err != nil {
@@ -30,7 +49,19 @@ func NewManager(filePath string) (*Manager, error) {
 	}
 
 	if err := json.Unmarshal(data, &m.store); err != nil {
-		return nil, err
+		moved, qerr := quarantine(filePath)
+		if qerr != nil {
+			return nil, fmt.Errorf("%w (and could not move it aside: %v)", err, qerr)
+		}
+		log.Printf("WARNING: presets file %s is corrupt (%v); moved it to %s and starting empty", filePath, err, moved)
+		m.store = PresetStore{Presets: []Preset{}, RecentlyUsed: []string{}}
+		return m, nil
+	}
+	m.remember(data)
+	// Keep numbering versions after the history, whose revisions may be
+	// newer than the file's version if the last change was a disk reload.
+	if n := len(m.history); n > 0 && m.history[n-1].Rev > m.store.Version {
+		m.store.Version = m.history[n-1].Rev
 	}
 	if m.store.Presets == nil {
 		m.store.Presets = []Preset{}
@@ -38,9 +69,25 @@ func NewManager(filePath string) (*Manager, error) {
 	if m.store.RecentlyUsed == nil {
 		m.store.RecentlyUsed = []string{}
 	}
+	if repair(&m.store) {
+		log.Printf("presets: assigned IDs to presets with missing or duplicate IDs in %s", filePath)
+		if err := m.writeAtomic(m.store); err != nil {
+			return nil, err
+		}
+	}
+	for _, fe := range m.store.Validate() {
+		log.Printf("presets: %s: %s: %s", filePath, fe.Field, fe.Message)
+	}
 	return m, nil
 }
 
+// quarantine renames a corrupt store file to <path>.corrupt-<timestamp> so it
+// can be inspected, and returns the new name.
+func quarantine(path string) (string, error) {
+	moved := path + ".corrupt-" + time.Now().Format("20060102-150405")
+	return moved, os.Rename(path, moved)
+}
+
 // Get returns a snapshot of the current store (safe copy under RLock).
 func (m *Manager) Get() PresetStore {
 	m.mu.RLock()
@@ -48,30 +95,252 @@ func (m *Manager) Get() PresetStore {
 	return copyStore(m.store)
 }
 
+// Find returns the preset with the given id, or ErrNotFound.
+func (m *Manager) Find(id string) (Preset, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if i := m.indexOf(id); i >= 0 {
+		return m.store.Presets[i], nil
+	}
+	return Preset{}, ErrNotFound
+}
+
+// Search returns the presets matching q, best match first.
+func (m *Manager) Search(q Query) []SearchResult {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return Search(m.store.Presets, q)
+}
+
+// MRU ranking orders accepted by Recent.
+const (
+	ByRecency   = "recency"
+	ByFrequency = "frequency"
+)
+
+// Recent returns up to limit used presets, ordered by recency (the
+// recentlyUsed list) or by frequency (use count, ties broken by last use).
+func (m *Manager) Recent(by string, limit int) []Preset {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var list []Preset
+	if by == ByFrequency {
+		for _, p := range m.store.Presets {
+			if p.UseCount > 0 {
+				list = append(list, p)
+			}
+		}
+		slices.SortStableFunc(list, func(a, b Preset) int {
+			if a.UseCount != b.UseCount {
+				return b.UseCount - a.UseCount
+			}
+			return b.LastUsedAt.Compare(a.LastUsedAt)
+		})
+	} else {
+		for _, id := range m.store.RecentlyUsed {
+			if i := m.indexOf(id); i >= 0 {
+				list = append(list, m.store.Presets[i])
+			}
+		}
+	}
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	if list == nil {
+		list = []Preset{}
+	}
+	return list
+}
+
 // Save validates and atomically writes store to disk, then updates in-memory state.
 func (m *Manager) Save(store PresetStore) error {
+	_, err := m.SaveIf(store, AnyVersion)
+	return err
+}
+
+// SaveIf replaces the whole store like Save, but only if the current version
+// equals ifVersion (or ifVersion is AnyVersion). Returns the saved store, or
+// ErrVersionConflict if the store changed since the caller read it.
+func (m *Manager) SaveIf(store PresetStore, ifVersion int64) (PresetStore, error) {
 	if store.Presets == nil {
 		store.Presets = []Preset{}
 	}
 	if store.RecentlyUsed == nil {
 		store.RecentlyUsed = []string{}
 	}
+	store.Presets = slices.Clone(store.Presets)
+	assignIDs(store.Presets)
+	if err := validationError(store.Validate()); err != nil {
+		return PresetStore{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkVersion(ifVersion); err != nil {
+		return PresetStore{}, err
+	}
+	return m.commit(store)
+}
+
+// Add appends p to the store. An empty p.ID is replaced with a generated one.
+func (m *Manager) Add(p Preset, ifVersion int64) (Preset, PresetStore, error) {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	if err := validationError(p.Validate()); err != nil {
+		return Preset{}, PresetStore{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkVersion(ifVersion); err != nil {
+		return Preset{}, PresetStore{}, err
+	}
+	if m.indexOf(p.ID) >= 0 {
+		return Preset{}, PresetStore{}, ErrDuplicateID
+	}
+
+	store := copyStore(m.store)
+	store.Presets = append(store.Presets, p)
+	saved, err := m.commit(store)
+	if err != nil {
+		return Preset{}, PresetStore{}, err
+	}
+	return saved.Presets[len(saved.Presets)-1], saved, nil
+}
+
+// Update replaces the preset with p.ID, keeping its position in the list.
+func (m *Manager) Update(p Preset, ifVersion int64) (PresetStore, error) {
+	if err := validationError(p.Validate()); err != nil {
+		return PresetStore{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkVersion(ifVersion); err != nil {
+		return PresetStore{}, err
+	}
+	i := m.indexOf(p.ID)
+	if i < 0 {
+		return PresetStore{}, ErrNotFound
+	}
+
+	store := copyStore(m.store)
+	store.Presets[i] = p
+	return m.commit(store)
+}
+
+// Delete removes the preset with the given id and drops it from the MRU list.
+func (m *Manager) Delete(id string, ifVersion int64) (PresetStore, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkVersion(ifVersion); err != nil {
+		return PresetStore{}, err
+	}
+	i := m.indexOf(id)
+	if i < 0 {
+		return PresetStore{}, ErrNotFound
+	}
+
+	store := copyStore(m.store)
+	store.Presets = append(store.Presets[:i], store.Presets[i+1:]...)
+	ru := store.RecentlyUsed[:0]
+	for _, rid := range store.RecentlyUsed {
+		if rid != id {
+			ru = append(ru, rid)
+		}
+	}
+	store.RecentlyUsed = ru
+	return m.commit(store)
+}
+
+// Import merges pack into the store using strategy (see StrategySkip and
+// friends). With dryRun the report describes the changes without saving them.
+func (m *Manager) Import(pack Pack, strategy string, dryRun bool, ifVersion int64) (ImportReport, error) {
+	incoming := slices.Clone(pack.Presets)
+	assignIDs(incoming)
+	if err := validationError(PresetStore{Presets: incoming}.Validate()); err != nil {
+		return ImportReport{}, err
+	}
+	pack.Presets = incoming
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if err := m.checkVersion(ifVersion); err != nil {
+		return ImportReport{}, err
+	}
+
+	merged, report, err := mergePresets(m.store.Presets, pack.Presets, strategy)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	report.DryRun = dryRun
+	if dryRun || report.Summary[ActionAdd]+report.Summary[ActionOverwrite]+report.Summary[ActionDuplicate] == 0 {
+		return report, nil
+	}
+
+	store := copyStore(m.store)
+	store.Presets = merged
+	if _, err := m.commit(store); err != nil {
+		return ImportReport{}, err
+	}
+	return report, nil
+}
+
+// checkVersion picks up any pending edit on disk, then returns
+// ErrVersionConflict unless ifVersion matches the current store version.
+// Caller must hold m.mu.
+func (m *Manager) checkVersion(ifVersion int64) error {
+	m.refresh()
+	if ifVersion != AnyVersion && ifVersion != m.store.Version {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// commit bumps the version, stamps server-maintained preset fields, persists
+// store, records it in the history and makes it current. Caller must hold m.mu.
+func (m *Manager) commit(store PresetStore) (PresetStore, error) {
+	return m.commitWithNote(store, "")
+}
+
+// commitWithNote is commit with a note prefixed to the history summary.
+func (m *Manager) commitWithNote(store PresetStore, note string) (PresetStore, error) {
+	if err := validationError(store.Validate()); err != nil {
+		return PresetStore{}, err
+	}
+	now := time.Now()
+	store.Version = m.store.Version + 1
+	store.Presets = stampPresets(m.store.Presets, store.Presets, now)
 	if err := m.writeAtomic(store); err != nil {
-		return err
+		return PresetStore{}, err
 	}
+	m.record(m.store, store, note, now)
+	diff := DiffPresets(m.store.Presets, store.Presets)
 	m.store = store
-	return nil
+	m.notify(Change{Version: store.Version, Source: SourceAPI}, diff)
+	return copyStore(store), nil
+}
+
+// indexOf returns the position of the preset with id, or -1.
+// Caller must hold m.mu.
+func (m *Manager) indexOf(id string) int {
+	for i, p := range m.store.Presets {
+		if p.ID == id {
+			return i
+		}
+	}
+	return -1
 }
 
 // MarkUsed prepends id to the recentlyUsed list (deduplicating, capping at 10,
-// and filtering out IDs that no longer exist in presets). A non-existent id is
-// silently ignored.
+// and filtering out IDs that no longer exist in presets) and bumps the preset's
+// use count. A non-existent id is silently ignored.
 func (m *Manager) MarkUsed(id string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.refresh()
 
 	// Verify the id exists in presets.
 	found := false
@@ -112,26 +381,55 @@ func (m *Manager) MarkUsed(id string) error {
 	}
 
 	m.store.RecentlyUsed = newList
+	p := &m.store.Presets[m.indexOf(id)]
+	p.UseCount++
+	p.LastUsedAt = time.Now()
 	return m.writeAtomic(m.store)
 }
 
-// writeAtomic writes to a temp file then renames it over filePath.
-// Caller must hold m.mu if the in-memory store is being modified concurrently.
+// writeAtomic writes to a temp file then renames it over filePath, and
+// remembers the result so the watcher does not mistake it for an outside edit.
+// Caller must hold m.mu.
 func (m *Manager) writeAtomic(store PresetStore) error {
-	dir := filepath.Dir(m.filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
-	tmp := m.filePath + ".tmp"
 	data, err := json.MarshalIndent(store, "", "  ")
 	if err != nil {
 		return err
 	}
-	if err := os.WriteFile(tmp, data, 0600); err != nil {
+	if err := atomicfile.Write(m.filePath, data, 0600); err != nil {
 		return err
 	}
-	return os.Rename(tmp, m.filePath)
+	m.remember(data)
+	return nil
+}
+
+// stampPresets carries server-maintained fields over from prev into next:
+// creation time and usage stats are preserved, and UpdatedAt moves only when
+// the preset's user-editable fields changed.
+func stampPresets(prev, next []Preset, now time.Time) []Preset {
+	byID := make(map[string]Preset, len(prev))
+	for _, p := range prev {
+		byID[p.ID] = p
+	}
+	stamped := make([]Preset, len(next))
+	for i, p := range next {
+		old, existed := byID[p.ID]
+		p.CreatedAt, p.UpdatedAt = old.CreatedAt, old.UpdatedAt
+		p.UseCount, p.LastUsedAt = old.UseCount, old.LastUsedAt
+		if !existed || p.CreatedAt.IsZero() {
+			p.CreatedAt = now
+		}
+		if !existed || p.UpdatedAt.IsZero() || !sameContent(old, p) {
+			p.UpdatedAt = now
+		}
+		stamped[i] = p
+	}
+	return stamped
+}
+
+// sameContent reports whether a and b have identical user-editable fields.
+func sameContent(a, b Preset) bool {
+	return a.Title == b.Title && a.Content == b.Content && a.Folder == b.Folder &&
+		slices.Equal(a.Tags, b.Tags) && reflect.DeepEqual(a.Variables, b.Variables)
 }
 
 func copyStore(s PresetStore) PresetStore {
@@ -139,5 +437,5 @@ func copyStore(s PresetStore) PresetStore {
 	copy(presets, s.Presets)
 	ru := make([]string, len(s.RecentlyUsed))
 	copy(ru, s.RecentlyUsed)
-	return PresetStore{Presets: presets, RecentlyUsed: ru}
+	return PresetStore{Version: s.Version, Presets: presets, RecentlyUsed: ru}
 }
@@ -1,6 +1,7 @@
 package preset_test
 
 import (
+	"errors"
 	"sync"
 	"testing"
 
@@ -185,3 +186,116 @@ func TestConcurrentSave(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestAddUpdateDelete(t *testing.T) {
+	pm, _ := preset.NewManager(t.TempDir() + "/presets.json")
+
+	added, store, err := pm.Add(preset.Preset{Title: "A", Content: "a"}, preset.AnyVersion)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if added.ID == "" {
+		t.Fatal("expected a generated ID")
+	}
+	if store.Version != 1 {
+		t.Fatalf("expected version 1 after Add, got %d", store.Version)
+	}
+
+	store, err = pm.Update(preset.Preset{ID: added.ID, Title: "A2", Content: "a2"}, store.Version)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if store.Presets[0].Title != "A2" || store.Version != 2 {
+		t.Fatalf("unexpected store after Update: %+v", store)
+	}
+
+	pm.MarkUsed(added.ID)
+	store, err = pm.Delete(added.ID, store.Version)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if len(store.Presets) != 0 || len(store.RecentlyUsed) != 0 {
+		t.Fatalf("expected empty store after Delete, got %+v", store)
+	}
+}
+
+func TestUpdateDeleteNotFound(t *testing.T) {
+	pm, _ := preset.NewManager(t.TempDir() + "/presets.json")
+
+	if _, err := pm.Update(preset.Preset{ID: "nope", Title: "nope"}, preset.AnyVersion); !errors.Is(err, preset.ErrNotFound) {
+		t.Fatalf("Update: expected ErrNotFound, got %v", err)
+	}
+	if _, err := pm.Delete("nope", preset.AnyVersion); !errors.Is(err, preset.ErrNotFound) {
+		t.Fatalf("Delete: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestAddDuplicateID(t *testing.T) {
+	pm, _ := preset.NewManager(t.TempDir() + "/presets.json")
+	pm.Add(preset.Preset{ID: "x", Title: "x"}, preset.AnyVersion)
+	if _, _, err := pm.Add(preset.Preset{ID: "x", Title: "x"}, preset.AnyVersion); !errors.Is(err, preset.ErrDuplicateID) {
+		t.Fatalf("expected ErrDuplicateID, got %v", err)
+	}
+}
+
+func TestStaleVersionRejected(t *testing.T) {
+	path := t.TempDir() + "/presets.json"
+	pm, _ := preset.NewManager(path)
+	pm.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "A"}}})
+	stale := pm.Get().Version
+
+	// Another writer moves the store on.
+	if _, err := pm.Update(preset.Preset{ID: "a", Title: "theirs"}, stale); err != nil {
+		t.Fatalf("first Update: %v", err)
+	}
+
+	if _, err := pm.Update(preset.Preset{ID: "a", Title: "mine"}, stale); !errors.Is(err, preset.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if _, err := pm.SaveIf(preset.PresetStore{}, stale); !errors.Is(err, preset.ErrVersionConflict) {
+		t.Fatalf("SaveIf: expected ErrVersionConflict, got %v", err)
+	}
+	if got := pm.Get().Presets[0].Title; got != "theirs" {
+		t.Fatalf("stale write must not apply, got title %q", got)
+	}
+
+	// The version survives a reload.
+	pm2, _ := preset.NewManager(path)
+	if pm2.Get().Version != pm.Get().Version {
+		t.Fatalf("version not persisted: %d vs %d", pm2.Get().Version, pm.Get().Version)
+	}
+}
+
+func TestTimestampsAndUseCount(t *testing.T) {
+	pm, _ := preset.NewManager(t.TempDir() + "/presets.json")
+	pm.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "A"}, {ID: "b", Title: "B"}}})
+
+	first := pm.Get().Presets[0]
+	if first.CreatedAt.IsZero() || first.UpdatedAt.IsZero() {
+		t.Fatalf("expected server-set timestamps, got %+v", first)
+	}
+
+	pm.MarkUsed("a")
+	pm.MarkUsed("a")
+	pm.MarkUsed("b")
+
+	// A client PUT with stale stats and an unchanged "a" keeps server values.
+	pm.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "A"}, {ID: "b", Title: "B2"}}})
+	got := pm.Get().Presets
+	if got[0].UseCount != 2 || !got[0].UpdatedAt.Equal(first.UpdatedAt) || !got[0].CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("unchanged preset lost server fields: %+v", got[0])
+	}
+	if got[1].UseCount != 1 || got[1].UpdatedAt.Before(first.UpdatedAt) {
+		t.Fatalf("edited preset has unexpected stats: %+v", got[1])
+	}
+
+	byFreq := pm.Recent(preset.ByFrequency, 10)
+	if len(byFreq) != 2 || byFreq[0].ID != "a" {
+		t.Fatalf("expected a first by frequency, got %+v", byFreq)
+	}
+	pm.MarkUsed("b") // the PUT above cleared recentlyUsed
+	byRecency := pm.Recent(preset.ByRecency, 1)
+	if len(byRecency) != 1 || byRecency[0].ID != "b" {
+		t.Fatalf("expected b first by recency, got %+v", byRecency)
+	}
+}
@@ -1,18 +1,67 @@
 package preset
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
-// Preset is a single reusable text snippet.
+// Preset is a single reusable text snippet. Content may contain {{name}}
+// placeholders that are expanded by Render.
 type Preset struct {
-	ID      string `json:"id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	ID        string     `json:"id" yaml:"id"`
+	Title     string     `json:"title" yaml:"title"`
+	Content   string     `json:"content" yaml:"content"`
+	Variables []Variable `json:"variables,omitempty" yaml:"variables,omitempty"`
+	Folder    string     `json:"folder,omitempty" yaml:"folder,omitempty"` // slash-separated, e.g. "ops/deploy"
+	Tags      []string   `json:"tags,omitempty" yaml:"tags,omitempty"`
+
+	// Maintained by the server; values supplied by clients are ignored.
+	CreatedAt  time.Time `json:"createdAt,omitzero" yaml:"createdAt,omitempty"`
+	UpdatedAt  time.Time `json:"updatedAt,omitzero" yaml:"updatedAt,omitempty"`
+	UseCount   int       `json:"useCount,omitempty" yaml:"useCount,omitempty"`
+	LastUsedAt time.Time `json:"lastUsedAt,omitzero" yaml:"lastUsedAt,omitempty"`
+}
+
+// Variable types accepted in Variable.Type. An empty type means VarString.
+const (
+	VarString = "string"
+	VarNumber = "number"
+	VarBool   = "bool"
+	VarEnum   = "enum"
+	VarDate   = "date" // YYYY-MM-DD
+)
+
+// Variable declares a placeholder used in Preset.Content, so clients can
+// prompt for it and the server can validate the supplied value.
+type Variable struct {
+	Name        string   `json:"name" yaml:"name"`
+	Type        string   `json:"type,omitempty" yaml:"type,omitempty"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Default     string   `json:"default,omitempty" yaml:"default,omitempty"`
+	Options     []string `json:"options,omitempty" yaml:"options,omitempty"` // allowed values for VarEnum
+	Required    bool     `json:"required,omitempty" yaml:"required,omitempty"`
+}
+
+// FieldError describes a validation problem with a single input field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 // PresetStore is the full persistent state.
 type PresetStore struct {
+	// Version increments on every change to the preset list and is used for
+	// optimistic concurrency control. Values supplied by clients are ignored.
+	Version      int64    `json:"version"`
 	Presets      []Preset `json:"presets"`
 	RecentlyUsed []string `json:"recentlyUsed"` // MRU order, max 10 IDs
 }
 
-var ErrNotFound = errors.New("preset not found")
+// AnyVersion disables the version check on conditional writes.
+const AnyVersion int64 = -1
+
+var (
+	ErrNotFound        = errors.New("preset not found")
+	ErrDuplicateID     = errors.New("preset id already exists")
+	ErrVersionConflict = errors.New("preset store version conflict")
+)
@@ -0,0 +1,136 @@
+package preset
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// placeholderRe matches {{name}} and {{ dotted.name }} placeholders.
+var placeholderRe = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+// Builtins resolves built-in placeholders such as {{date}} or {{cwd}} that are
+// not declared as preset variables. It is only called for names that appear
+// in the content, so expensive lookups are skipped when unused.
+type Builtins func(name string) (string, bool)
+
+// Placeholders returns the distinct placeholder names in content, in order of
+// first appearance.
+func Placeholders(content string) []string {
+	var names []string
+	for _, m := range placeholderRe.FindAllStringSubmatch(content, -1) {
+		if !slices.Contains(names, m[1]) {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// Render expands p.Content. Each placeholder is resolved from values, then the
+// declared variable's default, then builtins. A declared variable left
+// without a value renders empty unless it is required. Undeclared
+// placeholders that resolve to nothing are left as they are, so content
+// meant for another template language, such as Helm or Jinja, passes
+// through. Supplied values are checked against the declared variable types;
+// unknown variables, invalid values and missing required values are reported
+// as field errors and nothing is rendered.
+func Render(p Preset, values map[string]string, builtins Builtins) (string, []FieldError) {
+	used := Placeholders(p.Content)
+	declared := make(map[string]Variable, len(p.Variables))
+	for _, v := range p.Variables {
+		declared[v.Name] = v
+	}
+
+	var errs []FieldError
+	for name := range values {
+		if _, ok := declared[name]; !ok && !slices.Contains(used, name) {
+			errs = append(errs, FieldError{Field: name, Message: "unknown variable"})
+		}
+	}
+
+	resolved := make(map[string]string, len(used))
+	invalid := make(map[string]bool)
+	for _, v := range p.Variables {
+		val, ok := values[v.Name]
+		if !ok || val == "" {
+			val, ok = v.Default, v.Default != ""
+		}
+		if !ok {
+			continue
+		}
+		if msg := checkType(v, val); msg != "" {
+			errs = append(errs, FieldError{Field: v.Name, Message: msg})
+			invalid[v.Name] = true
+			continue
+		}
+		resolved[v.Name] = val
+	}
+
+	for _, name := range used {
+		if _, ok := resolved[name]; ok || invalid[name] {
+			continue
+		}
+		v, isDeclared := declared[name]
+		if val, ok := values[name]; ok && !isDeclared {
+			resolved[name] = val
+			continue
+		}
+		if builtins != nil {
+			if val, ok := builtins(name); ok {
+				resolved[name] = val
+				continue
+			}
+		}
+		if isDeclared && !v.Required {
+			resolved[name] = ""
+		}
+	}
+
+	for _, v := range p.Variables {
+		if _, ok := resolved[v.Name]; !ok && v.Required && !invalid[v.Name] {
+			errs = append(errs, FieldError{Field: v.Name, Message: "value is required"})
+		}
+	}
+
+	if len(errs) > 0 {
+		slices.SortFunc(errs, func(a, b FieldError) int { return strings.Compare(a.Field, b.Field) })
+		return "", errs
+	}
+	return placeholderRe.ReplaceAllStringFunc(p.Content, func(m string) string {
+		if val, ok := resolved[placeholderRe.FindStringSubmatch(m)[1]]; ok {
+			return val
+		}
+		return m
+	}), nil
+}
+
+// checkType returns a message describing why val is not a valid value for v,
+// or "" if it is.
+func checkType(v Variable, val string) string {
+	switch v.Type {
+	case "", VarString:
+		return ""
+	case VarNumber:
+		if _, err := strconv.ParseFloat(val, 64); err != nil {
+			return "must be a number"
+		}
+	case VarBool:
+		if _, err := strconv.ParseBool(val); err != nil {
+			return "must be true or false"
+		}
+	case VarEnum:
+		if !slices.Contains(v.Options, val) {
+			return fmt.Sprintf("must be one of %v", v.Options)
+		}
+	case VarDate:
+		if _, err := time.Parse(time.DateOnly, val); err != nil {
+			return "must be a date (YYYY-MM-DD)"
+		}
+	default:
+		return fmt.Sprintf("unsupported variable type %q", v.Type)
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package preset_test
+
+import (
+	"reflect"
+	"testing"
+
+	"web-terminal/preset"
+)
+
+func TestRenderResolutionOrder(t *testing.T) {
+	p := preset.Preset{
+		Content: "deploy {{branch}} to {{ env }} on {{date}} x{{count}}",
+		Variables: []preset.Variable{
+			{Name: "env", Type: preset.VarEnum, Options: []string{"staging", "prod"}, Default: "staging"},
+			{Name: "count", Type: preset.VarNumber, Default: "1"},
+		},
+	}
+	builtins := func(name string) (string, bool) {
+		switch name {
+		case "branch":
+			return "main", true
+		case "date":
+			return "2026-01-02", true
+		}
+		return "", false
+	}
+
+	got, errs := preset.Render(p, map[string]string{"env": "prod"}, builtins)
+	if errs != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if want := "deploy main to prod on 2026-01-02 x1"; got != want {
+		t.Fatalf("Render = %q, want %q", got, want)
+	}
+
+	// Supplied values override builtins for undeclared placeholders.
+	got, _ = preset.Render(p, map[string]string{"branch": "release"}, builtins)
+	if want := "deploy release to staging on 2026-01-02 x1"; got != want {
+		t.Fatalf("Render = %q, want %q", got, want)
+	}
+}
+
+func TestRenderValidation(t *testing.T) {
+	p := preset.Preset{
+		Content: "{{env}} {{n}} {{flag}} {{when}} {{missing}}",
+		Variables: []preset.Variable{
+			{Name: "env", Type: preset.VarEnum, Options: []string{"staging", "prod"}},
+			{Name: "n", Type: preset.VarNumber},
+			{Name: "flag", Type: preset.VarBool},
+			{Name: "when", Type: preset.VarDate},
+			{Name: "ticket", Required: true},
+		},
+	}
+	values := map[string]string{"env": "dev", "n": "many", "flag": "maybe", "when": "tomorrow", "bogus": "x"}
+
+	_, errs := preset.Render(p, values, nil)
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	want := []string{"bogus", "env", "flag", "n", "ticket", "when"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("error fields = %v, want %v (errors: %v)", fields, want, errs)
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	got := preset.Placeholders("{{a}} {{ session.name }} {{a}} {b}")
+	if want := []string{"a", "session.name"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Placeholders = %v, want %v", got, want)
+	}
+}
+
+func TestRenderLeavesOtherTemplatesAlone(t *testing.T) {
+	p := preset.Preset{
+		Content:   "helm upgrade --set image={{ .Values.image }} {{release}}{{suffix}} # {{ user }}",
+		Variables: []preset.Variable{{Name: "release", Required: true}, {Name: "suffix"}},
+	}
+	got, errs := preset.Render(p, map[string]string{"release": "web"}, nil)
+	if errs != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if want := "helm upgrade --set image={{ .Values.image }} web # {{ user }}"; got != want {
+		t.Fatalf("Render = %q, want %q", got, want)
+	}
+
+	if _, errs := preset.Render(p, nil, nil); len(errs) != 1 || errs[0].Field != "release" {
+		t.Fatalf("expected only the required variable to be missing, got %v", errs)
+	}
+}
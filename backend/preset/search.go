@@ -0,0 +1,108 @@
+package preset
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Query selects presets by free text, tags and folder. Empty fields match
+// everything.
+type Query struct {
+	Text   string
+	Tags   []string // presets must carry every tag (case-insensitive)
+	Folder string   // matches the folder and its subfolders
+}
+
+// SearchResult is a preset with its relevance score.
+type SearchResult struct {
+	Preset
+	Score float64 `json:"score"`
+}
+
+// Ranking weights for Search. Title hits outrank content hits, and frequently
+// used presets get a small logarithmic boost.
+const (
+	scoreTitleWord    = 3.0
+	scoreTitlePrefix  = 2.0
+	scoreTitleSubstr  = 1.0
+	scoreContentHit   = 0.5
+	maxContentHits    = 4
+	scoreUseCountBase = 0.25
+)
+
+// Search returns the presets matching q, ranked by relevance (best first).
+// Every term in q.Text must occur in the title or content.
+func Search(presets []Preset, q Query) []SearchResult {
+	terms := tokenize(q.Text)
+	results := []SearchResult{}
+	for _, p := range presets {
+		if !hasTags(p, q.Tags) || !inFolder(p.Folder, q.Folder) {
+			continue
+		}
+		score, ok := scoreTerms(p, terms)
+		if !ok {
+			continue
+		}
+		score += scoreUseCountBase * math.Log1p(float64(p.UseCount))
+		results = append(results, SearchResult{Preset: p, Score: score})
+	}
+	slices.SortStableFunc(results, func(a, b SearchResult) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	})
+	return results
+}
+
+// scoreTerms scores p against the query terms; ok is false if any term does
+// not occur at all.
+func scoreTerms(p Preset, terms []string) (score float64, ok bool) {
+	title := strings.ToLower(p.Title)
+	titleWords := tokenize(p.Title)
+	content := strings.ToLower(p.Content)
+	for _, t := range terms {
+		var s float64
+		switch {
+		case slices.Contains(titleWords, t):
+			s = scoreTitleWord
+		case slices.ContainsFunc(titleWords, func(w string) bool { return strings.HasPrefix(w, t) }):
+			s = scoreTitlePrefix
+		case strings.Contains(title, t):
+			s = scoreTitleSubstr
+		}
+		s += scoreContentHit * float64(min(strings.Count(content, t), maxContentHits))
+		if s == 0 {
+			return 0, false
+		}
+		score += s
+	}
+	return score, true
+}
+
+// tokenize lower-cases s and splits it into letter/digit runs.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func hasTags(p Preset, tags []string) bool {
+	for _, want := range tags {
+		if !slices.ContainsFunc(p.Tags, func(t string) bool { return strings.EqualFold(t, want) }) {
+			return false
+		}
+	}
+	return true
+}
+
+func inFolder(folder, want string) bool {
+	want = strings.Trim(want, "/")
+	folder = strings.Trim(folder, "/")
+	return want == "" || folder == want || strings.HasPrefix(folder, want+"/")
+}
@@ -0,0 +1,69 @@
+package preset_test
+
+import (
+	"testing"
+
+	"web-terminal/preset"
+)
+
+var searchFixture = []preset.Preset{
+	{ID: "1", Title: "Deploy staging", Content: "make deploy ENV=staging", Folder: "ops/deploy", Tags: []string{"deploy"}},
+	{ID: "2", Title: "Tail logs", Content: "kubectl logs -f deploy/api", Folder: "ops", Tags: []string{"k8s"}},
+	{ID: "3", Title: "Deployment checklist", Content: "review, tag, release", Folder: "docs", Tags: []string{"Deploy", "docs"}},
+	{ID: "4", Title: "Code review prompt", Content: "Review this diff carefully", Folder: "prompts"},
+}
+
+func ids(results []preset.SearchResult) []string {
+	var out []string
+	for _, r := range results {
+		out = append(out, r.ID)
+	}
+	return out
+}
+
+func TestSearchRanksTitleAboveContent(t *testing.T) {
+	got := ids(preset.Search(searchFixture, preset.Query{Text: "deploy"}))
+	// Exact title word, then title prefix, then content-only match.
+	want := []string{"1", "3", "2"}
+	if len(got) != len(want) {
+		t.Fatalf("Search = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Search = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSearchRequiresAllTerms(t *testing.T) {
+	got := ids(preset.Search(searchFixture, preset.Query{Text: "review diff"}))
+	if len(got) != 1 || got[0] != "4" {
+		t.Fatalf("Search = %v, want [4]", got)
+	}
+}
+
+func TestSearchTagAndFolderFilters(t *testing.T) {
+	got := ids(preset.Search(searchFixture, preset.Query{Tags: []string{"deploy"}}))
+	if len(got) != 2 {
+		t.Fatalf("tag filter (case-insensitive) = %v, want 2 results", got)
+	}
+	got = ids(preset.Search(searchFixture, preset.Query{Folder: "ops"}))
+	if len(got) != 2 {
+		t.Fatalf("folder filter should include subfolders, got %v", got)
+	}
+	got = ids(preset.Search(searchFixture, preset.Query{Folder: "op"}))
+	if len(got) != 0 {
+		t.Fatalf("folder filter must not match partial names, got %v", got)
+	}
+}
+
+func TestSearchUseCountBoost(t *testing.T) {
+	presets := []preset.Preset{
+		{ID: "a", Title: "build"},
+		{ID: "b", Title: "build", UseCount: 20},
+	}
+	got := ids(preset.Search(presets, preset.Query{Text: "build"}))
+	if got[0] != "b" {
+		t.Fatalf("expected frequently used preset first, got %v", got)
+	}
+}
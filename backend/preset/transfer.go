@@ -0,0 +1,286 @@
+package preset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.yaml.in/yaml/v3"
+)
+
+// Formats accepted by EncodePack and DecodePack.
+const (
+	FormatJSON     = "json"
+	FormatYAML     = "yaml"
+	FormatMarkdown = "markdown"
+)
+
+// Merge strategies for Import, applied when an incoming preset has the same ID
+// or title as an existing one.
+const (
+	StrategySkip      = "skip"      // keep the existing preset
+	StrategyOverwrite = "overwrite" // replace the existing preset's contents
+	StrategyDuplicate = "duplicate" // add the incoming preset under a new ID
+)
+
+// Import actions reported in ImportChange.Action.
+const (
+	ActionAdd       = "add"
+	ActionOverwrite = "overwrite"
+	ActionUnchanged = "unchanged"
+	ActionSkip      = "skip"
+	ActionDuplicate = "duplicate"
+)
+
+var (
+	ErrUnknownFormat   = errors.New("unknown preset format")
+	ErrUnknownStrategy = errors.New("unknown merge strategy")
+)
+
+// Pack is a shareable set of presets. Its JSON form is compatible with
+// PresetStore, so an exported pack can also be PUT to /api/presets.
+type Pack struct {
+	Presets []Preset `json:"presets" yaml:"presets"`
+}
+
+// NewPack copies presets into a pack, dropping server-maintained fields that
+// are meaningless on another server.
+func NewPack(presets []Preset) Pack {
+	pack := Pack{Presets: make([]Preset, len(presets))}
+	for i, p := range presets {
+		p.CreatedAt, p.UpdatedAt, p.LastUsedAt = time.Time{}, time.Time{}, time.Time{}
+		p.UseCount = 0
+		pack.Presets[i] = p
+	}
+	return pack
+}
+
+// EncodePack writes pack to w in the given format.
+func EncodePack(w io.Writer, pack Pack, format string) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(pack)
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(pack); err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatMarkdown:
+		return encodeMarkdown(w, pack)
+	}
+	return ErrUnknownFormat
+}
+
+// DecodePack parses data in the given format.
+func DecodePack(data []byte, format string) (Pack, error) {
+	var pack Pack
+	var err error
+	switch format {
+	case FormatJSON:
+		err = json.Unmarshal(data, &pack)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &pack)
+	case FormatMarkdown:
+		pack, err = decodeMarkdown(data)
+	default:
+		err = ErrUnknownFormat
+	}
+	if err != nil {
+		return Pack{}, err
+	}
+	return NewPack(pack.Presets), nil
+}
+
+// markdownMeta is the per-preset metadata stored in an HTML comment so the
+// Markdown export stays readable and still round-trips.
+type markdownMeta struct {
+	ID        string     `json:"id"`
+	Folder    string     `json:"folder,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	Variables []Variable `json:"variables,omitempty"`
+}
+
+const markdownMetaPrefix = "<!-- preset "
+
+var backtickRunRe = regexp.MustCompile("`{3,}")
+
+// encodeMarkdown writes each preset as a "## Title" section followed by a
+// metadata comment and its content in a fenced code block.
+func encodeMarkdown(w io.Writer, pack Pack) error {
+	var b bytes.Buffer
+	b.WriteString("# Presets\n")
+	for _, p := range pack.Presets {
+		meta, err := json.Marshal(markdownMeta{ID: p.ID, Folder: p.Folder, Tags: p.Tags, Variables: p.Variables})
+		if err != nil {
+			return err
+		}
+		// The fence must be longer than any backtick run inside the content.
+		fence := "```"
+		for _, run := range backtickRunRe.FindAllString(p.Content, -1) {
+			if len(run) >= len(fence) {
+				fence = strings.Repeat("`", len(run)+1)
+			}
+		}
+		title := strings.Join(strings.Fields(p.Title), " ")
+		fmt.Fprintf(&b, "\n## %s\n\n%s%s -->\n\n%s\n%s", title, markdownMetaPrefix, meta, fence, p.Content)
+		if !strings.HasSuffix(p.Content, "\n") {
+			b.WriteByte('\n')
+		}
+		b.WriteString(fence + "\n")
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// decodeMarkdown parses the format written by encodeMarkdown. Sections
+// without a metadata comment get their ID assigned on import.
+func decodeMarkdown(data []byte) (Pack, error) {
+	pack := Pack{Presets: []Preset{}}
+	var cur *Preset
+	var fence string
+	var content []string
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case fence != "":
+			if strings.TrimRight(line, " ") == fence {
+				cur.Content = strings.Join(content, "\n")
+				fence, content = "", nil
+				continue
+			}
+			content = append(content, line)
+		case strings.HasPrefix(line, "## "):
+			pack.Presets = append(pack.Presets, Preset{Title: strings.TrimSpace(line[3:])})
+			cur = &pack.Presets[len(pack.Presets)-1]
+		case cur != nil && strings.HasPrefix(line, markdownMetaPrefix):
+			raw := strings.TrimSuffix(strings.TrimPrefix(line, markdownMetaPrefix), " -->")
+			var meta markdownMeta
+			if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+				return Pack{}, fmt.Errorf("preset %q: invalid metadata: %w", cur.Title, err)
+			}
+			cur.ID, cur.Folder, cur.Tags, cur.Variables = meta.ID, meta.Folder, meta.Tags, meta.Variables
+		case cur != nil && strings.HasPrefix(line, "```"):
+			fence = strings.TrimRight(line, " ")
+			fence = fence[:strings.LastIndex(fence, "`")+1] // drop any info string
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return Pack{}, err
+	}
+	if fence != "" {
+		return Pack{}, fmt.Errorf("preset %q: unterminated code block", cur.Title)
+	}
+	return pack, nil
+}
+
+// ImportChange describes what Import did (or would do) with one preset.
+type ImportChange struct {
+	Action     string `json:"action"`
+	ID         string `json:"id"`
+	Title      string `json:"title"`
+	ConflictID string `json:"conflictId,omitempty"` // existing preset that matched
+	ConflictOn string `json:"conflictOn,omitempty"` // "id" or "title"
+}
+
+// ImportReport summarises an import. With DryRun set nothing was saved.
+type ImportReport struct {
+	DryRun  bool           `json:"dryRun"`
+	Summary map[string]int `json:"summary"`
+	Changes []ImportChange `json:"changes"`
+}
+
+// mergePresets applies incoming on top of existing using strategy. Incoming
+// presets conflict with an existing one that has the same ID or, failing
+// that, the same title (case-insensitive).
+func mergePresets(existing, incoming []Preset, strategy string) ([]Preset, ImportReport, error) {
+	if strategy != StrategySkip && strategy != StrategyOverwrite && strategy != StrategyDuplicate {
+		return nil, ImportReport{}, ErrUnknownStrategy
+	}
+	merged := make([]Preset, len(existing))
+	copy(merged, existing)
+	report := ImportReport{Summary: map[string]int{}, Changes: []ImportChange{}}
+
+	find := func(p Preset) (int, string) {
+		for i, e := range merged {
+			if p.ID != "" && e.ID == p.ID {
+				return i, "id"
+			}
+		}
+		for i, e := range merged {
+			if strings.EqualFold(strings.TrimSpace(e.Title), strings.TrimSpace(p.Title)) {
+				return i, "title"
+			}
+		}
+		return -1, ""
+	}
+
+	for _, p := range incoming {
+		i, on := find(p)
+		change := ImportChange{ID: p.ID, Title: p.Title, ConflictOn: on}
+		if i >= 0 {
+			change.ConflictID = merged[i].ID
+		}
+		switch {
+		case i < 0:
+			if p.ID == "" {
+				p.ID = uuid.New().String()
+			}
+			merged = append(merged, p)
+			change.Action, change.ID = ActionAdd, p.ID
+		case strategy == StrategySkip:
+			change.Action = ActionSkip
+		case strategy == StrategyOverwrite:
+			p.ID = merged[i].ID
+			change.ID = p.ID
+			if sameContent(merged[i], p) {
+				change.Action = ActionUnchanged
+				break
+			}
+			merged[i] = p
+			change.Action = ActionOverwrite
+		case strategy == StrategyDuplicate:
+			p.ID = uuid.New().String()
+			p.Title = uniqueTitle(merged, p.Title)
+			merged = append(merged, p)
+			change.Action, change.ID, change.Title = ActionDuplicate, p.ID, p.Title
+		}
+		report.Summary[change.Action]++
+		report.Changes = append(report.Changes, change)
+	}
+	return merged, report, nil
+}
+
+// uniqueTitle returns title, suffixed with "(copy)" or "(copy N)" if another
+// preset already uses it.
+func uniqueTitle(presets []Preset, title string) string {
+	taken := func(t string) bool {
+		for _, p := range presets {
+			if strings.EqualFold(p.Title, t) {
+				return true
+			}
+		}
+		return false
+	}
+	if !taken(title) {
+		return title
+	}
+	candidate := title + " (copy)"
+	for n := 2; taken(candidate); n++ {
+		candidate = fmt.Sprintf("%s (copy %d)", title, n)
+	}
+	return candidate
+}
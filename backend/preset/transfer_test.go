@@ -0,0 +1,133 @@
+package preset_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"web-terminal/preset"
+)
+
+var packFixture = preset.Pack{Presets: []preset.Preset{
+	{ID: "1", Title: "Deploy", Content: "make deploy ENV={{env}}", Folder: "ops", Tags: []string{"deploy"},
+		Variables: []preset.Variable{{Name: "env", Type: preset.VarEnum, Options: []string{"staging", "prod"}, Required: true}}},
+	{ID: "2", Title: "Fenced", Content: "Explain:\n```go\nfmt.Println()\n```\n\n## not a heading"},
+}}
+
+func TestPackRoundTrip(t *testing.T) {
+	for _, format := range []string{preset.FormatJSON, preset.FormatYAML, preset.FormatMarkdown} {
+		var buf bytes.Buffer
+		if err := preset.EncodePack(&buf, packFixture, format); err != nil {
+			t.Fatalf("%s: encode: %v", format, err)
+		}
+		got, err := preset.DecodePack(buf.Bytes(), format)
+		if err != nil {
+			t.Fatalf("%s: decode: %v\n%s", format, err, buf.String())
+		}
+		if !reflect.DeepEqual(got, packFixture) {
+			t.Fatalf("%s: round trip mismatch:\ngot  %+v\nwant %+v\n%s", format, got, packFixture, buf.String())
+		}
+	}
+}
+
+func TestDecodeUnknownFormat(t *testing.T) {
+	if _, err := preset.DecodePack([]byte("{}"), "toml"); err != preset.ErrUnknownFormat {
+		t.Fatalf("expected ErrUnknownFormat, got %v", err)
+	}
+}
+
+func importFixture(t *testing.T) *preset.Manager {
+	t.Helper()
+	pm, err := preset.NewManager(t.TempDir() + "/presets.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm.Save(preset.PresetStore{Presets: []preset.Preset{
+		{ID: "1", Title: "Deploy", Content: "old"},
+		{ID: "9", Title: "Lint", Content: "make lint"},
+	}})
+	return pm
+}
+
+var incoming = preset.Pack{Presets: []preset.Preset{
+	{ID: "1", Title: "Deploy", Content: "new"},     // conflicts on ID
+	{ID: "x", Title: "Lint", Content: "make lint"}, // conflicts on title, same content
+	{ID: "y", Title: "Test", Content: "make test"}, // new
+}}
+
+func TestImportStrategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		actions  []string
+		count    int
+	}{
+		{preset.StrategySkip, []string{"skip", "skip", "add"}, 3},
+		{preset.StrategyOverwrite, []string{"overwrite", "unchanged", "add"}, 3},
+		{preset.StrategyDuplicate, []string{"duplicate", "duplicate", "add"}, 5},
+	}
+	for _, tt := range tests {
+		pm := importFixture(t)
+		report, err := pm.Import(incoming, tt.strategy, false, preset.AnyVersion)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.strategy, err)
+		}
+		for i, c := range report.Changes {
+			if c.Action != tt.actions[i] {
+				t.Errorf("%s: change %d action = %q, want %q", tt.strategy, i, c.Action, tt.actions[i])
+			}
+		}
+		if n := len(pm.Get().Presets); n != tt.count {
+			t.Errorf("%s: %d presets after import, want %d", tt.strategy, n, tt.count)
+		}
+	}
+}
+
+func TestImportOverwriteKeepsExistingID(t *testing.T) {
+	pm := importFixture(t)
+	pack := preset.Pack{Presets: []preset.Preset{{ID: "other", Title: "DEPLOY", Content: "new"}}}
+	if _, err := pm.Import(pack, preset.StrategyOverwrite, false, preset.AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+	p, err := pm.Find("1")
+	if err != nil || p.Content != "new" {
+		t.Fatalf("expected preset 1 overwritten, got %+v", p)
+	}
+}
+
+func TestImportDuplicateRenames(t *testing.T) {
+	pm := importFixture(t)
+	pack := preset.Pack{Presets: []preset.Preset{{Title: "Deploy"}, {Title: "Deploy"}}}
+	report, err := pm.Import(pack, preset.StrategyDuplicate, false, preset.AnyVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Changes[0].Title != "Deploy (copy)" || report.Changes[1].Title != "Deploy (copy 2)" {
+		t.Fatalf("unexpected titles: %+v", report.Changes)
+	}
+}
+
+func TestImportDryRunDoesNotSave(t *testing.T) {
+	pm := importFixture(t)
+	before := pm.Get()
+	report, err := pm.Import(incoming, preset.StrategyOverwrite, true, preset.AnyVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || report.Summary["overwrite"] != 1 || report.Summary["add"] != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if after := pm.Get(); after.Version != before.Version || len(after.Presets) != len(before.Presets) {
+		t.Fatalf("dry run modified the store: %+v", after)
+	}
+}
+
+func TestImportVersionConflict(t *testing.T) {
+	pm := importFixture(t)
+	stale := pm.Get().Version - 1
+	if _, err := pm.Import(incoming, preset.StrategySkip, false, stale); err != preset.ErrVersionConflict {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	if _, err := pm.Import(incoming, "merge", false, preset.AnyVersion); err != preset.ErrUnknownStrategy {
+		t.Fatalf("expected ErrUnknownStrategy, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package preset
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Limits enforced by Validate.
+const (
+	MaxPresets      = 2000
+	MaxIDLen        = 128
+	MaxTitleLen     = 200
+	MaxContentBytes = 128 << 10
+	MaxFolderLen    = 200
+	MaxTags         = 20
+	MaxTagLen       = 50
+	MaxVariables    = 50
+	MaxOptions      = 100
+)
+
+var variableNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// ValidationError is returned by writes whose input fails Validate.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 0 {
+		return "invalid presets"
+	}
+	msg := fmt.Sprintf("invalid presets: %s: %s", e.Errors[0].Field, e.Errors[0].Message)
+	if n := len(e.Errors) - 1; n > 0 {
+		msg += fmt.Sprintf(" (and %d more)", n)
+	}
+	return msg
+}
+
+// validationError wraps errs in a *ValidationError, or returns nil.
+func validationError(errs []FieldError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: errs}
+}
+
+// Validate checks a single preset. Field names are relative to the preset,
+// e.g. "title" or "variables[0].name".
+func (p Preset) Validate() []FieldError {
+	return validatePreset(p, "")
+}
+
+// Validate checks every preset, the preset count and that IDs are unique.
+// Field names are paths such as "presets[3].title".
+func (s PresetStore) Validate() []FieldError {
+	var errs []FieldError
+	if len(s.Presets) > MaxPresets {
+		errs = append(errs, FieldError{Field: "presets", Message: fmt.Sprintf("at most %d presets are allowed", MaxPresets)})
+	}
+	seen := make(map[string]int, len(s.Presets))
+	for i, p := range s.Presets {
+		prefix := fmt.Sprintf("presets[%d].", i)
+		errs = append(errs, validatePreset(p, prefix)...)
+		if first, dup := seen[p.ID]; dup && p.ID != "" {
+			errs = append(errs, FieldError{Field: prefix + "id", Message: fmt.Sprintf("duplicate of presets[%d].id", first)})
+		} else {
+			seen[p.ID] = i
+		}
+	}
+	return errs
+}
+
+func validatePreset(p Preset, prefix string) []FieldError {
+	var errs []FieldError
+	add := func(field, format string, args ...any) {
+		errs = append(errs, FieldError{Field: prefix + field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case p.ID == "":
+		add("id", "is required")
+	case len(p.ID) > MaxIDLen:
+		add("id", "must be at most %d bytes", MaxIDLen)
+	}
+	switch {
+	case strings.TrimSpace(p.Title) == "":
+		add("title", "is required")
+	case utf8.RuneCountInString(p.Title) > MaxTitleLen:
+		add("title", "must be at most %d characters", MaxTitleLen)
+	}
+	if len(p.Content) > MaxContentBytes {
+		add("content", "must be at most %d KiB", MaxContentBytes>>10)
+	}
+	if len(p.Folder) > MaxFolderLen {
+		add("folder", "must be at most %d characters", MaxFolderLen)
+	}
+
+	if len(p.Tags) > MaxTags {
+		add("tags", "at most %d tags are allowed", MaxTags)
+	}
+	for i, tag := range p.Tags {
+		switch {
+		case strings.TrimSpace(tag) == "":
+			add(fmt.Sprintf("tags[%d]", i), "must not be empty")
+		case utf8.RuneCountInString(tag) > MaxTagLen:
+			add(fmt.Sprintf("tags[%d]", i), "must be at most %d characters", MaxTagLen)
+		}
+	}
+
+	if len(p.Variables) > MaxVariables {
+		add("variables", "at most %d variables are allowed", MaxVariables)
+	}
+	names := make(map[string]bool, len(p.Variables))
+	for i, v := range p.Variables {
+		field := fmt.Sprintf("variables[%d].", i)
+		switch {
+		case !variableNameRe.MatchString(v.Name):
+			add(field+"name", "must start with a letter or underscore and contain only letters, digits, _ . -")
+		case names[v.Name]:
+			add(field+"name", "duplicate variable %q", v.Name)
+		}
+		names[v.Name] = true
+
+		switch v.Type {
+		case "", VarString, VarNumber, VarBool, VarDate:
+		case VarEnum:
+			if len(v.Options) == 0 {
+				add(field+"options", "enum variables need at least one option")
+			}
+		default:
+			add(field+"type", "unsupported variable type %q", v.Type)
+			continue
+		}
+		if len(v.Options) > MaxOptions {
+			add(field+"options", "at most %d options are allowed", MaxOptions)
+		}
+		if v.Default != "" && (v.Type != VarEnum || len(v.Options) > 0) {
+			if msg := checkType(v, v.Default); msg != "" {
+				add(field+"default", "%s", msg)
+			}
+		}
+	}
+	return errs
+}
+
+// assignIDs gives presets without an ID a generated one, and reports whether
+// any were assigned.
+func assignIDs(presets []Preset) bool {
+	changed := false
+	for i := range presets {
+		if presets[i].ID == "" {
+			presets[i].ID = uuid.New().String()
+			changed = true
+		}
+	}
+	return changed
+}
+
+// repair fixes integrity problems in a store read from disk that would
+// otherwise make presets unaddressable: missing IDs are generated and
+// repeated IDs replaced. It reports whether anything changed.
+func repair(store *PresetStore) bool {
+	changed := assignIDs(store.Presets)
+	seen := make(map[string]bool, len(store.Presets))
+	for i := range store.Presets {
+		if seen[store.Presets[i].ID] {
+			store.Presets[i].ID = uuid.New().String()
+			changed = true
+		}
+		seen[store.Presets[i].ID] = true
+	}
+	return changed
+}
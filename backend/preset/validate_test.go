@@ -0,0 +1,110 @@
+package preset_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"web-terminal/preset"
+)
+
+func fields(errs []preset.FieldError) []string {
+	var out []string
+	for _, e := range errs {
+		out = append(out, e.Field)
+	}
+	return out
+}
+
+func TestValidateStore(t *testing.T) {
+	store := preset.PresetStore{Presets: []preset.Preset{
+		{ID: "a", Title: "A"},
+		{ID: "a", Title: " "},
+		{ID: "c", Title: "C", Content: strings.Repeat("x", preset.MaxContentBytes+1), Tags: []string{""}},
+		{ID: "d", Title: "D", Variables: []preset.Variable{
+			{Name: "1bad"},
+			{Name: "env", Type: preset.VarEnum},
+			{Name: "n", Type: preset.VarNumber, Default: "many"},
+			{Name: "n"},
+			{Name: "x", Type: "color"},
+		}},
+	}}
+	got := strings.Join(fields(store.Validate()), " ")
+	want := "presets[1].title presets[1].id presets[2].content presets[2].tags[0] " +
+		"presets[3].variables[0].name presets[3].variables[1].options presets[3].variables[2].default " +
+		"presets[3].variables[3].name presets[3].variables[4].type"
+	if got != want {
+		t.Fatalf("Validate fields:\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestSaveRejectsInvalidStore(t *testing.T) {
+	pm, _ := preset.NewManager(t.TempDir() + "/presets.json")
+	err := pm.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: ""}}})
+	var invalid *preset.ValidationError
+	if !errors.As(err, &invalid) || invalid.Errors[0].Field != "presets[0].title" {
+		t.Fatalf("expected ValidationError for title, got %v", err)
+	}
+	if _, _, err := pm.Add(preset.Preset{Title: strings.Repeat("t", preset.MaxTitleLen+1)}, preset.AnyVersion); !errors.As(err, &invalid) {
+		t.Fatalf("expected ValidationError from Add, got %v", err)
+	}
+	if len(pm.Get().Presets) != 0 {
+		t.Fatal("invalid presets were stored")
+	}
+}
+
+func TestSaveGeneratesMissingIDs(t *testing.T) {
+	pm, _ := preset.NewManager(t.TempDir() + "/presets.json")
+	store, err := pm.SaveIf(preset.PresetStore{Presets: []preset.Preset{{Title: "A"}, {Title: "B"}}}, preset.AnyVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Presets[0].ID == "" || store.Presets[0].ID == store.Presets[1].ID {
+		t.Fatalf("expected distinct generated IDs, got %+v", store.Presets)
+	}
+}
+
+func TestCorruptFileIsQuarantined(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "presets.json")
+	os.WriteFile(path, []byte(`{"presets": [`), 0600)
+
+	pm, err := preset.NewManager(path)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if n := len(pm.Get().Presets); n != 0 {
+		t.Fatalf("expected empty store, got %d presets", n)
+	}
+	moved, _ := filepath.Glob(path + ".corrupt-*")
+	if len(moved) != 1 {
+		t.Fatalf("expected one quarantined file, got %v", moved)
+	}
+	if data, _ := os.ReadFile(moved[0]); string(data) != `{"presets": [` {
+		t.Fatalf("quarantined file content = %q", data)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected original file moved away, stat err = %v", err)
+	}
+}
+
+func TestLoadRepairsIDs(t *testing.T) {
+	path := t.TempDir() + "/presets.json"
+	os.WriteFile(path, []byte(`{"presets":[{"id":"a","title":"A"},{"id":"a","title":"B"},{"title":"C"}]}`), 0600)
+
+	pm, err := preset.NewManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := pm.Get().Presets
+	if ps[0].ID != "a" || ps[1].ID == "a" || ps[2].ID == "" {
+		t.Fatalf("IDs not repaired: %+v", ps)
+	}
+	// The repair is written back so IDs stay stable.
+	pm2, _ := preset.NewManager(path)
+	if pm2.Get().Presets[2].ID != ps[2].ID {
+		t.Fatal("repaired IDs were not persisted")
+	}
+}
@@ -0,0 +1,137 @@
+package preset
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+)
+
+// Sources of a Change.
+const (
+	SourceAPI  = "api"  // written through the Manager
+	SourceDisk = "disk" // edited outside the server and reloaded
+)
+
+// Change describes a new version of the store, passed to OnChange listeners.
+type Change struct {
+	Version int64  `json:"version"`
+	Source  string `json:"source"`
+}
+
+// OnChange registers fn to be called after every new store version. fn runs
+// with the manager locked, so it must not block or call back into m.
+func (m *Manager) OnChange(fn func(Change)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// OnDiff registers fn to be called after every new store version with what
+// changed in the preset list. Like OnChange listeners, fn runs with the
+// manager locked.
+func (m *Manager) OnDiff(fn func(Change, Diff)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.diffListeners = append(m.diffListeners, fn)
+}
+
+// notify calls the OnChange and OnDiff listeners. Caller must hold m.mu.
+func (m *Manager) notify(c Change, d Diff) {
+	for _, fn := range m.listeners {
+		fn(c)
+	}
+	for _, fn := range m.diffListeners {
+		fn(c, d)
+	}
+}
+
+// Watch polls the store file every interval until ctx is done and reloads
+// edits made outside the server. Polling rather than inotify keeps working
+// when editors or git replace the file instead of writing it in place.
+func (m *Manager) Watch(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			m.mu.Lock()
+			m.refresh()
+			m.mu.Unlock()
+		}
+	}
+}
+
+// diskState identifies the contents of the store file as last seen by m.
+type diskState struct {
+	modTime time.Time
+	size    int64
+	sum     [sha256.Size]byte
+}
+
+// remember records data, just read from or written to the store file, as the
+// known disk contents. Caller must hold m.mu.
+func (m *Manager) remember(data []byte) {
+	m.disk.sum = sha256.Sum256(data)
+	if info, err := os.Stat(m.filePath); err == nil {
+		m.disk.modTime, m.disk.size = info.ModTime(), info.Size()
+	}
+}
+
+// refresh reloads the store if the file was changed by anything other than
+// m. The reload gets a new version, so API writes prepared against the old
+// one fail with ErrVersionConflict. An unreadable or invalid file is logged
+// and the last good state kept. Caller must hold m.mu.
+func (m *Manager) refresh() {
+	info, err := os.Stat(m.filePath)
+	if err != nil || (info.ModTime().Equal(m.disk.modTime) && info.Size() == m.disk.size) {
+		return
+	}
+	data, err := os.ReadFile(m.filePath)
+	if err != nil {
+		log.Printf("presets: reload %s: %v", m.filePath, err)
+		return
+	}
+	sum := sha256.Sum256(data)
+	m.disk.modTime, m.disk.size = info.ModTime(), info.Size()
+	if sum == m.disk.sum {
+		return
+	}
+	m.disk.sum = sum
+
+	var store PresetStore
+	if err := json.Unmarshal(data, &store); err != nil {
+		log.Printf("presets: ignoring invalid %s, keeping last good state: %v", m.filePath, err)
+		return
+	}
+	if store.Presets == nil {
+		store.Presets = []Preset{}
+	}
+	if store.RecentlyUsed == nil {
+		store.RecentlyUsed = []string{}
+	}
+	repaired := repair(&store)
+	if errs := store.Validate(); len(errs) > 0 {
+		log.Printf("presets: ignoring invalid %s, keeping last good state: %v", m.filePath, &ValidationError{Errors: errs})
+		return
+	}
+
+	now := time.Now()
+	store.Version = m.store.Version + 1
+	store.Presets = stampPresets(m.store.Presets, store.Presets, now)
+	m.record(m.store, store, "reloaded from disk", now)
+	diff := DiffPresets(m.store.Presets, store.Presets)
+	m.store = store
+	if repaired {
+		// Persist generated IDs so they stay stable across reloads.
+		if err := m.writeAtomic(store); err != nil {
+			log.Printf("presets: %v", err)
+		}
+	}
+	log.Printf("presets: reloaded %s (version %d)", m.filePath, store.Version)
+	m.notify(Change{Version: store.Version, Source: SourceDisk}, diff)
+}
@@ -0,0 +1,106 @@
+package preset_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"web-terminal/preset"
+)
+
+func TestExternalEditIsReloaded(t *testing.T) {
+	path := t.TempDir() + "/presets.json"
+	pm, _ := preset.NewManager(path)
+	var changes []preset.Change
+	pm.OnChange(func(c preset.Change) { changes = append(changes, c) })
+
+	pm.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "A"}}})
+	stale := pm.Get().Version
+
+	os.WriteFile(path, []byte(`{"presets":[{"id":"a","title":"Edited"},{"id":"b","title":"B"}]}`), 0600)
+
+	// An API write prepared against the old version now conflicts.
+	if _, err := pm.Update(preset.Preset{ID: "a", Title: "Mine"}, stale); err != preset.ErrVersionConflict {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+	store := pm.Get()
+	if len(store.Presets) != 2 || store.Presets[0].Title != "Edited" || store.Version != stale+1 {
+		t.Fatalf("external edit not loaded: %+v", store)
+	}
+	if len(changes) != 2 || changes[1] != (preset.Change{Version: stale + 1, Source: preset.SourceDisk}) {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	if got := pm.History()[0].Summary; got != "reloaded from disk: added 1, updated 1" {
+		t.Fatalf("history summary = %q", got)
+	}
+}
+
+func TestOwnWritesAreNotReloaded(t *testing.T) {
+	pm, _ := preset.NewManager(t.TempDir() + "/presets.json")
+	pm.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "a"}}})
+	pm.MarkUsed("a")
+	v := pm.Get().Version
+	if _, err := pm.Update(preset.Preset{ID: "a", Title: "x"}, v); err != nil {
+		t.Fatalf("Update after own writes: %v", err)
+	}
+}
+
+func TestInvalidEditKeepsLastGoodState(t *testing.T) {
+	path := t.TempDir() + "/presets.json"
+	pm, _ := preset.NewManager(path)
+	pm.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "A"}}})
+	before := pm.Get()
+
+	os.WriteFile(path, []byte(`{"presets": [`), 0600)
+	if err := pm.MarkUsed("a"); err != nil {
+		t.Fatalf("MarkUsed: %v", err)
+	}
+	after := pm.Get()
+	if after.Version != before.Version || len(after.Presets) != 1 {
+		t.Fatalf("invalid file replaced state: %+v", after)
+	}
+}
+
+func TestWatchPollsFile(t *testing.T) {
+	path := t.TempDir() + "/presets.json"
+	pm, _ := preset.NewManager(path)
+	changed := make(chan preset.Change, 1)
+	pm.OnChange(func(c preset.Change) { changed <- c })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pm.Watch(ctx, 10*time.Millisecond)
+
+	os.WriteFile(path, []byte(`{"presets":[{"id":"a","title":"A"}]}`), 0600)
+	select {
+	case c := <-changed:
+		if c.Source != preset.SourceDisk || c.Version != 1 {
+			t.Fatalf("unexpected change: %+v", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher did not pick up the edit")
+	}
+}
+
+func TestOnDiff(t *testing.T) {
+	pm, _ := preset.NewManager(t.TempDir() + "/presets.json")
+	pm.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "A"}, {ID: "b", Title: "B"}}})
+	var diffs []preset.Diff
+	pm.OnDiff(func(c preset.Change, d preset.Diff) { diffs = append(diffs, d) })
+
+	pm.Save(preset.PresetStore{Presets: []preset.Preset{{ID: "a", Title: "A2"}, {ID: "c", Title: "C"}}})
+	pm.Save(pm.Get())
+
+	if len(diffs) != 2 {
+		t.Fatalf("expected 2 diffs, got %+v", diffs)
+	}
+	d := diffs[0]
+	if len(d.Added) != 1 || d.Added[0].ID != "c" || len(d.Removed) != 1 || d.Removed[0].ID != "b" ||
+		len(d.Updated) != 1 || d.Updated[0].Before.Title != "A" || d.Updated[0].After.Title != "A2" {
+		t.Fatalf("unexpected diff %+v", d)
+	}
+	if !diffs[1].Empty() {
+		t.Fatalf("expected saving the same presets to change nothing, got %+v", diffs[1])
+	}
+}
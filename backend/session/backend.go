@@ -0,0 +1,79 @@
+package session
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+)
+
+// Built-in backends.
+const (
+	BackendPTY     = "pty"     // local shell on a PTY
+	BackendSSH     = "ssh"     // remote shell through the built-in SSH client
+	BackendNsenter = "nsenter" // local shell inside another process's namespaces
+	BackendMock    = "mock"    // in-process echo, for tests
+)
+
+var ErrUnknownBackend = errors.New("unknown session backend")
+
+// Backend runs a session's shell. The session reads the shell's output with
+// Read until it fails, then calls Wait; every other method may be called
+// concurrently with Read.
+type Backend interface {
+	// Start launches the shell described by spec.
+	Start(spec Spec) error
+	// Read returns the shell's terminal output. Any error, usually io.EOF,
+	// means the shell is gone.
+	Read(p []byte) (int, error)
+	// Write sends input to the shell's terminal.
+	Write(p []byte) (int, error)
+	Resize(cols, rows uint16) error
+	Signal(sig os.Signal) error
+	// Wait releases the shell's resources after Read has failed and
+	// returns how it ended.
+	Wait() error
+	// Close stops the shell, making Read fail.
+	Close() error
+}
+
+// BackendFactory creates the backend for a new session.
+type BackendFactory func(s *Session) Backend
+
+// readyBackend is implemented by backends whose shell starts some time
+// after Start returns; output before then is not from the shell.
+type readyBackend interface {
+	Ready() <-chan struct{}
+}
+
+// cwdBackend is implemented by backends whose shell runs in a directory of
+// the backend's own filesystem.
+type cwdBackend interface {
+	Cwd() (string, error)
+}
+
+// pump feeds the backend's output to the session until the shell is gone,
+// then ends the session.
+func (s *Session) pump(onExit func(id string)) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := s.shell.Read(buf)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buf[:n])
+			s.handleOutput(data)
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("session %s read error: %v", s.ID, err)
+			}
+			break
+		}
+	}
+	if s.lines != nil {
+		close(s.lines)
+	}
+	_ = s.shell.Wait()
+	close(s.done)
+	onExit(s.ID)
+}
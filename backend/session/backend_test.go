@@ -0,0 +1,122 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// recordingBackend wraps the mock backend and records the calls made to it.
+type recordingBackend struct {
+	mockBackend
+	spec    Spec
+	signals []os.Signal
+}
+
+func (b *recordingBackend) Start(spec Spec) error {
+	b.spec = spec
+	return b.mockBackend.Start(spec)
+}
+
+func (b *recordingBackend) Signal(sig os.Signal) error {
+	b.signals = append(b.signals, sig)
+	return nil
+}
+
+func TestBackendSelection(t *testing.T) {
+	m := NewManagerWithBackend(BackendMock)
+	var rec *recordingBackend
+	m.RegisterBackend("custom", func(*Session) Backend {
+		rec = &recordingBackend{}
+		return rec
+	})
+
+	s, err := m.CreateWithSpec("custom", Spec{Backend: "custom", Shell: "fish"})
+	if err != nil {
+		t.Fatalf("CreateWithSpec: %v", err)
+	}
+	if s.Backend != "custom" || rec.spec.Shell != "fish" {
+		t.Fatalf("custom backend not used: %q %+v", s.Backend, rec.spec)
+	}
+	s.WriteToPTY([]byte("ping"))
+	waitFor(t, "echo", func() bool { return bytes.Equal(s.ScrollbackSnapshot(), []byte("ping")) })
+	s.Signal(syscall.SIGINT)
+	if !slices.Equal(rec.signals, []os.Signal{syscall.SIGINT}) {
+		t.Fatalf("signal not delivered: %v", rec.signals)
+	}
+
+	def, _ := m.Create("default")
+	if def.Backend != BackendMock {
+		t.Fatalf("expected the default backend, got %q", def.Backend)
+	}
+	if _, err := m.CreateWithSpec("x", Spec{Backend: "nope"}); !errors.Is(err, ErrUnknownBackend) {
+		t.Fatalf("expected ErrUnknownBackend, got %v", err)
+	}
+	if _, err := m.CreateWithSpec("y", Spec{Namespace: &Namespace{}}); !errors.Is(err, ErrInvalidNamespace) {
+		t.Fatalf("expected ErrInvalidNamespace for a namespace without pid, got %v", err)
+	}
+	if _, err := m.CreateWithSpec("z", Spec{Backend: BackendSSH}); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("expected ErrInvalidTarget for ssh without a target, got %v", err)
+	}
+}
+
+func TestPTYBackend(t *testing.T) {
+	m := NewManager()
+	s, err := m.CreateWithSpec("pty", Spec{Shell: "sh", Cwd: os.TempDir()})
+	if err != nil {
+		t.Fatalf("CreateWithSpec: %v", err)
+	}
+	if s.Backend != BackendPTY || s.PID() == 0 {
+		t.Fatalf("unexpected backend %q pid %d", s.Backend, s.PID())
+	}
+	if cwd, err := s.Cwd(); err != nil || cwd != os.TempDir() {
+		t.Fatalf("Cwd = %q, %v", cwd, err)
+	}
+	if err := s.Resize(100, 30); err != nil {
+		t.Fatalf("Resize: %v", err)
+	}
+	s.WriteToPTY([]byte("stty size; exit\r"))
+	select {
+	case <-s.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("session did not end when the shell exited")
+	}
+	if !strings.Contains(string(s.ScrollbackSnapshot()), "30 100") {
+		t.Fatalf("resize not applied: %q", s.ScrollbackSnapshot())
+	}
+	if _, ok := m.Get(s.ID); ok {
+		t.Fatal("ended session still listed")
+	}
+}
+
+func TestNsenterCommand(t *testing.T) {
+	cmd, err := nsenterCommand(Spec{Namespace: &Namespace{PID: 42}, Cwd: "/app", Env: map[string]string{"A": "1"}})
+	if err != nil {
+		t.Fatalf("nsenterCommand: %v", err)
+	}
+	want := []string{"nsenter", "--target", "42", "--all", "--root", "--wd=/app", "--", "sh"}
+	if !slices.Equal(cmd.Args, want) {
+		t.Fatalf("args = %v, want %v", cmd.Args, want)
+	}
+	if cmd.Dir != "" || cmd.Env[len(cmd.Env)-1] != "A=1" {
+		t.Fatalf("unexpected dir %q or env %v", cmd.Dir, cmd.Env)
+	}
+
+	cmd, err = nsenterCommand(Spec{Namespace: &Namespace{PID: 42, Types: []string{"net"}}, Shell: "bash", Args: []string{"-l"}})
+	if err != nil {
+		t.Fatalf("nsenterCommand: %v", err)
+	}
+	want = []string{"nsenter", "--target", "42", "--net", "--", "bash", "-l"}
+	if !slices.Equal(cmd.Args, want) {
+		t.Fatalf("args = %v, want %v", cmd.Args, want)
+	}
+
+	if _, err := nsenterCommand(Spec{Namespace: &Namespace{PID: 42, Types: []string{"disk"}}}); !errors.Is(err, ErrInvalidNamespace) {
+		t.Fatalf("expected ErrInvalidNamespace, got %v", err)
+	}
+}
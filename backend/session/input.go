@@ -0,0 +1,86 @@
+package session
+
+import (
+	"sync"
+	"unicode/utf8"
+)
+
+// maxInputLine bounds the lines passed to OnInput hooks; longer input
+// without Enter is cut.
+const maxInputLine = 4096
+
+// inputBuffer assembles the bytes written to a session's shell into the
+// lines that were typed. Backspace removes a character and Ctrl-U the whole
+// line; Enter ends it and Ctrl-C abandons it. Escape sequences, such as
+// arrow keys and bracketed paste markers, and other control characters are
+// dropped, so a line edited with the cursor keys or recalled from the
+// shell's history is only approximated.
+type inputBuffer struct {
+	mu    sync.Mutex
+	state int
+	buf   []byte
+}
+
+// Input parser states.
+const (
+	inputText = iota
+	inputEsc
+	inputCSI // CSI or SS3, up to the final byte
+)
+
+// feed processes written bytes and returns the non-empty lines they end.
+func (b *inputBuffer) feed(data []byte) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var lines []string
+	for _, c := range data {
+		switch b.state {
+		case inputEsc:
+			b.state = inputText
+			if c == '[' || c == 'O' {
+				b.state = inputCSI
+			}
+			continue
+		case inputCSI:
+			if c >= 0x40 && c <= 0x7e {
+				b.state = inputText
+			}
+			continue
+		}
+		switch {
+		case c == '\r' || c == '\n':
+			if len(b.buf) > 0 {
+				lines = append(lines, string(b.buf))
+			}
+			b.buf = b.buf[:0]
+		case c == 0x1b:
+			b.state = inputEsc
+		case c == 0x7f || c == '\b':
+			if len(b.buf) > 0 {
+				_, size := utf8.DecodeLastRune(b.buf)
+				b.buf = b.buf[:len(b.buf)-size]
+			}
+		case c == 0x03 || c == 0x15: // Ctrl-C, Ctrl-U
+			b.buf = b.buf[:0]
+		case c < 0x20 && c != '\t':
+		case len(b.buf) < maxInputLine:
+			b.buf = append(b.buf, c)
+		}
+	}
+	return lines
+}
+
+// tapInput passes the lines completed by input written to the shell to the
+// session's input hooks. Input during a file transfer is protocol data, not
+// typing, and is skipped.
+func (s *Session) tapInput(p []byte) {
+	s.outMu.Lock()
+	transferring := s.transfer != nil
+	s.outMu.Unlock()
+	if transferring {
+		return
+	}
+	for _, line := range s.input.feed(p) {
+		s.onInput(s, line)
+	}
+}
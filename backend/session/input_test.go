@@ -0,0 +1,50 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInputBufferEditing(t *testing.T) {
+	var b inputBuffer
+	// Typed a key at a time, with a typo fixed, an arrow key and a
+	// pasted line wrapped in bracketed paste markers.
+	var got []string
+	for _, c := range []byte("lx\x7fs -l\x1b[D\r") {
+		got = append(got, b.feed([]byte{c})...)
+	}
+	got = append(got, b.feed([]byte("\x1b[200~echo hi\x1b[201~\r\n"))...)
+	want := []string{"ls -l", "echo hi"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestInputBufferAbandonedLines(t *testing.T) {
+	var b inputBuffer
+	got := b.feed([]byte("rm -rf /\x03\rdate\x15uptime\r\r"))
+	if want := []string{"uptime"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	got = b.feed([]byte("héllo\x7f\x7f\x7f\x7flo\r"))
+	if want := []string{"hlo"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestOnInput(t *testing.T) {
+	m := NewManagerWithBackend(BackendMock)
+	var got []string
+	m.OnInput(func(s *Session, line string) { got = append(got, s.Name+": "+line) })
+	s, err := m.Create("typing")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer m.Kill(s.ID)
+
+	s.WriteToPTY([]byte("whoami"))
+	s.WriteToPTY([]byte("\r"))
+	if want := []string{"typing: whoami"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
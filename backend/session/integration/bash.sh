@@ -0,0 +1,117 @@
+# web-terminal shell integration for bash.
+#
+# Reports command boundaries (OSC 133), the command line (OSC 633;E) and the
+# working directory (OSC 7) to the terminal. Sessions started by
+# web-terminal load this from a PROMPT_COMMAND passed in the environment,
+# once the login profile has run; elsewhere, source it from ~/.bashrc.
+
+# Loaded by that PROMPT_COMMAND: take it out again, keeping whatever the
+# profile added, and stop handing it to child processes.
+if [ -n "$WEB_TERMINAL_BASH_INTEGRATION" ]; then
+  __wt_boot='. "$WEB_TERMINAL_BASH_INTEGRATION" && __wt_precmd && __wt_trap=$(trap -p DEBUG) && __wt_start'
+  PROMPT_COMMAND=${PROMPT_COMMAND//"$__wt_boot"/}
+  while [[ $PROMPT_COMMAND == *';;'* ]]; do
+    PROMPT_COMMAND=${PROMPT_COMMAND//;;/;}
+  done
+  PROMPT_COMMAND=${PROMPT_COMMAND#"${PROMPT_COMMAND%%[!;[:space:]]*}"}
+  PROMPT_COMMAND=${PROMPT_COMMAND%"${PROMPT_COMMAND##*[!;[:space:]]}"}
+  export -n PROMPT_COMMAND
+  unset WEB_TERMINAL_BASH_INTEGRATION __wt_boot
+fi
+
+if [[ $- == *i* && -z $__wt_installed ]]; then
+  __wt_installed=1
+  __wt_ready=0
+  __wt_running=0
+
+  __wt_osc() {
+    printf '\033]%s\007' "$1"
+  }
+
+  # Escapes a command line for OSC 633;E: backslashes are doubled, and
+  # semicolons and control characters written as \xNN.
+  __wt_escape() {
+    local s=$1 out= c i
+    for ((i = 0; i < ${#s}; i++)); do
+      c=${s:i:1}
+      case $c in
+        \\) out+='\\\\' ;;
+        ';') out+='\x3b' ;;
+        [[:cntrl:]]) printf -v c '\\x%02x' "'$c"; out+=$c ;;
+        *) out+=$c ;;
+      esac
+    done
+    printf '%s' "$out"
+  }
+
+  __wt_cwd() {
+    local path=$PWD out= c i
+    for ((i = 0; i < ${#path}; i++)); do
+      c=${path:i:1}
+      case $c in
+        [a-zA-Z0-9/._~-]) out+=$c ;;
+        *) printf -v c '%%%02X' "'$c"; out+=$c ;;
+      esac
+    done
+    __wt_osc "7;file://${HOSTNAME}${out}"
+  }
+
+  # Runs first in PROMPT_COMMAND, while $? is still the command's status.
+  __wt_precmd() {
+    local status=$?
+    if [ "$__wt_running" = 1 ]; then
+      __wt_osc "133;D;$status"
+      __wt_running=0
+    fi
+    __wt_cwd
+  }
+
+  # Runs last in PROMPT_COMMAND, so the DEBUG trap only sees commands typed
+  # at the prompt.
+  __wt_arm() {
+    __wt_ready=1
+  }
+
+  # Returns the status it was called with, for a DEBUG trap chained after
+  # it.
+  __wt_preexec() {
+    local status=$?
+    [ -n "$__wt_ready" ] && [ "$__wt_ready" != 0 ] || return $status
+    [ -n "$COMP_LINE" ] && return $status
+    local cmd
+    cmd=$(HISTTIMEFORMAT= builtin history 1)
+    # Armed by __wt_start, the rest of that PROMPT_COMMAND may still be
+    # running: wait for a command to reach the history.
+    [ "$__wt_ready" = 1 ] || [ "$cmd" != "$__wt_last" ] || return $status
+    __wt_ready=0
+    cmd=${cmd#"${cmd%%[![:space:]]*}"}
+    cmd=${cmd#*[[:space:]]}
+    cmd=${cmd#"${cmd%%[![:space:]]*}"}
+    __wt_osc "633;E;$(__wt_escape "$cmd")"
+    __wt_osc "133;C"
+    __wt_running=1
+    return $status
+  }
+
+  # Sets the DEBUG trap, keeping one set before in $__wt_trap, and arms
+  # it for the next command in the history. PROMPT_COMMAND runs this once, after reading the trap itself: bash
+  # hides an earlier DEBUG trap from this file while it is sourced, and
+  # from functions.
+  __wt_start() {
+    PROMPT_COMMAND=${PROMPT_COMMAND//"$__wt_once"/}
+    local prev=${__wt_trap#"trap -- '"}
+    prev=${prev%"' DEBUG"}
+    prev=${prev//"'\''"/"'"}
+    unset __wt_trap
+    [ -n "$__wt_started" ] && return
+    __wt_started=1
+    __wt_last=$(HISTTIMEFORMAT= builtin history 1)
+    __wt_ready=2
+    # Last, as the trap already runs for the commands after it here.
+    trap "__wt_preexec${prev:+; $prev}" DEBUG
+  }
+
+  __wt_once=';__wt_trap=$(trap -p DEBUG);__wt_start'
+  PROMPT_COMMAND="__wt_precmd${PROMPT_COMMAND:+;$PROMPT_COMMAND};__wt_arm$__wt_once"
+  PS1="\[\e]133;A\a\]${PS1}\[\e]133;B\a\]"
+fi
@@ -0,0 +1,49 @@
+# web-terminal shell integration for zsh.
+#
+# Reports command boundaries (OSC 133), the command line (OSC 633;E) and the
+# working directory (OSC 7) to the terminal. Source it from ~/.zshrc.
+
+if [[ -o interactive && -z $__wt_installed ]]; then
+  __wt_installed=1
+  __wt_running=0
+
+  __wt_osc() {
+    printf '\033]%s\007' "$1"
+  }
+
+  # Escapes a command line for OSC 633;E: backslashes are doubled, and
+  # semicolons and control characters written as \xNN.
+  __wt_escape() {
+    local s=$1 out= c i
+    for ((i = 1; i <= ${#s}; i++)); do
+      c=${s[i]}
+      case $c in
+        \\) out+='\\\\' ;;
+        ';') out+='\x3b' ;;
+        [[:cntrl:]]) out+=$(printf '\\x%02x' "'$c") ;;
+        *) out+=$c ;;
+      esac
+    done
+    printf '%s' "$out"
+  }
+
+  __wt_precmd() {
+    local ret=$?
+    if [[ $__wt_running == 1 ]]; then
+      __wt_osc "133;D;$ret"
+      __wt_running=0
+    fi
+    __wt_osc "7;file://${HOST}${PWD// /%20}"
+  }
+
+  __wt_preexec() {
+    __wt_osc "633;E;$(__wt_escape "$1")"
+    __wt_osc "133;C"
+    __wt_running=1
+  }
+
+  autoload -Uz add-zsh-hook
+  add-zsh-hook precmd __wt_precmd
+  add-zsh-hook preexec __wt_preexec
+  PS1=$'%{\e]133;A\a%}'"$PS1"$'%{\e]133;B\a%}'
+fi
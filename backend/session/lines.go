@@ -0,0 +1,179 @@
+package session
+
+// maxLine bounds the lines passed to OnLine hooks; longer runs of output
+// without a newline are split.
+const maxLine = 4096
+
+// lineQueue is how many output chunks may wait for a session's line hooks.
+// The read loop never waits for them: chunks arriving while the queue is
+// full are not seen by the hooks.
+const lineQueue = 256
+
+// Line is a line of a session's terminal output with escape sequences and
+// other control characters removed.
+type Line struct {
+	// N numbers the session's lines from 1. A partial line and the lines
+	// that complete it share N.
+	N    int64
+	Text string
+	// Partial is set when the line has no newline yet, such as a prompt
+	// waiting for input. It is passed again, extended, as more output
+	// arrives.
+	Partial bool
+}
+
+// Highlight marks text in the session's recent output, such as a line
+// found by an OnLine hook.
+type Highlight struct {
+	Text  string `json:"text"`
+	Color string `json:"color,omitempty"` // CSS color; empty leaves it to the client
+}
+
+// Highlight asks the connected client, if any, to mark h.Text where it last
+// appears in its terminal.
+func (s *Session) Highlight(h Highlight) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.send(Output{Highlight: &h})
+}
+
+// lineBuffer splits terminal output into lines of plain text.
+type lineBuffer struct {
+	state int
+	buf   []byte
+	cr    bool  // a carriage return moved the cursor to the start of the line
+	dirty bool  // buf changed since it was last passed on
+	n     int64 // number of the current line
+	open  bool  // the current line has been numbered
+}
+
+// Parser states, following escape sequences only far enough to skip them.
+const (
+	lineText = iota
+	lineEsc
+	lineEscIntermediate
+	lineCSI
+	lineString    // OSC, DCS, APC, PM or SOS, up to BEL or ST
+	lineStringEsc // ESC inside a string, possibly starting ST
+)
+
+// feed processes a chunk of output and returns the lines it completes,
+// followed by the partial line at its end if that changed.
+func (b *lineBuffer) feed(data []byte) []Line {
+	var lines []Line
+	emit := func(partial bool) {
+		if partial && !b.dirty {
+			return
+		}
+		if !b.open {
+			b.n++
+			b.open = true
+		}
+		lines = append(lines, Line{N: b.n, Text: string(b.buf), Partial: partial})
+		b.dirty = false
+		if !partial {
+			b.buf = b.buf[:0]
+			b.open = false
+		}
+	}
+	for _, c := range data {
+		switch b.state {
+		case lineText:
+			switch {
+			case c == '\n':
+				emit(false)
+				b.cr = false
+			case c == '\r':
+				b.cr = true
+			case c == 0x1b:
+				b.state = lineEsc
+			case c == '\b':
+				b.backspace()
+			case c == '\t' || c >= 0x20 && c != 0x7f:
+				if b.cr {
+					// Text after a bare carriage return overwrites the line,
+					// as progress bars do; keep only the newest.
+					b.buf = b.buf[:0]
+					b.cr = false
+				}
+				b.buf = append(b.buf, c)
+				b.dirty = true
+				if len(b.buf) >= maxLine {
+					emit(false)
+				}
+			}
+		case lineEsc:
+			switch {
+			case c == '[':
+				b.state = lineCSI
+			case c == ']' || c == 'P' || c == '_' || c == '^' || c == 'X':
+				b.state = lineString
+			case c >= 0x20 && c <= 0x2f:
+				b.state = lineEscIntermediate
+			default:
+				b.state = lineText
+			}
+		case lineEscIntermediate:
+			if c < 0x20 || c > 0x2f {
+				b.state = lineText
+			}
+		case lineCSI:
+			if c >= 0x40 && c <= 0x7e {
+				b.state = lineText
+			}
+		case lineString:
+			switch c {
+			case 0x07:
+				b.state = lineText
+			case 0x1b:
+				b.state = lineStringEsc
+			}
+		case lineStringEsc:
+			if c == '\\' {
+				b.state = lineText
+			} else {
+				b.state = lineString
+			}
+		}
+	}
+	if len(b.buf) > 0 {
+		emit(true)
+	}
+	return lines
+}
+
+// backspace removes the last character, including all bytes of a UTF-8
+// sequence.
+func (b *lineBuffer) backspace() {
+	i := len(b.buf) - 1
+	for i > 0 && b.buf[i]&0xc0 == 0x80 {
+		i--
+	}
+	if i >= 0 {
+		b.buf = b.buf[:i]
+		b.dirty = true
+	}
+}
+
+// tapLines queues terminal output for the session's line hooks without
+// blocking. outMu must be held.
+func (s *Session) tapLines(data []byte) {
+	if s.lines == nil {
+		return
+	}
+	select {
+	case s.lines <- data:
+	default:
+	}
+}
+
+// watchLines passes the session's output to fn line by line until the
+// session ends.
+func (s *Session) watchLines(fn func(*Session, Line)) {
+	var b lineBuffer
+	for data := range s.lines {
+		for _, l := range b.feed(data) {
+			fn(s, l)
+		}
+	}
+}
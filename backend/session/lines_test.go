@@ -0,0 +1,82 @@
+package session
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLineBufferStripsEscapes(t *testing.T) {
+	var b lineBuffer
+	got := b.feed([]byte("\x1b[1;31mred\x1b[0m \x1b]0;title\x07text\x1b(B\r\n" +
+		"\x1bP1$r0m\x1b\\plain\n"))
+	want := []Line{{N: 1, Text: "red text"}, {N: 2, Text: "plain"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestLineBufferPartialLines(t *testing.T) {
+	var b lineBuffer
+	// A prompt without a newline is passed on as partial, and again as
+	// it grows; sequences split across chunks are still skipped.
+	got := b.feed([]byte("done\nPassword: \x1b[3"))
+	want := []Line{{N: 1, Text: "done"}, {N: 2, Text: "Password: ", Partial: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if got := b.feed([]byte("3m")); len(got) != 0 {
+		t.Fatalf("unchanged partial line passed on again: %+v", got)
+	}
+	got = b.feed([]byte("x\bhunter2\r\n"))
+	want = []Line{{N: 2, Text: "Password: hunter2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestLineBufferCarriageReturn(t *testing.T) {
+	var b lineBuffer
+	got := b.feed([]byte(" 10%\r 50%\r100%\n"))
+	want := []Line{{N: 1, Text: "100%"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestLineBufferSplitsLongLines(t *testing.T) {
+	var b lineBuffer
+	got := b.feed([]byte(strings.Repeat("a", maxLine+10)))
+	if len(got) != 2 || len(got[0].Text) != maxLine || got[0].Partial || !got[1].Partial || got[1].N != 2 {
+		t.Fatalf("unexpected lines %+v", got)
+	}
+}
+
+func TestOnLine(t *testing.T) {
+	m := NewManagerWithBackend(BackendMock)
+	got := make(chan Line, 16)
+	m.OnLine(func(s *Session, l Line) {
+		if !l.Partial {
+			got <- l
+		}
+	})
+	s, err := m.CreateWithSpec("lines", Spec{Tags: []string{"ci"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer m.Kill(s.ID)
+	if !reflect.DeepEqual(s.Tags, []string{"ci"}) {
+		t.Fatalf("expected tags from the spec, got %v", s.Tags)
+	}
+
+	s.WriteToPTY([]byte("\x1b[32mok\x1b[0m\r\n"))
+	select {
+	case l := <-got:
+		if l.Text != "ok" {
+			t.Fatalf("expected %q, got %q", "ok", l.Text)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no line")
+	}
+}
@@ -2,8 +2,8 @@ package session
 
 import (
 	"errors"
-	"io"
-	"os"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,94 +12,204 @@ import (
 
 var ErrNameTaken = errors.New("session name already in use")
 var ErrNotFound = errors.New("session not found")
+var ErrNoCwd = errors.New("session working directory unknown")
+var ErrClosed = errors.New("session closed")
 
 type Manager struct {
-	mu       sync.RWMutex
-	sessions map[string]*Session
-	spawnFn  func(s *Session, onExit func(string)) error // nil → use spawnPTY
+	mu             sync.RWMutex
+	sessions       map[string]*Session
+	backends       map[string]BackendFactory
+	defaultBackend string
+	onCreate       []func(*Session)
+	onExit         []func(*Session)
+	onNotify       []func(Notification)
+	onLine         []func(*Session, Line)
+	onInput        []func(*Session, string)
+	notifyDefaults NotifySettings
+	hostKeys       HostKeyStore // nil → ~/.ssh/known_hosts, read-only
+	keys           KeyStore
+	bashScript     string // integration script for local bash shells; "" disables it
 }
 
+// NewManager creates a Manager with the built-in backends registered and
+// local PTY sessions by default.
 func NewManager() *Manager {
-	return &Manager{sessions: make(map[string]*Session)}
-}
-
-// NewManagerWithSpawnFn creates a Manager with a custom spawn function.
-// Pass MockSpawnFn for a pipe-based in-process mock (no real PTY).
-func NewManagerWithSpawnFn(fn func(s *Session, onExit func(string)) error) *Manager {
-	return &Manager{sessions: make(map[string]*Session), spawnFn: fn}
-}
-
-// MockSpawnFn is an os.Pipe-based spawn function for testing.
-// It wires a pipe so data written via WriteToPTY is echoed back as PTY output.
-func MockSpawnFn(s *Session, onExit func(string)) error {
-	r, w, err := os.Pipe()
-	if err != nil {
-		return err
-	}
-	s.ptmx = w
-	go func() {
-		defer r.Close()
-		buf := make([]byte, 4096)
-		for {
-			n, readErr := r.Read(buf)
-			if n > 0 {
-				data := make([]byte, n)
-				copy(data, buf[:n])
-				s.scrollback.Write(data)
-				s.LastActive = time.Now()
-				s.outMu.Lock()
-				if s.outChan != nil {
-					select {
-					case s.outChan <- data:
-					default:
-					}
-				}
-				s.outMu.Unlock()
-			}
-			if readErr != nil {
-				if readErr != io.EOF {
-					_ = readErr // pipe errors are expected on close
-				}
-				close(s.done)
-				onExit(s.ID)
-				return
-			}
-		}
-	}()
-	return nil
+	return NewManagerWithBackend(BackendPTY)
+}
+
+// NewManagerWithBackend creates a Manager whose sessions use the named
+// backend unless their spec picks another. Pass BackendMock for a pipe-based
+// in-process mock (no real PTY).
+func NewManagerWithBackend(name string) *Manager {
+	m := &Manager{
+		sessions:       make(map[string]*Session),
+		defaultBackend: name,
+		notifyDefaults: NotifySettings{MinCommandSeconds: DefaultMinCommandSeconds},
+	}
+	m.backends = map[string]BackendFactory{
+		BackendPTY:     newPTYBackend,
+		BackendSSH:     m.newSSHBackend,
+		BackendNsenter: newNsenterBackend,
+		BackendMock:    MockBackend,
+	}
+	return m
+}
+
+// RegisterBackend makes a backend available to sessions under name,
+// replacing any backend registered under it before.
+func (m *Manager) RegisterBackend(name string, f BackendFactory) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.backends[name] = f
+}
+
+func (m *Manager) newSSHBackend(s *Session) Backend {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return newSSHBackend(s, m.hostKeys, m.keys)
+}
+
+// backendName picks the backend for spec.
+func (m *Manager) backendName(spec Spec) string {
+	switch {
+	case spec.Backend != "":
+		return spec.Backend
+	case spec.Target != nil:
+		return BackendSSH
+	case spec.Namespace != nil:
+		return BackendNsenter
+	}
+	return m.defaultBackend
 }
 
+// Create starts a default `bash --login` session with the given name.
 func (m *Manager) Create(name string) (*Session, error) {
+	return m.CreateWithSpec(name, Spec{})
+}
+
+// SetHostKeys sets where SSH sessions look up hosts whose key the target
+// does not pin. Unknown hosts are offered to the user and added once
+// trusted. By default ~/.ssh/known_hosts is used and unknown hosts are
+// rejected.
+func (m *Manager) SetHostKeys(store HostKeyStore) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.hostKeys = store
+}
 
-	for _, s := range m.sessions {
-		if s.Name == name {
-			return nil, ErrNameTaken
+// SetKeyStore sets where SSH sessions find the keys their targets refer to
+// by Auth.KeyID.
+func (m *Manager) SetKeyStore(keys KeyStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = keys
+}
+
+// SetShellIntegration turns shell integration for new local bash sessions
+// on or off. When on, their login shell loads the integration script from
+// PROMPT_COMMAND, after the profile.
+func (m *Manager) SetShellIntegration(enabled bool) error {
+	rc := ""
+	if enabled {
+		var err error
+		if rc, err = writeBashScript(); err != nil {
+			return err
 		}
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bashScript = rc
+	return nil
+}
+
+// CreateWithSpec starts a session whose shell, working directory and
+// environment come from spec. Any spec.Commands are typed into the shell once
+// its prompt appears. With spec.Target the shell runs on a remote host; the
+// connection is made in the background and its progress shown in the
+// session's output.
+func (m *Manager) CreateWithSpec(name string, spec Spec) (*Session, error) {
+	if _, taken := m.FindByName(name); taken {
+		return nil, ErrNameTaken
+	}
 
 	s := &Session{
 		ID:         uuid.New().String(),
 		Name:       name,
+		Tags:       spec.Tags,
 		CreatedAt:  time.Now(),
 		LastActive: time.Now(),
+		spec:       spec,
 		scrollback: newScrollbackBuf(),
+		activity:   make(chan struct{}, 1),
 		done:       make(chan struct{}),
+		onNotify:   m.notify,
+		onInput:    m.inputLine,
 	}
 
-	spawn := m.spawnFn
-	if spawn == nil {
-		spawn = spawnPTY
+	s.Backend = m.backendName(spec)
+	m.mu.RLock()
+	newBackend, ok := m.backends[s.Backend]
+	bashScript := m.bashScript
+	s.notifySettings = m.notifyDefaults
+	watchLines := len(m.onLine) > 0
+	if len(m.onInput) > 0 {
+		s.input = &inputBuffer{}
+	}
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBackend, s.Backend)
+	}
+	if s.Backend == BackendPTY && bashScript != "" && integrates(spec) {
+		spec.bashScript = bashScript
+	}
+	cmds := spec.Commands
+	if s.Backend == BackendSSH {
+		if spec.Target != nil {
+			s.Remote = spec.Target.String()
+		}
+		if spec.Cwd != "" {
+			cmds = append([]string{"cd " + shellQuote(spec.Cwd)}, cmds...)
+		}
 	}
-	if err := spawn(s, m.remove); err != nil {
+	// Starting may take a while, so it runs unlocked and the name is checked
+	// again afterwards.
+	s.shell = newBackend(s)
+	if err := s.shell.Start(spec); err != nil {
 		return nil, err
 	}
 
+	m.mu.Lock()
+	for _, other := range m.sessions {
+		if other.Name == name {
+			m.mu.Unlock()
+			s.shell.Close()
+			s.shell.Wait()
+			return nil, ErrNameTaken
+		}
+	}
 	m.sessions[s.ID] = s
+	created := m.onCreate
+	m.mu.Unlock()
+	for _, fn := range created {
+		fn(s)
+	}
+	if watchLines {
+		s.lines = make(chan []byte, lineQueue)
+		go s.watchLines(m.line)
+	}
+	go s.pump(m.remove)
+
+	if len(cmds) > 0 {
+		go runStartup(s, cmds)
+	}
 	return s, nil
 }
 
+// shellQuote quotes arg for a POSIX shell.
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
 func (m *Manager) List() []*Session {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -118,27 +228,130 @@ func (m *Manager) Get(id string) (*Session, bool) {
 	return s, ok
 }
 
-func (m *Manager) Kill(id string) error {
+// FindByName returns the session with the given name, if any. Names are unique
+// among active sessions.
+func (m *Manager) FindByName(name string) (*Session, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, s := range m.sessions {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// OnCreate registers fn to be called once for every session created, after
+// it has been added to the manager.
+func (m *Manager) OnCreate(fn func(*Session)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onCreate = append(m.onCreate, fn)
+}
+
+// OnExit registers fn to be called once for every session that is killed or
+// whose shell exits, after it has been removed from the manager.
+func (m *Manager) OnExit(fn func(*Session)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onExit = append(m.onExit, fn)
+}
+
+// OnNotify registers fn to be called with every notification a session
+// raises. fn is called from the session's read loop, so it must not block.
+func (m *Manager) OnNotify(fn func(Notification)) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.onNotify = append(m.onNotify, fn)
+}
+
+// SetNotifyDefaults sets the notification settings new sessions start with.
+func (m *Manager) SetNotifyDefaults(ns NotifySettings) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notifyDefaults = ns
+}
 
+func (m *Manager) notify(n Notification) {
+	m.mu.RLock()
+	hooks := m.onNotify
+	m.mu.RUnlock()
+	for _, fn := range hooks {
+		fn(n)
+	}
+}
+
+// OnLine registers fn to be called with every line of terminal output of
+// sessions created afterwards. Calls for one session are made in order from
+// a goroutine of its own, so a slow fn delays only that session's hooks; if
+// they fall too far behind the output, lines are skipped.
+func (m *Manager) OnLine(fn func(*Session, Line)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onLine = append(m.onLine, fn)
+}
+
+func (m *Manager) line(s *Session, l Line) {
+	m.mu.RLock()
+	hooks := m.onLine
+	m.mu.RUnlock()
+	for _, fn := range hooks {
+		fn(s, l)
+	}
+}
+
+// OnInput registers fn to be called with every line of input written to
+// sessions created afterwards, whether typed in a browser, sent as a preset
+// or replied by a trigger. Lines are rebuilt from the bytes written (see
+// inputBuffer), so they are what was sent, not necessarily what the shell
+// ran. fn is called from the writing goroutine, so it must not block.
+func (m *Manager) OnInput(fn func(*Session, string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onInput = append(m.onInput, fn)
+}
+
+func (m *Manager) inputLine(s *Session, line string) {
+	m.mu.RLock()
+	hooks := m.onInput
+	m.mu.RUnlock()
+	for _, fn := range hooks {
+		fn(s, line)
+	}
+}
+
+func (m *Manager) Kill(id string) error {
+	m.mu.Lock()
 	s, ok := m.sessions[id]
 	if !ok {
+		m.mu.Unlock()
 		return ErrNotFound
 	}
 
-	if s.cmd != nil && s.cmd.Process != nil {
-		_ = s.cmd.Process.Kill()
-	}
-	if s.ptmx != nil {
-		s.ptmx.Close()
-	}
+	s.shell.Close()
+	s.stopClient(nil) // the output reader may be waiting to hand over transfer data
 	delete(m.sessions, id)
+	hooks := m.onExit
+	m.mu.Unlock()
+
+	for _, fn := range hooks {
+		fn(s)
+	}
 	return nil
 }
 
+// remove drops a session whose shell exited. A session already removed by
+// Kill is ignored, so exit hooks run only once.
 func (m *Manager) remove(id string) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
 	delete(m.sessions, id)
+	hooks := m.onExit
+	m.mu.Unlock()
+
+	if ok {
+		for _, fn := range hooks {
+			fn(s)
+		}
+	}
 }
@@ -6,7 +6,7 @@ import (
 )
 
 func TestCreateAndGet(t *testing.T) {
-	m := NewManagerWithSpawnFn(MockSpawnFn)
+	m := NewManagerWithBackend(BackendMock)
 	s, err := m.Create("test")
 	if err != nil {
 		t.Fatalf("Create failed: %v", err)
@@ -24,7 +24,7 @@ func TestCreateAndGet(t *testing.T) {
 }
 
 func TestCreateNameUniqueness(t *testing.T) {
-	m := NewManagerWithSpawnFn(MockSpawnFn)
+	m := NewManagerWithBackend(BackendMock)
 	_, err := m.Create("dup")
 	if err != nil {
 		t.Fatalf("first Create failed: %v", err)
@@ -36,7 +36,7 @@ func TestCreateNameUniqueness(t *testing.T) {
 }
 
 func TestList(t *testing.T) {
-	m := NewManagerWithSpawnFn(MockSpawnFn)
+	m := NewManagerWithBackend(BackendMock)
 	m.Create("a")
 	m.Create("b")
 	list := m.List()
@@ -46,7 +46,7 @@ func TestList(t *testing.T) {
 }
 
 func TestKill(t *testing.T) {
-	m := NewManagerWithSpawnFn(MockSpawnFn)
+	m := NewManagerWithBackend(BackendMock)
 	s, err := m.Create("killme")
 	if err != nil {
 		t.Fatalf("Create failed: %v", err)
@@ -61,14 +61,14 @@ func TestKill(t *testing.T) {
 }
 
 func TestKillNotFound(t *testing.T) {
-	m := NewManagerWithSpawnFn(MockSpawnFn)
+	m := NewManagerWithBackend(BackendMock)
 	if err := m.Kill("nonexistent"); err != ErrNotFound {
 		t.Fatalf("expected ErrNotFound, got %v", err)
 	}
 }
 
 func TestGetNotFound(t *testing.T) {
-	m := NewManagerWithSpawnFn(MockSpawnFn)
+	m := NewManagerWithBackend(BackendMock)
 	_, ok := m.Get("nonexistent")
 	if ok {
 		t.Fatal("expected ok=false for nonexistent session")
@@ -76,15 +76,15 @@ func TestGetNotFound(t *testing.T) {
 }
 
 func TestAutoRemoveOnPipeClose(t *testing.T) {
-	m := NewManagerWithSpawnFn(MockSpawnFn)
+	m := NewManagerWithBackend(BackendMock)
 	s, err := m.Create("auto-remove")
 	if err != nil {
 		t.Fatalf("Create failed: %v", err)
 	}
 
-	// Close the write end of the pipe (s.ptmx), simulating bash exit.
-	// The MockSpawnFn goroutine reads EOF from the read end and calls remove.
-	s.ptmx.Close()
+	// Closing the mock backend looks like bash exiting: the read loop sees
+	// EOF and calls remove.
+	s.shell.Close()
 
 	deadline := time.Now().Add(2 * time.Second)
 	for time.Now().Before(deadline) {
@@ -95,3 +95,73 @@ func TestAutoRemoveOnPipeClose(t *testing.T) {
 	}
 	t.Fatal("session was not auto-removed after PTY close")
 }
+
+func TestOnExitRunsOncePerSession(t *testing.T) {
+	m := NewManagerWithBackend(BackendMock)
+	exited := make(chan string, 4)
+	m.OnExit(func(s *Session) { exited <- s.Name })
+
+	killed, _ := m.Create("killed")
+	m.Kill(killed.ID)
+	ended, _ := m.Create("ended")
+	ended.shell.Close()
+
+	got := map[string]int{}
+	deadline := time.After(2 * time.Second)
+	for len(got) < 2 {
+		select {
+		case name := <-exited:
+			got[name]++
+		case <-deadline:
+			t.Fatalf("exit hooks not called, got %v", got)
+		}
+	}
+	select {
+	case name := <-exited:
+		t.Fatalf("exit hook called twice for %q", name)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestCreateWithSpecRunsStartupCommands(t *testing.T) {
+	defer func(timeout, settle time.Duration) {
+		startupTimeout, startupSettle = timeout, settle
+	}(startupTimeout, startupSettle)
+	startupTimeout, startupSettle = 20*time.Millisecond, 10*time.Millisecond
+
+	m := NewManagerWithBackend(BackendMock)
+	s, err := m.CreateWithSpec("startup", Spec{Commands: []string{"cd /srv", "make logs"}})
+	if err != nil {
+		t.Fatalf("CreateWithSpec failed: %v", err)
+	}
+
+	// The mock backend echoes PTY input back as output, so typed commands land in
+	// the scrollback.
+	want := "cd /srv\rmake logs\r"
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if string(s.ScrollbackSnapshot()) == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected startup commands %q in scrollback, got %q", want, s.ScrollbackSnapshot())
+}
+
+func TestShellCommandFromSpec(t *testing.T) {
+	cmd := shellCommand(Spec{Shell: "zsh", Cwd: "/tmp", Env: map[string]string{"FOO": "bar"}})
+	if cmd.Args[0] != "zsh" || len(cmd.Args) != 1 {
+		t.Fatalf("expected bare zsh, got %v", cmd.Args)
+	}
+	if cmd.Dir != "/tmp" {
+		t.Fatalf("expected cwd /tmp, got %q", cmd.Dir)
+	}
+	if last := cmd.Env[len(cmd.Env)-1]; last != "FOO=bar" {
+		t.Fatalf("expected FOO=bar appended to env, got %q", last)
+	}
+
+	def := shellCommand(Spec{})
+	if len(def.Args) != 2 || def.Args[0] != "bash" || def.Args[1] != "--login" {
+		t.Fatalf("expected default bash --login, got %v", def.Args)
+	}
+}
@@ -0,0 +1,42 @@
+package session
+
+import (
+	"os"
+)
+
+// mockBackend is an os.Pipe-based backend for testing: input written to it
+// is echoed back as output. Closing it looks like the shell exiting.
+type mockBackend struct {
+	r, w *os.File
+	cwd  string
+}
+
+// MockBackend creates a mock backend; it is registered as BackendMock.
+func MockBackend(*Session) Backend {
+	return &mockBackend{}
+}
+
+func (b *mockBackend) Start(spec Spec) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	b.r, b.w = r, w
+	b.cwd = expandHome(spec.Cwd)
+	return nil
+}
+
+func (b *mockBackend) Read(p []byte) (int, error)  { return b.r.Read(p) }
+func (b *mockBackend) Write(p []byte) (int, error) { return b.w.Write(p) }
+func (b *mockBackend) Resize(uint16, uint16) error { return nil }
+func (b *mockBackend) Signal(os.Signal) error      { return nil }
+func (b *mockBackend) Wait() error                 { return b.r.Close() }
+func (b *mockBackend) Close() error                { return b.w.Close() }
+
+// Cwd returns the directory the spec names, as if the shell never left it.
+func (b *mockBackend) Cwd() (string, error) {
+	if b.cwd == "" {
+		return "", ErrNoCwd
+	}
+	return b.cwd, nil
+}
@@ -2,33 +2,86 @@ package session
 
 import (
 	"os"
-	"os/exec"
 	"sync"
 	"time"
 )
 
 const maxScrollback = 1 << 20 // 1MB
 
+// Spec describes how a session's shell is started. The zero value starts
+// `bash --login` in the backend's working directory.
+type Spec struct {
+	Shell    string            `json:"shell,omitempty"`
+	Args     []string          `json:"args,omitempty"`
+	Cwd      string            `json:"cwd,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Commands []string          `json:"commands,omitempty"` // typed once the prompt appears
+	// Backend names the registered backend that runs the shell. Empty
+	// means ssh with a Target, nsenter with a Namespace and the manager's
+	// default otherwise.
+	Backend string `json:"backend,omitempty"`
+	// Target runs the shell on a remote host through the built-in SSH
+	// client. Shell and Args then name the remote command, Cwd is entered
+	// with cd, and Env is offered to the server.
+	Target *Target `json:"target,omitempty"`
+	// Namespace runs the shell inside another process's namespaces. Cwd is
+	// then a directory in its mount namespace.
+	Namespace *Namespace `json:"namespace,omitempty"`
+	// Tags label the session, for example to scope trigger rules.
+	Tags []string `json:"tags,omitempty"`
+
+	bashScript string // bash integration script, set by the manager
+}
+
 type Session struct {
 	ID         string    `json:"id"`
 	Name       string    `json:"name"`
 	CreatedAt  time.Time `json:"created_at"`
 	LastActive time.Time `json:"last_active"`
 	Connected  bool      `json:"connected"`
+	Backend    string    `json:"backend"`
+	Remote     string    `json:"remote,omitempty"` // user@host:port of an SSH session
+	// HostKey is the SHA256 fingerprint of the remote host's key once it
+	// has been verified.
+	HostKey         string   `json:"hostKey,omitempty"`
+	AgentForwarding bool     `json:"agentForwarding,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
 
-	cmd        *exec.Cmd
-	ptmx       *os.File
+	spec       Spec
+	shell      Backend
 	scrollback *scrollbackBuf
-	outChan    chan []byte
+	activity   chan struct{} // signalled (non-blocking) on every PTY read
+	outChan    chan Output
 	kickChan   chan struct{}
 	outMu      sync.Mutex
-	done       chan struct{}
+	transfer   *Transfer // in-band file transfer in progress; guarded by outMu
+	detector   transferDetector
+	prompt     *pendingPrompt // host key question awaiting an answer; guarded by outMu
+	tracker    shellTracker
+	lines      chan []byte  // terminal output for the manager's line hooks; nil without hooks
+	input      *inputBuffer // typed input for the manager's input hooks; nil without hooks
+	onInput    func(*Session, string)
+	// outStop is closed when outChan's client is displaced or goes away,
+	// releasing a transfer chunk blocked on it. It and outChan are set with
+	// outMu and stopMu held; stopClient closes it holding only stopMu.
+	outStop chan struct{}
+	// sending is the channel a transfer chunk is being sent to, outside
+	// outMu; sent is closed once the send is over. Guarded by stopMu.
+	sending chan Output
+	sent    chan struct{}
+	stopMu  sync.Mutex
+	// Notifications; guarded by outMu.
+	notifySettings NotifySettings
+	lastBell       time.Time
+	onNotify       func(Notification)
+	done           chan struct{}
 }
 
 type scrollbackBuf struct {
-	mu   sync.Mutex
-	data []byte
-	max  int
+	mu    sync.Mutex
+	data  []byte
+	max   int
+	total int64 // bytes ever written
 }
 
 func newScrollbackBuf() *scrollbackBuf {
@@ -39,12 +92,21 @@ func (s *scrollbackBuf) Write(p []byte) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.data = append(s.data, p...)
+	s.total += int64(len(p))
 	if len(s.data) > s.max {
 		excess := len(s.data) - s.max
 		s.data = s.data[excess:]
 	}
 }
 
+// Offsets returns the range of output the buffer holds, counting every byte
+// ever written.
+func (s *scrollbackBuf) Offsets() (start, end int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total - int64(len(s.data)), s.total
+}
+
 func (s *scrollbackBuf) Snapshot() []byte {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -56,11 +118,21 @@ func (s *scrollbackBuf) Snapshot() []byte {
 	return cp
 }
 
+// notifyActivity signals that PTY output arrived without ever blocking the
+// read loop.
+func (s *Session) notifyActivity() {
+	select {
+	case s.activity <- struct{}{}:
+	default:
+	}
+}
+
 // SetClient registers a channel to receive live PTY output. If a previous
 // client is connected it is kicked: its kick channel is closed so ws.go can
 // detect the displacement and close that WebSocket connection. Returns a kick
 // channel that will be closed if this client is itself later displaced.
-func (s *Session) SetClient(ch chan []byte) <-chan struct{} {
+func (s *Session) SetClient(ch chan Output) <-chan struct{} {
+	s.stopClient(nil)
 	s.outMu.Lock()
 	defer s.outMu.Unlock()
 	// Displace any existing client.
@@ -69,42 +141,103 @@ func (s *Session) SetClient(ch chan []byte) <-chan struct{} {
 	}
 	kick := make(chan struct{})
 	s.kickChan = kick
-	s.outChan = ch
+	s.stopMu.Lock()
+	s.outChan, s.outStop = ch, make(chan struct{})
+	s.stopMu.Unlock()
 	s.Connected = true
+	if s.prompt != nil {
+		// The question was asked before this client arrived.
+		s.send(Output{Prompt: &s.prompt.HostKeyPrompt})
+	}
 	return kick
 }
 
 // ClearClient is called when a connection ends. It only updates session state
 // if ch is still the current owner (guards against a displaced connection
-// clearing a newer one). It always closes ch so the pump goroutine exits.
-func (s *Session) ClearClient(ch chan []byte) {
+// clearing a newer one), cancelling any transfer the client was taking part
+// in. It always closes ch so the pump goroutine exits.
+func (s *Session) ClearClient(ch chan Output) {
+	s.stopClient(ch)
 	s.outMu.Lock()
 	owned := s.outChan == ch
 	if owned {
-		s.outChan = nil
+		s.cancelTransferLocked()
+		s.stopMu.Lock()
+		s.outChan, s.outStop = nil, nil
+		s.stopMu.Unlock()
 		s.Connected = false
 		s.kickChan = nil
 	}
 	s.outMu.Unlock()
+	// A transfer chunk may still be on its way to ch; stopClient, here or
+	// when ch was displaced, has told it to give up.
+	s.stopMu.Lock()
+	sent := s.sent
+	if s.sending != ch {
+		sent = nil
+	}
+	s.stopMu.Unlock()
+	if sent != nil {
+		<-sent
+	}
 	close(ch)
 }
 
+// stopClient releases a transfer chunk waiting for the current client, or
+// for ch if it is not nil and still the current client, so that the caller
+// can take outMu.
+func (s *Session) stopClient(ch chan Output) {
+	s.stopMu.Lock()
+	defer s.stopMu.Unlock()
+	if s.outStop != nil && (ch == nil || ch == s.outChan) {
+		close(s.outStop)
+		s.outStop = nil
+	}
+}
+
 // ScrollbackSnapshot returns a copy of the scrollback buffer.
 func (s *Session) ScrollbackSnapshot() []byte {
 	return s.scrollback.Snapshot()
 }
 
-// Done returns a channel that is closed when the bash process exits.
+// Done returns a channel that is closed when the shell exits.
 func (s *Session) Done() <-chan struct{} {
 	return s.done
 }
 
-// WriteToPTY writes input bytes to the PTY master.
+// WriteToPTY writes input bytes to the shell's terminal.
 func (s *Session) WriteToPTY(p []byte) (int, error) {
-	return s.ptmx.Write(p)
+	n, err := s.shell.Write(p)
+	if s.input != nil && n > 0 {
+		s.tapInput(p[:n])
+	}
+	return n, err
+}
+
+// Resize sets the size of the shell's terminal.
+func (s *Session) Resize(cols, rows uint16) error {
+	return s.shell.Resize(cols, rows)
 }
 
-// PTY returns the PTY master file for pty.Setsize calls.
-func (s *Session) PTY() *os.File {
-	return s.ptmx
+// Signal sends sig to the shell.
+func (s *Session) Signal(sig os.Signal) error {
+	return s.shell.Signal(sig)
+}
+
+// PID returns the process ID of the session's shell, or 0 if the session is
+// not backed by a local process.
+func (s *Session) PID() int {
+	if b, ok := s.shell.(interface{ PID() int }); ok {
+		return b.PID()
+	}
+	return 0
+}
+
+// Cwd returns the shell's current working directory. Sessions whose shell
+// does not run on the backend's filesystem, such as SSH sessions, have none.
+func (s *Session) Cwd() (string, error) {
+	if b, ok := s.shell.(cwdBackend); ok {
+		return b.Cwd()
+	}
+	return "", ErrNoCwd
 }
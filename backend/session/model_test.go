@@ -9,7 +9,7 @@ func TestSetClientConnected(t *testing.T) {
 		scrollback: newScrollbackBuf(),
 		done:       make(chan struct{}),
 	}
-	ch := make(chan []byte, 1)
+	ch := make(chan Output, 1)
 	kick := s.SetClient(ch)
 	if !s.Connected {
 		t.Fatal("expected Connected to be true after SetClient")
@@ -24,10 +24,10 @@ func TestSetClientKicksPrior(t *testing.T) {
 		scrollback: newScrollbackBuf(),
 		done:       make(chan struct{}),
 	}
-	ch1 := make(chan []byte, 1)
+	ch1 := make(chan Output, 1)
 	kick1 := s.SetClient(ch1)
 
-	ch2 := make(chan []byte, 1)
+	ch2 := make(chan Output, 1)
 	_ = s.SetClient(ch2)
 
 	select {
@@ -43,10 +43,10 @@ func TestClearClientOwnershipGuard(t *testing.T) {
 		scrollback: newScrollbackBuf(),
 		done:       make(chan struct{}),
 	}
-	ch1 := make(chan []byte, 1)
+	ch1 := make(chan Output, 1)
 	_ = s.SetClient(ch1)
 
-	ch2 := make(chan []byte, 1)
+	ch2 := make(chan Output, 1)
 	_ = s.SetClient(ch2)
 
 	// ClearClient with the displaced channel should NOT clear Connected.
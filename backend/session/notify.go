@@ -0,0 +1,114 @@
+package session
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Notification kinds.
+const (
+	NotifyBell    = "bell"    // BEL character
+	NotifyMessage = "message" // OSC 9 or OSC 777 notification
+	NotifyCommand = "command" // a command reported by shell integration finished
+	NotifyTrigger = "trigger" // raised with Notify, for example by a trigger rule
+)
+
+// bellInterval is the shortest time between two bell notifications of a
+// session, so a burst of bells (say, from tab completion) raises only one.
+const bellInterval = 5 * time.Second
+
+// DefaultMinCommandSeconds is how long a command must run before its end is
+// notified, unless changed with SetNotifyDefaults.
+const DefaultMinCommandSeconds = 10
+
+// Notification tells users about something that happened in a session they
+// may not be looking at.
+type Notification struct {
+	SessionID   string    `json:"sessionId"`
+	SessionName string    `json:"sessionName"`
+	Kind        string    `json:"kind"`
+	Title       string    `json:"title,omitempty"`
+	Body        string    `json:"body,omitempty"`
+	Command     *Command  `json:"command,omitempty"` // set for NotifyCommand
+	Time        time.Time `json:"time"`
+}
+
+// NotifySettings controls which notifications a session raises.
+type NotifySettings struct {
+	Muted bool `json:"muted"`
+	// MinCommandSeconds is how long a command must have run for its end to
+	// be notified; 0 notifies every command.
+	MinCommandSeconds int `json:"minCommandSeconds"`
+}
+
+// conEmuOSC9 matches the ConEmu uses of OSC 9, such as progress reports,
+// which are not notifications.
+var conEmuOSC9 = regexp.MustCompile(`^\d+(;|$)`)
+
+// oscNotification returns the notification an OSC sequence asks for, if any.
+func oscNotification(code, arg string) (Notification, bool) {
+	switch code {
+	case "9":
+		if arg == "" || conEmuOSC9.MatchString(arg) {
+			return Notification{}, false
+		}
+		return Notification{Kind: NotifyMessage, Body: arg}, true
+	case "777":
+		// OSC 777;notify;title;body
+		kind, rest, _ := strings.Cut(arg, ";")
+		if kind != "notify" {
+			return Notification{}, false
+		}
+		title, body, _ := strings.Cut(rest, ";")
+		return Notification{Kind: NotifyMessage, Title: title, Body: body}, true
+	}
+	return Notification{}, false
+}
+
+// raise sends n to the client and the manager's notification hooks, unless
+// the session's settings filter it out. outMu must be held.
+func (s *Session) raise(n Notification) {
+	if s.notifySettings.Muted {
+		return
+	}
+	now := time.Now()
+	switch n.Kind {
+	case NotifyBell:
+		if now.Sub(s.lastBell) < bellInterval {
+			return
+		}
+		s.lastBell = now
+	case NotifyCommand:
+		if n.Command.DurationMs < int64(s.notifySettings.MinCommandSeconds)*1000 {
+			return
+		}
+	}
+	n.SessionID, n.SessionName, n.Time = s.ID, s.Name, now
+	s.send(Output{Notification: &n})
+	if s.onNotify != nil {
+		s.onNotify(n)
+	}
+}
+
+// Notify raises n on behalf of something watching the session, subject to
+// the session's settings like the notifications in its output.
+func (s *Session) Notify(n Notification) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.raise(n)
+}
+
+// NotifySettings returns the session's notification settings.
+func (s *Session) NotifySettings() NotifySettings {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	return s.notifySettings
+}
+
+// SetNotifySettings changes the session's notification settings.
+func (s *Session) SetNotifySettings(ns NotifySettings) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.notifySettings = ns
+}
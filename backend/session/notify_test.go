@@ -0,0 +1,84 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func newNotifyTestSession(t *testing.T) (*Session, <-chan Notification) {
+	t.Helper()
+	m := NewManagerWithBackend(BackendMock)
+	got := make(chan Notification, 16)
+	m.OnNotify(func(n Notification) { got <- n })
+	s, err := m.Create("notify")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return s, got
+}
+
+func nextNotification(t *testing.T, got <-chan Notification) Notification {
+	t.Helper()
+	select {
+	case n := <-got:
+		return n
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notification")
+		return Notification{}
+	}
+}
+
+func expectNoNotification(t *testing.T, got <-chan Notification) {
+	t.Helper()
+	select {
+	case n := <-got:
+		t.Fatalf("unexpected notification %+v", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNotificationsFromOutput(t *testing.T) {
+	s, got := newNotifyTestSession(t)
+
+	// The BEL ending an OSC sequence is not a bell, and ConEmu progress
+	// reports are not notifications.
+	s.WriteToPTY([]byte("\x1b]0;title\x07\x1b]9;4;1;50\x07\x1b]9;build done\x07"))
+	n := nextNotification(t, got)
+	if n.Kind != NotifyMessage || n.Body != "build done" || n.SessionID != s.ID || n.SessionName != "notify" {
+		t.Fatalf("unexpected OSC 9 notification %+v", n)
+	}
+	s.WriteToPTY([]byte("\x1b]777;notify;Tests;all passed\x1b\\"))
+	if n := nextNotification(t, got); n.Title != "Tests" || n.Body != "all passed" {
+		t.Fatalf("unexpected OSC 777 notification %+v", n)
+	}
+
+	// Bells within bellInterval of each other raise one notification.
+	s.WriteToPTY([]byte("\a\a"))
+	if n := nextNotification(t, got); n.Kind != NotifyBell {
+		t.Fatalf("expected a bell, got %+v", n)
+	}
+	s.WriteToPTY([]byte("\a"))
+	expectNoNotification(t, got)
+
+	s.SetNotifySettings(NotifySettings{Muted: true})
+	s.WriteToPTY([]byte("\x1b]9;muted\x07"))
+	expectNoNotification(t, got)
+}
+
+func TestCommandNotificationMinDuration(t *testing.T) {
+	s, got := newNotifyTestSession(t)
+	if ns := s.NotifySettings(); ns.MinCommandSeconds != DefaultMinCommandSeconds {
+		t.Fatalf("unexpected default settings %+v", ns)
+	}
+
+	// A quick command is not worth a notification by default.
+	s.WriteToPTY([]byte("\x1b]633;E;true\x07\x1b]133;C\x07\x1b]133;D;0\x07"))
+	expectNoNotification(t, got)
+
+	s.SetNotifySettings(NotifySettings{MinCommandSeconds: 0})
+	s.WriteToPTY([]byte("\x1b]633;E;make\x07\x1b]133;C\x07\x1b]133;D;2\x07"))
+	n := nextNotification(t, got)
+	if n.Kind != NotifyCommand || n.Command == nil || n.Command.Command != "make" || *n.Command.ExitCode != 2 {
+		t.Fatalf("unexpected command notification %+v", n)
+	}
+}
@@ -0,0 +1,92 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+var ErrInvalidNamespace = errors.New("invalid namespace")
+
+// Namespace names a running process whose namespaces the nsenter backend
+// starts the shell in, e.g. a container's init process.
+type Namespace struct {
+	PID int `json:"pid"`
+	// Types limits which namespaces are entered: mount, uts, ipc, net, pid,
+	// user, cgroup or time. Empty means all of them.
+	Types []string `json:"types,omitempty"`
+}
+
+var namespaceFlags = map[string]string{
+	"mount":  "--mount",
+	"uts":    "--uts",
+	"ipc":    "--ipc",
+	"net":    "--net",
+	"pid":    "--pid",
+	"user":   "--user",
+	"cgroup": "--cgroup",
+	"time":   "--time",
+}
+
+// nsenterBackend runs a shell on a local PTY inside the namespaces of
+// spec.Namespace through nsenter(1). Its working directory is in the target's
+// mount namespace, so the session has no Cwd on the backend's filesystem.
+type nsenterBackend struct {
+	ptyBackend
+}
+
+func newNsenterBackend(*Session) Backend {
+	return &nsenterBackend{}
+}
+
+func (b *nsenterBackend) Start(spec Spec) error {
+	cmd, err := nsenterCommand(spec)
+	if err != nil {
+		return err
+	}
+	b.spec = spec
+	return b.start(cmd)
+}
+
+func (b *nsenterBackend) Cwd() (string, error) {
+	return "", ErrNoCwd
+}
+
+// nsenterCommand wraps the shell described by spec, defaulting to sh, in
+// nsenter. spec.Cwd is resolved inside the target's mount namespace.
+func nsenterCommand(spec Spec) (*exec.Cmd, error) {
+	ns := spec.Namespace
+	if ns == nil || ns.PID <= 0 {
+		return nil, fmt.Errorf("%w: a target pid is required", ErrInvalidNamespace)
+	}
+	args := []string{"--target", strconv.Itoa(ns.PID)}
+	enterMount := len(ns.Types) == 0
+	if enterMount {
+		args = append(args, "--all")
+	}
+	for _, t := range ns.Types {
+		flag, ok := namespaceFlags[t]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown namespace type %q", ErrInvalidNamespace, t)
+		}
+		args = append(args, flag)
+		enterMount = enterMount || t == "mount"
+	}
+	if enterMount {
+		wd := "--wd"
+		if spec.Cwd != "" {
+			wd += "=" + spec.Cwd
+		}
+		args = append(args, "--root", wd)
+	}
+
+	shell := spec.Shell
+	if shell == "" {
+		shell = "sh"
+	}
+	inner := shellCommand(Spec{Shell: shell, Args: spec.Args, Env: spec.Env})
+	cmd := exec.Command("nsenter", append(append(args, "--"), inner.Args...)...)
+	cmd.Env = inner.Env
+	return cmd, nil
+}
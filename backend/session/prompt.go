@@ -0,0 +1,71 @@
+package session
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// hostKeyPromptTimeout bounds how long a connection waits for the user to
+// decide about an unknown host key.
+var hostKeyPromptTimeout = 2 * time.Minute
+
+// HostKeyPrompt asks the user whether to trust a host key seen for the
+// first time.
+type HostKeyPrompt struct {
+	ID          string `json:"id"`
+	Host        string `json:"host"`
+	KeyType     string `json:"keyType"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+type pendingPrompt struct {
+	HostKeyPrompt
+	answer chan bool // buffered; receives the first answer
+}
+
+// askHostKey shows p to the connected client, or to the next one to
+// connect, and waits for the answer. It reports false if the user declines,
+// nobody answers in time, or stop is closed.
+func (s *Session) askHostKey(p HostKeyPrompt, stop <-chan struct{}) bool {
+	p.ID = uuid.New().String()
+	pending := &pendingPrompt{HostKeyPrompt: p, answer: make(chan bool, 1)}
+	s.outMu.Lock()
+	s.prompt = pending
+	s.send(Output{Prompt: &pending.HostKeyPrompt})
+	s.outMu.Unlock()
+	defer func() {
+		s.outMu.Lock()
+		if s.prompt == pending {
+			s.prompt = nil
+		}
+		s.outMu.Unlock()
+	}()
+
+	timer := time.NewTimer(hostKeyPromptTimeout)
+	defer timer.Stop()
+	select {
+	case trust := <-pending.answer:
+		return trust
+	case <-timer.C:
+		return false
+	case <-stop:
+		return false
+	}
+}
+
+// AnswerHostKey answers the host key prompt with the given ID. It reports
+// false if no such prompt is pending.
+func (s *Session) AnswerHostKey(id string, trust bool) bool {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	if s.prompt == nil || s.prompt.ID != id {
+		return false
+	}
+	select {
+	case s.prompt.answer <- trust:
+	default:
+	}
+	s.prompt = nil
+	return true
+}
@@ -1,56 +1,133 @@
 package session
 
 import (
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"os"
 	"os/exec"
-	"time"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
 
 	"github.com/creack/pty"
 )
 
-func spawnPTY(s *Session, onExit func(id string)) error {
-	cmd := exec.Command("bash", "--login")
-	cmd.Env = append(cmd.Environ(), "TERM=xterm-256color")
+// ptyBackend runs a local shell on a PTY.
+type ptyBackend struct {
+	spec Spec
+	cmd  *exec.Cmd
+	ptmx *os.File
+}
+
+func newPTYBackend(*Session) Backend {
+	return &ptyBackend{}
+}
+
+func (b *ptyBackend) Start(spec Spec) error {
+	b.spec = spec
+	return b.start(shellCommand(spec))
+}
 
+func (b *ptyBackend) start(cmd *exec.Cmd) error {
 	ptmx, err := pty.Start(cmd)
 	if err != nil {
 		return err
 	}
-	s.ptmx = ptmx
-	s.cmd = cmd
-
-	go readLoop(s, onExit)
+	b.cmd, b.ptmx = cmd, ptmx
 	return nil
 }
 
-func readLoop(s *Session, onExit func(id string)) {
-	buf := make([]byte, 4096)
-	for {
-		n, err := s.ptmx.Read(buf)
-		if n > 0 {
-			data := make([]byte, n)
-			copy(data, buf[:n])
-
-			s.scrollback.Write(data)
-			s.LastActive = time.Now()
-
-			s.outMu.Lock()
-			if s.outChan != nil {
-				select {
-				case s.outChan <- data:
-				default:
-				}
-			}
-			s.outMu.Unlock()
-		}
-		if err != nil {
-			if err != io.EOF {
-				log.Printf("session %s PTY read error: %v", s.ID, err)
-			}
-			close(s.done)
-			onExit(s.ID)
-			return
+func (b *ptyBackend) Read(p []byte) (int, error) {
+	n, err := b.ptmx.Read(p)
+	if errors.Is(err, syscall.EIO) {
+		// Linux reports the shell closing its side of the PTY as EIO.
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (b *ptyBackend) Write(p []byte) (int, error) {
+	return b.ptmx.Write(p)
+}
+
+func (b *ptyBackend) Resize(cols, rows uint16) error {
+	return pty.Setsize(b.ptmx, &pty.Winsize{Rows: rows, Cols: cols})
+}
+
+func (b *ptyBackend) Signal(sig os.Signal) error {
+	return b.cmd.Process.Signal(sig)
+}
+
+func (b *ptyBackend) Wait() error {
+	err := b.cmd.Wait()
+	b.ptmx.Close()
+	return err
+}
+
+func (b *ptyBackend) Close() error {
+	_ = b.cmd.Process.Kill()
+	return b.ptmx.Close()
+}
+
+// PID returns the process ID of the shell.
+func (b *ptyBackend) PID() int {
+	return b.cmd.Process.Pid
+}
+
+// Cwd returns the shell's current working directory as reported by
+// /proc/<pid>/cwd, falling back to the directory it was started in.
+func (b *ptyBackend) Cwd() (string, error) {
+	if dir, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", b.PID())); err == nil {
+		return dir, nil
+	}
+	if b.spec.Cwd != "" {
+		return expandHome(b.spec.Cwd), nil
+	}
+	return "", ErrNoCwd
+}
+
+// shellCommand builds the shell command described by spec, defaulting to
+// `bash --login` with TERM=xterm-256color.
+func shellCommand(spec Spec) *exec.Cmd {
+	shell, args := spec.Shell, spec.Args
+	if shell == "" {
+		shell = "bash"
+		if args == nil {
+			args = []string{"--login"}
 		}
 	}
+	cmd := exec.Command(shell, args...)
+	cmd.Dir = expandHome(spec.Cwd)
+	cmd.Env = append(cmd.Environ(), "TERM=xterm-256color")
+	if spec.bashScript != "" {
+		// The login shell runs the profile as usual and then, before its
+		// first prompt, PROMPT_COMMAND; the script removes it again.
+		cmd.Env = append(cmd.Env,
+			"WEB_TERMINAL_BASH_INTEGRATION="+spec.bashScript,
+			"PROMPT_COMMAND="+bashBootstrap)
+	}
+
+	keys := make([]string, 0, len(spec.Env))
+	for k := range spec.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cmd.Env = append(cmd.Env, k+"="+spec.Env[k])
+	}
+	return cmd
+}
+
+// expandHome replaces a leading "~" with the backend user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
 }
@@ -0,0 +1,57 @@
+package session
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Bracketed-paste markers. Shells with bracketed paste enabled treat
+// everything between them as literal text rather than typed keystrokes.
+const (
+	pasteStart = "\x1b[200~"
+	pasteEnd   = "\x1b[201~"
+)
+
+// SendOptions controls how Send types text into a session.
+type SendOptions struct {
+	BracketedPaste bool          // wrap the text in bracketed-paste markers
+	Enter          bool          // append a carriage return after the text
+	LineDelay      time.Duration // pause between lines of multi-line text
+}
+
+// Send writes text to the session's PTY as if typed by a client. With a line
+// delay, each line is written separately so interactive programs can keep up;
+// ctx cancels the remaining lines.
+func (s *Session) Send(ctx context.Context, text string, opts SendOptions) error {
+	chunks := []string{text}
+	if opts.LineDelay > 0 {
+		chunks = strings.SplitAfter(text, "\n")
+	}
+	if opts.BracketedPaste {
+		chunks[0] = pasteStart + chunks[0]
+		chunks[len(chunks)-1] += pasteEnd
+	}
+	if opts.Enter {
+		chunks[len(chunks)-1] += "\r"
+	}
+
+	for i, c := range chunks {
+		if i > 0 {
+			select {
+			case <-time.After(opts.LineDelay):
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-s.done:
+				return ErrClosed
+			}
+		}
+		if c == "" {
+			continue
+		}
+		if _, err := s.WriteToPTY([]byte(c)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func waitScrollback(t *testing.T, s *Session, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if string(s.ScrollbackSnapshot()) == want {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected scrollback %q, got %q", want, s.ScrollbackSnapshot())
+}
+
+func TestSendBracketedPasteWithEnter(t *testing.T) {
+	m := NewManagerWithBackend(BackendMock)
+	s, _ := m.Create("send")
+
+	if err := s.Send(context.Background(), "ls\npwd", SendOptions{BracketedPaste: true, Enter: true}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitScrollback(t, s, "\x1b[200~ls\npwd\x1b[201~\r")
+}
+
+func TestSendLineDelay(t *testing.T) {
+	m := NewManagerWithBackend(BackendMock)
+	s, _ := m.Create("send-delay")
+
+	start := time.Now()
+	if err := s.Send(context.Background(), "a\nb\nc", SendOptions{LineDelay: 20 * time.Millisecond}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("expected two line delays, took %v", elapsed)
+	}
+	waitScrollback(t, s, "a\nb\nc")
+}
+
+func TestSendCancelled(t *testing.T) {
+	m := NewManagerWithBackend(BackendMock)
+	s, _ := m.Create("send-cancel")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Send(ctx, "a\nb", SendOptions{LineDelay: time.Second}); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package session
+
+import (
+	"embed"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Shell integration scripts, which make the shell report command boundaries
+// (OSC 133), command lines (OSC 633;E) and its working directory (OSC 7).
+//
+//go:embed integration/*.sh
+var integrationScripts embed.FS
+
+// maxCommands bounds a session's command history.
+const maxCommands = 500
+
+// maxOSC bounds the OSC sequences the tracker collects; longer ones are
+// ignored.
+const maxOSC = 4096
+
+// IntegrationScript returns the shell integration script for shell ("bash"
+// or "zsh").
+func IntegrationScript(shell string) ([]byte, bool) {
+	data, err := integrationScripts.ReadFile("integration/" + shell + ".sh")
+	return data, err == nil
+}
+
+// Command is a command run at the prompt of a shell with integration.
+// OutputStart and OutputEnd are offsets into the session's output as counted
+// by ShellState's ScrollbackStart and ScrollbackEnd.
+type Command struct {
+	Command     string    `json:"command"`
+	Cwd         string    `json:"cwd,omitempty"`
+	StartedAt   time.Time `json:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt,omitzero"`
+	DurationMs  int64     `json:"durationMs,omitempty"`
+	ExitCode    *int      `json:"exitCode,omitempty"`
+	OutputStart int64     `json:"outputStart"`
+	OutputEnd   int64     `json:"outputEnd,omitempty"`
+}
+
+// ShellState is what shell integration has reported about a session.
+type ShellState struct {
+	// Integrated is set once the shell has sent any integration sequence.
+	Integrated bool      `json:"integrated"`
+	Cwd        string    `json:"cwd,omitempty"` // as reported by the shell, possibly on a remote host
+	Running    *Command  `json:"running,omitempty"`
+	Commands   []Command `json:"commands"` // finished commands, oldest first
+	// The scrollback holds output bytes ScrollbackStart up to
+	// ScrollbackEnd, counted from the start of the session.
+	ScrollbackStart int64 `json:"scrollbackStart"`
+	ScrollbackEnd   int64 `json:"scrollbackEnd"`
+}
+
+// shellTracker follows the integration sequences in a session's output.
+// feed is only called from the read loop; the state is also read by
+// ShellState.
+type shellTracker struct {
+	// Parser state, owned by feed.
+	state    int
+	osc      []byte
+	oscStart int64
+
+	mu         sync.Mutex
+	integrated bool
+	cwd        string
+	command    string // from the last OSC 633;E
+	running    *Command
+	history    []Command
+}
+
+// Parser states.
+const (
+	trackText = iota
+	trackEsc
+	trackOSC
+	trackOSCEsc
+)
+
+// feed scans output that starts at offset base of the session's output and
+// returns the notifications it asks for.
+func (t *shellTracker) feed(data []byte, base int64) []Notification {
+	var raised []Notification
+	dispatch := func(end int64) {
+		if n, ok := t.dispatch(t.osc, t.oscStart, end); ok {
+			raised = append(raised, n)
+		}
+	}
+	for i, b := range data {
+		switch t.state {
+		case trackText:
+			switch b {
+			case 0x1b:
+				t.state = trackEsc
+				t.oscStart = base + int64(i)
+			case 0x07:
+				raised = append(raised, Notification{Kind: NotifyBell})
+			}
+		case trackEsc:
+			switch b {
+			case ']':
+				t.state = trackOSC
+				t.osc = t.osc[:0]
+			case 0x1b:
+				t.oscStart = base + int64(i)
+			default:
+				t.state = trackText
+			}
+		case trackOSC:
+			switch {
+			case b == 0x07:
+				dispatch(base + int64(i) + 1)
+				t.state = trackText
+			case b == 0x1b:
+				t.state = trackOSCEsc
+			case len(t.osc) < maxOSC:
+				t.osc = append(t.osc, b)
+			default:
+				t.state = trackText
+			}
+		case trackOSCEsc:
+			if b == '\\' {
+				dispatch(base + int64(i) + 1)
+			}
+			t.state = trackText
+		}
+	}
+	return raised
+}
+
+// dispatch handles one OSC sequence occupying output offsets start to end
+// and returns the notification it raises, if any.
+func (t *shellTracker) dispatch(payload []byte, start, end int64) (Notification, bool) {
+	p := string(payload)
+	code, arg, _ := strings.Cut(p, ";")
+	if code != "7" && code != "133" && code != "633" {
+		return oscNotification(code, arg)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.integrated = true
+	if code == "7" {
+		if u, err := url.Parse(arg); err == nil && u.Scheme == "file" {
+			t.cwd = u.Path
+		}
+		return Notification{}, false
+	}
+	kind, rest, _ := strings.Cut(arg, ";")
+	switch kind {
+	case "E":
+		cmd, _, _ := strings.Cut(rest, ";") // an optional nonce follows
+		t.command = unescapeOSC633(cmd)
+	case "P":
+		if dir, ok := strings.CutPrefix(rest, "Cwd="); ok {
+			t.cwd = unescapeOSC633(dir)
+		}
+	case "C":
+		t.running = &Command{Command: t.command, Cwd: t.cwd, StartedAt: time.Now(), OutputStart: end}
+		t.command = ""
+	case "D":
+		if t.running == nil {
+			return Notification{}, false
+		}
+		c := *t.running
+		t.running = nil
+		c.FinishedAt = time.Now()
+		c.DurationMs = c.FinishedAt.Sub(c.StartedAt).Milliseconds()
+		c.OutputEnd = start
+		if exit, err := strconv.Atoi(strings.TrimSpace(rest)); err == nil {
+			c.ExitCode = &exit
+		}
+		t.history = append(t.history, c)
+		if len(t.history) > maxCommands {
+			t.history = t.history[len(t.history)-maxCommands:]
+		}
+		return Notification{Kind: NotifyCommand, Title: c.Command, Command: &c}, true
+	}
+	return Notification{}, false
+}
+
+// unescapeOSC633 decodes the \\ and \xNN escapes of an OSC 633 value.
+func unescapeOSC633(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			if s[i+1] == '\\' {
+				b.WriteByte('\\')
+				i++
+				continue
+			}
+			if s[i+1] == 'x' && i+3 < len(s) {
+				if v, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+					b.WriteByte(byte(v))
+					i += 3
+					continue
+				}
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// ShellState returns what shell integration has reported so far.
+func (s *Session) ShellState() ShellState {
+	t := &s.tracker
+	t.mu.Lock()
+	st := ShellState{
+		Integrated: t.integrated,
+		Cwd:        t.cwd,
+		Commands:   make([]Command, len(t.history)),
+	}
+	copy(st.Commands, t.history)
+	if t.running != nil {
+		running := *t.running
+		st.Running = &running
+	}
+	t.mu.Unlock()
+	st.ScrollbackStart, st.ScrollbackEnd = s.scrollback.Offsets()
+	return st
+}
+
+// bashBootstrap is the PROMPT_COMMAND that loads the bash integration script
+// named by WEB_TERMINAL_BASH_INTEGRATION and does for the first prompt what
+// the script's own PROMPT_COMMAND does for later ones. integration/bash.sh
+// removes it by this exact text.
+const bashBootstrap = `. "$WEB_TERMINAL_BASH_INTEGRATION" && __wt_precmd && __wt_trap=$(trap -p DEBUG) && __wt_start`
+
+// writeBashScript writes the bash integration script to a file for
+// bashBootstrap to load.
+func writeBashScript() (string, error) {
+	script, _ := IntegrationScript("bash")
+	f, err := os.CreateTemp("", "web-terminal-bash-*.sh")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(script); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// integrates reports whether spec starts the default bash, which is what
+// the PROMPT_COMMAND injection supports.
+func integrates(spec Spec) bool {
+	return (spec.Shell == "" || filepath.Base(spec.Shell) == "bash") && spec.Args == nil
+}
@@ -0,0 +1,124 @@
+package session
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestShellTrackerCommands(t *testing.T) {
+	var tr shellTracker
+	out := "\x1b]7;file://host/home/me%20too\x07" +
+		"\x1b]133;A\x07$ \x1b]133;B\x07" +
+		"\x1b]633;E;echo a\\x3bb \\\\ c\x07\x1b]133;C\x07" +
+		"a;b \\ c\r\n" +
+		"\x1b]133;D;0\x1b\\"
+	// Feed it a byte at a time, as if every read split a sequence.
+	for i := range len(out) {
+		tr.feed([]byte{out[i]}, int64(i))
+	}
+
+	if tr.cwd != "/home/me too" {
+		t.Fatalf("cwd = %q", tr.cwd)
+	}
+	if len(tr.history) != 1 {
+		t.Fatalf("expected one command, got %+v", tr.history)
+	}
+	c := tr.history[0]
+	if c.Command != `echo a;b \ c` || c.Cwd != "/home/me too" {
+		t.Fatalf("unexpected command %+v", c)
+	}
+	if c.ExitCode == nil || *c.ExitCode != 0 {
+		t.Fatalf("exit code not recorded: %+v", c)
+	}
+	if got := out[c.OutputStart:c.OutputEnd]; got != "a;b \\ c\r\n" {
+		t.Fatalf("output offsets cover %q", got)
+	}
+
+	// A D without a C, such as after an empty prompt, records nothing.
+	tr.feed([]byte("\x1b]133;D;1\x07"), int64(len(out)))
+	if len(tr.history) != 1 {
+		t.Fatalf("unexpected command recorded: %+v", tr.history)
+	}
+}
+
+func TestShellStateOffsetsFollowScrollback(t *testing.T) {
+	m := NewManagerWithBackend(BackendMock)
+	s, err := m.Create("offsets")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	s.WriteToPTY([]byte("before\x1b]133;C\x07output\x1b]133;D;2\x07"))
+	waitFor(t, "command", func() bool { return len(s.ShellState().Commands) == 1 })
+
+	st := s.ShellState()
+	c := st.Commands[0]
+	snap := s.ScrollbackSnapshot()
+	if got := string(snap[c.OutputStart-st.ScrollbackStart : c.OutputEnd-st.ScrollbackStart]); got != "output" {
+		t.Fatalf("offsets cover %q", got)
+	}
+	if !st.Integrated || *c.ExitCode != 2 || st.ScrollbackEnd != int64(len(snap)) {
+		t.Fatalf("unexpected state %+v", st)
+	}
+}
+
+func TestBashIntegration(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not installed")
+	}
+	m := NewManager()
+	if err := m.SetShellIntegration(true); err != nil {
+		t.Fatalf("SetShellIntegration: %v", err)
+	}
+	t.Cleanup(func() { os.Remove(m.bashScript) })
+	// The profile's DEBUG trap and PROMPT_COMMAND run alongside the
+	// integration's.
+	home := t.TempDir()
+	profile := `trap 'touch "$HOME/debug"' DEBUG` + "\n" +
+		`PROMPT_COMMAND="${PROMPT_COMMAND:+$PROMPT_COMMAND; }: profile"` + "\n"
+	if err := os.WriteFile(filepath.Join(home, ".bash_profile"), []byte(profile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := m.CreateWithSpec("bash", Spec{Cwd: os.TempDir(), Env: map[string]string{"HOME": home}})
+	if err != nil {
+		t.Fatalf("CreateWithSpec: %v", err)
+	}
+	defer m.Kill(s.ID)
+
+	waitFor(t, "prompt", func() bool { return s.ShellState().Integrated })
+	s.WriteToPTY([]byte("sleep 0.1; false\r"))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.ShellState().Commands) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("no command recorded: %q", s.ScrollbackSnapshot())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	st := s.ShellState()
+	c := st.Commands[0]
+	if c.Command != "sleep 0.1; false" || c.ExitCode == nil || *c.ExitCode != 1 {
+		t.Fatalf("unexpected command %+v", c)
+	}
+	if c.DurationMs < 100 || c.Cwd != os.TempDir() || st.Cwd != os.TempDir() {
+		t.Fatalf("unexpected duration or cwd: %+v, cwd %q", c, st.Cwd)
+	}
+
+	// Only typed commands are recorded, not those run for the prompt.
+	s.WriteToPTY([]byte("cd /\r"))
+	waitFor(t, "cd", func() bool { return s.ShellState().Cwd == "/" })
+	if cmds := s.ShellState().Commands; len(cmds) != 2 || cmds[1].Command != "cd /" {
+		t.Fatalf("unexpected history %+v", cmds)
+	}
+	if _, err := os.Stat(filepath.Join(home, "debug")); err != nil {
+		t.Fatalf("profile's DEBUG trap did not run: %v", err)
+	}
+
+	// The shell is still a login shell.
+	s.WriteToPTY([]byte("shopt login_shell\r"))
+	waitFor(t, "shopt", func() bool {
+		return regexp.MustCompile(`login_shell\s+on`).Match(s.ScrollbackSnapshot())
+	})
+}
@@ -0,0 +1,691 @@
+package session
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var (
+	ErrInvalidTarget   = errors.New("invalid ssh target")
+	ErrHostKeyRejected = errors.New("host key not trusted")
+	ErrNotSSH          = errors.New("not an ssh session")
+	ErrNoForwarding    = errors.New("agent forwarding was not requested when the session started")
+)
+
+// HostKeyStore verifies host keys and records the ones the user trusts on
+// first use.
+type HostKeyStore interface {
+	// Lookup reports whether key is on record for hostname. It returns false
+	// with a nil error for a host without any key, and an error if the host
+	// has a different key.
+	Lookup(hostname string, remote net.Addr, key ssh.PublicKey) (bool, error)
+	Add(hostname string, key ssh.PublicKey) error
+}
+
+// KeyStore provides the private keys that targets refer to by Auth.KeyID.
+type KeyStore interface {
+	PrivateKey(id string) (crypto.PrivateKey, error)
+}
+
+var (
+	// sshDialTimeout bounds connecting and authenticating to a host.
+	sshDialTimeout = 10 * time.Second
+	// sshKeepAliveInterval is how often an idle connection is probed; after
+	// sshKeepAliveMax unanswered probes it is considered lost.
+	sshKeepAliveInterval = 15 * time.Second
+	sshKeepAliveMax      = 3
+	// sshReconnectDelays are the waits before each reconnect attempt after a
+	// connection is lost. When they run out the session ends.
+	sshReconnectDelays = []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second}
+)
+
+// Target is a remote host whose shell the session runs through the built-in
+// SSH client.
+type Target struct {
+	Host string `json:"host"`
+	Port int    `json:"port,omitempty"` // default 22
+	User string `json:"user"`
+	Auth Auth   `json:"auth"`
+	// HostKey pins the host's key by its SHA256 fingerprint ("SHA256:…").
+	// Without it the key is checked against known_hosts.
+	HostKey string `json:"hostKey,omitempty"`
+	// ForwardAgent makes an agent available on the host: the one at
+	// SSH_AUTH_SOCK if set, otherwise one holding the session's own key.
+	ForwardAgent bool `json:"forwardAgent,omitempty"`
+}
+
+// Auth holds the credentials offered to the host, tried in the order agent,
+// stored key, private key, password.
+type Auth struct {
+	Password   string `json:"password,omitempty"`
+	KeyID      string `json:"keyId,omitempty"`      // a key from the server's key store
+	PrivateKey string `json:"privateKey,omitempty"` // PEM
+	Passphrase string `json:"passphrase,omitempty"` // for an encrypted PrivateKey
+	Agent      bool   `json:"agent,omitempty"`      // use the agent at SSH_AUTH_SOCK
+}
+
+// Addr returns the target's host:port.
+func (t Target) Addr() string {
+	port := t.Port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(t.Host, strconv.Itoa(port))
+}
+
+// String returns user@host:port.
+func (t Target) String() string {
+	return t.User + "@" + t.Addr()
+}
+
+// Validate reports whether t names a host, a user and some credentials.
+func (t Target) Validate() error {
+	switch {
+	case t.Host == "" || strings.ContainsAny(t.Host, " /@"):
+		return fmt.Errorf("%w: host is required", ErrInvalidTarget)
+	case t.Port < 0 || t.Port > 65535:
+		return fmt.Errorf("%w: port out of range", ErrInvalidTarget)
+	case t.User == "":
+		return fmt.Errorf("%w: user is required", ErrInvalidTarget)
+	case t.Auth.Password == "" && t.Auth.KeyID == "" && t.Auth.PrivateKey == "" && !t.Auth.Agent:
+		return fmt.Errorf("%w: no credentials", ErrInvalidTarget)
+	}
+	return nil
+}
+
+// sshBackend connects a session to a remote shell and keeps it connected,
+// reconnecting with a fresh shell if the connection drops.
+type sshBackend struct {
+	s        *Session
+	spec     Spec
+	config   *ssh.ClientConfig
+	hostKeys HostKeyStore // nil → ~/.ssh/known_hosts, without prompts
+	keys     KeyStore
+	agent    net.Conn                     // open for the backend's lifetime when agent auth is used
+	forward  func(ch io.ReadWriter) error // serves one agent channel opened by the host
+	stop     chan struct{}                // closed by Close
+	ready    chan struct{}                // closed once the first shell has started
+
+	// Copied from the package settings when the session starts.
+	keepAliveInterval time.Duration
+	reconnectDelays   []time.Duration
+
+	mu         sync.Mutex
+	client     *ssh.Client
+	sess       *ssh.Session
+	stdin      io.Writer // nil while reconnecting
+	cols       int
+	rows       int
+	closed     bool
+	forwarding bool // serve agent requests from the host
+
+	// Used only by Read and Wait.
+	stdout    io.Reader // current shell's output; nil before connecting
+	connected bool      // a shell was started at least once
+	eof       bool
+	waitErr   error
+}
+
+// newSSHBackend creates a backend for s that verifies hosts with hostKeys
+// and finds stored keys in keys.
+func newSSHBackend(s *Session, hostKeys HostKeyStore, keys KeyStore) *sshBackend {
+	return &sshBackend{
+		s:                 s,
+		hostKeys:          hostKeys,
+		keys:              keys,
+		cols:              80,
+		rows:              24,
+		stop:              make(chan struct{}),
+		ready:             make(chan struct{}),
+		keepAliveInterval: sshKeepAliveInterval,
+		reconnectDelays:   sshReconnectDelays,
+	}
+}
+
+// Start prepares the connection to spec.Target; connecting happens on the
+// first Read. Only problems with the target itself are reported here;
+// connection failures end the session with a notice in its output.
+func (b *sshBackend) Start(spec Spec) error {
+	if spec.Target == nil {
+		return fmt.Errorf("%w: target is required", ErrInvalidTarget)
+	}
+	t := *spec.Target
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	b.spec = spec
+	s, keys := b.s, b.keys
+	fail := func(err error) error {
+		b.closeAgent()
+		return err
+	}
+
+	var methods []ssh.AuthMethod
+	var ownKey crypto.PrivateKey
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if t.Auth.Agent {
+		if sock == "" {
+			return fmt.Errorf("%w: agent requested but SSH_AUTH_SOCK is not set", ErrInvalidTarget)
+		}
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return fmt.Errorf("connect to ssh agent: %w", err)
+		}
+		b.agent = conn
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+	if t.Auth.KeyID != "" {
+		if keys == nil {
+			return fail(fmt.Errorf("%w: no key store", ErrInvalidTarget))
+		}
+		priv, err := keys.PrivateKey(t.Auth.KeyID)
+		if err != nil {
+			return fail(fmt.Errorf("%w: key %s: %v", ErrInvalidTarget, t.Auth.KeyID, err))
+		}
+		signer, err := ssh.NewSignerFromKey(priv)
+		if err != nil {
+			return fail(fmt.Errorf("%w: key %s: %v", ErrInvalidTarget, t.Auth.KeyID, err))
+		}
+		ownKey = priv
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if t.Auth.PrivateKey != "" {
+		priv, err := parseRawKey(t.Auth.PrivateKey, t.Auth.Passphrase)
+		if err != nil {
+			return fail(fmt.Errorf("%w: %v", ErrInvalidTarget, err))
+		}
+		signer, err := ssh.NewSignerFromKey(priv)
+		if err != nil {
+			return fail(fmt.Errorf("%w: %v", ErrInvalidTarget, err))
+		}
+		if ownKey == nil {
+			ownKey = priv
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if t.Auth.Password != "" {
+		methods = append(methods, ssh.Password(t.Auth.Password),
+			ssh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = t.Auth.Password
+				}
+				return answers, nil
+			}))
+	}
+
+	if t.ForwardAgent {
+		switch {
+		case sock != "":
+			b.forward = func(ch io.ReadWriter) error {
+				// Connect per channel so a restarted agent is picked up.
+				conn, err := net.Dial("unix", sock)
+				if err != nil {
+					return err
+				}
+				defer conn.Close()
+				return agent.ServeAgent(agent.NewClient(conn), ch)
+			}
+		case ownKey != nil:
+			ring := agent.NewKeyring()
+			if err := ring.Add(agent.AddedKey{PrivateKey: ownKey, Comment: s.Name}); err != nil {
+				return fail(fmt.Errorf("%w: agent forwarding: %v", ErrInvalidTarget, err))
+			}
+			b.forward = func(ch io.ReadWriter) error { return agent.ServeAgent(ring, ch) }
+		default:
+			return fail(fmt.Errorf("%w: agent forwarding needs SSH_AUTH_SOCK or a private key", ErrInvalidTarget))
+		}
+		b.forwarding = true
+		s.AgentForwarding = true
+	}
+
+	if t.HostKey == "" && b.hostKeys == nil {
+		// Without a store, fall back to the container's known_hosts.
+		check, err := defaultKnownHosts()
+		if err != nil {
+			return fail(err)
+		}
+		b.hostKeys = knownHostsFile{check}
+	}
+	b.config = &ssh.ClientConfig{
+		User:            t.User,
+		Auth:            methods,
+		HostKeyCallback: b.verifyHostKey,
+		Timeout:         sshDialTimeout,
+	}
+	return nil
+}
+
+// parseRawKey parses a PEM private key, decrypting it with passphrase if set.
+func parseRawKey(pem, passphrase string) (crypto.PrivateKey, error) {
+	if passphrase != "" {
+		return ssh.ParseRawPrivateKeyWithPassphrase([]byte(pem), []byte(passphrase))
+	}
+	return ssh.ParseRawPrivateKey([]byte(pem))
+}
+
+// verifyHostKey checks the host's key against the target's pinned
+// fingerprint or the host key store. A host the store has never seen is
+// trusted only if the user accepts its fingerprint.
+func (b *sshBackend) verifyHostKey(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	t := b.spec.Target
+	fp := ssh.FingerprintSHA256(key)
+	if t.HostKey != "" {
+		if fp != t.HostKey {
+			return fmt.Errorf("host key mismatch: got %s, want %s", fp, t.HostKey)
+		}
+		b.s.HostKey = fp
+		return nil
+	}
+
+	known, err := b.hostKeys.Lookup(hostname, remote, key)
+	if err != nil {
+		return fmt.Errorf("host key verification failed for %s (%s %s): %w", hostname, key.Type(), fp, err)
+	}
+	if !known {
+		b.notice(fmt.Sprintf("the authenticity of host %s can't be established; %s key fingerprint is %s", hostname, key.Type(), fp))
+		prompt := HostKeyPrompt{Host: hostname, KeyType: key.Type(), Fingerprint: fp}
+		if !b.s.askHostKey(prompt, b.stop) {
+			return ErrHostKeyRejected
+		}
+		if err := b.hostKeys.Add(hostname, key); err != nil {
+			return fmt.Errorf("record host key: %w", err)
+		}
+	}
+	b.s.HostKey = fp
+	return nil
+}
+
+// knownHostsFile adapts a read-only known_hosts check to HostKeyStore. Hosts
+// it does not know are offered to the user as with any store, but a key the
+// user trusts is not written back: it is trusted for that connection only.
+type knownHostsFile struct {
+	check ssh.HostKeyCallback
+}
+
+func (k knownHostsFile) Lookup(hostname string, remote net.Addr, key ssh.PublicKey) (bool, error) {
+	err := k.check(hostname, remote, key)
+	// A KeyError without Want entries means the file has no key at all for
+	// the host; with them, the host has a different key.
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (k knownHostsFile) Add(string, ssh.PublicKey) error {
+	return nil
+}
+
+// defaultKnownHosts loads ~/.ssh/known_hosts.
+func defaultKnownHosts() (ssh.HostKeyCallback, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("no known_hosts: %w", err)
+	}
+	check, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("no known_hosts; pin the host key instead: %w", err)
+	}
+	return check, nil
+}
+
+// connect dials the host and starts the remote shell on a new PTY of the
+// last known size. It returns the shell's output.
+func (b *sshBackend) connect() (io.Reader, error) {
+	spec := b.spec
+	client, err := ssh.Dial("tcp", spec.Target.Addr(), b.config)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := client.NewSession()
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	fail := func(err error) (io.Reader, error) {
+		sess.Close()
+		client.Close()
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(spec.Env))
+	for k := range spec.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		// Most servers only accept variables listed in AcceptEnv.
+		_ = sess.Setenv(k, spec.Env[k])
+	}
+
+	b.mu.Lock()
+	cols, rows := b.cols, b.rows
+	b.mu.Unlock()
+	if b.forward != nil {
+		go b.serveAgent(client.HandleChannelOpen("auth-agent@openssh.com"))
+		if err := agent.RequestAgentForwarding(sess); err != nil {
+			return fail(fmt.Errorf("request agent forwarding: %w", err))
+		}
+	}
+
+	modes := ssh.TerminalModes{ssh.ECHO: 1, ssh.TTY_OP_ISPEED: 38400, ssh.TTY_OP_OSPEED: 38400}
+	if err := sess.RequestPty("xterm-256color", rows, cols, modes); err != nil {
+		return fail(err)
+	}
+	stdin, err := sess.StdinPipe()
+	if err != nil {
+		return fail(err)
+	}
+	stdout, err := sess.StdoutPipe()
+	if err != nil {
+		return fail(err)
+	}
+	if spec.Shell != "" {
+		err = sess.Start(strings.Join(append([]string{spec.Shell}, spec.Args...), " "))
+	} else {
+		err = sess.Shell()
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	b.mu.Lock()
+	b.client, b.sess, b.stdin = client, sess, stdin
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		// Killed while connecting.
+		return fail(ErrClosed)
+	}
+	go b.keepAlive(client)
+	return stdout, nil
+}
+
+// Read returns the remote shell's output. Connecting and reconnecting
+// happen here, on the session's read loop, with notices about them written
+// straight to the session's output.
+func (b *sshBackend) Read(p []byte) (int, error) {
+	for {
+		if b.eof {
+			return 0, io.EOF
+		}
+		if b.stdout == nil {
+			b.stdout = b.advance()
+			continue
+		}
+		n, err := b.stdout.Read(p)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil {
+			b.stdout = nil
+		}
+	}
+}
+
+// advance connects the first time it is called and, after the shell's
+// output has ended, reconnects if the connection was lost. It returns nil
+// and sets eof when the session should end.
+func (b *sshBackend) advance() io.Reader {
+	target := b.spec.Target.String()
+	if !b.connected {
+		b.notice("connecting to " + target + "…")
+		stdout, err := b.connect()
+		if err != nil {
+			if !b.isClosed() {
+				log.Printf("session %s: connect to %s: %v", b.s.ID, target, err)
+				b.notice("could not connect to " + target + ": " + err.Error())
+			}
+			b.eof, b.waitErr = true, err
+			return nil
+		}
+		b.connected = true
+		close(b.ready)
+		return stdout
+	}
+
+	b.mu.Lock()
+	sess, client := b.sess, b.client
+	b.stdin = nil
+	b.mu.Unlock()
+	err := sess.Wait()
+	client.Close()
+	if b.isClosed() || !connectionLost(err) {
+		b.eof, b.waitErr = true, err
+		return nil
+	}
+
+	log.Printf("session %s: connection to %s lost: %v", b.s.ID, target, err)
+	stdout := b.reconnect()
+	if stdout == nil {
+		b.eof, b.waitErr = true, err
+	}
+	return stdout
+}
+
+// Wait returns how the remote shell ended, or why the connection could not
+// be made or kept.
+func (b *sshBackend) Wait() error {
+	b.closeAgent()
+	return b.waitErr
+}
+
+// Ready is closed once the first remote shell has started.
+func (b *sshBackend) Ready() <-chan struct{} {
+	return b.ready
+}
+
+// connectionLost reports whether a remote shell's Wait error means the
+// connection dropped rather than the shell exiting.
+func connectionLost(err error) bool {
+	var exit *ssh.ExitError
+	return err != nil && !errors.As(err, &exit)
+}
+
+// reconnect retries connect with back-off, telling the user in the terminal.
+// It returns nil when the attempts are exhausted or the session was killed.
+func (b *sshBackend) reconnect() io.Reader {
+	target := b.spec.Target.String()
+	b.notice("connection to " + target + " lost, reconnecting…")
+	for _, delay := range b.reconnectDelays {
+		select {
+		case <-time.After(delay):
+		case <-b.stop:
+			return nil
+		}
+		stdout, err := b.connect()
+		if err == nil {
+			b.notice("reconnected to " + target + " (new shell)")
+			return stdout
+		}
+		log.Printf("session %s: reconnect to %s: %v", b.s.ID, target, err)
+	}
+	b.notice("could not reconnect to " + target)
+	return nil
+}
+
+// notice writes a highlighted line into the session's output. It is only
+// called from Read, so notices and shell output stay in order.
+func (b *sshBackend) notice(text string) {
+	b.s.handleOutput([]byte("\r\n\x1b[33m[" + text + "]\x1b[0m\r\n"))
+}
+
+// keepAlive probes the connection while it is idle and closes it after
+// sshKeepAliveMax unanswered probes, so a dead network path is noticed.
+func (b *sshBackend) keepAlive(client *ssh.Client) {
+	ticker := time.NewTicker(b.keepAliveInterval)
+	defer ticker.Stop()
+	missed := 0
+	for range ticker.C {
+		reply := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			reply <- err
+		}()
+		select {
+		case err := <-reply:
+			if err != nil {
+				return // connection closed
+			}
+			missed = 0
+		case <-time.After(b.keepAliveInterval):
+			if missed++; missed >= sshKeepAliveMax {
+				log.Printf("session %s: %s stopped answering keepalives", b.s.ID, b.spec.Target)
+				client.Close()
+				return
+			}
+		}
+	}
+}
+
+// Write sends input to the remote shell. Input typed while reconnecting is
+// dropped.
+func (b *sshBackend) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	stdin := b.stdin
+	b.mu.Unlock()
+	if stdin == nil {
+		if b.isClosed() {
+			return 0, ErrClosed
+		}
+		return len(p), nil
+	}
+	return stdin.Write(p)
+}
+
+// Resize changes the remote PTY's size, remembering it for reconnects.
+func (b *sshBackend) Resize(cols, rows uint16) error {
+	b.mu.Lock()
+	b.cols, b.rows = int(cols), int(rows)
+	sess := b.sess
+	b.mu.Unlock()
+	if sess == nil {
+		return nil
+	}
+	return sess.WindowChange(int(rows), int(cols))
+}
+
+// sshSignals maps the signals Signal can deliver to their SSH names.
+var sshSignals = map[os.Signal]ssh.Signal{
+	syscall.SIGHUP:  ssh.SIGHUP,
+	syscall.SIGINT:  ssh.SIGINT,
+	syscall.SIGQUIT: ssh.SIGQUIT,
+	syscall.SIGKILL: ssh.SIGKILL,
+	syscall.SIGTERM: ssh.SIGTERM,
+	syscall.SIGUSR1: ssh.SIGUSR1,
+	syscall.SIGUSR2: ssh.SIGUSR2,
+}
+
+// Signal asks the server to deliver sig to the remote shell. Many servers
+// ignore such requests.
+func (b *sshBackend) Signal(sig os.Signal) error {
+	name, ok := sshSignals[sig]
+	if !ok {
+		return fmt.Errorf("signal %v cannot be sent over ssh", sig)
+	}
+	b.mu.Lock()
+	sess := b.sess
+	b.mu.Unlock()
+	if sess == nil {
+		return ErrClosed
+	}
+	return sess.Signal(name)
+}
+
+// serveAgent answers the host's agent requests while forwarding is on.
+func (b *sshBackend) serveAgent(channels <-chan ssh.NewChannel) {
+	for nch := range channels {
+		b.mu.Lock()
+		on := b.forwarding
+		b.mu.Unlock()
+		if !on {
+			nch.Reject(ssh.Prohibited, "agent forwarding is turned off")
+			continue
+		}
+		ch, reqs, err := nch.Accept()
+		if err != nil {
+			continue
+		}
+		go ssh.DiscardRequests(reqs)
+		go func() {
+			defer ch.Close()
+			if err := b.forward(ch); err != nil && !errors.Is(err, io.EOF) {
+				log.Printf("session %s: forwarded agent: %v", b.s.ID, err)
+			}
+		}()
+	}
+}
+
+// SetAgentForwarding turns agent forwarding for an SSH session on or off.
+func (s *Session) SetAgentForwarding(on bool) error {
+	b, ok := s.shell.(*sshBackend)
+	if !ok {
+		return ErrNotSSH
+	}
+	return b.SetForwarding(on)
+}
+
+// SetForwarding turns serving agent requests from the host on or off. It
+// can only be turned on if forwarding was requested when the session
+// started.
+func (b *sshBackend) SetForwarding(on bool) error {
+	if b.forward == nil {
+		if on {
+			return ErrNoForwarding
+		}
+		return nil
+	}
+	b.mu.Lock()
+	b.forwarding = on
+	b.mu.Unlock()
+	b.s.AgentForwarding = on
+	return nil
+}
+
+// Close disconnects for good; Read then ends the session.
+func (b *sshBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	close(b.stop)
+	if b.client != nil {
+		b.client.Close()
+	}
+	return nil
+}
+
+func (b *sshBackend) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+func (b *sshBackend) closeAgent() {
+	if b.agent != nil {
+		b.agent.Close()
+	}
+}
@@ -0,0 +1,554 @@
+package session
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testSSHServer is an in-process SSH server whose "shell" echoes its input.
+// Typing "exit" ends the shell with status 0.
+type testSSHServer struct {
+	t        *testing.T
+	ln       net.Listener
+	config   *ssh.ServerConfig
+	hostKey  ssh.PublicKey
+	mu       sync.Mutex
+	conns    []net.Conn
+	sconns   []*ssh.ServerConn
+	userKeys []ssh.PublicKey // accepted for alice besides the password
+	sizes    [][2]uint32     // cols, rows from pty-req and window-change
+	requests []string        // global request types
+}
+
+func newTestSSHServer(t *testing.T) *testSSHServer {
+	t.Helper()
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &testSSHServer{t: t, hostKey: signer.PublicKey()}
+	srv.config = &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "alice" && string(pass) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			srv.mu.Lock()
+			defer srv.mu.Unlock()
+			for _, k := range srv.userKeys {
+				if c.User() == "alice" && bytes.Equal(k.Marshal(), key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, errors.New("denied")
+		},
+	}
+	srv.config.AddHostKey(signer)
+	srv.ln, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.ln.Close() })
+	go srv.serve()
+	return srv
+}
+
+func (srv *testSSHServer) target() *Target {
+	addr := srv.ln.Addr().(*net.TCPAddr)
+	return &Target{
+		Host:    "127.0.0.1",
+		Port:    addr.Port,
+		User:    "alice",
+		Auth:    Auth{Password: "secret"},
+		HostKey: ssh.FingerprintSHA256(srv.hostKey),
+	}
+}
+
+func (srv *testSSHServer) serve() {
+	for {
+		nc, err := srv.ln.Accept()
+		if err != nil {
+			return
+		}
+		srv.mu.Lock()
+		srv.conns = append(srv.conns, nc)
+		srv.mu.Unlock()
+		go srv.handle(nc)
+	}
+}
+
+// dropAll cuts every connection without a clean SSH shutdown.
+func (srv *testSSHServer) dropAll() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, c := range srv.conns {
+		c.Close()
+	}
+}
+
+func (srv *testSSHServer) handle(nc net.Conn) {
+	sconn, chans, reqs, err := ssh.NewServerConn(nc, srv.config)
+	if err != nil {
+		return
+	}
+	srv.mu.Lock()
+	srv.sconns = append(srv.sconns, sconn)
+	srv.mu.Unlock()
+	go func() {
+		for r := range reqs {
+			srv.mu.Lock()
+			srv.requests = append(srv.requests, r.Type)
+			srv.mu.Unlock()
+			if r.WantReply {
+				r.Reply(true, nil)
+			}
+		}
+	}()
+	for nch := range chans {
+		ch, chReqs, err := nch.Accept()
+		if err != nil {
+			continue
+		}
+		go srv.shell(ch, chReqs)
+	}
+}
+
+func (srv *testSSHServer) shell(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	go func() {
+		for r := range reqs {
+			switch r.Type {
+			case "pty-req":
+				// string term, uint32 cols, uint32 rows, ...
+				termLen := binary.BigEndian.Uint32(r.Payload)
+				p := r.Payload[4+termLen:]
+				srv.recordSize(binary.BigEndian.Uint32(p), binary.BigEndian.Uint32(p[4:]))
+			case "window-change":
+				srv.recordSize(binary.BigEndian.Uint32(r.Payload), binary.BigEndian.Uint32(r.Payload[4:]))
+			case "auth-agent-req@openssh.com":
+				srv.mu.Lock()
+				srv.requests = append(srv.requests, r.Type)
+				srv.mu.Unlock()
+			}
+			if r.WantReply {
+				r.Reply(true, nil)
+			}
+		}
+	}()
+	buf := make([]byte, 1024)
+	for {
+		n, err := ch.Read(buf)
+		if err != nil {
+			return
+		}
+		if bytes.Contains(buf[:n], []byte("exit")) {
+			ch.SendRequest("exit-status", false, []byte{0, 0, 0, 0})
+			ch.Close()
+			return
+		}
+		ch.Write(buf[:n])
+	}
+}
+
+func (srv *testSSHServer) recordSize(cols, rows uint32) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.sizes = append(srv.sizes, [2]uint32{cols, rows})
+}
+
+func (srv *testSSHServer) lastSize() [2]uint32 {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.sizes) == 0 {
+		return [2]uint32{}
+	}
+	return srv.sizes[len(srv.sizes)-1]
+}
+
+// waitFor polls cond until it holds or a second passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSSHSessionEchoResizeAndExit(t *testing.T) {
+	srv := newTestSSHServer(t)
+	m := NewManager()
+	s, err := m.CreateWithSpec("remote", Spec{Target: srv.target()})
+	if err != nil {
+		t.Fatalf("CreateWithSpec: %v", err)
+	}
+	if s.Remote != "alice@"+srv.ln.Addr().String() {
+		t.Fatalf("unexpected Remote %q", s.Remote)
+	}
+	if _, err := s.Cwd(); !errors.Is(err, ErrNoCwd) {
+		t.Fatalf("expected ErrNoCwd for a remote session, got %v", err)
+	}
+
+	waitConnected(t, s)
+	s.WriteToPTY([]byte("hello"))
+	waitFor(t, "echo", func() bool { return bytes.Contains(s.ScrollbackSnapshot(), []byte("hello")) })
+
+	if err := s.Resize(120, 40); err != nil {
+		t.Fatalf("Resize: %v", err)
+	}
+	waitFor(t, "window-change", func() bool { return srv.lastSize() == [2]uint32{120, 40} })
+
+	s.WriteToPTY([]byte("exit"))
+	select {
+	case <-s.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("session did not end when the remote shell exited")
+	}
+	if _, ok := m.Get(s.ID); ok {
+		t.Fatal("ended session still listed")
+	}
+}
+
+func TestSSHSessionReconnects(t *testing.T) {
+	defer func(d []time.Duration) { sshReconnectDelays = d }(sshReconnectDelays)
+	sshReconnectDelays = []time.Duration{10 * time.Millisecond}
+
+	srv := newTestSSHServer(t)
+	m := NewManager()
+	s, err := m.CreateWithSpec("remote", Spec{Target: srv.target()})
+	if err != nil {
+		t.Fatalf("CreateWithSpec: %v", err)
+	}
+	defer m.Kill(s.ID)
+	s.Resize(100, 30)
+	waitFor(t, "window-change", func() bool { return srv.lastSize() == [2]uint32{100, 30} })
+
+	srv.dropAll()
+	waitFor(t, "reconnect", func() bool {
+		return bytes.Contains(s.ScrollbackSnapshot(), []byte("reconnected to alice@"))
+	})
+	if got := srv.lastSize(); got != [2]uint32{100, 30} {
+		t.Fatalf("new PTY should keep the last size, got %v", got)
+	}
+	s.WriteToPTY([]byte("again"))
+	waitFor(t, "echo after reconnect", func() bool { return bytes.Contains(s.ScrollbackSnapshot(), []byte("again")) })
+}
+
+func TestSSHKeepAlive(t *testing.T) {
+	defer func(d time.Duration) { sshKeepAliveInterval = d }(sshKeepAliveInterval)
+	sshKeepAliveInterval = 20 * time.Millisecond
+
+	srv := newTestSSHServer(t)
+	m := NewManager()
+	s, err := m.CreateWithSpec("remote", Spec{Target: srv.target()})
+	if err != nil {
+		t.Fatalf("CreateWithSpec: %v", err)
+	}
+	defer m.Kill(s.ID)
+	waitFor(t, "keepalive", func() bool {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		return strings.Contains(strings.Join(srv.requests, ","), "keepalive@openssh.com")
+	})
+}
+
+func TestSSHSessionRejected(t *testing.T) {
+	srv := newTestSSHServer(t)
+	m := NewManager()
+
+	// Connection failures end the session with a notice in its output.
+	wrongKey := srv.target()
+	wrongKey.HostKey = "SHA256:AAAA"
+	s, err := m.CreateWithSpec("a", Spec{Target: wrongKey})
+	if err != nil {
+		t.Fatalf("CreateWithSpec: %v", err)
+	}
+	waitEnded(t, s)
+	if !bytes.Contains(s.ScrollbackSnapshot(), []byte("host key mismatch")) {
+		t.Fatalf("expected a host key mismatch notice, got %q", s.ScrollbackSnapshot())
+	}
+
+	wrongPass := srv.target()
+	wrongPass.Auth.Password = "nope"
+	s, err = m.CreateWithSpec("b", Spec{Target: wrongPass})
+	if err != nil {
+		t.Fatalf("CreateWithSpec: %v", err)
+	}
+	waitEnded(t, s)
+	if !bytes.Contains(s.ScrollbackSnapshot(), []byte("could not connect")) {
+		t.Fatalf("expected an authentication failure notice, got %q", s.ScrollbackSnapshot())
+	}
+
+	if _, err := m.CreateWithSpec("c", Spec{Target: &Target{Host: "example.com"}}); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("expected ErrInvalidTarget, got %v", err)
+	}
+	unknownKey := srv.target()
+	unknownKey.Auth = Auth{KeyID: "missing"}
+	m.SetKeyStore(memKeys{})
+	if _, err := m.CreateWithSpec("d", Spec{Target: unknownKey}); !errors.Is(err, ErrInvalidTarget) {
+		t.Fatalf("expected ErrInvalidTarget for an unknown key, got %v", err)
+	}
+	waitFor(t, "failed sessions to be removed", func() bool { return len(m.List()) == 0 })
+}
+
+func waitConnected(t *testing.T, s *Session) {
+	t.Helper()
+	select {
+	case <-s.shell.(*sshBackend).Ready():
+	case <-s.Done():
+		t.Fatalf("session ended: %q", s.ScrollbackSnapshot())
+	case <-time.After(2 * time.Second):
+		t.Fatal("session did not connect")
+	}
+}
+
+func waitEnded(t *testing.T, s *Session) {
+	t.Helper()
+	select {
+	case <-s.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("session did not end")
+	}
+}
+
+// memHostKeys is an in-memory HostKeyStore.
+type memHostKeys struct {
+	mu   sync.Mutex
+	keys map[string]string // hostname → fingerprint
+}
+
+func (k *memHostKeys) Lookup(hostname string, _ net.Addr, key ssh.PublicKey) (bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	fp, ok := k.keys[hostname]
+	if ok && fp != ssh.FingerprintSHA256(key) {
+		return false, errors.New("key changed")
+	}
+	return ok, nil
+}
+
+func (k *memHostKeys) Add(hostname string, key ssh.PublicKey) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[hostname] = ssh.FingerprintSHA256(key)
+	return nil
+}
+
+// memKeys is an in-memory KeyStore.
+type memKeys map[string]crypto.PrivateKey
+
+func (k memKeys) PrivateKey(id string) (crypto.PrivateKey, error) {
+	if priv, ok := k[id]; ok {
+		return priv, nil
+	}
+	return nil, errors.New("no such key")
+}
+
+// nextPrompt waits for a host key question on ch.
+func nextPrompt(t *testing.T, ch chan Output) *HostKeyPrompt {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case out := <-ch:
+			if out.Prompt != nil {
+				return out.Prompt
+			}
+		case <-timeout:
+			t.Fatal("no host key prompt")
+		}
+	}
+}
+
+func TestSSHHostKeyTrustOnFirstUse(t *testing.T) {
+	srv := newTestSSHServer(t)
+	store := &memHostKeys{keys: map[string]string{}}
+	m := NewManager()
+	m.SetHostKeys(store)
+
+	unpinned := srv.target()
+	unpinned.HostKey = ""
+	s, err := m.CreateWithSpec("declined", Spec{Target: unpinned})
+	if err != nil {
+		t.Fatalf("CreateWithSpec: %v", err)
+	}
+	ch := make(chan Output, 64)
+	s.SetClient(ch)
+	p := nextPrompt(t, ch)
+	if p.Fingerprint != ssh.FingerprintSHA256(srv.hostKey) || p.KeyType != ssh.KeyAlgoED25519 {
+		t.Fatalf("unexpected prompt %+v", p)
+	}
+	if s.AnswerHostKey("other", true) {
+		t.Fatal("answer to an unknown prompt accepted")
+	}
+	if !s.AnswerHostKey(p.ID, false) {
+		t.Fatal("answer not accepted")
+	}
+	waitEnded(t, s)
+	if !bytes.Contains(s.ScrollbackSnapshot(), []byte(ErrHostKeyRejected.Error())) {
+		t.Fatalf("expected a rejection notice, got %q", s.ScrollbackSnapshot())
+	}
+	if len(store.keys) != 0 {
+		t.Fatal("declined key was stored")
+	}
+
+	// A client connecting after the question was asked still gets it.
+	s, err = m.CreateWithSpec("trusted", Spec{Target: unpinned})
+	if err != nil {
+		t.Fatalf("CreateWithSpec: %v", err)
+	}
+	defer m.Kill(s.ID)
+	waitFor(t, "prompt", func() bool {
+		return bytes.Contains(s.ScrollbackSnapshot(), []byte("can't be established"))
+	})
+	ch = make(chan Output, 64)
+	s.SetClient(ch)
+	p = nextPrompt(t, ch)
+	s.AnswerHostKey(p.ID, true)
+	waitConnected(t, s)
+	s.WriteToPTY([]byte("hello"))
+	waitFor(t, "echo", func() bool { return bytes.Contains(s.ScrollbackSnapshot(), []byte("hello")) })
+	if s.HostKey != p.Fingerprint {
+		t.Fatalf("HostKey = %q, want %q", s.HostKey, p.Fingerprint)
+	}
+	store.mu.Lock()
+	stored := store.keys[srv.ln.Addr().String()]
+	store.mu.Unlock()
+	if stored != p.Fingerprint {
+		t.Fatalf("trusted key not stored: %v", store.keys)
+	}
+
+	// Known now, so no question is asked.
+	again, err := m.CreateWithSpec("again", Spec{Target: unpinned})
+	if err != nil {
+		t.Fatalf("CreateWithSpec: %v", err)
+	}
+	defer m.Kill(again.ID)
+	waitConnected(t, again)
+	again.WriteToPTY([]byte("known"))
+	waitFor(t, "echo", func() bool { return bytes.Contains(again.ScrollbackSnapshot(), []byte("known")) })
+}
+
+func TestSSHKnownHostsFile(t *testing.T) {
+	srv := newTestSSHServer(t)
+	unpinned := srv.target()
+	unpinned.HostKey = ""
+	path := t.TempDir() + "/known_hosts"
+	open := func(name, content string) *Session {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		check, err := knownhosts.New(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := NewManager()
+		m.SetHostKeys(knownHostsFile{check})
+		s, err := m.CreateWithSpec(name, Spec{Target: unpinned})
+		if err != nil {
+			t.Fatalf("CreateWithSpec: %v", err)
+		}
+		return s
+	}
+
+	// A host the file doesn't list is asked about, not refused outright.
+	s := open("unknown", "")
+	ch := make(chan Output, 64)
+	s.SetClient(ch)
+	s.AnswerHostKey(nextPrompt(t, ch).ID, false)
+	waitEnded(t, s)
+	if !bytes.Contains(s.ScrollbackSnapshot(), []byte(ErrHostKeyRejected.Error())) {
+		t.Fatalf("expected a rejection notice, got %q", s.ScrollbackSnapshot())
+	}
+
+	// A host listed with another key fails verification.
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	otherKey, _ := ssh.NewPublicKey(other.Public())
+	s = open("changed", knownhosts.Line([]string{knownhosts.Normalize(srv.ln.Addr().String())}, otherKey)+"\n")
+	waitEnded(t, s)
+	if !bytes.Contains(s.ScrollbackSnapshot(), []byte("host key verification failed")) {
+		t.Fatalf("expected a verification failure, got %q", s.ScrollbackSnapshot())
+	}
+}
+
+func TestSSHStoredKeyAndAgentForwarding(t *testing.T) {
+	srv := newTestSSHServer(t)
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signer, _ := ssh.NewSignerFromKey(priv)
+	srv.userKeys = []ssh.PublicKey{signer.PublicKey()}
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	m := NewManager()
+	m.SetKeyStore(memKeys{"k1": priv})
+	target := srv.target()
+	target.Auth = Auth{KeyID: "k1"}
+	target.ForwardAgent = true
+	s, err := m.CreateWithSpec("remote", Spec{Target: target})
+	if err != nil {
+		t.Fatalf("CreateWithSpec: %v", err)
+	}
+	defer m.Kill(s.ID)
+	waitConnected(t, s)
+	s.WriteToPTY([]byte("hello"))
+	waitFor(t, "echo", func() bool { return bytes.Contains(s.ScrollbackSnapshot(), []byte("hello")) })
+	if !s.AgentForwarding {
+		t.Fatal("agent forwarding not reported")
+	}
+	srv.mu.Lock()
+	sconn := srv.sconns[len(srv.sconns)-1]
+	requested := strings.Contains(strings.Join(srv.requests, ","), "auth-agent-req@openssh.com")
+	srv.mu.Unlock()
+	if !requested {
+		t.Fatal("agent forwarding was not requested")
+	}
+
+	// The host sees the session's key through the forwarded agent.
+	listKeys := func() ([]*agent.Key, error) {
+		ch, reqs, err := sconn.OpenChannel("auth-agent@openssh.com", nil)
+		if err != nil {
+			return nil, err
+		}
+		defer ch.Close()
+		go ssh.DiscardRequests(reqs)
+		return agent.NewClient(ch).List()
+	}
+	keys, err := listKeys()
+	if err != nil || len(keys) != 1 || !bytes.Equal(keys[0].Marshal(), signer.PublicKey().Marshal()) {
+		t.Fatalf("forwarded agent keys = %v, %v", keys, err)
+	}
+
+	if err := s.SetAgentForwarding(false); err != nil {
+		t.Fatalf("SetAgentForwarding: %v", err)
+	}
+	if _, err := listKeys(); err == nil {
+		t.Fatal("agent channel accepted while forwarding is off")
+	}
+
+	local, err := m.CreateWithSpec("local", Spec{Shell: "sh"})
+	if err != nil {
+		t.Fatalf("CreateWithSpec: %v", err)
+	}
+	defer m.Kill(local.ID)
+	if err := local.SetAgentForwarding(true); !errors.Is(err, ErrNotSSH) {
+		t.Fatalf("expected ErrNotSSH, got %v", err)
+	}
+}
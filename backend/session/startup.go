@@ -0,0 +1,62 @@
+package session
+
+import (
+	"log"
+	"time"
+)
+
+var (
+	// startupTimeout bounds how long to wait for the shell's first output
+	// before typing startup commands anyway.
+	startupTimeout = 5 * time.Second
+	// startupSettle is how long the PTY must stay quiet after output before
+	// the prompt is considered drawn.
+	startupSettle = 300 * time.Millisecond
+)
+
+// runStartup types cmds into the session once its prompt appears: it waits for
+// the first PTY output (or startupTimeout), then for the output to settle, and
+// writes each command followed by a carriage return.
+func runStartup(s *Session, cmds []string) {
+	if b, ok := s.shell.(readyBackend); ok {
+		// Output before the shell starts is only connection progress.
+		select {
+		case <-b.Ready():
+		case <-s.done:
+			return
+		}
+		select {
+		case <-s.activity:
+		default:
+		}
+	}
+	select {
+	case <-s.activity:
+	case <-time.After(startupTimeout):
+	case <-s.done:
+		return
+	}
+
+	settle := time.NewTimer(startupSettle)
+	defer settle.Stop()
+	for quiet := false; !quiet; {
+		select {
+		case <-s.activity:
+			if !settle.Stop() {
+				<-settle.C
+			}
+			settle.Reset(startupSettle)
+		case <-settle.C:
+			quiet = true
+		case <-s.done:
+			return
+		}
+	}
+
+	for _, c := range cmds {
+		if _, err := s.WriteToPTY([]byte(c + "\r")); err != nil {
+			log.Printf("session %s startup command error: %v", s.ID, err)
+			return
+		}
+	}
+}
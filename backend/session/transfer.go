@@ -0,0 +1,246 @@
+package session
+
+import (
+	"bytes"
+	"log"
+	"time"
+)
+
+// Transfer protocols recognised in the PTY stream.
+const (
+	ProtocolZmodem = "zmodem"
+	ProtocolTrzsz  = "trzsz"
+)
+
+// Transfer directions, as seen from the browser.
+const (
+	DirectionUpload   = "upload"   // browser → remote host (rz, trz)
+	DirectionDownload = "download" // remote host → browser (sz, tsz)
+)
+
+// Transfer describes an in-band file transfer started by a program in the
+// session, for example `rz` or `tsz` run on a host reached through ssh.
+type Transfer struct {
+	Protocol  string `json:"protocol"`
+	Direction string `json:"direction"`
+}
+
+// transferStarts are the sequences that open a transfer. ZMODEM begins with
+// a hex header: ZRQINIT (type 00) from sz, ZRINIT (type 01) from rz. trzsz
+// prints a magic line naming the mode: S(end), R(eceive) or D(irectory).
+var transferStarts = []struct {
+	seq []byte
+	t   Transfer
+}{
+	{[]byte("**\x18B00"), Transfer{ProtocolZmodem, DirectionDownload}},
+	{[]byte("**\x18B01"), Transfer{ProtocolZmodem, DirectionUpload}},
+	{[]byte("::TRZSZ:TRANSFER:S:"), Transfer{ProtocolTrzsz, DirectionDownload}},
+	{[]byte("::TRZSZ:TRANSFER:R:"), Transfer{ProtocolTrzsz, DirectionUpload}},
+	{[]byte("::TRZSZ:TRANSFER:D:"), Transfer{ProtocolTrzsz, DirectionUpload}},
+}
+
+// maxStartLen is the length of the longest start sequence.
+var maxStartLen = func() int {
+	n := 0
+	for _, s := range transferStarts {
+		n = max(n, len(s.seq))
+	}
+	return n
+}()
+
+// Cancel sequences written to the PTY when a transfer cannot go ahead. Five
+// or more CANs abort ZMODEM; the backspaces erase them from a line editor if
+// the peer already gave up. trz and tsz stop on Ctrl-C.
+var (
+	zmodemAbort = []byte("\x18\x18\x18\x18\x18\x18\x18\x18\b\b\b\b\b\b\b\b")
+	trzszAbort  = []byte("\x03")
+)
+
+// transferDetector finds transfer start sequences in a byte stream, including
+// sequences split across reads.
+type transferDetector struct {
+	tail []byte // end of the previous chunk, shorter than maxStartLen
+}
+
+// scan looks for a start sequence in p. It returns the transfer and the
+// offset in p at which the transfer's bytes begin, or nil if p holds none.
+// An offset of 0 may mean the sequence began in an earlier chunk.
+func (d *transferDetector) scan(p []byte) (int, *Transfer) {
+	buf := append(d.tail, p...)
+	first, found := -1, Transfer{}
+	for _, s := range transferStarts {
+		if i := bytes.Index(buf, s.seq); i >= 0 && (first < 0 || i < first) {
+			first, found = i, s.t
+		}
+	}
+	if first >= 0 {
+		d.tail = d.tail[:0]
+		return max(first-(len(buf)-len(p)), 0), &found
+	}
+	if keep := maxStartLen - 1; len(buf) > keep {
+		buf = buf[len(buf)-keep:]
+	}
+	d.tail = append(d.tail[:0], buf...)
+	return 0, nil
+}
+
+// reset forgets any partial sequence.
+func (d *transferDetector) reset() {
+	d.tail = d.tail[:0]
+}
+
+// Output is a chunk of PTY output delivered to the connected client.
+type Output struct {
+	Data []byte
+	// Transfer is set while the chunk belongs to an in-band file transfer
+	// rather than the terminal; it is the same pointer for every chunk of
+	// one transfer.
+	Transfer *Transfer
+	// Prompt asks the client whether to trust a host key; Data is empty.
+	Prompt *HostKeyPrompt
+	// Notification is raised by the session; Data is empty.
+	Notification *Notification
+	// Highlight asks the client to mark text already sent; Data is empty.
+	Highlight *Highlight
+}
+
+// handleOutput records a chunk read from the PTY and forwards it to the
+// client. Once a transfer start sequence is seen, the rest of the stream is
+// forwarded as transfer data, kept out of the scrollback, until the client
+// ends the transfer. Without a client to take it, the transfer is cancelled.
+func (s *Session) handleOutput(data []byte) {
+	s.LastActive = time.Now()
+	s.notifyActivity()
+
+	// Transfer data is handed over after outMu is released: the client may
+	// need the session, for example to answer or end the transfer, before it
+	// can take the chunk.
+	if ch, out := s.routeOutput(data); ch != nil {
+		s.sendTransfer(ch, out)
+	}
+}
+
+// routeOutput records and sends terminal output in data. During a transfer
+// it returns the transfer chunk instead, and the client channel to send it
+// to.
+func (s *Session) routeOutput(data []byte) (chan Output, Output) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	if s.transfer == nil {
+		at, t := s.detector.scan(data)
+		if t == nil {
+			s.writeScrollback(data)
+			s.send(Output{Data: data})
+			return nil, Output{}
+		}
+		if at > 0 {
+			s.writeScrollback(data[:at])
+			s.send(Output{Data: data[:at]})
+		}
+		data = data[at:]
+		if s.outChan == nil {
+			log.Printf("session %s: cancelling %s %s, no client connected", s.ID, t.Protocol, t.Direction)
+			s.abortTransfer(t)
+			return nil, Output{}
+		}
+		s.transfer = t
+	}
+	return s.outChan, Output{Data: data, Transfer: s.transfer}
+}
+
+// writeScrollback records terminal output, following any shell integration
+// sequences and raising any notifications in it. outMu must be held.
+func (s *Session) writeScrollback(data []byte) {
+	_, end := s.scrollback.Offsets()
+	raised := s.tracker.feed(data, end)
+	s.scrollback.Write(data)
+	s.tapLines(data)
+	for _, n := range raised {
+		s.raise(n)
+	}
+}
+
+// send delivers out to the client without blocking. outMu must be held.
+func (s *Session) send(out Output) {
+	if s.outChan == nil {
+		return
+	}
+	select {
+	case s.outChan <- out:
+	default:
+	}
+}
+
+// sendTransfer delivers a chunk of transfer data to client channel ch,
+// waiting for room rather than dropping it: a transfer cannot survive a gap.
+// While it waits the session's output is not read, which holds back the
+// sender. It gives up if ch is no longer the client. outMu must not be held.
+func (s *Session) sendTransfer(ch chan Output, out Output) {
+	s.stopMu.Lock()
+	stop := s.outStop
+	if s.outChan != ch || stop == nil {
+		s.stopMu.Unlock()
+		return
+	}
+	sent := make(chan struct{})
+	s.sending, s.sent = ch, sent
+	s.stopMu.Unlock()
+	defer func() {
+		s.stopMu.Lock()
+		s.sending, s.sent = nil, nil
+		s.stopMu.Unlock()
+		close(sent)
+	}()
+	select {
+	case ch <- out:
+	case <-stop:
+	}
+}
+
+// Transfer returns the transfer in progress, or nil.
+func (s *Session) Transfer() *Transfer {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	return s.transfer
+}
+
+// EndTransfer returns the session to terminal output after the client has
+// finished a transfer.
+func (s *Session) EndTransfer() {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.transfer = nil
+	s.detector.reset()
+}
+
+// CancelTransfer aborts the transfer in progress, telling the program at the
+// other end to stop.
+func (s *Session) CancelTransfer() {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.cancelTransferLocked()
+}
+
+// cancelTransferLocked is CancelTransfer with outMu held.
+func (s *Session) cancelTransferLocked() {
+	if s.transfer == nil {
+		return
+	}
+	s.abortTransfer(s.transfer)
+	s.transfer = nil
+	s.detector.reset()
+}
+
+// abortTransfer writes t's cancel sequence to the PTY. The write runs in the
+// background since it may block while the caller holds outMu.
+func (s *Session) abortTransfer(t *Transfer) {
+	seq := zmodemAbort
+	if t.Protocol == ProtocolTrzsz {
+		seq = trzszAbort
+	}
+	go func() {
+		if _, err := s.WriteToPTY(seq); err != nil {
+			log.Printf("session %s: cancel %s transfer: %v", s.ID, t.Protocol, err)
+		}
+	}()
+}
@@ -0,0 +1,197 @@
+package session
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+// Byte fixtures captured from lrzsz and trzsz.
+var (
+	rzStart  = []byte("rz waiting to receive.**\x18B0100000023be50\r\x8a\x11")
+	szStart  = []byte("**\x18B00000000000000\r\x8a\x11")
+	tszStart = []byte("\x1b7\x07::TRZSZ:TRANSFER:S:1.1.6:0000000000000000\r\n")
+)
+
+func TestDetectorFindsStartSequences(t *testing.T) {
+	cases := []struct {
+		name  string
+		chunk []byte
+		want  Transfer
+		at    int
+	}{
+		{"rz", rzStart, Transfer{ProtocolZmodem, DirectionUpload}, len("rz waiting to receive.")},
+		{"sz", szStart, Transfer{ProtocolZmodem, DirectionDownload}, 0},
+		{"tsz", tszStart, Transfer{ProtocolTrzsz, DirectionDownload}, 3},
+	}
+	for _, c := range cases {
+		var d transferDetector
+		at, got := d.scan(c.chunk)
+		if got == nil || *got != c.want || at != c.at {
+			t.Errorf("%s: got %v at %d, want %v at %d", c.name, got, at, c.want, c.at)
+		}
+	}
+
+	var d transferDetector
+	if _, got := d.scan([]byte("plain output with ** stars\r\n")); got != nil {
+		t.Fatalf("unexpected transfer in plain output: %v", got)
+	}
+}
+
+func TestDetectorSplitAcrossReads(t *testing.T) {
+	var d transferDetector
+	// The sequence arrives one byte at a time.
+	for i, b := range szStart {
+		at, got := d.scan([]byte{b})
+		if i < 5 && got != nil {
+			t.Fatalf("detected too early at byte %d", i)
+		}
+		if i == 5 {
+			if got == nil || got.Direction != DirectionDownload || at != 0 {
+				t.Fatalf("expected download at byte 5, got %v at %d", got, at)
+			}
+			return
+		}
+	}
+	t.Fatal("sequence not detected")
+}
+
+func newTransferTestSession(t *testing.T) (*Session, *os.File) {
+	t.Helper()
+	b := &mockBackend{}
+	if err := b.Start(Spec{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close(); b.Wait() })
+	return &Session{
+		ID:         "t",
+		shell:      b,
+		scrollback: newScrollbackBuf(),
+		activity:   make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}, b.r
+}
+
+func TestHandleOutputSwitchesToTransfer(t *testing.T) {
+	s, _ := newTransferTestSession(t)
+	ch := make(chan Output, 8)
+	s.SetClient(ch)
+
+	s.handleOutput(append([]byte("$ "), rzStart...))
+	s.handleOutput([]byte("\x18B0900000000a87c\r\x8a\x11"))
+
+	out := <-ch
+	if out.Transfer != nil || string(out.Data) != "$ rz waiting to receive." {
+		t.Fatalf("expected terminal output before the transfer, got %+v", out)
+	}
+	first, second := <-ch, <-ch
+	if first.Transfer == nil || first.Transfer != second.Transfer || first.Transfer.Direction != DirectionUpload {
+		t.Fatalf("expected both chunks to belong to one upload, got %+v %+v", first, second)
+	}
+	if bytes.Contains(s.ScrollbackSnapshot(), []byte("**\x18B01")) {
+		t.Fatal("transfer bytes leaked into the scrollback")
+	}
+
+	s.EndTransfer()
+	s.handleOutput([]byte("done\r\n"))
+	if out := <-ch; out.Transfer != nil || string(out.Data) != "done\r\n" {
+		t.Fatalf("expected terminal output after EndTransfer, got %+v", out)
+	}
+}
+
+func TestTransferCancelledWithoutClient(t *testing.T) {
+	s, r := newTransferTestSession(t)
+	s.handleOutput(szStart)
+	if s.Transfer() != nil {
+		t.Fatal("transfer must not start without a client")
+	}
+
+	r.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 64)
+	n, err := r.Read(buf)
+	if err != nil || !bytes.Equal(buf[:n], zmodemAbort) {
+		t.Fatalf("expected the ZMODEM abort sequence, got %q %v", buf[:n], err)
+	}
+}
+
+func TestClearClientCancelsTransfer(t *testing.T) {
+	s, r := newTransferTestSession(t)
+	ch := make(chan Output, 8)
+	s.SetClient(ch)
+	s.handleOutput(tszStart)
+	if s.Transfer() == nil {
+		t.Fatal("expected a transfer in progress")
+	}
+
+	s.ClearClient(ch)
+	if s.Transfer() != nil {
+		t.Fatal("transfer should end with its client")
+	}
+	r.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 8)
+	if n, _ := r.Read(buf); !bytes.Equal(buf[:n], trzszAbort) {
+		t.Fatalf("expected the trzsz abort, got %q", buf[:n])
+	}
+}
+
+func TestTransferDataWaitsForClient(t *testing.T) {
+	s, _ := newTransferTestSession(t)
+	ch := make(chan Output, 1)
+	s.SetClient(ch)
+	s.handleOutput(szStart)
+
+	// The channel is full: the next chunk must wait rather than be dropped.
+	sent := make(chan struct{})
+	go func() {
+		s.handleOutput([]byte("chunk"))
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("transfer chunk sent to a full channel")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// Meanwhile the client can still reach the session, for example to
+	// answer with transfer data before it takes the chunk.
+	current := make(chan *Transfer)
+	go func() { current <- s.Transfer() }()
+	select {
+	case tr := <-current:
+		if tr == nil {
+			t.Fatal("expected the transfer to be in progress")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("session locked while a transfer chunk waits")
+	}
+	if out := <-ch; !bytes.Equal(out.Data, szStart) {
+		t.Fatalf("unexpected first chunk %+v", out)
+	}
+	if out := <-ch; string(out.Data) != "chunk" || out.Transfer == nil {
+		t.Fatalf("unexpected second chunk %+v", out)
+	}
+	<-sent
+}
+
+func TestClearClientReleasesWaitingTransferData(t *testing.T) {
+	s, _ := newTransferTestSession(t)
+	ch := make(chan Output, 1)
+	s.SetClient(ch)
+	s.handleOutput(szStart)
+
+	sent := make(chan struct{})
+	go func() {
+		s.handleOutput([]byte("chunk"))
+		close(sent)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	s.ClearClient(ch)
+	select {
+	case <-sent:
+	case <-time.After(2 * time.Second):
+		t.Fatal("transfer chunk still waiting after ClearClient")
+	}
+	if s.Transfer() != nil {
+		t.Fatal("transfer should end with its client")
+	}
+}
@@ -0,0 +1,311 @@
+package sshkeys
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh"
+
+	"web-terminal/internal/atomicfile"
+)
+
+// Key types accepted by Generate.
+const (
+	TypeEd25519 = "ed25519"
+	TypeECDSA   = "ecdsa"
+	TypeRSA     = "rsa"
+)
+
+// MaxNameLen bounds a key's display name.
+const MaxNameLen = 100
+
+var (
+	ErrLocked        = errors.New("key store is locked: SSH_KEY_PASSPHRASE is not set")
+	ErrBadPassphrase = errors.New("key store passphrase does not match")
+	ErrNotFound      = errors.New("key not found")
+	ErrInvalidKey    = errors.New("invalid key")
+	ErrDuplicate     = errors.New("key already stored")
+)
+
+// Key describes a stored private key. The private part never leaves the
+// store except to sign in for a session.
+type Key struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`        // e.g. "ssh-ed25519"
+	Fingerprint string    `json:"fingerprint"` // SHA256:…
+	PublicKey   string    `json:"publicKey"`   // authorized_keys line
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type storedKey struct {
+	Key
+	Sealed []byte `json:"sealed"` // nonce || AES-256-GCM(OpenSSH PEM)
+}
+
+// keyFile is the on-disk format. Check is a known value sealed with the
+// passphrase's key, so a wrong passphrase is noticed at startup.
+type keyFile struct {
+	Salt  []byte      `json:"salt"`
+	Check []byte      `json:"check"`
+	Keys  []storedKey `json:"keys"`
+}
+
+const checkValue = "web-terminal ssh keys"
+
+// KeyStore holds private keys encrypted at rest with a key derived from a
+// passphrase. Without a passphrase the store is locked: keys can be listed
+// and deleted but not added or used.
+type KeyStore struct {
+	mu       sync.RWMutex
+	filePath string
+	aead     cipher.AEAD // nil when locked
+	file     keyFile
+}
+
+// NewKeyStore loads the store at filePath and unlocks it with passphrase.
+// An empty passphrase leaves it locked; a wrong one is ErrBadPassphrase.
+func NewKeyStore(filePath, passphrase string) (*KeyStore, error) {
+	ks := &KeyStore{filePath: filePath, file: keyFile{Keys: []storedKey{}}}
+	data, err := os.ReadFile(filePath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, &ks.file); err != nil {
+			return nil, err
+		}
+		if ks.file.Keys == nil {
+			ks.file.Keys = []storedKey{}
+		}
+	}
+	if passphrase == "" {
+		return ks, nil
+	}
+
+	fresh := len(ks.file.Salt) == 0
+	if fresh {
+		ks.file.Salt = make([]byte, 16)
+		if _, err := rand.Read(ks.file.Salt); err != nil {
+			return nil, err
+		}
+	}
+	if ks.aead, err = deriveAEAD(passphrase, ks.file.Salt); err != nil {
+		return nil, err
+	}
+	if fresh {
+		if ks.file.Check, err = ks.seal([]byte(checkValue)); err != nil {
+			return nil, err
+		}
+		return ks, nil
+	}
+	if plain, err := ks.open(ks.file.Check); err != nil || string(plain) != checkValue {
+		return nil, ErrBadPassphrase
+	}
+	return ks, nil
+}
+
+// deriveAEAD stretches passphrase with scrypt into an AES-256-GCM key.
+func deriveAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (ks *KeyStore) seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, ks.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return ks.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func (ks *KeyStore) open(sealed []byte) ([]byte, error) {
+	n := ks.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("sealed data too short")
+	}
+	return ks.aead.Open(nil, sealed[:n], sealed[n:], nil)
+}
+
+// Locked reports whether the store has no passphrase.
+func (ks *KeyStore) Locked() bool {
+	return ks.aead == nil
+}
+
+// List returns the stored keys, oldest first.
+func (ks *KeyStore) List() []Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	list := make([]Key, len(ks.file.Keys))
+	for i, k := range ks.file.Keys {
+		list[i] = k.Key
+	}
+	return list
+}
+
+// Generate creates and stores a new key of the given type ("" means
+// ed25519).
+func (ks *KeyStore) Generate(name, keyType string) (Key, error) {
+	var priv crypto.PrivateKey
+	var err error
+	switch keyType {
+	case "", TypeEd25519:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	case TypeECDSA:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case TypeRSA:
+		priv, err = rsa.GenerateKey(rand.Reader, 4096)
+	default:
+		return Key{}, fmt.Errorf("%w: unknown type %q", ErrInvalidKey, keyType)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	return ks.add(name, priv)
+}
+
+// Import stores a PEM private key, decrypting it with passphrase if it is
+// encrypted.
+func (ks *KeyStore) Import(name, pemData, passphrase string) (Key, error) {
+	var priv any
+	var err error
+	if passphrase != "" {
+		priv, err = ssh.ParseRawPrivateKeyWithPassphrase([]byte(pemData), []byte(passphrase))
+	} else {
+		priv, err = ssh.ParseRawPrivateKey([]byte(pemData))
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	return ks.add(name, derefEd25519(priv))
+}
+
+// derefEd25519 turns the *ed25519.PrivateKey the ssh parser returns into the
+// value form the crypto packages expect.
+func derefEd25519(priv any) crypto.PrivateKey {
+	if p, ok := priv.(*ed25519.PrivateKey); ok {
+		return *p
+	}
+	return priv
+}
+
+// add seals priv and stores it under a new ID.
+func (ks *KeyStore) add(name string, priv crypto.PrivateKey) (Key, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > MaxNameLen {
+		return Key{}, fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidKey, MaxNameLen)
+	}
+	if ks.Locked() {
+		return Key{}, ErrLocked
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return Key{}, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, name)
+	if err != nil {
+		return Key{}, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	sealed, err := ks.seal(pem.EncodeToMemory(block))
+	if err != nil {
+		return Key{}, err
+	}
+	pub := signer.PublicKey()
+	k := storedKey{
+		Key: Key{
+			ID:          uuid.New().String(),
+			Name:        name,
+			Type:        pub.Type(),
+			Fingerprint: ssh.FingerprintSHA256(pub),
+			PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))) + " " + name,
+			CreatedAt:   time.Now().UTC(),
+		},
+		Sealed: sealed,
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if slices.ContainsFunc(ks.file.Keys, func(o storedKey) bool { return o.Fingerprint == k.Fingerprint }) {
+		return Key{}, ErrDuplicate
+	}
+	next := append(slices.Clone(ks.file.Keys), k)
+	if err := ks.write(next); err != nil {
+		return Key{}, err
+	}
+	ks.file.Keys = next
+	return k.Key, nil
+}
+
+// Delete removes a key.
+func (ks *KeyStore) Delete(id string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	i := slices.IndexFunc(ks.file.Keys, func(k storedKey) bool { return k.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	next := slices.Delete(slices.Clone(ks.file.Keys), i, i+1)
+	if err := ks.write(next); err != nil {
+		return err
+	}
+	ks.file.Keys = next
+	return nil
+}
+
+// PrivateKey decrypts and returns the key with the given ID.
+func (ks *KeyStore) PrivateKey(id string) (crypto.PrivateKey, error) {
+	if ks.Locked() {
+		return nil, ErrLocked
+	}
+	ks.mu.RLock()
+	i := slices.IndexFunc(ks.file.Keys, func(k storedKey) bool { return k.ID == id })
+	var sealed []byte
+	if i >= 0 {
+		sealed = ks.file.Keys[i].Sealed
+	}
+	ks.mu.RUnlock()
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	plain, err := ks.open(sealed)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := ssh.ParseRawPrivateKey(plain)
+	if err != nil {
+		return nil, err
+	}
+	return derefEd25519(priv), nil
+}
+
+// write persists keys. ks.mu must be held.
+func (ks *KeyStore) write(keys []storedKey) error {
+	f := ks.file
+	f.Keys = keys
+	return atomicfile.WriteJSON(ks.filePath, f)
+}
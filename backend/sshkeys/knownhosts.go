@@ -0,0 +1,150 @@
+// Package sshkeys manages the credentials of SSH sessions: the host keys the
+// server trusts and the private keys it authenticates with.
+package sshkeys
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"web-terminal/internal/atomicfile"
+)
+
+var ErrHostNotFound = errors.New("host not in known_hosts")
+
+// HostKey is one entry of the known_hosts file.
+type HostKey struct {
+	Hosts       []string `json:"hosts"`
+	Type        string   `json:"type"`
+	Fingerprint string   `json:"fingerprint"`
+}
+
+// KnownHosts is a known_hosts file in OpenSSH format that grows as hosts
+// are trusted on first use.
+type KnownHosts struct {
+	mu       sync.Mutex
+	filePath string
+}
+
+// NewKnownHosts returns the store kept in filePath. The file is created
+// when the first host is added.
+func NewKnownHosts(filePath string) *KnownHosts {
+	return &KnownHosts{filePath: filePath}
+}
+
+// Lookup checks key against the entries for hostname. It reports false with
+// a nil error for a host that has no entry yet, and an error if the host is
+// known with a different key.
+func (k *KnownHosts) Lookup(hostname string, remote net.Addr, key ssh.PublicKey) (bool, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, err := os.Stat(k.filePath); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	check, err := knownhosts.New(k.filePath)
+	if err != nil {
+		return false, err
+	}
+	err = check(hostname, remote, key)
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Add trusts key for hostname.
+func (k *KnownHosts) Add(hostname string, key ssh.PublicKey) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	data, err := os.ReadFile(k.filePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	data = append(data, line+"\n"...)
+	return atomicfile.Write(k.filePath, data, 0600)
+}
+
+// List returns the trusted host keys in file order. Hashed host names are
+// listed as they appear in the file.
+func (k *KnownHosts) List() ([]HostKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	list := []HostKey{}
+	err := k.scan(func(line []byte, hosts []string, key ssh.PublicKey) {
+		if key == nil {
+			return
+		}
+		list = append(list, HostKey{Hosts: hosts, Type: key.Type(), Fingerprint: ssh.FingerprintSHA256(key)})
+	})
+	return list, err
+}
+
+// Remove forgets every key of host, which may be given as "host" or
+// "host:port".
+func (k *KnownHosts) Remove(host string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	want := knownhosts.Normalize(host)
+	var kept bytes.Buffer
+	removed := false
+	err := k.scan(func(line []byte, hosts []string, key ssh.PublicKey) {
+		if key != nil && slices.Contains(hosts, want) {
+			removed = true
+			return
+		}
+		kept.Write(line)
+		kept.WriteByte('\n')
+	})
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrHostNotFound
+	}
+	return atomicfile.Write(k.filePath, kept.Bytes(), 0600)
+}
+
+// scan calls fn for every line of the file. For anything but a plain host
+// key entry, such as comments and @revoked lines, key is nil.
+func (k *KnownHosts) scan(fn func(line []byte, hosts []string, key ssh.PublicKey)) error {
+	f, err := os.Open(k.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if trimmed := strings.TrimSpace(string(line)); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			fn(line, nil, nil)
+			continue
+		}
+		marker, hosts, key, _, _, err := ssh.ParseKnownHosts(line)
+		if err != nil || marker != "" {
+			fn(line, nil, nil)
+			continue
+		}
+		fn(line, hosts, key)
+	}
+	return sc.Err()
+}
@@ -0,0 +1,169 @@
+package sshkeys_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"web-terminal/sshkeys"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKnownHosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	kh := sshkeys.NewKnownHosts(path)
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 22}
+	key, other := newHostKey(t), newHostKey(t)
+
+	if ok, err := kh.Lookup("example.com:22", remote, key); ok || err != nil {
+		t.Fatalf("Lookup before any entry = %v, %v", ok, err)
+	}
+	if err := kh.Add("example.com:22", key); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := kh.Add("other.example:2222", other); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if ok, err := kh.Lookup("example.com:22", remote, key); !ok || err != nil {
+		t.Fatalf("Lookup of a trusted key = %v, %v", ok, err)
+	}
+	if ok, err := kh.Lookup("new.example:22", remote, key); ok || err != nil {
+		t.Fatalf("Lookup of an unknown host = %v, %v", ok, err)
+	}
+	if _, err := kh.Lookup("example.com:22", remote, other); err == nil {
+		t.Fatal("expected an error for a changed key")
+	}
+
+	list, err := kh.List()
+	if err != nil || len(list) != 2 {
+		t.Fatalf("List = %v, %v", list, err)
+	}
+	if list[0].Hosts[0] != "example.com" || list[0].Fingerprint != ssh.FingerprintSHA256(key) {
+		t.Fatalf("unexpected entry %+v", list[0])
+	}
+	if list[1].Hosts[0] != "[other.example]:2222" {
+		t.Fatalf("non-default port not bracketed: %+v", list[1])
+	}
+
+	// Comments survive removal.
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, append([]byte("# managed by web-terminal\n"), data...), 0600)
+	if err := kh.Remove("example.com"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := kh.Remove("example.com"); !errors.Is(err, sshkeys.ErrHostNotFound) {
+		t.Fatalf("expected ErrHostNotFound, got %v", err)
+	}
+	if ok, err := kh.Lookup("example.com:22", remote, key); ok || err != nil {
+		t.Fatalf("Lookup after Remove = %v, %v", ok, err)
+	}
+	data, _ = os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "# managed by web-terminal\n") || !strings.Contains(string(data), "[other.example]:2222") {
+		t.Fatalf("unexpected file after Remove:\n%s", data)
+	}
+}
+
+func TestKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	ks, err := sshkeys.NewKeyStore(path, "correct horse")
+	if err != nil {
+		t.Fatalf("NewKeyStore: %v", err)
+	}
+
+	gen, err := ks.Generate("laptop", "")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if gen.Type != ssh.KeyAlgoED25519 || !strings.HasPrefix(gen.Fingerprint, "SHA256:") || !strings.HasSuffix(gen.PublicKey, " laptop") {
+		t.Fatalf("unexpected key %+v", gen)
+	}
+	if _, err := ks.Generate("x", "dsa"); !errors.Is(err, sshkeys.ErrInvalidKey) {
+		t.Fatalf("expected ErrInvalidKey for an unknown type, got %v", err)
+	}
+	if _, err := ks.Generate(" ", ""); !errors.Is(err, sshkeys.ErrInvalidKey) {
+		t.Fatalf("expected ErrInvalidKey for an empty name, got %v", err)
+	}
+
+	// Import an encrypted OpenSSH key.
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte("pw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemData := string(pem.EncodeToMemory(block))
+	if _, err := ks.Import("imported", pemData, "wrong"); !errors.Is(err, sshkeys.ErrInvalidKey) {
+		t.Fatalf("expected ErrInvalidKey for a wrong passphrase, got %v", err)
+	}
+	imp, err := ks.Import("imported", pemData, "pw")
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if _, err := ks.Import("again", pemData, "pw"); !errors.Is(err, sshkeys.ErrDuplicate) {
+		t.Fatalf("expected ErrDuplicate, got %v", err)
+	}
+
+	// Private keys are not stored in the clear.
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "PRIVATE KEY") {
+		t.Fatal("private key stored unencrypted")
+	}
+
+	reopened, err := sshkeys.NewKeyStore(path, "correct horse")
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	got, err := reopened.PrivateKey(imp.ID)
+	if err != nil {
+		t.Fatalf("PrivateKey: %v", err)
+	}
+	if !priv.Equal(got) {
+		t.Fatal("imported key changed")
+	}
+	if len(reopened.List()) != 2 {
+		t.Fatalf("expected 2 keys, got %v", reopened.List())
+	}
+
+	if _, err := sshkeys.NewKeyStore(path, "wrong"); !errors.Is(err, sshkeys.ErrBadPassphrase) {
+		t.Fatalf("expected ErrBadPassphrase, got %v", err)
+	}
+	locked, err := sshkeys.NewKeyStore(path, "")
+	if err != nil {
+		t.Fatalf("open locked: %v", err)
+	}
+	if !locked.Locked() || len(locked.List()) != 2 {
+		t.Fatal("locked store should still list keys")
+	}
+	if _, err := locked.PrivateKey(gen.ID); !errors.Is(err, sshkeys.ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if _, err := locked.Generate("new", ""); !errors.Is(err, sshkeys.ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	if err := reopened.Delete(gen.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := reopened.PrivateKey(gen.ID); !errors.Is(err, sshkeys.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
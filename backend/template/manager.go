@@ -0,0 +1,75 @@
+package template
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"web-terminal/internal/atomicfile"
+)
+
+// Manager handles loading, saving, and looking up session templates.
+type Manager struct {
+	mu       sync.RWMutex
+	filePath string
+	store    TemplateStore
+}
+
+// NewManager loads the template store from filePath, or creates an empty store
+// if the file does not exist. Returns an error only on unexpected I/O failures.
+func NewManager(filePath string) (*Manager, error) {
+	m := &Manager{filePath: filePath, store: TemplateStore{Templates: []Template{}}}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return m, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &m.store); err != nil {
+		return nil, err
+	}
+	if m.store.Templates == nil {
+		m.store.Templates = []Template{}
+	}
+	return m, nil
+}
+
+// Get returns a snapshot of the current store.
+func (m *Manager) Get() TemplateStore {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	templates := make([]Template, len(m.store.Templates))
+	copy(templates, m.store.Templates)
+	return TemplateStore{Templates: templates}
+}
+
+// Find returns the template with the given id, or ErrNotFound.
+func (m *Manager) Find(id string) (Template, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, t := range m.store.Templates {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+	return Template{}, ErrNotFound
+}
+
+// Save atomically writes store to disk, then updates in-memory state.
+func (m *Manager) Save(store TemplateStore) error {
+	if store.Templates == nil {
+		store.Templates = []Template{}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := atomicfile.WriteJSON(m.filePath, store); err != nil {
+		return err
+	}
+	m.store = store
+	return nil
+}
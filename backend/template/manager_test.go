@@ -0,0 +1,77 @@
+package template_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"web-terminal/template"
+)
+
+func TestNewManagerMissingFile(t *testing.T) {
+	tm, err := template.NewManager(t.TempDir() + "/nonexistent.json")
+	if err != nil {
+		t.Fatalf("expected no error for missing file, got %v", err)
+	}
+	if got := tm.Get(); len(got.Templates) != 0 {
+		t.Fatalf("expected empty templates, got %d", len(got.Templates))
+	}
+}
+
+func TestSaveReloadAndFind(t *testing.T) {
+	path := t.TempDir() + "/templates.json"
+	tm, _ := template.NewManager(path)
+
+	store := template.TemplateStore{
+		Templates: []template.Template{{
+			ID:       "api-dev",
+			Name:     "api-dev",
+			Cwd:      "~/src/api",
+			Env:      map[string]string{"APP_ENV": "dev"},
+			Commands: []string{"source .venv/bin/activate", "tail -f logs/app.log"},
+		}},
+	}
+	if err := tm.Save(store); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	tm2, err := template.NewManager(path)
+	if err != nil {
+		t.Fatalf("NewManager reload: %v", err)
+	}
+	got, err := tm2.Find("api-dev")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	spec := got.Spec()
+	if spec.Cwd != "~/src/api" || spec.Env["APP_ENV"] != "dev" || len(spec.Commands) != 2 {
+		t.Fatalf("unexpected spec after reload: %+v", spec)
+	}
+}
+
+func TestFindNotFound(t *testing.T) {
+	tm, _ := template.NewManager(t.TempDir() + "/templates.json")
+	if _, err := tm.Find("missing"); !errors.Is(err, template.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestFormatSessionName(t *testing.T) {
+	now := time.Date(2026, 3, 4, 9, 5, 0, 0, time.UTC)
+	cases := []struct {
+		tpl  template.Template
+		want string
+	}{
+		{template.Template{Name: "api-dev"}, "api-dev"},
+		{template.Template{Name: "api", SessionName: "{name}-{n}"}, "api-2"},
+		{template.Template{Name: "logs", SessionName: "{name}-{date}-{time}"}, "logs-20260304-0905"},
+	}
+	for _, c := range cases {
+		if got := c.tpl.FormatSessionName(2, now); got != c.want {
+			t.Errorf("FormatSessionName(%q) = %q, want %q", c.tpl.SessionName, got, c.want)
+		}
+	}
+	if !(template.Template{SessionName: "x-{n}"}).Numbered() {
+		t.Error("expected {n} pattern to be numbered")
+	}
+}
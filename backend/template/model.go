@@ -0,0 +1,63 @@
+package template
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"web-terminal/session"
+)
+
+// Template describes how to start a preconfigured session.
+type Template struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// SessionName is the pattern used to name sessions started from this
+	// template. Supported placeholders: {name}, {n}, {date}, {time}.
+	SessionName string            `json:"sessionName,omitempty"`
+	Shell       string            `json:"shell,omitempty"`
+	Args        []string          `json:"args,omitempty"`
+	Cwd         string            `json:"cwd,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	Commands    []string          `json:"commands,omitempty"` // typed once the prompt appears
+}
+
+// TemplateStore is the full persistent state.
+type TemplateStore struct {
+	Templates []Template `json:"templates"`
+}
+
+var ErrNotFound = errors.New("template not found")
+
+// Spec returns the session spec that starts this template's shell.
+func (t Template) Spec() session.Spec {
+	return session.Spec{
+		Shell:    t.Shell,
+		Args:     t.Args,
+		Cwd:      t.Cwd,
+		Env:      t.Env,
+		Commands: t.Commands,
+	}
+}
+
+// Numbered reports whether the session name pattern contains the {n} counter,
+// meaning successive sessions from this template get distinct names.
+func (t Template) Numbered() bool {
+	return strings.Contains(t.SessionName, "{n}")
+}
+
+// FormatSessionName expands the session name pattern for the n-th instance
+// started at now. An empty pattern falls back to the template name.
+func (t Template) FormatSessionName(n int, now time.Time) string {
+	pattern := t.SessionName
+	if pattern == "" {
+		pattern = "{name}"
+	}
+	return strings.NewReplacer(
+		"{name}", t.Name,
+		"{n}", strconv.Itoa(n),
+		"{date}", now.Format("20060102"),
+		"{time}", now.Format("1504"),
+	).Replace(pattern)
+}
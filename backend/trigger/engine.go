@@ -0,0 +1,117 @@
+package trigger
+
+import (
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"web-terminal/session"
+)
+
+// minInterval is the shortest time between two firings of a rule in one
+// session, except for highlights. It stops a reply that the shell echoes
+// back, or a flood of matching lines, from firing the rule in a loop.
+const minInterval = time.Second
+
+// sessionState remembers when rules last fired in a session.
+type sessionState struct {
+	line map[string]int64     // rule ID → number of the line it last fired on
+	last map[string]time.Time // rule ID → when it last fired
+}
+
+// evaluate runs the rules in scope for s against l. It is called in order
+// for each session's lines, from a goroutine of the session's own.
+func (m *Manager) evaluate(s *session.Session, l session.Line) {
+	m.mu.RLock()
+	rules, hooks := m.rules, m.onMatch
+	m.mu.RUnlock()
+
+	for _, r := range rules {
+		if r.Disabled || !r.applies(s) {
+			continue
+		}
+		loc := r.re.FindStringIndex(l.Text)
+		if loc == nil || !m.fire(s.ID, r.Rule, l.N) {
+			continue
+		}
+		match := Match{
+			Rule:        r.ID,
+			RuleName:    r.Name,
+			Action:      r.Action,
+			SessionID:   s.ID,
+			SessionName: s.Name,
+			Line:        l.Text,
+			Text:        l.Text[loc[0]:loc[1]],
+			Time:        time.Now(),
+		}
+		m.act(s, r.Rule, match)
+		for _, fn := range hooks {
+			fn(match)
+		}
+	}
+}
+
+// applies reports whether r's scope includes s.
+func (r compiled) applies(s *session.Session) bool {
+	switch {
+	case r.Scope.Session != "":
+		return r.Scope.Session == s.ID
+	case r.Scope.Tag != "":
+		return slices.ContainsFunc(s.Tags, func(t string) bool { return strings.EqualFold(t, r.Scope.Tag) })
+	}
+	return true
+}
+
+// fire records that r matched line n of session id and reports whether it
+// should act: a rule acts once per line, even as a partial line grows, and
+// at most once per minInterval unless it only highlights.
+func (m *Manager) fire(id string, r Rule, n int64) bool {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	st := m.state[id]
+	if st == nil {
+		st = &sessionState{line: make(map[string]int64), last: make(map[string]time.Time)}
+		m.state[id] = st
+	}
+	if st.line[r.ID] == n {
+		return false
+	}
+	now := time.Now()
+	if r.Action != ActionHighlight && now.Sub(st.last[r.ID]) < minInterval {
+		return false
+	}
+	st.line[r.ID], st.last[r.ID] = n, now
+	return true
+}
+
+// forget drops the state of a session that has ended.
+func (m *Manager) forget(s *session.Session) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	delete(m.state, s.ID)
+}
+
+// act performs r's action for match. Actions that may block run in the
+// background.
+func (m *Manager) act(s *session.Session, r Rule, match Match) {
+	switch r.Action {
+	case ActionHighlight:
+		s.Highlight(session.Highlight{Text: match.Text, Color: r.Color})
+	case ActionNotify:
+		s.Notify(session.Notification{Kind: session.NotifyTrigger, Title: r.Name, Body: match.Line})
+	case ActionReply:
+		go func() {
+			if _, err := s.WriteToPTY([]byte(r.Reply)); err != nil {
+				log.Printf("trigger %q: reply to session %s: %v", r.Name, s.ID, err)
+			}
+		}()
+	case ActionKill:
+		log.Printf("trigger %q: killing session %s", r.Name, s.ID)
+		go m.sessions.Kill(s.ID) //nolint:errcheck
+	case ActionWebhook:
+		if err := m.webhooks.Send(r.Webhook, EventMatched, match); err != nil {
+			log.Printf("trigger %q: webhook %s: %v", r.Name, r.Webhook, err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package trigger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"web-terminal/internal/atomicfile"
+	"web-terminal/session"
+	"web-terminal/webhook"
+)
+
+// Manager persists trigger rules and evaluates them against the output of a
+// session.Manager's sessions.
+type Manager struct {
+	mu       sync.RWMutex
+	filePath string
+	store    TriggerStore
+	rules    []compiled // store.Rules with their patterns; replaced, never modified
+	onChange []func(id string)
+	onMatch  []func(Match)
+
+	sessions *session.Manager
+	webhooks *webhook.Manager // for ActionWebhook
+	stateMu  sync.Mutex
+	state    map[string]*sessionState // by session ID
+}
+
+type compiled struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// NewManager loads the rule store from filePath, or starts empty if the file
+// does not exist, and starts watching the output of sessions created from
+// now on. Rules with ActionWebhook deliver through webhooks.
+func NewManager(filePath string, sessions *session.Manager, webhooks *webhook.Manager) (*Manager, error) {
+	m := &Manager{
+		filePath: filePath,
+		store:    TriggerStore{Rules: []Rule{}},
+		sessions: sessions,
+		webhooks: webhooks,
+		state:    make(map[string]*sessionState),
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &m.store); err != nil {
+			return nil, err
+		}
+		if err := rejectURLRules(data); err != nil {
+			return nil, err
+		}
+		if m.store.Rules == nil {
+			m.store.Rules = []Rule{}
+		}
+		if m.rules, err = compile(m.store.Rules); err != nil {
+			return nil, err
+		}
+	}
+	sessions.OnLine(m.evaluate)
+	sessions.OnExit(m.forget)
+	return m, nil
+}
+
+// OnChange registers fn to be called with the ID of every rule that is
+// created, updated or deleted. fn runs with the manager locked, so it must
+// not block or call back into m.
+func (m *Manager) OnChange(fn func(id string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = append(m.onChange, fn)
+}
+
+// OnMatch registers fn to be called with every match that fires a rule. fn
+// is called from the session's line watcher, so it must not block.
+func (m *Manager) OnMatch(fn func(Match)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onMatch = append(m.onMatch, fn)
+}
+
+// List returns every rule.
+func (m *Manager) List() []Rule {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.store.Rules)
+}
+
+// Get returns the rule with the given ID.
+func (m *Manager) Get(id string) (Rule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if i := m.indexOf(id); i >= 0 {
+		return m.store.Rules[i], nil
+	}
+	return Rule{}, ErrNotFound
+}
+
+// Create stores r under a new ID.
+func (m *Manager) Create(r Rule) (Rule, error) {
+	r.ID = uuid.New().String()
+	r.UpdatedAt = time.Now().UTC()
+	if err := m.validate(r); err != nil {
+		return Rule{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	next := append(slices.Clone(m.store.Rules), r)
+	if err := m.commit(next, r.ID); err != nil {
+		return Rule{}, err
+	}
+	return r, nil
+}
+
+// Update replaces rule id with r.
+func (m *Manager) Update(id string, r Rule) (Rule, error) {
+	r.ID = id
+	r.UpdatedAt = time.Now().UTC()
+	if err := m.validate(r); err != nil {
+		return Rule{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.indexOf(id)
+	if i < 0 {
+		return Rule{}, ErrNotFound
+	}
+	next := slices.Clone(m.store.Rules)
+	next[i] = r
+	if err := m.commit(next, id); err != nil {
+		return Rule{}, err
+	}
+	return r, nil
+}
+
+// Delete removes rule id.
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.indexOf(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	next := slices.Delete(slices.Clone(m.store.Rules), i, i+1)
+	return m.commit(next, id)
+}
+
+// validate checks r, including that the webhook it delivers to exists. A
+// webhook deleted later only stops the rule's deliveries.
+func (m *Manager) validate(r Rule) error {
+	if _, err := r.Validate(); err != nil {
+		return err
+	}
+	if r.Action == ActionWebhook {
+		if _, err := m.webhooks.Get(r.Webhook); err != nil {
+			return fmt.Errorf("%w: webhook %q not found", ErrInvalid, r.Webhook)
+		}
+	}
+	return nil
+}
+
+// compile validates rules and compiles their patterns.
+// rejectURLRules fails if data holds a webhook rule that posts to a URL of
+// its own, as rules did before they named a registered webhook. It can't be
+// converted as is: a webhook subscribed to trigger matches receives every
+// rule's, so the rule has to be pointed at a webhook by hand.
+func rejectURLRules(data []byte) error {
+	var legacy struct {
+		Rules []struct {
+			Name    string `json:"name"`
+			Webhook string `json:"webhook"`
+			URL     string `json:"url"`
+		} `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	for _, r := range legacy.Rules {
+		if r.URL != "" && r.Webhook == "" {
+			return fmt.Errorf("rule %q: %w: url is no longer supported; register %s as a webhook and set the rule's webhook to its ID",
+				r.Name, ErrInvalid, r.URL)
+		}
+	}
+	return nil
+}
+
+func compile(rules []Rule) ([]compiled, error) {
+	out := make([]compiled, len(rules))
+	for i, r := range rules {
+		re, err := r.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		out[i] = compiled{r, re}
+	}
+	return out, nil
+}
+
+// commit persists rules and notifies subscribers that id changed. m.mu must
+// be held.
+func (m *Manager) commit(rules []Rule, id string) error {
+	c, err := compile(rules)
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteJSON(m.filePath, TriggerStore{Rules: rules}); err != nil {
+		return err
+	}
+	m.store.Rules = rules
+	m.rules = c
+	for _, fn := range m.onChange {
+		fn(id)
+	}
+	return nil
+}
+
+// indexOf returns the position of rule id, or -1. m.mu must be held.
+func (m *Manager) indexOf(id string) int {
+	return slices.IndexFunc(m.store.Rules, func(r Rule) bool { return r.ID == id })
+}
@@ -0,0 +1,237 @@
+package trigger_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"web-terminal/session"
+	"web-terminal/trigger"
+	"web-terminal/webhook"
+)
+
+func newTestManager(t *testing.T) (*trigger.Manager, *session.Manager, string) {
+	t.Helper()
+	path := t.TempDir() + "/triggers.json"
+	sm := session.NewManagerWithBackend(session.BackendMock)
+	m, err := trigger.NewManager(path, sm, newTestWebhooks(t))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return m, sm, path
+}
+
+func newTestWebhooks(t *testing.T) *webhook.Manager {
+	t.Helper()
+	dir := t.TempDir()
+	wm, err := webhook.NewManager(dir+"/webhooks.json", dir+"/dead-letter.jsonl")
+	if err != nil {
+		t.Fatalf("webhook.NewManager: %v", err)
+	}
+	return wm
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRuleCRUD(t *testing.T) {
+	m, sm, path := newTestManager(t)
+
+	for _, bad := range []trigger.Rule{
+		{Name: "re", Pattern: "(", Action: trigger.ActionNotify},
+		{Name: "empty", Pattern: "x*", Action: trigger.ActionNotify},
+		{Name: "action", Pattern: "x", Action: "explode"},
+		{Name: "reply", Pattern: "x", Action: trigger.ActionReply},
+		{Name: "webhook", Pattern: "x", Action: trigger.ActionWebhook},
+		{Name: "unknown webhook", Pattern: "x", Action: trigger.ActionWebhook, Webhook: "nope"},
+		{Name: "scope", Pattern: "x", Action: trigger.ActionKill, Scope: trigger.Scope{Session: "s", Tag: "t"}},
+	} {
+		if _, err := m.Create(bad); !errors.Is(err, trigger.ErrInvalid) {
+			t.Errorf("rule %q: expected ErrInvalid, got %v", bad.Name, err)
+		}
+	}
+
+	r, err := m.Create(trigger.Rule{Name: "errors", Pattern: `(?i)error`, Action: trigger.ActionHighlight, Color: "#ff0000"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	r.Action = trigger.ActionNotify
+	if _, err := m.Update(r.ID, r); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, err := m.Update("missing", r); !errors.Is(err, trigger.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	reloaded, err := trigger.NewManager(path, sm, newTestWebhooks(t))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if got, err := reloaded.Get(r.ID); err != nil || got.Action != trigger.ActionNotify {
+		t.Fatalf("expected the updated rule after reload, got %+v, %v", got, err)
+	}
+	if err := m.Delete(r.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if len(m.List()) != 0 {
+		t.Fatalf("expected no rules, got %+v", m.List())
+	}
+}
+
+func TestReplyToPrompt(t *testing.T) {
+	m, sm, _ := newTestManager(t)
+	if _, err := m.Create(trigger.Rule{Name: "confirm", Pattern: `Continue\? \[y/N\] $`, Action: trigger.ActionReply, Reply: "yes\n"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	s, err := sm.Create("reply")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer sm.Kill(s.ID)
+
+	// The prompt has no newline yet; the mock shell echoes the reply.
+	s.WriteToPTY([]byte("\x1b[1mContinue? [y/N] \x1b[0m"))
+	waitFor(t, "the reply", func() bool {
+		return strings.HasSuffix(string(s.ScrollbackSnapshot()), "\x1b[0myes\n")
+	})
+	time.Sleep(50 * time.Millisecond)
+	if n := strings.Count(string(s.ScrollbackSnapshot()), "yes"); n != 1 {
+		t.Fatalf("expected one reply, got %d", n)
+	}
+}
+
+func TestScopeAndHighlight(t *testing.T) {
+	m, sm, _ := newTestManager(t)
+	matches := make(chan trigger.Match, 4)
+	m.OnMatch(func(match trigger.Match) { matches <- match })
+	if _, err := m.Create(trigger.Rule{Name: "prod errors", Pattern: `ERROR \w+`, Scope: trigger.Scope{Tag: "PROD"}, Action: trigger.ActionHighlight}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	prod, err := sm.CreateWithSpec("prod", session.Spec{Tags: []string{"prod"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer sm.Kill(prod.ID)
+	dev, err := sm.Create("dev")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer sm.Kill(dev.ID)
+	out := make(chan session.Output, 16)
+	prod.SetClient(out)
+	defer prod.ClearClient(out)
+
+	dev.WriteToPTY([]byte("ERROR disk\n"))
+	prod.WriteToPTY([]byte("12:00 ERROR disk full\n"))
+	select {
+	case match := <-matches:
+		if match.SessionID != prod.ID || match.Text != "ERROR disk" || match.Line != "12:00 ERROR disk full" {
+			t.Fatalf("unexpected match %+v", match)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no match")
+	}
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case o := <-out:
+			if o.Highlight == nil {
+				continue
+			}
+			if o.Highlight.Text != "ERROR disk" {
+				t.Fatalf("unexpected highlight %+v", o.Highlight)
+			}
+		case <-deadline:
+			t.Fatal("no highlight")
+		}
+		break
+	}
+	select {
+	case match := <-matches:
+		t.Fatalf("unexpected match %+v", match)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWebhookAndKill(t *testing.T) {
+	type delivery struct {
+		event string
+		match trigger.Match
+	}
+	posted := make(chan delivery, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p struct{ Data trigger.Match }
+		json.NewDecoder(r.Body).Decode(&p)
+		posted <- delivery{r.Header.Get(webhook.HeaderEvent), p.Data}
+	}))
+	defer srv.Close()
+
+	// The webhook's own subscription doesn't matter to the rule.
+	wm := newTestWebhooks(t)
+	hook, err := wm.Create(webhook.Webhook{Name: "alerts", URL: srv.URL, Events: []string{"session.created"}})
+	if err != nil {
+		t.Fatalf("webhook Create: %v", err)
+	}
+	sm := session.NewManagerWithBackend(session.BackendMock)
+	m, err := trigger.NewManager(t.TempDir()+"/triggers.json", sm, wm)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	s, err := sm.Create("doomed")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	for _, r := range []trigger.Rule{
+		{Name: "hook", Pattern: "panic:", Action: trigger.ActionWebhook, Webhook: hook.ID},
+		{Name: "stop", Pattern: "panic:", Scope: trigger.Scope{Session: s.ID}, Action: trigger.ActionKill},
+	} {
+		if _, err := m.Create(r); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	s.WriteToPTY([]byte("panic: oops\n"))
+	select {
+	case d := <-posted:
+		if d.event != trigger.EventMatched || d.match.RuleName != "hook" || d.match.SessionName != "doomed" {
+			t.Fatalf("unexpected webhook delivery %+v", d)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no webhook delivery")
+	}
+	select {
+	case <-s.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("session not killed")
+	}
+	if _, ok := sm.Get(s.ID); ok {
+		t.Fatal("killed session still listed")
+	}
+}
+
+func TestRejectsURLRules(t *testing.T) {
+	path := t.TempDir() + "/triggers.json"
+	old := `{"rules": [{"id": "r1", "name": "page me", "pattern": "panic:", "action": "webhook", "url": "https://hooks.example/page"}]}`
+	if err := os.WriteFile(path, []byte(old), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sm := session.NewManagerWithBackend(session.BackendMock)
+	_, err := trigger.NewManager(path, sm, newTestWebhooks(t))
+	if !errors.Is(err, trigger.ErrInvalid) || !strings.Contains(err.Error(), "https://hooks.example/page") {
+		t.Fatalf("expected ErrInvalid naming the URL, got %v", err)
+	}
+}
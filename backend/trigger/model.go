@@ -0,0 +1,118 @@
+// Package trigger stores rules that watch session output for a regular
+// expression and act on the session when a line matches.
+package trigger
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Actions a rule can take on a match.
+const (
+	ActionHighlight = "highlight" // mark the match in the session's terminal
+	ActionNotify    = "notify"    // raise a session notification
+	ActionWebhook   = "webhook"   // deliver the match to Webhook
+	ActionReply     = "reply"     // type Reply into the session
+	ActionKill      = "kill"      // end the session
+)
+
+// EventMatched is the webhook event ActionWebhook delivers, with the Match
+// as its data.
+const EventMatched = "trigger.matched"
+
+// Limits enforced by Validate.
+const (
+	MaxNameLen    = 100
+	MaxPatternLen = 1000
+	MaxReplyLen   = 4096
+)
+
+var (
+	ErrNotFound = errors.New("trigger not found")
+	ErrInvalid  = errors.New("invalid trigger")
+)
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Scope selects the sessions a rule applies to: one session, the sessions
+// carrying a tag, or every session when both are empty.
+type Scope struct {
+	Session string `json:"session,omitempty"` // session ID
+	Tag     string `json:"tag,omitempty"`     // compared case-insensitively
+}
+
+// Rule acts on sessions whose output matches Pattern. Patterns use RE2
+// syntax and are matched against single lines of output with escape
+// sequences removed.
+type Rule struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Pattern  string `json:"pattern"`
+	Scope    Scope  `json:"scope"`
+	Action   string `json:"action"`
+	Reply    string `json:"reply,omitempty"`   // input typed by ActionReply, sent as is
+	Webhook  string `json:"webhook,omitempty"` // ID of the webhook ActionWebhook delivers to
+	Color    string `json:"color,omitempty"`   // ActionHighlight color, as #rrggbb
+	Disabled bool   `json:"disabled,omitempty"`
+
+	UpdatedAt time.Time `json:"updatedAt,omitzero"`
+}
+
+// TriggerStore is the full persistent state.
+type TriggerStore struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Match describes a line that matched a rule, as passed to OnMatch hooks and
+// webhooks.
+type Match struct {
+	Rule        string    `json:"rule"` // rule ID
+	RuleName    string    `json:"ruleName"`
+	Action      string    `json:"action"`
+	SessionID   string    `json:"sessionId"`
+	SessionName string    `json:"sessionName"`
+	Line        string    `json:"line"`
+	Text        string    `json:"text"` // the part of Line the pattern matched
+	Time        time.Time `json:"time"`
+}
+
+// Validate checks the rule's fields and compiles its pattern. Patterns that
+// match an empty line are rejected, since they would fire on every line.
+func (r Rule) Validate() (*regexp.Regexp, error) {
+	if r.Name == "" || len(r.Name) > MaxNameLen {
+		return nil, fmt.Errorf("%w: name must be 1-%d characters", ErrInvalid, MaxNameLen)
+	}
+	if r.Pattern == "" || len(r.Pattern) > MaxPatternLen {
+		return nil, fmt.Errorf("%w: pattern must be 1-%d characters", ErrInvalid, MaxPatternLen)
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: pattern: %v", ErrInvalid, err)
+	}
+	if re.MatchString("") {
+		return nil, fmt.Errorf("%w: pattern matches an empty line", ErrInvalid)
+	}
+	if r.Scope.Session != "" && r.Scope.Tag != "" {
+		return nil, fmt.Errorf("%w: scope names both a session and a tag", ErrInvalid)
+	}
+	switch r.Action {
+	case ActionHighlight:
+		if r.Color != "" && !colorPattern.MatchString(r.Color) {
+			return nil, fmt.Errorf("%w: color must look like #rrggbb", ErrInvalid)
+		}
+	case ActionReply:
+		if r.Reply == "" || len(r.Reply) > MaxReplyLen {
+			return nil, fmt.Errorf("%w: reply must be 1-%d characters", ErrInvalid, MaxReplyLen)
+		}
+	case ActionWebhook:
+		if r.Webhook == "" {
+			return nil, fmt.Errorf("%w: webhook must name a webhook", ErrInvalid)
+		}
+	case ActionNotify, ActionKill:
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalid, r.Action)
+	}
+	return re, nil
+}
@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// deliveryTimeout bounds one delivery attempt.
+const deliveryTimeout = 10 * time.Second
+
+// Dispatch delivers event to every enabled webhook subscribed to it.
+// Deliveries run in the background.
+func (m *Manager) Dispatch(event string, data any) {
+	m.mu.RLock()
+	var targets []Webhook
+	for _, w := range m.store.Webhooks {
+		if w.wants(event) {
+			targets = append(targets, w)
+		}
+	}
+	m.mu.RUnlock()
+	for _, w := range targets {
+		m.deliver(w, event, data)
+	}
+}
+
+// Ping sends a ping event to webhook id, whatever events it subscribes to,
+// and returns the delivery to follow in Deliveries.
+func (m *Manager) Ping(id string) (Delivery, error) {
+	w, err := m.Get(id)
+	if err != nil {
+		return Delivery{}, err
+	}
+	return m.deliver(w, EventPing, map[string]string{"webhook": w.Name}), nil
+}
+
+// Send delivers event to webhook id, whatever events it subscribes to. A
+// disabled webhook gets nothing.
+func (m *Manager) Send(id, event string, data any) error {
+	w, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	if !w.Disabled {
+		m.deliver(w, event, data)
+	}
+	return nil
+}
+
+// deliver records a delivery of event to w and starts sending it.
+func (m *Manager) deliver(w Webhook, event string, data any) Delivery {
+	d := &Delivery{
+		ID:        uuid.New().String(),
+		Webhook:   w.ID,
+		Event:     event,
+		Status:    StatusPending,
+		CreatedAt: time.Now().UTC(),
+		Attempts:  []Attempt{},
+	}
+	body, err := json.Marshal(Payload{ID: d.ID, Event: event, Time: d.CreatedAt, Data: data})
+	if err != nil {
+		log.Printf("webhook %q: encode %s: %v", w.Name, event, err)
+		d.Status = StatusFailed
+		return *d
+	}
+
+	m.deliveriesMu.Lock()
+	recent := append(m.deliveries[w.ID], d)
+	if len(recent) > maxDeliveries {
+		recent = recent[len(recent)-maxDeliveries:]
+	}
+	m.deliveries[w.ID] = recent
+	snapshot := *d
+	m.deliveriesMu.Unlock()
+
+	m.mu.RLock()
+	policy := m.retry
+	m.mu.RUnlock()
+	go m.send(w, d, body, policy)
+	return snapshot
+}
+
+// send attempts d until it succeeds, fails permanently or runs out of
+// attempts, waiting longer before each retry.
+func (m *Manager) send(w Webhook, d *Delivery, body []byte, policy RetryPolicy) {
+	wait := policy.Backoff
+	for n := 1; ; n++ {
+		a, retry := m.attempt(w, d, body)
+		status := StatusPending
+		switch {
+		case a.Error == "" && a.StatusCode < 300:
+			status = StatusDelivered
+		case !retry || n >= policy.Attempts:
+			status = StatusFailed
+		}
+		m.record(d, a, status)
+		if status == StatusDelivered {
+			return
+		}
+		if status == StatusFailed {
+			m.writeDeadLetter(w, d, body)
+			return
+		}
+		time.Sleep(wait)
+		wait = min(wait*2, policy.MaxBackoff)
+	}
+}
+
+// attempt POSTs body once. It reports whether a failure is worth
+// retrying: network errors, 5xx, 408 and 429 are; other responses are not.
+func (m *Manager) attempt(w Webhook, d *Delivery, body []byte) (Attempt, bool) {
+	a := Attempt{Time: time.Now().UTC()}
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		a.Error = err.Error()
+		return a, false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "web-terminal-webhook")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderSignature, Sign(w.Secret, body))
+	resp, err := m.client.Do(req)
+	a.DurationMs = time.Since(a.Time).Milliseconds()
+	if err != nil {
+		a.Error = err.Error()
+		return a, true
+	}
+	resp.Body.Close()
+	a.StatusCode = resp.StatusCode
+	switch {
+	case resp.StatusCode < 300:
+		return a, false
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		a.Error = resp.Status
+		return a, true
+	}
+	a.Error = resp.Status
+	return a, false
+}
+
+// Sign returns the signature header value for body: "sha256=" and the hex
+// HMAC-SHA256 of body keyed with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// record adds an attempt to d and updates its status.
+func (m *Manager) record(d *Delivery, a Attempt, status string) {
+	m.deliveriesMu.Lock()
+	defer m.deliveriesMu.Unlock()
+	d.Attempts = append(d.Attempts, a)
+	d.Status = status
+}
+
+// deadLetter is a line of the dead-letter log: a delivery that failed for
+// good, with what it was trying to send.
+type deadLetter struct {
+	Delivery
+	URL     string          `json:"url"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// writeDeadLetter appends a failed delivery to the dead-letter log.
+func (m *Manager) writeDeadLetter(w Webhook, d *Delivery, body []byte) {
+	if m.deadLetter == "" {
+		return
+	}
+	m.deliveriesMu.Lock()
+	entry := deadLetter{Delivery: *d, URL: w.URL, Payload: body}
+	entry.Attempts = append([]Attempt(nil), d.Attempts...)
+	m.deliveriesMu.Unlock()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	m.deadMu.Lock()
+	defer m.deadMu.Unlock()
+	f, err := os.OpenFile(m.deadLetter, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err == nil {
+		_, err = f.Write(append(line, '\n'))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		log.Printf("webhook %q: dead-letter log: %v", w.Name, err)
+		return
+	}
+	log.Printf("webhook %q: gave up on %s delivery %s after %d attempts", w.Name, d.Event, d.ID, len(entry.Attempts))
+}
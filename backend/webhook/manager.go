@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"web-terminal/internal/atomicfile"
+)
+
+// maxDeliveries is how many recent deliveries are kept per webhook.
+const maxDeliveries = 100
+
+// RetryPolicy controls how often a failing delivery is attempted. The wait
+// before retry n (from 1) is Backoff << (n-1), at most MaxBackoff.
+type RetryPolicy struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy tries a delivery 6 times over about a minute.
+var DefaultRetryPolicy = RetryPolicy{Attempts: 6, Backoff: 2 * time.Second, MaxBackoff: time.Minute}
+
+// Manager persists webhooks and delivers events to them. Recent deliveries
+// are kept in memory; deliveries that fail for good are appended to a
+// dead-letter log.
+type Manager struct {
+	mu         sync.RWMutex
+	filePath   string
+	store      WebhookStore
+	deadLetter string // JSON lines; "" disables the log
+	retry      RetryPolicy
+	client     *http.Client
+
+	deliveriesMu sync.Mutex
+	deliveries   map[string][]*Delivery // by webhook ID, oldest first
+	deadMu       sync.Mutex
+}
+
+// NewManager loads the webhook store from filePath, or starts empty if the
+// file does not exist. Failed deliveries are appended to deadLetterPath.
+func NewManager(filePath, deadLetterPath string) (*Manager, error) {
+	m := &Manager{
+		filePath:   filePath,
+		store:      WebhookStore{Webhooks: []Webhook{}},
+		deadLetter: deadLetterPath,
+		retry:      DefaultRetryPolicy,
+		client:     &http.Client{Timeout: deliveryTimeout},
+		deliveries: make(map[string][]*Delivery),
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return m, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m.store); err != nil {
+		return nil, err
+	}
+	if m.store.Webhooks == nil {
+		m.store.Webhooks = []Webhook{}
+	}
+	return m, nil
+}
+
+// SetRetryPolicy changes how failing deliveries are retried.
+func (m *Manager) SetRetryPolicy(p RetryPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retry = p
+}
+
+// List returns every webhook.
+func (m *Manager) List() []Webhook {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.store.Webhooks)
+}
+
+// Get returns the webhook with the given ID.
+func (m *Manager) Get(id string) (Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if i := m.indexOf(id); i >= 0 {
+		return m.store.Webhooks[i], nil
+	}
+	return Webhook{}, ErrNotFound
+}
+
+// Create stores w under a new ID, generating a secret if it has none.
+func (m *Manager) Create(w Webhook) (Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.create(w)
+}
+
+// Ensure returns the webhook with w's URL, creating w as Create does if
+// there is none. An existing webhook is returned as it is, so changes made
+// to it since are kept.
+func (m *Manager) Ensure(w Webhook) (Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := slices.IndexFunc(m.store.Webhooks, func(e Webhook) bool { return e.URL == w.URL }); i >= 0 {
+		return m.store.Webhooks[i], nil
+	}
+	return m.create(w)
+}
+
+// Update replaces webhook id with w. An empty secret keeps the current one.
+func (m *Manager) Update(id string, w Webhook) (Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.indexOf(id)
+	if i < 0 {
+		return Webhook{}, ErrNotFound
+	}
+	w.ID = id
+	w.UpdatedAt = time.Now().UTC()
+	if w.Secret == "" {
+		w.Secret = m.store.Webhooks[i].Secret
+	}
+	if err := w.Validate(); err != nil {
+		return Webhook{}, err
+	}
+	next := slices.Clone(m.store.Webhooks)
+	next[i] = w
+	if err := m.commit(next); err != nil {
+		return Webhook{}, err
+	}
+	return w, nil
+}
+
+// Delete removes webhook id and its delivery records. Deliveries already
+// under way still finish.
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.indexOf(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	next := slices.Delete(slices.Clone(m.store.Webhooks), i, i+1)
+	if err := m.commit(next); err != nil {
+		return err
+	}
+	m.deliveriesMu.Lock()
+	delete(m.deliveries, id)
+	m.deliveriesMu.Unlock()
+	return nil
+}
+
+// Deliveries returns the recent deliveries to webhook id, newest first.
+func (m *Manager) Deliveries(id string) ([]Delivery, error) {
+	if _, err := m.Get(id); err != nil {
+		return nil, err
+	}
+	m.deliveriesMu.Lock()
+	defer m.deliveriesMu.Unlock()
+	recent := m.deliveries[id]
+	out := make([]Delivery, 0, len(recent))
+	for i := len(recent) - 1; i >= 0; i-- {
+		d := *recent[i]
+		d.Attempts = slices.Clone(d.Attempts)
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// create stores w under a new ID. m.mu must be held.
+func (m *Manager) create(w Webhook) (Webhook, error) {
+	w.ID = uuid.New().String()
+	w.UpdatedAt = time.Now().UTC()
+	if w.Secret == "" {
+		w.Secret = newSecret()
+	}
+	if err := w.Validate(); err != nil {
+		return Webhook{}, err
+	}
+	next := append(slices.Clone(m.store.Webhooks), w)
+	if err := m.commit(next); err != nil {
+		return Webhook{}, err
+	}
+	return w, nil
+}
+
+// commit persists webhooks. m.mu must be held.
+func (m *Manager) commit(webhooks []Webhook) error {
+	if err := atomicfile.WriteJSON(m.filePath, WebhookStore{Webhooks: webhooks}); err != nil {
+		return err
+	}
+	m.store.Webhooks = webhooks
+	return nil
+}
+
+// indexOf returns the position of webhook id, or -1. m.mu must be held.
+func (m *Manager) indexOf(id string) int {
+	return slices.IndexFunc(m.store.Webhooks, func(w Webhook) bool { return w.ID == id })
+}
+
+// newSecret returns a random signing secret.
+func newSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,270 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"web-terminal/webhook"
+)
+
+func newTestManager(t *testing.T) (*webhook.Manager, string) {
+	t.Helper()
+	dir := t.TempDir()
+	m, err := webhook.NewManager(dir+"/webhooks.json", dir+"/dead-letter.jsonl")
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	m.SetRetryPolicy(webhook.RetryPolicy{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+	return m, dir
+}
+
+// waitForStatus waits until the newest delivery to webhook id has status.
+func waitForStatus(t *testing.T, m *webhook.Manager, id, status string) webhook.Delivery {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		ds, err := m.Deliveries(id)
+		if err != nil {
+			t.Fatalf("Deliveries: %v", err)
+		}
+		if len(ds) > 0 && ds[0].Status == status {
+			return ds[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for a %s delivery, have %+v", status, ds)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebhookCRUD(t *testing.T) {
+	m, dir := newTestManager(t)
+
+	if _, err := m.Create(webhook.Webhook{Name: "ci", URL: "ftp://example.com"}); !errors.Is(err, webhook.ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for a non-http URL, got %v", err)
+	}
+	if _, err := m.Create(webhook.Webhook{Name: "ci", URL: "https://example.com", Events: []string{"session.renamed"}}); !errors.Is(err, webhook.ErrInvalid) {
+		t.Fatalf("expected ErrInvalid for an unknown event, got %v", err)
+	}
+
+	w, err := m.Create(webhook.Webhook{Name: "ci", URL: "https://example.com/hook", Events: []string{"session.created"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if w.ID == "" || len(w.Secret) != 64 {
+		t.Fatalf("expected an ID and a generated secret, got %+v", w)
+	}
+
+	w.Name = "ci-renamed"
+	secret := w.Secret
+	w.Secret = ""
+	saved, err := m.Update(w.ID, w)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if saved.Secret != secret {
+		t.Fatal("expected an empty secret to keep the current one")
+	}
+
+	reloaded, err := webhook.NewManager(dir+"/webhooks.json", "")
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got, err := reloaded.Get(w.ID); err != nil || got.Name != "ci-renamed" {
+		t.Fatalf("expected the webhook to persist, got %+v %v", got, err)
+	}
+
+	if err := m.Delete(w.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := m.Get(w.ID); !errors.Is(err, webhook.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestDispatchSignsAndFilters(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan received, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{r.Header, body}
+	}))
+	defer srv.Close()
+
+	m, _ := newTestManager(t)
+	w, err := m.Create(webhook.Webhook{Name: "ci", URL: srv.URL, Events: []string{"session.created"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	m.Dispatch("session.exited", map[string]string{"id": "s1"})
+	m.Dispatch("session.created", map[string]string{"id": "s1"})
+
+	var r received
+	select {
+	case r = <-got:
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not called")
+	}
+	if r.header.Get(webhook.HeaderEvent) != "session.created" {
+		t.Fatalf("expected only the subscribed event, got %q", r.header.Get(webhook.HeaderEvent))
+	}
+	if sig := r.header.Get(webhook.HeaderSignature); sig != webhook.Sign(w.Secret, r.body) {
+		t.Fatalf("signature %q does not match the body", sig)
+	}
+	var p struct {
+		ID    string            `json:"id"`
+		Event string            `json:"event"`
+		Data  map[string]string `json:"data"`
+	}
+	json.Unmarshal(r.body, &p)
+	if p.ID != r.header.Get(webhook.HeaderDelivery) || p.Data["id"] != "s1" {
+		t.Fatalf("unexpected payload %s", r.body)
+	}
+
+	d := waitForStatus(t, m, w.ID, webhook.StatusDelivered)
+	if d.ID != p.ID || len(d.Attempts) != 1 || d.Attempts[0].StatusCode != http.StatusOK {
+		t.Fatalf("unexpected delivery %+v", d)
+	}
+}
+
+func TestSendIgnoresSubscriptions(t *testing.T) {
+	got := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Get(webhook.HeaderEvent)
+	}))
+	defer srv.Close()
+
+	m, _ := newTestManager(t)
+	w, _ := m.Create(webhook.Webhook{Name: "ci", URL: srv.URL, Events: []string{"session.created"}})
+	if err := m.Send(w.ID, "trigger.matched", nil); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	select {
+	case event := <-got:
+		if event != "trigger.matched" {
+			t.Fatalf("unexpected event %q", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not called")
+	}
+	if err := m.Send("missing", "trigger.matched", nil); !errors.Is(err, webhook.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	w.Disabled = true
+	if _, err := m.Update(w.ID, w); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := m.Send(w.ID, "trigger.matched", nil); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	select {
+	case event := <-got:
+		t.Fatalf("disabled webhook got %q", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEnsure(t *testing.T) {
+	m, _ := newTestManager(t)
+	hook := webhook.Webhook{Name: "notify", URL: "https://example.com/notify", Events: []string{"notification"}}
+	w, err := m.Ensure(hook)
+	if err != nil || w.ID == "" || w.Secret == "" {
+		t.Fatalf("Ensure: %+v, %v", w, err)
+	}
+	w.Name = "renamed"
+	if _, err := m.Update(w.ID, w); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	again, err := m.Ensure(hook)
+	if err != nil || again.ID != w.ID || again.Name != "renamed" {
+		t.Fatalf("expected the existing webhook, got %+v, %v", again, err)
+	}
+	if len(m.List()) != 1 {
+		t.Fatalf("expected one webhook, got %+v", m.List())
+	}
+}
+
+func TestRetryThenDeliver(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	m, _ := newTestManager(t)
+	w, _ := m.Create(webhook.Webhook{Name: "flaky", URL: srv.URL})
+	if _, err := m.Ping(w.ID); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	d := waitForStatus(t, m, w.ID, webhook.StatusDelivered)
+	if len(d.Attempts) != 3 || d.Attempts[0].StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected two failed attempts then success, got %+v", d.Attempts)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	m, dir := newTestManager(t)
+	w, _ := m.Create(webhook.Webhook{Name: "down", URL: srv.URL})
+	m.Dispatch("notification", map[string]string{"body": "done"})
+	d := waitForStatus(t, m, w.ID, webhook.StatusFailed)
+	if len(d.Attempts) != 3 || calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d (%d calls)", len(d.Attempts), calls.Load())
+	}
+
+	var line []byte
+	deadline := time.Now().Add(2 * time.Second)
+	for len(line) == 0 && time.Now().Before(deadline) {
+		line, _ = os.ReadFile(dir + "/dead-letter.jsonl")
+		time.Sleep(5 * time.Millisecond)
+	}
+	var entry struct {
+		ID      string          `json:"id"`
+		URL     string          `json:"url"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(line, &entry); err != nil {
+		t.Fatalf("dead-letter line %q: %v", line, err)
+	}
+	if entry.ID != d.ID || entry.URL != srv.URL || !strings.Contains(string(entry.Payload), `"body":"done"`) {
+		t.Fatalf("unexpected dead letter %s", line)
+	}
+}
+
+func TestClientErrorIsNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	m, _ := newTestManager(t)
+	w, _ := m.Create(webhook.Webhook{Name: "gone", URL: srv.URL})
+	m.Ping(w.ID)
+	d := waitForStatus(t, m, w.ID, webhook.StatusFailed)
+	if len(d.Attempts) != 1 || calls.Load() != 1 {
+		t.Fatalf("expected a single attempt, got %+v", d.Attempts)
+	}
+}
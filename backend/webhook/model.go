@@ -0,0 +1,121 @@
+// Package webhook delivers server events to external HTTP endpoints as
+// signed JSON POSTs, retrying failed deliveries and recording the ones that
+// never succeed.
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Headers set on every delivery. The signature is the hex HMAC-SHA256 of
+// the request body keyed with the webhook's secret, prefixed with "sha256=".
+const (
+	HeaderEvent     = "X-Web-Terminal-Event"
+	HeaderDelivery  = "X-Web-Terminal-Delivery"
+	HeaderSignature = "X-Web-Terminal-Signature-256"
+)
+
+// Events a webhook can subscribe to. EventPing is only sent by Ping.
+var Events = []string{
+	"session.created",
+	"session.exited",
+	"session.attached",
+	"session.detached",
+	"trigger.matched",
+	"presets.changed",
+	"layout.changed",
+	"notification",
+}
+
+const EventPing = "ping"
+
+// Delivery states.
+const (
+	StatusPending   = "pending"   // attempted, or waiting for a retry
+	StatusDelivered = "delivered" // an attempt got a 2xx response
+	StatusFailed    = "failed"    // out of attempts, or rejected; see the dead-letter log
+)
+
+// Limits enforced by Validate.
+const (
+	MaxNameLen   = 100
+	MaxSecretLen = 256
+)
+
+var (
+	ErrNotFound = errors.New("webhook not found")
+	ErrInvalid  = errors.New("invalid webhook")
+)
+
+// Webhook is an endpoint that receives events. Secret, generated if left
+// empty, keys the signature of each delivery.
+type Webhook struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	Secret   string   `json:"secret"`
+	Events   []string `json:"events,omitempty"` // empty means every event
+	Disabled bool     `json:"disabled,omitempty"`
+
+	UpdatedAt time.Time `json:"updatedAt,omitzero"`
+}
+
+// WebhookStore is the full persistent state.
+type WebhookStore struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	ID    string    `json:"id"` // delivery ID
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Data  any       `json:"data,omitempty"`
+}
+
+// Delivery is the record of sending one event to one webhook.
+type Delivery struct {
+	ID        string    `json:"id"`
+	Webhook   string    `json:"webhook"` // webhook ID
+	Event     string    `json:"event"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+	Attempts  []Attempt `json:"attempts"`
+}
+
+// Attempt is one POST of a delivery.
+type Attempt struct {
+	Time       time.Time `json:"time"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+}
+
+// Validate checks the webhook's name, URL and events.
+func (w Webhook) Validate() error {
+	if w.Name == "" || len(w.Name) > MaxNameLen {
+		return fmt.Errorf("%w: name must be 1-%d characters", ErrInvalid, MaxNameLen)
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an http or https URL", ErrInvalid)
+	}
+	if len(w.Secret) > MaxSecretLen {
+		return fmt.Errorf("%w: secret must be at most %d characters", ErrInvalid, MaxSecretLen)
+	}
+	for _, e := range w.Events {
+		if !slices.Contains(Events, e) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalid, e)
+		}
+	}
+	return nil
+}
+
+// wants reports whether w receives event.
+func (w Webhook) wants(event string) bool {
+	return !w.Disabled && (len(w.Events) == 0 || slices.Contains(w.Events, event))
+}
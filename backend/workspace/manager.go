@@ -0,0 +1,178 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"web-terminal/internal/atomicfile"
+	"web-terminal/session"
+)
+
+// Manager persists workspace definitions and starts, stops and reports on
+// their member sessions through a session.Manager. Member sessions are found
+// by name, so no runtime state is kept beyond the session registry itself.
+type Manager struct {
+	mu       sync.RWMutex
+	filePath string
+	store    WorkspaceStore
+	sessions *session.Manager
+}
+
+// NewManager loads the workspace store from filePath, or creates an empty
+// store if the file does not exist. Returns an error only on unexpected I/O
+// failures.
+func NewManager(filePath string, sessions *session.Manager) (*Manager, error) {
+	m := &Manager{
+		filePath: filePath,
+		store:    WorkspaceStore{Workspaces: []Workspace{}},
+		sessions: sessions,
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return m, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &m.store); err != nil {
+		return nil, err
+	}
+	if m.store.Workspaces == nil {
+		m.store.Workspaces = []Workspace{}
+	}
+	return m, nil
+}
+
+// Get returns a snapshot of the current store.
+func (m *Manager) Get() WorkspaceStore {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	workspaces := make([]Workspace, len(m.store.Workspaces))
+	copy(workspaces, m.store.Workspaces)
+	return WorkspaceStore{Workspaces: workspaces}
+}
+
+// Find returns the workspace with the given id, or ErrNotFound.
+func (m *Manager) Find(id string) (Workspace, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, w := range m.store.Workspaces {
+		if w.ID == id {
+			return w, nil
+		}
+	}
+	return Workspace{}, ErrNotFound
+}
+
+// Save validates and atomically writes store to disk, then updates in-memory
+// state.
+func (m *Manager) Save(store WorkspaceStore) error {
+	if store.Workspaces == nil {
+		store.Workspaces = []Workspace{}
+	}
+	if err := store.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := atomicfile.WriteJSON(m.filePath, store); err != nil {
+		return err
+	}
+	m.store = store
+	return nil
+}
+
+// List returns the status of every workspace.
+func (m *Manager) List() []Status {
+	store := m.Get()
+	list := make([]Status, 0, len(store.Workspaces))
+	for _, w := range store.Workspaces {
+		list = append(list, m.status(w, nil))
+	}
+	return list
+}
+
+// Status reports the aggregate state of workspace id.
+func (m *Manager) Status(id string) (Status, error) {
+	w, err := m.Find(id)
+	if err != nil {
+		return Status{}, err
+	}
+	return m.status(w, nil), nil
+}
+
+// Start launches every member session of workspace id that is not already
+// running. Members that fail to start are reported in the returned status;
+// the error is non-nil only if the workspace does not exist.
+func (m *Manager) Start(id string) (Status, error) {
+	w, err := m.Find(id)
+	if err != nil {
+		return Status{}, err
+	}
+
+	errs := make(map[string]error)
+	for _, mem := range w.Members {
+		name := w.SessionName(mem)
+		if _, ok := m.sessions.FindByName(name); ok {
+			continue
+		}
+		if _, err := m.sessions.CreateWithSpec(name, mem.Spec); err != nil {
+			errs[mem.Name] = err
+		}
+	}
+	return m.status(w, errs), nil
+}
+
+// Stop kills every running member session of workspace id.
+func (m *Manager) Stop(id string) (Status, error) {
+	w, err := m.Find(id)
+	if err != nil {
+		return Status{}, err
+	}
+
+	errs := make(map[string]error)
+	for _, mem := range w.Members {
+		s, ok := m.sessions.FindByName(w.SessionName(mem))
+		if !ok {
+			continue
+		}
+		if err := m.sessions.Kill(s.ID); err != nil && !errors.Is(err, session.ErrNotFound) {
+			errs[mem.Name] = err
+		}
+	}
+	return m.status(w, errs), nil
+}
+
+// status builds w's status from the live session registry, attaching any
+// per-member errors from a start or stop attempt.
+func (m *Manager) status(w Workspace, errs map[string]error) Status {
+	st := Status{ID: w.ID, Name: w.Name, Members: make([]MemberStatus, 0, len(w.Members))}
+	running := 0
+	for _, mem := range w.Members {
+		ms := MemberStatus{Member: mem, SessionName: w.SessionName(mem), State: StateStopped}
+		if s, ok := m.sessions.FindByName(ms.SessionName); ok {
+			ms.SessionID = s.ID
+			ms.State = StateRunning
+			running++
+		}
+		if err := errs[mem.Name]; err != nil {
+			ms.Error = err.Error()
+		}
+		st.Members = append(st.Members, ms)
+	}
+
+	switch {
+	case running == 0:
+		st.State = StateStopped
+	case running == len(w.Members):
+		st.State = StateRunning
+	default:
+		st.State = StatePartial
+	}
+	return st
+}
@@ -0,0 +1,108 @@
+package workspace_test
+
+import (
+	"errors"
+	"testing"
+
+	"web-terminal/session"
+	"web-terminal/workspace"
+)
+
+func newTestManager(t *testing.T) (*workspace.Manager, *session.Manager) {
+	t.Helper()
+	sm := session.NewManagerWithBackend(session.BackendMock)
+	wm, err := workspace.NewManager(t.TempDir()+"/workspaces.json", sm)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	err = wm.Save(workspace.WorkspaceStore{Workspaces: []workspace.Workspace{{
+		ID:   "proj",
+		Name: "proj",
+		Members: []workspace.Member{
+			{Name: "server"},
+			{Name: "worker"},
+			{Name: "logs", Spec: session.Spec{Cwd: "/tmp"}},
+		},
+	}}})
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return wm, sm
+}
+
+func TestStartStopWorkspace(t *testing.T) {
+	wm, sm := newTestManager(t)
+
+	st, err := wm.Start("proj")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if st.State != workspace.StateRunning {
+		t.Fatalf("expected running, got %q", st.State)
+	}
+	if len(sm.List()) != 3 {
+		t.Fatalf("expected 3 sessions, got %d", len(sm.List()))
+	}
+	if _, ok := sm.FindByName("proj/logs"); !ok {
+		t.Fatal("expected session named proj/logs")
+	}
+
+	// Starting again is a no-op for members already running.
+	if _, err := wm.Start("proj"); err != nil {
+		t.Fatalf("second Start: %v", err)
+	}
+	if len(sm.List()) != 3 {
+		t.Fatalf("expected still 3 sessions, got %d", len(sm.List()))
+	}
+
+	st, err = wm.Stop("proj")
+	if err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if st.State != workspace.StateStopped || len(sm.List()) != 0 {
+		t.Fatalf("expected stopped with no sessions, got %q and %d sessions", st.State, len(sm.List()))
+	}
+}
+
+func TestStatusPartial(t *testing.T) {
+	wm, sm := newTestManager(t)
+	wm.Start("proj")
+
+	s, _ := sm.FindByName("proj/worker")
+	sm.Kill(s.ID)
+
+	st, err := wm.Status("proj")
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if st.State != workspace.StatePartial {
+		t.Fatalf("expected partial, got %q", st.State)
+	}
+	for _, m := range st.Members {
+		want := workspace.StateRunning
+		if m.Name == "worker" {
+			want = workspace.StateStopped
+		}
+		if m.State != want {
+			t.Fatalf("member %s: expected %q, got %q", m.Name, want, m.State)
+		}
+	}
+}
+
+func TestWorkspaceNotFound(t *testing.T) {
+	wm, _ := newTestManager(t)
+	if _, err := wm.Start("missing"); !errors.Is(err, workspace.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSaveRejectsDuplicateMembers(t *testing.T) {
+	wm, _ := newTestManager(t)
+	err := wm.Save(workspace.WorkspaceStore{Workspaces: []workspace.Workspace{{
+		ID:      "dup",
+		Members: []workspace.Member{{Name: "a"}, {Name: "a"}},
+	}}})
+	if err == nil {
+		t.Fatal("expected validation error for duplicate member names")
+	}
+}
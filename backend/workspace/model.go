@@ -0,0 +1,95 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+
+	"web-terminal/session"
+)
+
+// Member is one session launched as part of a workspace.
+type Member struct {
+	Name string `json:"name"`
+	session.Spec
+}
+
+// Workspace is a named set of sessions started and stopped together.
+type Workspace struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Members []Member `json:"members"`
+}
+
+// WorkspaceStore is the full persistent state.
+type WorkspaceStore struct {
+	Workspaces []Workspace `json:"workspaces"`
+}
+
+// Workspace and member states reported by Status.
+const (
+	StateRunning = "running"
+	StatePartial = "partial"
+	StateStopped = "stopped"
+)
+
+// MemberStatus reports the live session backing a workspace member.
+type MemberStatus struct {
+	Member
+	SessionName string `json:"sessionName"`
+	SessionID   string `json:"sessionId,omitempty"`
+	State       string `json:"state"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Status is a workspace annotated with the state of its member sessions. Its
+// JSON form is a superset of Workspace, so it can be edited and PUT back.
+type Status struct {
+	ID      string         `json:"id"`
+	Name    string         `json:"name"`
+	State   string         `json:"state"`
+	Members []MemberStatus `json:"members"`
+}
+
+var ErrNotFound = errors.New("workspace not found")
+
+// SessionName returns the name of the session backing member m.
+func (w Workspace) SessionName(m Member) string {
+	prefix := w.Name
+	if prefix == "" {
+		prefix = w.ID
+	}
+	return prefix + "/" + m.Name
+}
+
+// Validate checks that the workspace has an ID and uniquely named members.
+func (w Workspace) Validate() error {
+	if w.ID == "" {
+		return errors.New("workspace id is required")
+	}
+	seen := make(map[string]bool, len(w.Members))
+	for _, m := range w.Members {
+		if m.Name == "" {
+			return fmt.Errorf("workspace %q: member name is required", w.ID)
+		}
+		if seen[m.Name] {
+			return fmt.Errorf("workspace %q: duplicate member %q", w.ID, m.Name)
+		}
+		seen[m.Name] = true
+	}
+	return nil
+}
+
+// Validate checks every workspace and that workspace IDs are unique.
+func (s WorkspaceStore) Validate() error {
+	seen := make(map[string]bool, len(s.Workspaces))
+	for _, w := range s.Workspaces {
+		if err := w.Validate(); err != nil {
+			return err
+		}
+		if seen[w.ID] {
+			return fmt.Errorf("duplicate workspace id %q", w.ID)
+		}
+		seen[w.ID] = true
+	}
+	return nil
+}
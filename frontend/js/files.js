@@ -0,0 +1,136 @@
+// FileEditor edits a text file on the host in the notes pane. While a file
+// is open its CodeMirror view replaces the notes view; closing it brings the
+// notes back.
+export class FileEditor {
+  constructor(sessionId, notes) {
+    this.sessionId = sessionId;
+    this.notes = notes;
+    this.view = null;
+    this.path = null;
+    this.etag = null;
+    this.dirty = false;
+  }
+
+  init() {
+    document.getElementById('etn-open-file').addEventListener('click', () => this.promptOpen());
+    document.getElementById('file-save').addEventListener('click', () => this.save());
+    document.getElementById('file-reload').addEventListener('click', () => this.open(this.path));
+    document.getElementById('file-overwrite').addEventListener('click', () => this.save(true));
+    document.getElementById('file-close').addEventListener('click', () => this.close());
+  }
+
+  // Suggests the shell's working directory as the starting point.
+  async promptOpen() {
+    let dir = '';
+    try {
+      const resp = await fetch(`/api/sessions/${encodeURIComponent(this.sessionId)}/files`);
+      if (resp.ok) dir = (await resp.json()).path.replace(/\/?$/, '/');
+    } catch {}
+    const path = window.prompt('Open file (absolute path)', dir);
+    if (path) this.open(path.trim());
+  }
+
+  async open(path) {
+    if (this.dirty && this.path !== path && !window.confirm('Discard unsaved changes?')) return;
+    let resp;
+    try {
+      resp = await fetch(`/api/files/content?path=${encodeURIComponent(path)}`);
+    } catch (err) {
+      window.alert(`Failed to open ${path}: ${err.message}`);
+      return;
+    }
+    if (!resp.ok) {
+      window.alert(`Failed to open ${path}: ${(await resp.text()).trim()}`);
+      return;
+    }
+    this.path = path;
+    this.etag = resp.headers.get('ETag');
+    this._mount(await resp.text());
+    this._setStatus('');
+  }
+
+  // Saves with If-Match so changes made on disk since opening are not lost;
+  // force overwrites them anyway.
+  async save(force = false) {
+    if (!this.view) return;
+    const headers = { 'Content-Type': 'text/plain; charset=utf-8' };
+    if (!force && this.etag) headers['If-Match'] = this.etag;
+    let resp;
+    try {
+      resp = await fetch(`/api/files/content?path=${encodeURIComponent(this.path)}`, {
+        method: 'PUT',
+        headers,
+        body: this.view.state.doc.toString(),
+      });
+    } catch (err) {
+      this._setStatus(`Save failed: ${err.message}`);
+      return;
+    }
+    if (resp.status === 409) {
+      this._setStatus('Changed on disk since it was opened', true);
+      return;
+    }
+    if (!resp.ok) {
+      this._setStatus(`Save failed: ${(await resp.text()).trim()}`);
+      return;
+    }
+    this.etag = resp.headers.get('ETag');
+    this.dirty = false;
+    this._setStatus('Saved');
+  }
+
+  close() {
+    if (this.dirty && !window.confirm('Discard unsaved changes?')) return;
+    this.view?.destroy();
+    this.view = null;
+    this.path = null;
+    this.etag = null;
+    this.dirty = false;
+    document.getElementById('file-bar').style.display = 'none';
+    if (this.notes.view) this.notes.view.dom.style.display = '';
+  }
+
+  _mount(content) {
+    this.view?.destroy();
+    this.dirty = false;
+    if (this.notes.view) this.notes.view.dom.style.display = 'none';
+    this.view = new CM.EditorView({
+      state: CM.EditorState.create({
+        doc: content,
+        extensions: [
+          CM.lineNumbers(),
+          CM.history(),
+          CM.drawSelection(),
+          CM.indentOnInput(),
+          CM.bracketMatching(),
+          CM.highlightActiveLine(),
+          CM.highlightSelectionMatches(),
+          CM.keymap.of([
+            { key: 'Mod-s', preventDefault: true, run: () => { this.save(); return true; } },
+            ...CM.defaultKeymap,
+            ...CM.historyKeymap,
+            ...CM.searchKeymap,
+          ]),
+          CM.oneDark,
+          CM.search({ top: true }),
+          CM.EditorView.updateListener.of(update => {
+            if (update.docChanged && !this.dirty) {
+              this.dirty = true;
+              this._setStatus('Modified');
+            }
+          }),
+        ],
+      }),
+      parent: document.getElementById('editor-area'),
+    });
+    document.getElementById('file-bar-path').textContent = this.path;
+    document.getElementById('file-bar').style.display = 'flex';
+    this.view.focus();
+  }
+
+  _setStatus(text, conflict = false) {
+    document.getElementById('file-bar-status').textContent = text;
+    document.getElementById('file-reload').style.display = conflict ? '' : 'none';
+    document.getElementById('file-overwrite').style.display = conflict ? '' : 'none';
+  }
+}
@@ -1,5 +1,6 @@
-import { escapeHtml, formatRelative } from '/js/utils.js';
+import { escapeHtml, formatRelative, showNotification } from '/js/utils.js';
 import { PresetEditor } from '/js/presets.js';
+import { openTriggers } from '/js/triggers.js';
 
 const tbody = document.getElementById('sessions-tbody');
 const emptyState = document.getElementById('empty-state');
@@ -8,6 +9,8 @@ const modal = document.getElementById('modal');
 const modalCancel = document.getElementById('modal-cancel');
 const modalCreate = document.getElementById('modal-create');
 const modalInput = document.getElementById('modal-input');
+const modalTemplate = document.getElementById('modal-template');
+const modalTags = document.getElementById('modal-tags');
 const modalError = document.getElementById('modal-error');
 
 async function loadSessions() {
@@ -37,7 +40,7 @@ async function loadSessions() {
       : '<span class="dot dot-idle" title="Idle">&#9679;</span> idle';
 
     tr.innerHTML = `
-      <td data-label="Name">${escapeHtml(s.name)}</td>
+      <td data-label="Name">${escapeHtml(s.name)}${s.remote ? ` <span class="session-remote" title="SSH session">${escapeHtml(s.remote)}</span>` : ''}${(s.tags || []).map(t => ` <span class="session-tag">${escapeHtml(t)}</span>`).join('')}</td>
       <td data-label="Created">${formatRelative(s.created_at)}</td>
       <td data-label="Last Active">${formatRelative(s.last_active)}</td>
       <td data-label="Status">${statusDot}</td>
@@ -63,12 +66,53 @@ async function loadSessions() {
   });
 }
 
+// Layouts are listed so a split-pane page can be reopened on any device.
+async function loadLayouts() {
+  let store = { layouts: [] };
+  try {
+    const resp = await fetch('/api/layouts');
+    if (resp.ok) store = await resp.json();
+  } catch {}
+
+  const section = document.getElementById('layouts-section');
+  const list = document.getElementById('layouts-list');
+  const layouts = store.layouts || [];
+  section.style.display = layouts.length ? 'block' : 'none';
+  list.innerHTML = layouts.map(l => `
+    <li>
+      <a href="/layout/${encodeURIComponent(l.id)}" target="_blank">${escapeHtml(l.name)}</a>
+      <span class="layouts-updated">updated ${formatRelative(l.updatedAt)}</span>
+    </li>
+  `).join('');
+}
+
+async function loadTemplates() {
+  let store = { templates: [] };
+  try {
+    const resp = await fetch('/api/templates');
+    if (resp.ok) store = await resp.json();
+  } catch {}
+
+  const templates = store.templates || [];
+  modalTemplate.innerHTML = '<option value="">No template</option>';
+  for (const t of templates) {
+    const opt = document.createElement('option');
+    opt.value = t.id;
+    opt.textContent = t.name || t.id;
+    modalTemplate.appendChild(opt);
+  }
+  modalTemplate.style.display = templates.length > 0 ? 'block' : 'none';
+}
+
 // Modal logic
 newSessionBtn.addEventListener('click', () => {
   modalInput.value = '';
   modalError.textContent = '';
+  modalTemplate.value = '';
+  modalTags.value = '';
   modal.style.display = 'flex';
   modalInput.focus();
+  loadTemplates();
 });
 
 modalCancel.addEventListener('click', () => {
@@ -86,7 +130,9 @@ modalInput.addEventListener('keydown', (e) => {
 
 modalCreate.addEventListener('click', async () => {
   const name = modalInput.value.trim();
-  if (!name) {
+  const templateId = modalTemplate.value;
+  const tags = modalTags.value.split(',').map(t => t.trim()).filter(Boolean);
+  if (!name && !templateId) {
     modalError.textContent = 'Session name is required.';
     return;
   }
@@ -94,7 +140,7 @@ modalCreate.addEventListener('click', async () => {
   const resp = await fetch('/api/sessions', {
     method: 'POST',
     headers: { 'Content-Type': 'application/json' },
-    body: JSON.stringify({ name }),
+    body: JSON.stringify(templateId ? { name, templateId } : { name, tags }),
   });
 
   if (resp.status === 409) {
@@ -116,6 +162,27 @@ document.getElementById('presets-btn').addEventListener('click', () => {
   new PresetEditor({ showInsert: false }).open();
 });
 
+document.getElementById('triggers-btn').addEventListener('click', openTriggers);
+
+// Notifications from every session, e.g. a long build finishing in a tab
+// that isn't open.
+const notifyBtn = document.getElementById('notify-btn');
+if (typeof Notification === 'undefined' || Notification.permission !== 'default') {
+  notifyBtn.style.display = 'none';
+}
+notifyBtn.addEventListener('click', async () => {
+  await Notification.requestPermission();
+  if (Notification.permission !== 'default') notifyBtn.style.display = 'none';
+});
+new EventSource('/api/events').addEventListener('notification', (e) => {
+  const n = JSON.parse(e.data);
+  showNotification(n, () => window.open(`/session/${n.sessionId}`, '_blank'));
+});
+
 // Initial load + auto-refresh
 loadSessions();
-setInterval(loadSessions, 5000);
+loadLayouts();
+setInterval(() => {
+  loadSessions();
+  loadLayouts();
+}, 5000);
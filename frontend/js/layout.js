@@ -0,0 +1,383 @@
+import { escapeHtml, showNotification, base64ToBytes } from '/js/utils.js';
+import { TerminalAdapter } from '/js/terminal.js';
+import { TransferController } from '/js/transfer.js';
+
+// A layout page shows several sessions side by side. The split tree lives on
+// the server, so every device showing the layout sees the same panes; all of
+// them stream over one WebSocket whose messages carry the pane ID as their
+// channel.
+
+// Extract layout id from URL path: /layout/:id
+const pathParts = window.location.pathname.split('/');
+const layoutId = pathParts[pathParts.length - 1];
+
+if (!layoutId) {
+  document.body.textContent = 'Invalid layout URL.';
+  throw new Error('Invalid layout URL');
+}
+
+const root = document.getElementById('layout-root');
+const statusBar = document.getElementById('status-bar');
+
+let layout = null;        // latest layout from the server
+let ws = null;
+let wsState = 'connected';
+let layoutDeleted = false;
+let pageUnloading = false;
+let reconnectAttempts = 0;
+const MAX_RECONNECT = 10;
+
+// Panes outlive re-renders so their terminals keep their contents.
+// pane ID → { id, el, adapter, transfers, size }
+const panes = new Map();
+
+function send(msg) {
+  if (ws && ws.readyState === WebSocket.OPEN) ws.send(JSON.stringify(msg));
+}
+
+function getPane(id) {
+  let pane = panes.get(id);
+  if (pane) return pane;
+
+  const el = document.createElement('div');
+  el.className = 'layout-pane';
+  el.innerHTML = `
+    <div class="layout-pane-toolbar">
+      <span class="layout-pane-title"></span>
+      <button class="btn" data-action="split-h" title="Split right">&#9707;</button>
+      <button class="btn" data-action="split-v" title="Split down">&#9636;</button>
+      <button class="btn btn-danger" data-action="close" title="Close pane">&times;</button>
+    </div>
+    <div class="layout-pane-term"></div>
+    <div class="layout-pane-notice" style="display:none"></div>
+  `;
+  const adapter = new TerminalAdapter();
+  adapter.attach(el.querySelector('.layout-pane-term'));
+  pane = { id, el, adapter, size: null };
+  pane.transfers = new TransferController({
+    send: (msg) => send({ ...msg, channel: id }),
+    write: (text) => adapter.write(text),
+  });
+  adapter.onData((text) => send({ type: 'input', channel: id, data: btoa(text) }));
+  adapter.onResize((cols, rows) => {
+    pane.size = { cols, rows };
+    send({ type: 'resize', channel: id, cols, rows });
+  });
+  el.addEventListener('mousedown', () => setFocus(id));
+  el.querySelector('[data-action="split-h"]').addEventListener('click', () => splitPane(id, 'horizontal'));
+  el.querySelector('[data-action="split-v"]').addEventListener('click', () => splitPane(id, 'vertical'));
+  el.querySelector('[data-action="close"]').addEventListener('click', () => closePane(id));
+  panes.set(id, pane);
+  return pane;
+}
+
+function showNotice(pane, text) {
+  const notice = pane.el.querySelector('.layout-pane-notice');
+  notice.textContent = text;
+  notice.style.display = text ? 'flex' : 'none';
+}
+
+// render builds the split tree from layout, reusing existing panes and
+// disposing of those no longer in it.
+function render() {
+  const seen = new Set();
+  const build = (node) => {
+    if (!node.split) {
+      seen.add(node.pane);
+      const pane = getPane(node.pane);
+      pane.el.classList.toggle('layout-pane-focused', node.pane === layout.focus);
+      return pane.el;
+    }
+    const box = document.createElement('div');
+    box.className = `layout-split layout-split-${node.split}`;
+    node.children.forEach((child, i) => {
+      if (i > 0) box.appendChild(makeDivider(node, i, box));
+      const el = build(child);
+      el.style.flex = `${node.sizes?.[i] ?? 1} 1 0`;
+      box.appendChild(el);
+    });
+    return box;
+  };
+  root.replaceChildren(build(layout.root));
+  panes.get(layout.focus)?.adapter.focus();
+
+  for (const [id, pane] of panes) {
+    if (!seen.has(id)) {
+      pane.adapter.dispose();
+      panes.delete(id);
+    }
+  }
+  renderTitles();
+  renderStatusBar();
+}
+
+async function renderTitles() {
+  let sessions = [];
+  try {
+    const resp = await fetch('/api/sessions');
+    if (resp.ok) sessions = await resp.json();
+  } catch {}
+  const names = new Map(sessions.map(s => [s.id, s.name]));
+  walk(layout.root, (node) => {
+    const pane = panes.get(node.pane);
+    if (pane) pane.el.querySelector('.layout-pane-title').textContent = names.get(node.session) ?? 'ended';
+  });
+}
+
+function renderStatusBar() {
+  let statusDot;
+  if (wsState === 'connected') {
+    statusDot = '<span class="dot dot-connected" title="Connected">&#9679;</span> connected';
+  } else if (wsState === 'reconnecting') {
+    statusDot = '<span class="dot dot-reconnecting" title="Reconnecting">&#9679;</span> reconnecting…';
+  } else {
+    statusDot = '<span class="dot dot-disconnected" title="Disconnected">&#9679;</span> disconnected';
+  }
+  statusBar.innerHTML = `
+    <div class="status-bar-meta">
+      <span class="status-bar-name">${escapeHtml(layout?.name ?? layoutId)}</span>
+      <span class="status-bar-sep">|</span>
+      <span>${layout ? countPanes(layout.root) : 0} panes</span>
+      <span class="status-bar-sep">|</span>
+      <span>${statusDot}</span>
+    </div>
+    <div style="display:flex;align-items:center;gap:6px">
+      <button class="btn btn-danger" id="status-delete-btn">Delete layout</button>
+    </div>
+  `;
+  document.getElementById('status-delete-btn').addEventListener('click', async () => {
+    if (!window.confirm('Delete this layout? Its sessions keep running.')) return;
+    await fetch(`/api/layouts/${layoutId}`, { method: 'DELETE' });
+  });
+}
+
+function walk(node, fn) {
+  if (!node.split) {
+    fn(node);
+    return;
+  }
+  node.children.forEach(child => walk(child, fn));
+}
+
+function countPanes(node) {
+  let n = 0;
+  walk(node, () => n++);
+  return n;
+}
+
+// replaceNode returns a copy of tree with the pane id replaced by the result
+// of fn, which may return null to remove it. A split left with one child is
+// replaced by that child.
+function replaceNode(tree, id, fn) {
+  if (!tree.split) return tree.pane === id ? fn(tree) : tree;
+  const children = [];
+  const sizes = [];
+  tree.children.forEach((child, i) => {
+    const next = replaceNode(child, id, fn);
+    if (next) {
+      children.push(next);
+      sizes.push(tree.sizes?.[i] ?? 1);
+    }
+  });
+  if (children.length === 1) return children[0];
+  return { ...tree, children, sizes };
+}
+
+// save stores a changed layout. On a conflict the server's layout wins; it
+// also arrives over the WebSocket, so the page just re-renders.
+async function save(next) {
+  const resp = await fetch(`/api/layouts/${layoutId}`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json', 'If-Match': `"${layout.revision}"` },
+    body: JSON.stringify(next),
+  });
+  if (resp.status === 409) {
+    window.alert('The layout was changed on another device; your change was not saved.');
+  } else if (!resp.ok) {
+    window.alert((await resp.text()).trim());
+  }
+}
+
+async function splitPane(id, direction) {
+  const resp = await fetch('/api/sessions', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ name: `${layout.name}-${Math.random().toString(36).slice(2, 8)}` }),
+  });
+  if (!resp.ok) {
+    window.alert((await resp.text()).trim());
+    return;
+  }
+  const session = await resp.json();
+  const rootNode = replaceNode(layout.root, id, (node) => ({
+    split: direction,
+    children: [node, { session: session.id }],
+    sizes: [1, 1],
+  }));
+  await save({ ...layout, root: rootNode });
+}
+
+async function closePane(id) {
+  if (countPanes(layout.root) === 1) {
+    window.alert('The last pane cannot be closed; delete the layout instead.');
+    return;
+  }
+  const rootNode = replaceNode(layout.root, id, () => null);
+  await save({ ...layout, root: rootNode, focus: layout.focus === id ? '' : layout.focus });
+}
+
+function setFocus(id) {
+  if (!layout || layout.focus === id) return;
+  save({ ...layout, focus: id });
+}
+
+// makeDivider returns the handle between children i-1 and i of split node.
+// Dragging it moves space between the two and saves the new sizes on release.
+function makeDivider(node, i, box) {
+  const divider = document.createElement('div');
+  divider.className = 'layout-divider';
+  const horizontal = node.split === 'horizontal';
+
+  const start = (e) => {
+    e.preventDefault();
+    const sizes = node.children.map((_, j) => node.sizes?.[j] ?? 1);
+    const total = sizes.reduce((a, b) => a + b, 0);
+    const rect = box.getBoundingClientRect();
+    const length = horizontal ? rect.width : rect.height;
+    const pair = sizes[i - 1] + sizes[i];
+    const before = sizes.slice(0, i - 1).reduce((a, b) => a + b, 0);
+    const els = [divider.previousElementSibling, divider.nextElementSibling];
+    document.body.style.cursor = horizontal ? 'col-resize' : 'row-resize';
+    document.body.style.userSelect = 'none';
+
+    function onMove(clientX, clientY) {
+      const pos = horizontal ? clientX - rect.left : clientY - rect.top;
+      const share = (pos / length) * total - before;
+      sizes[i - 1] = Math.min(Math.max(share, pair * 0.1), pair * 0.9);
+      sizes[i] = pair - sizes[i - 1];
+      els[0].style.flex = `${sizes[i - 1]} 1 0`;
+      els[1].style.flex = `${sizes[i]} 1 0`;
+    }
+    function onMouseMove(ev) { onMove(ev.clientX, ev.clientY); }
+    function onTouchMove(ev) { ev.preventDefault(); onMove(ev.touches[0].clientX, ev.touches[0].clientY); }
+    function onEnd() {
+      document.body.style.cursor = '';
+      document.body.style.userSelect = '';
+      document.removeEventListener('mousemove', onMouseMove);
+      document.removeEventListener('mouseup', onEnd);
+      document.removeEventListener('touchmove', onTouchMove);
+      document.removeEventListener('touchend', onEnd);
+      node.sizes = sizes;
+      save(layout);
+    }
+    document.addEventListener('mousemove', onMouseMove);
+    document.addEventListener('mouseup', onEnd);
+    document.addEventListener('touchmove', onTouchMove, { passive: false });
+    document.addEventListener('touchend', onEnd);
+  };
+  divider.addEventListener('mousedown', start);
+  divider.addEventListener('touchstart', start, { passive: false });
+  return divider;
+}
+
+function setWsState(state) {
+  wsState = state;
+  renderStatusBar();
+}
+
+function connect() {
+  const proto = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+  ws = new WebSocket(`${proto}//${window.location.host}/api/layouts/${layoutId}/ws`);
+
+  ws.onopen = () => {
+    reconnectAttempts = 0;
+    setWsState('connected');
+    // Panes are replayed from scratch on every connect.
+    for (const pane of panes.values()) pane.adapter.write('\x1b[H\x1b[2J\x1b[3J');
+  };
+
+  ws.onmessage = (event) => {
+    let msg;
+    try {
+      msg = JSON.parse(event.data);
+    } catch {
+      return;
+    }
+
+    if (msg.type === 'layout') {
+      layout = msg.layout;
+      document.title = layout.name;
+      render();
+      // Tell the server each pane's size, including panes it just attached.
+      for (const pane of panes.values()) {
+        if (pane.size) send({ type: 'resize', channel: pane.id, ...pane.size });
+      }
+      return;
+    }
+    if (msg.type === 'closed' && !msg.channel) {
+      layoutDeleted = true;
+      document.getElementById('layout-ended').style.display = 'flex';
+      return;
+    }
+
+    const pane = panes.get(msg.channel);
+    if (!pane) return;
+    // Output is acknowledged once processed so the server keeps sending;
+    // see "Flow control" in the README.
+    if (msg.type === 'output') {
+      showNotice(pane, '');
+      const bytes = base64ToBytes(msg.data);
+      pane.adapter.write(bytes, () => send({ type: 'ack', channel: pane.id, bytes: bytes.length }));
+    } else if (msg.type === 'reset') {
+      // The pane fell too far behind; its scrollback follows.
+      pane.adapter.write('\x1b[H\x1b[2J\x1b[3J');
+    } else if (msg.type === 'transfer') {
+      pane.transfers.start(msg);
+    } else if (msg.type === 'transfer-data') {
+      pane.transfers.receive(msg.data);
+      send({ type: 'ack', channel: pane.id, bytes: base64ToBytes(msg.data).length });
+    } else if (msg.type === 'hostkey') {
+      const accept = window.confirm(
+        `The authenticity of host ${msg.host} can't be established.\n` +
+        `${msg.keyType} key fingerprint is ${msg.fingerprint}.\n\n` +
+        'Trust this host and continue connecting?');
+      send({ type: 'hostkey-reply', channel: pane.id, id: msg.id, accept });
+    } else if (msg.type === 'notification') {
+      if (document.hidden) showNotification(msg.notification, () => window.focus());
+    } else if (msg.type === 'highlight') {
+      pane.adapter.highlight(msg.highlight.text, msg.highlight.color);
+    } else if (msg.type === 'displaced') {
+      showNotice(pane, 'Opened in another tab');
+    } else if (msg.type === 'closed') {
+      showNotice(pane, 'Session ended');
+    }
+  };
+
+  ws.onclose = () => {
+    for (const pane of panes.values()) pane.transfers.reset();
+    if (!layoutDeleted && !pageUnloading) scheduleReconnect();
+  };
+
+  ws.onerror = (err) => {
+    console.error('WebSocket error:', err);
+  };
+}
+
+function scheduleReconnect() {
+  if (reconnectAttempts >= MAX_RECONNECT) {
+    setWsState('disconnected');
+    return;
+  }
+  const delay = Math.min(1000 * (2 ** reconnectAttempts), 10000);
+  reconnectAttempts++;
+  setWsState('reconnecting');
+  setTimeout(connect, delay);
+}
+
+renderStatusBar();
+connect();
+
+window.addEventListener('beforeunload', () => {
+  pageUnloading = true;
+  if (ws) ws.close();
+});
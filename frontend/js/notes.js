@@ -3,8 +3,13 @@ export class NoteEditor {
     this.sessionId = sessionId;
     this.sessionNameGetter = sessionNameGetter;
     this.storageKey = `wt:notes:${sessionId}`;
+    this.apiUrl = `/api/sessions/${encodeURIComponent(sessionId)}/notes`;
     this.view = null;
     this.data = null; // { tabs: [], activeTabId: string }
+    this.revision = null; // server revision; null until synced
+    this.keep = false;    // keep notes on the server after the session ends
+    this._pushing = false;
+    this._pushAgain = false;
   }
 
   init() {
@@ -14,6 +19,109 @@ export class NoteEditor {
     this._bindToolbar();
     this._loadActiveTab();
     this._updateToolbar();
+    this._syncFromServer();
+  }
+
+  // localStorage is a cache for instant display; the server copy is
+  // authoritative. If the server has no notes yet, the local ones (for
+  // example from before notes were stored server-side) are uploaded.
+  async _syncFromServer() {
+    let notes;
+    try {
+      const resp = await fetch(this.apiUrl);
+      if (!resp.ok) return;
+      notes = await resp.json();
+    } catch {
+      return;
+    }
+    this.revision = notes.revision;
+    this.keep = !!notes.keep;
+    this._updateKeepButton();
+    if (notes.tabs?.length) {
+      this.data = { tabs: notes.tabs, activeTabId: notes.activeTabId };
+      if (!this.data.tabs.find(t => t.id === this.data.activeTabId)) {
+        this.data.activeTabId = this.data.tabs[0].id;
+      }
+      localStorage.setItem(this.storageKey, JSON.stringify(this.data));
+      this._renderTabs();
+      this._loadActiveTab();
+      this._updateToolbar();
+    } else {
+      this._pushToServer();
+    }
+  }
+
+  async _pushToServer() {
+    if (this.revision === null) return; // not synced yet
+    if (this._pushing) {
+      this._pushAgain = true;
+      return;
+    }
+    this._pushing = true;
+    try {
+      const resp = await fetch(this.apiUrl, {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json', 'If-Match': `"${this.revision}"` },
+        body: JSON.stringify({
+          tabs: this.data.tabs,
+          activeTabId: this.data.activeTabId,
+          keep: this.keep,
+        }),
+      });
+      if (resp.status === 409) {
+        const { notes } = await resp.json();
+        this._mergeServerNotes(notes);
+        this._pushAgain = true;
+      } else if (resp.ok) {
+        this.revision = (await resp.json()).revision;
+      } else {
+        throw new Error(`HTTP ${resp.status}`);
+      }
+    } catch (err) {
+      console.error('Failed to save notes:', err);
+    } finally {
+      this._pushing = false;
+    }
+    if (this._pushAgain) {
+      this._pushAgain = false;
+      this._pushToServer();
+    }
+  }
+
+  // Merges notes saved meanwhile by another browser. Tabs from both sides are
+  // kept; for a tab on both, the local copy wins unless the server's has
+  // already been sent (read-only).
+  _mergeServerNotes(server) {
+    this._saveCurrentContent();
+    this.revision = server.revision;
+    const local = new Map(this.data.tabs.map(t => [t.id, t]));
+    const merged = server.tabs.map(st => {
+      const lt = local.get(st.id);
+      local.delete(st.id);
+      return lt && !st.readonly ? lt : st;
+    });
+    const before = this._getActiveTab();
+    this.data.tabs = [...local.values(), ...merged];
+    if (!this._getActiveTab()) this.data.activeTabId = this.data.tabs[0]?.id ?? null;
+    localStorage.setItem(this.storageKey, JSON.stringify(this.data));
+    this._renderTabs();
+    if (this._getActiveTab() !== before) this._loadActiveTab();
+    this._updateToolbar();
+  }
+
+  toggleKeep() {
+    this.keep = !this.keep;
+    this._updateKeepButton();
+    this._pushToServer();
+  }
+
+  _updateKeepButton() {
+    const btn = document.getElementById('etn-keep');
+    if (!btn) return;
+    btn.classList.toggle('active', this.keep);
+    btn.title = this.keep
+      ? 'Notes are kept after the session ends and reattached to the next session with this name'
+      : 'Notes are deleted when the session ends';
   }
 
   _load() {
@@ -36,6 +144,7 @@ export class NoteEditor {
 
   _save() {
     localStorage.setItem(this.storageKey, JSON.stringify(this.data));
+    this._pushToServer();
   }
 
   _debouncedSave() {
@@ -172,6 +281,7 @@ export class NoteEditor {
     document.getElementById('etn-export').addEventListener('click', () => this.exportAll());
     document.getElementById('etn-delete').addEventListener('click', () => this.deleteCurrent());
     document.getElementById('etn-delete-all').addEventListener('click', () => this.deleteAllReadonly());
+    document.getElementById('etn-keep')?.addEventListener('click', () => this.toggleKeep());
 
     this._confirmAction = null;
     document.getElementById('editor-confirm-cancel').addEventListener('click', () => {
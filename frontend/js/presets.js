@@ -1,10 +1,43 @@
+// ── Template variables ──────────────────────────────────────────────────────
+// Presets may contain {{name}} placeholders. Declared variables are prompted
+// for, then the server expands the content (including built-ins such as
+// {{date}}, {{cwd}} and {{session.name}}). Returns null if the user cancels.
+
+export async function resolvePresetContent(preset, sessionId) {
+  const content = preset.content || '';
+  if (!content.includes('{{')) return content;
+
+  const variables = {};
+  for (const v of preset.variables || []) {
+    const hint = v.type === 'enum' && v.options?.length ? ` (${v.options.join(', ')})` : '';
+    const answer = window.prompt(`${v.description || v.name}${hint}`, v.default || '');
+    if (answer === null) return null;
+    variables[v.name] = answer;
+  }
+
+  const resp = await fetch(`/api/presets/${preset.id}/render`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ variables, sessionId }),
+  });
+  if (resp.status === 422) {
+    const { errors = [] } = await resp.json();
+    window.alert(errors.map(e => `${e.field}: ${e.message}`).join('\n'));
+    return null;
+  }
+  if (!resp.ok) throw new Error(`HTTP ${resp.status}`);
+  return (await resp.json()).content;
+}
+
 // ── PresetPopup ─────────────────────────────────────────────────────────────
-// Dropdown popup attached to a button. Shows recently-used presets + "Open Editor…".
+// Dropdown popup attached to a button. Shows recently-used presets, a search
+// box over all presets, and "Open Editor…".
 
 export class PresetPopup {
-  constructor(buttonEl, { onInsert, onOpenEditor }) {
+  constructor(buttonEl, { onInsert, onOpenEditor, sessionId = null }) {
     this._btn = buttonEl;
     this._onInsert = onInsert;
+    this._sessionId = sessionId;
     this._onOpenEditor = onOpenEditor;
     this._popup = null;
     this._open = false;
@@ -33,6 +66,12 @@ export class PresetPopup {
     }
 
     this._popup.innerHTML = '';
+    const searchEl = document.createElement('input');
+    searchEl.type = 'text';
+    searchEl.className = 'preset-popup-search';
+    searchEl.placeholder = 'Search presets…';
+    this._popup.appendChild(searchEl);
+
     const itemsEl = document.createElement('div');
     itemsEl.id = 'preset-popup-items';
     this._popup.appendChild(itemsEl);
@@ -54,32 +93,45 @@ export class PresetPopup {
     this._position();
     this._popup.style.display = 'block';
     this._open = true;
+    searchEl.focus();
 
-    // Load presets.
-    let store = { presets: [], recentlyUsed: [] };
+    searchEl.addEventListener('input', () => {
+      clearTimeout(this._searchTimer);
+      this._searchTimer = setTimeout(() => this._load(itemsEl, searchEl.value.trim()), 150);
+    });
+    await this._load(itemsEl, '');
+  }
+
+  // Loads recently-used presets, or ranked search results when query is set.
+  async _load(itemsEl, query) {
+    let presets = [];
     try {
-      const resp = await fetch('/api/presets');
-      if (resp.ok) store = await resp.json();
+      const url = query
+        ? `/api/presets?q=${encodeURIComponent(query)}`
+        : '/api/presets/recent';
+      const resp = await fetch(url);
+      if (resp.ok) {
+        const body = await resp.json();
+        presets = query ? (body.results || []) : (body.presets || []);
+      }
     } catch {}
 
     itemsEl.innerHTML = '';
-    const recentIds = (store.recentlyUsed || []).slice(0, 10);
-    const presetMap = Object.fromEntries((store.presets || []).map(p => [p.id, p]));
-    const recent = recentIds.map(id => presetMap[id]).filter(Boolean);
-
-    if (recent.length === 0) {
+    if (presets.length === 0) {
       const empty = document.createElement('div');
       empty.className = 'preset-popup-empty';
-      empty.textContent = 'No recent presets';
+      empty.textContent = query ? 'No matching presets' : 'No recent presets';
       itemsEl.appendChild(empty);
     } else {
-      for (const p of recent) {
+      for (const p of presets) {
         const el = document.createElement('div');
         el.className = 'preset-popup-item';
-        el.textContent = p.title || '(untitled)';
-        el.addEventListener('click', () => {
+        el.textContent = p.folder ? `${p.folder} / ${p.title || '(untitled)'}` : (p.title || '(untitled)');
+        el.addEventListener('click', async () => {
           this._close();
-          this._onInsert(p.content || '');
+          const content = await resolvePresetContent(p, this._sessionId).catch(() => null);
+          if (content === null) return;
+          this._onInsert(content);
           fetch(`/api/presets/${p.id}/use`, { method: 'POST' }).catch(() => {});
         });
         itemsEl.appendChild(el);
@@ -115,21 +167,27 @@ export class PresetPopup {
 // Full-screen dialog for creating, editing, reordering, and deleting presets.
 
 export class PresetEditor {
-  constructor({ showInsert = false, onInsert = null } = {}) {
+  constructor({ showInsert = false, onInsert = null, sessionId = null } = {}) {
     this._showInsert = showInsert;
     this._onInsert = onInsert;
+    this._sessionId = sessionId;
     this._presets = [];
     this._recentlyUsed = [];
+    this._etag = null;
     this._selectedIndex = 0;
     this._cmView = null;
     this._overlay = null;
+    this._events = null;
   }
 
   async open() {
     let store = { presets: [], recentlyUsed: [] };
     try {
       const resp = await fetch('/api/presets');
-      if (resp.ok) store = await resp.json();
+      if (resp.ok) {
+        store = await resp.json();
+        this._etag = resp.headers.get('ETag');
+      }
     } catch {}
 
     this._presets = store.presets || [];
@@ -143,6 +201,15 @@ export class PresetEditor {
     this._buildDOM();
     this._renderNav();
     this._loadPreset(0);
+
+    // Warn when the presets file is edited on disk while the editor is open.
+    this._events = new EventSource('/api/events');
+    this._events.addEventListener('presets.changed', (e) => {
+      const { source } = JSON.parse(e.data);
+      if (source === 'disk') {
+        this._showSaveError('Presets file changed on disk — reopen the editor to see the latest');
+      }
+    });
   }
 
   _blankPreset() {
@@ -193,10 +260,12 @@ export class PresetEditor {
     if (this._showInsert) {
       const insertBtn = this._makeBtn('Insert', async () => {
         this._saveCurrentToMemory();
-        const content = this._presets[this._selectedIndex]?.content || '';
-        if (this._onInsert) this._onInsert(content);
-        const id = this._presets[this._selectedIndex]?.id;
+        const preset = this._presets[this._selectedIndex];
+        const id = preset?.id;
         await this._saveToServer();
+        const content = preset ? await resolvePresetContent(preset, this._sessionId).catch(() => null) : '';
+        if (content === null) return;
+        if (this._onInsert) this._onInsert(content);
         if (id) fetch(`/api/presets/${id}/use`, { method: 'POST' }).catch(() => {});
         this._destroy();
       });
@@ -369,22 +438,35 @@ export class PresetEditor {
 
   async _saveToServer() {
     try {
+      const headers = { 'Content-Type': 'application/json' };
+      if (this._etag) headers['If-Match'] = this._etag;
       const resp = await fetch('/api/presets', {
         method: 'PUT',
-        headers: { 'Content-Type': 'application/json' },
+        headers,
         body: JSON.stringify({
           presets: this._presets,
           recentlyUsed: this._recentlyUsed,
         }),
       });
+      if (resp.status === 409) {
+        this._showSaveError('Presets were changed elsewhere — reopen the editor to see the latest');
+        return;
+      }
+      if (resp.status === 422) {
+        const { errors = [] } = await resp.json().catch(() => ({}));
+        const first = errors[0];
+        this._showSaveError(first ? `Not saved: ${first.field} ${first.message}` : 'Not saved: invalid presets');
+        return;
+      }
       if (!resp.ok) throw new Error(`HTTP ${resp.status}`);
+      this._etag = resp.headers.get('ETag');
     } catch (err) {
       console.error('Failed to save presets:', err);
       this._showSaveError();
     }
   }
 
-  _showSaveError() {
+  _showSaveError(message = 'Save failed — changes may not persist') {
     if (!this._overlay) return;
     let bar = this._overlay.querySelector('.preset-save-error');
     if (!bar) {
@@ -392,13 +474,17 @@ export class PresetEditor {
       bar.className = 'preset-save-error';
       this._overlay.querySelector('.preset-dialog').appendChild(bar);
     }
-    bar.textContent = 'Save failed — changes may not persist';
+    bar.textContent = message;
     bar.style.display = 'block';
     clearTimeout(this._saveErrorTimer);
     this._saveErrorTimer = setTimeout(() => { bar.style.display = 'none'; }, 4000);
   }
 
   _destroy() {
+    if (this._events) {
+      this._events.close();
+      this._events = null;
+    }
     if (this._cmView) {
       this._cmView.destroy();
       this._cmView = null;
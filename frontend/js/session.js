@@ -1,5 +1,6 @@
-import { escapeHtml, formatRelative } from '/js/utils.js';
+import { escapeHtml, formatRelative, showNotification, base64ToBytes } from '/js/utils.js';
 import { TerminalAdapter } from '/js/terminal.js';
+import { TransferController, registerTransferHandler } from '/js/transfer.js';
 
 // Extract session id from URL path: /session/:id
 const pathParts = window.location.pathname.split('/');
@@ -10,6 +11,7 @@ let sessionDisplaced = false;
 let pageUnloading = false;
 let wsState = 'connected';   // 'connected' | 'reconnecting' | 'disconnected'
 let lastSession = null;
+let notifySettings = null;   // loaded from /api/sessions/:id/notifications
 
 if (!sessionId) {
   document.body.textContent = 'Invalid session URL.';
@@ -36,9 +38,23 @@ function renderStatusBar(session) {
     ? '<button class="btn btn-primary" id="status-reconnect-btn">Reconnect</button>'
     : '';
 
+  const remote = session.remote
+    ? `<span class="status-bar-sep">|</span>
+      <span>${escapeHtml(session.remote)}</span>
+      <span class="status-bar-hostkey" title="Host key fingerprint">${escapeHtml(session.hostKey || 'host key not verified yet')}</span>`
+    : '';
+  const agentBtn = session.remote
+    ? `<button class="btn" id="status-agent-btn" title="Let the remote shell use your SSH agent">Agent forwarding: ${session.agentForwarding ? 'on' : 'off'}</button>`
+    : '';
+
+  const notifyBtn = notifySettings
+    ? `<button class="btn" id="status-notify-btn" title="Notify about bells, messages and commands over ${notifySettings.minCommandSeconds}s">Notifications: ${notifySettings.muted ? 'muted' : 'on'}</button>`
+    : '';
+
   statusBar.innerHTML = `
     <div class="status-bar-meta">
       <span class="status-bar-name">${escapeHtml(session.name)}</span>
+      ${remote}
       <span class="status-bar-sep">|</span>
       <span>created ${formatRelative(session.created_at)}</span>
       <span class="status-bar-sep">|</span>
@@ -48,6 +64,9 @@ function renderStatusBar(session) {
     </div>
     <div style="display:flex;align-items:center;gap:6px">
       ${reconnectBtn}
+      ${agentBtn}
+      ${notifyBtn}
+      <button class="btn" id="status-split-btn" title="Open this session in a split-pane layout">Split</button>
       <button class="btn btn-danger" id="status-kill-btn">Kill</button>
     </div>
   `;
@@ -58,6 +77,52 @@ function renderStatusBar(session) {
     });
   }
 
+  if (session.remote) {
+    document.getElementById('status-agent-btn').addEventListener('click', async () => {
+      const resp = await fetch(`/api/sessions/${sessionId}/agent-forwarding`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ enabled: !session.agentForwarding }),
+      });
+      if (!resp.ok) {
+        window.alert((await resp.text()).trim());
+        return;
+      }
+      renderStatusBar(await resp.json());
+    });
+  }
+
+  if (notifySettings) {
+    document.getElementById('status-notify-btn').addEventListener('click', async () => {
+      if (typeof Notification !== 'undefined' && Notification.permission === 'default') {
+        await Notification.requestPermission();
+      }
+      const resp = await fetch(`/api/sessions/${sessionId}/notifications`, {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ ...notifySettings, muted: !notifySettings.muted }),
+      });
+      if (resp.ok) {
+        notifySettings = await resp.json();
+        renderStatusBar(session);
+      }
+    });
+  }
+
+  document.getElementById('status-split-btn').addEventListener('click', async () => {
+    const resp = await fetch('/api/layouts', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ name: session.name, root: { session: sessionId } }),
+    });
+    if (!resp.ok) {
+      window.alert((await resp.text()).trim());
+      return;
+    }
+    const created = await resp.json();
+    window.location.href = `/layout/${created.id}`;
+  });
+
   document.getElementById('status-kill-btn').addEventListener('click', async () => {
     await fetch(`/api/sessions/${sessionId}`, { method: 'DELETE' });
     window.close();
@@ -88,7 +153,17 @@ async function loadStatus() {
   }
 }
 
+async function loadNotifySettings() {
+  try {
+    const resp = await fetch(`/api/sessions/${sessionId}/notifications`);
+    if (resp.ok) notifySettings = await resp.json();
+  } catch {
+    // Non-fatal
+  }
+}
+
 // Initial load + auto-refresh
+await loadNotifySettings();
 await loadStatus();
 setInterval(loadStatus, 5000);
 
@@ -118,6 +193,10 @@ adapter.onData((text) => {
 
 window.getSessionName = () => currentSessionName;
 
+// ZMODEM and trzsz are built in; handlers for other transfer protocols, or
+// replacements, plug in here.
+window.registerTransferHandler = registerTransferHandler;
+
 window.focusTerminal = () => {
   adapter.focus();
 };
@@ -135,6 +214,13 @@ let hasConnectedOnce = false;
 let replayDone = false;
 const MAX_RECONNECT = 10;
 
+const transfers = new TransferController({
+  send: (msg) => {
+    if (ws && ws.readyState === WebSocket.OPEN) ws.send(JSON.stringify(msg));
+  },
+  write: (text) => adapter.write(text),
+});
+
 function connect() {
   const proto = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
   ws = new WebSocket(`${proto}//${window.location.host}/api/sessions/${sessionId}/ws`);
@@ -165,11 +251,7 @@ function connect() {
     }
 
     if (msg.type === 'output') {
-      const binary = atob(msg.data);
-      const bytes = new Uint8Array(binary.length);
-      for (let i = 0; i < binary.length; i++) {
-        bytes[i] = binary.charCodeAt(i);
-      }
+      const bytes = base64ToBytes(msg.data);
       if (!replayDone) {
         // First output message after connect is the scrollback replay.
         // Once xterm finishes processing it, restore the viewport position.
@@ -178,6 +260,22 @@ function connect() {
       } else {
         adapter.write(bytes);
       }
+    } else if (msg.type === 'transfer') {
+      transfers.start(msg);
+    } else if (msg.type === 'transfer-data') {
+      transfers.receive(msg.data);
+    } else if (msg.type === 'hostkey') {
+      // Trust on first use: the connection waits for this answer.
+      const accept = window.confirm(
+        `The authenticity of host ${msg.host} can't be established.\n` +
+        `${msg.keyType} key fingerprint is ${msg.fingerprint}.\n\n` +
+        'Trust this host and continue connecting?');
+      ws.send(JSON.stringify({ type: 'hostkey-reply', id: msg.id, accept }));
+    } else if (msg.type === 'notification') {
+      // The page itself shows the terminal; notify only when it's hidden.
+      if (document.hidden) showNotification(msg.notification, () => window.focus());
+    } else if (msg.type === 'highlight') {
+      adapter.highlight(msg.highlight.text, msg.highlight.color);
     } else if (msg.type === 'displaced') {
       sessionDisplaced = true;
       setWsState('disconnected');
@@ -189,6 +287,7 @@ function connect() {
   };
 
   ws.onclose = () => {
+    transfers.reset();
     if (!sessionEnded && !sessionDisplaced && !pageUnloading) {
       scheduleReconnect();
     }
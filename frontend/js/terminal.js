@@ -1,5 +1,9 @@
 // TerminalAdapter wraps xterm.js behind a stable interface.
 // Swap this file to change the underlying terminal library.
+
+// How many lines above the cursor highlight() searches.
+const HIGHLIGHT_SEARCH_LINES = 200;
+
 export class TerminalAdapter {
   constructor(options = {}) {
     this._options = options;
@@ -85,6 +89,25 @@ export class TerminalAdapter {
     }
   }
 
+  // highlight marks the last occurrence of text near the cursor, such as a
+  // line matched by a server-side trigger, once pending output is written.
+  highlight(text, color = '#ffa000') {
+    if (!this._term || !text) return;
+    this._term.write('', () => {
+      const buf = this._term.buffer.active;
+      const cursorLine = buf.baseY + buf.cursorY;
+      for (let y = cursorLine; y >= Math.max(0, cursorLine - HIGHLIGHT_SEARCH_LINES); y--) {
+        const x = buf.getLine(y)?.translateToString(true).lastIndexOf(text) ?? -1;
+        if (x < 0) continue;
+        const marker = this._term.registerMarker(y - cursorLine);
+        if (marker) {
+          this._term.registerDecoration({ marker, x, width: text.length, backgroundColor: color });
+        }
+        return;
+      }
+    });
+  }
+
   focus() {
     if (this._term) {
       this._term.focus();
@@ -83,6 +83,25 @@ describe('TerminalAdapter', () => {
     expect(mockTerm.dispose).toHaveBeenCalledOnce();
   });
 
+  it('highlight decorates the last line containing the text', () => {
+    const lines = ['ok', 'ERROR one', 'fine', ''];
+    mockTerm.write = vi.fn((data, cb) => cb && cb());
+    mockTerm.buffer = {
+      active: {
+        viewportY: 0, baseY: 0, cursorY: 3,
+        getLine: y => ({ translateToString: () => lines[y] }),
+      },
+    };
+    mockTerm.registerMarker = vi.fn(() => ({ line: 1 }));
+    mockTerm.registerDecoration = vi.fn();
+    const adapter = new TerminalAdapter();
+    adapter.attach(document.createElement('div'));
+    adapter.highlight('ERROR', '#ff0000');
+    expect(mockTerm.registerMarker).toHaveBeenCalledWith(-2);
+    expect(mockTerm.registerDecoration).toHaveBeenCalledWith(
+      { marker: { line: 1 }, x: 0, width: 5, backgroundColor: '#ff0000' });
+  });
+
   it('dispose is safe before attach', () => {
     const adapter = new TerminalAdapter();
     expect(() => adapter.dispose()).not.toThrow();
@@ -0,0 +1,25 @@
+import { describeScope, parseReply } from '../triggers.js';
+
+describe('describeScope', () => {
+  it('describes an empty scope as all sessions', () => {
+    expect(describeScope({})).toBe('all sessions');
+    expect(describeScope()).toBe('all sessions');
+  });
+  it('names the tag', () => {
+    expect(describeScope({ tag: 'prod' })).toBe('tag prod');
+  });
+  it('shortens session IDs', () => {
+    expect(describeScope({ session: '0123456789abcdef' })).toBe('session 01234567');
+  });
+});
+
+describe('parseReply', () => {
+  it('turns escapes into control characters', () => {
+    expect(parseReply('yes\\n')).toBe('yes\n');
+    expect(parseReply('a\\tb\\r')).toBe('a\tb\r');
+  });
+  it('keeps escaped backslashes and plain text', () => {
+    expect(parseReply('C:\\\\n')).toBe('C:\\n');
+    expect(parseReply('plain')).toBe('plain');
+  });
+});
@@ -0,0 +1,159 @@
+import {
+  Client, Lines, Md5, encodeBytes, decodeBytes, encodeString, decodeString,
+} from '../trzsz.js';
+
+const hex = (bytes) => [...bytes].map((b) => b.toString(16).padStart(2, '0')).join('');
+const text = (s) => new TextEncoder().encode(s);
+
+function testFile(name, size) {
+  const bytes = Uint8Array.from({ length: size }, (_, i) => (i * 31 + (i >> 10)) & 0xff);
+  return {
+    name,
+    size,
+    bytes,
+    slice: (start, end) => ({ arrayBuffer: async () => bytes.slice(start, end).buffer }),
+  };
+}
+
+function md5(bytes) {
+  const m = new Md5();
+  m.update(bytes);
+  return m.digest();
+}
+
+// remote plays trz or tsz against a Client: script(peer) reads the
+// client's lines with peer.recv(type) and writes with peer.send(type, value).
+function remote(direction, mode, script, { pick = [] } = {}) {
+  const lines = new Lines();
+  const io = { sent: [], saved: [], notices: [] };
+  let client;
+  const ended = new Promise((resolve) => {
+    client = new Client(direction, {
+      send: (bytes) => setTimeout(() => lines.push(bytes)),
+      pick: async () => pick,
+      save: (name, chunks) => io.saved.push({ name, chunks }),
+      notice: (t) => io.notices.push(t),
+      end: resolve,
+    });
+  });
+  const peer = {
+    send: (type, value) => setTimeout(() => client.receive(text(`#${type}:${value}\r\n`))),
+    async recv(type) {
+      const line = await lines.next();
+      io.sent.push(line);
+      expect(line.slice(0, type.length + 2)).toBe(`#${type}:`);
+      return line.slice(type.length + 2);
+    },
+  };
+  client.receive(text(`\x1b7\x07::TRZSZ:TRANSFER:${mode}:1.1.6:0000000000000000\r\n`));
+  return Promise.all([script(peer), ended]).then(() => io);
+}
+
+async function config(peer, cfg = {}) {
+  const action = JSON.parse(await decodeString(await peer.recv('ACT')));
+  expect(action.confirm).toBe(true);
+  peer.send('CFG', await encodeString(JSON.stringify({ newline: '\n', binary: false, ...cfg })));
+}
+
+describe('trzsz encoding', () => {
+  it('computes MD5 digests', () => {
+    expect(hex(md5(text('')))).toBe('d41d8cd98f00b204e9800998ecf8427e');
+    expect(hex(md5(text('abc')))).toBe('900150983cd24fb0d6963f7d28e17f72');
+    expect(hex(md5(text('a'.repeat(1000))))).toBe('cabe45dcc9ae5b66ba86600cca6b8ba8');
+  });
+
+  it('round-trips zlib and base64', async () => {
+    const bytes = testFile('x', 5000).bytes;
+    expect(await decodeBytes(await encodeBytes(bytes))).toEqual(bytes);
+    expect(await decodeString(await encodeString('naïve.txt'))).toBe('naïve.txt');
+    // "eJwrSS0uAQAEXQHB" is zlib.compress(b"test") from Python, base64-encoded.
+    expect(await decodeString('eJwrSS0uAQAEXQHB')).toBe('test');
+  });
+});
+
+describe('trzsz transfers', () => {
+  it('downloads files sent by tsz', async () => {
+    const files = [testFile('/var/log/a.log', 25000), testFile('empty', 0)];
+    const io = await remote('download', 'S', async (peer) => {
+      await config(peer);
+      peer.send('NUM', files.length);
+      expect(await peer.recv('SUCC')).toBe(String(files.length));
+      for (const file of files) {
+        peer.send('NAME', await encodeString(file.name));
+        expect(await decodeString(await peer.recv('SUCC'))).toBe(file.name.split('/').pop());
+        peer.send('SIZE', file.size);
+        expect(await peer.recv('SUCC')).toBe(String(file.size));
+        for (let at = 0; at < file.size; at += 10240) {
+          const data = file.bytes.subarray(at, at + 10240);
+          peer.send('DATA', await encodeBytes(data));
+          expect(await peer.recv('SUCC')).toBe(String(data.length));
+        }
+        peer.send('MD5', await encodeBytes(md5(file.bytes)));
+        expect(await decodeBytes(await peer.recv('SUCC'))).toEqual(md5(file.bytes));
+      }
+      expect(await decodeString(await peer.recv('EXIT'))).toContain('a.log, empty');
+    });
+    expect(io.saved.map((f) => f.name)).toEqual(['a.log', 'empty']);
+    io.saved.forEach((f, i) => {
+      expect(new Uint8Array(f.chunks.flatMap((c) => [...c]))).toEqual(files[i].bytes);
+    });
+  });
+
+  it('uploads files to trz', async () => {
+    const files = [testFile('one.bin', 70000), testFile('two.txt', 3)];
+    await remote('upload', 'R', async (peer) => {
+      await config(peer);
+      const num = Number(await peer.recv('NUM'));
+      peer.send('SUCC', num);
+      for (let i = 0; i < num; i++) {
+        const name = await decodeString(await peer.recv('NAME'));
+        peer.send('SUCC', await encodeString(`${name}.1`));
+        const size = Number(await peer.recv('SIZE'));
+        peer.send('SUCC', size);
+        const chunks = [];
+        for (let got = 0; got < size;) {
+          const data = await decodeBytes(await peer.recv('DATA'));
+          chunks.push(...data);
+          got += data.length;
+          peer.send('SUCC', data.length);
+        }
+        const digest = await decodeBytes(await peer.recv('MD5'));
+        expect(name).toBe(files[i].name);
+        expect(new Uint8Array(chunks)).toEqual(files[i].bytes);
+        expect(digest).toEqual(md5(files[i].bytes));
+        peer.send('SUCC', await encodeBytes(digest));
+      }
+      expect(await decodeString(await peer.recv('EXIT'))).toContain('one.bin.1, two.txt.1');
+    }, { pick: files });
+  });
+
+  it('declines an upload when no file is picked', async () => {
+    await remote('upload', 'R', async (peer) => {
+      const action = JSON.parse(await decodeString(await peer.recv('ACT')));
+      expect(action.confirm).toBe(false);
+    });
+  });
+
+  it('declines directories and binary mode', async () => {
+    const io = await remote('upload', 'D', async (peer) => {
+      const action = JSON.parse(await decodeString(await peer.recv('ACT')));
+      expect(action.confirm).toBe(false);
+    });
+    expect(io.notices).toEqual(['trzsz directory uploads are not supported']);
+
+    const binary = await remote('download', 'S', async (peer) => {
+      await config(peer, { binary: true });
+      expect(await decodeString(await peer.recv('FAIL'))).toContain('binary mode');
+    });
+    expect(binary.notices).toEqual(['trzsz download failed: binary mode (-b) is not supported']);
+  });
+
+  it('reports a failure from the remote program', async () => {
+    const io = await remote('download', 'S', async (peer) => {
+      await config(peer);
+      peer.send('FAIL', await encodeString('Permission denied'));
+    });
+    expect(io.notices).toEqual(['trzsz download failed: Permission denied']);
+    expect(io.sent).toHaveLength(1);
+  });
+});
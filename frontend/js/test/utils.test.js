@@ -1,4 +1,4 @@
-import { escapeHtml, formatRelative } from '../utils.js';
+import { escapeHtml, formatRelative, notificationText } from '../utils.js';
 
 describe('escapeHtml', () => {
   it('escapes ampersand', () => {
@@ -48,3 +48,21 @@ describe('formatRelative', () => {
     expect(formatRelative(isoAgo(3 * 24 * 60 * 60_000))).toBe('3 days ago');
   });
 });
+
+describe('notificationText', () => {
+  it('describes a finished command', () => {
+    const n = { kind: 'command', sessionName: 'build', command: { command: 'make', durationMs: 12_400, exitCode: 0 } };
+    expect(notificationText(n)).toEqual({ title: 'build: command finished', body: 'make — 12s' });
+  });
+  it('reports a failing exit code', () => {
+    const n = { kind: 'command', sessionName: 'build', command: { command: 'make', durationMs: 1000, exitCode: 2 } };
+    expect(notificationText(n).title).toBe('build: command failed (exit 2)');
+  });
+  it('uses the message title and body', () => {
+    const n = { kind: 'message', sessionName: 'ci', title: 'Tests', body: 'all passed' };
+    expect(notificationText(n)).toEqual({ title: 'ci: Tests', body: 'all passed' });
+  });
+  it('describes a bell', () => {
+    expect(notificationText({ kind: 'bell', sessionName: 'shell' })).toEqual({ title: 'shell', body: 'Bell' });
+  });
+});
@@ -0,0 +1,137 @@
+import {
+  Receiver, Sender, hexHeader, crc32, ZRQINIT, ZRINIT, ZRPOS,
+} from '../zmodem.js';
+
+const latin1 = (s) => Uint8Array.from(s, (c) => c.charCodeAt(0));
+
+// Start sequences written by lrzsz, as in backend/session/transfer_test.go.
+const rzStart = latin1('**\x18B0100000023be50\r\x8a\x11');
+const szStart = latin1('**\x18B00000000000000\r\x8a\x11');
+
+function testFile(name, size) {
+  const bytes = Uint8Array.from({ length: size }, (_, i) => (i * 7 + (i >> 8)) & 0xff);
+  return {
+    name,
+    size,
+    bytes,
+    slice: (start, end) => ({ arrayBuffer: async () => bytes.slice(start, end).buffer }),
+  };
+}
+
+// connect runs sz (a Sender) against the browser's Receiver, or the
+// browser's Sender against rz (a Receiver), passing bytes asynchronously
+// as a connection would. mangle may alter what the sender writes.
+function connect({ files, pick = files, mangle = (b) => b, after = new Uint8Array(0) }) {
+  const got = [];
+  let rest;
+  let current;
+  let receiver;
+  let sender;
+  const result = new Promise((resolve, reject) => {
+    let finished = 0;
+    const finish = () => {
+      if (++finished === 2) resolve({ got, rest });
+    };
+    receiver = new Receiver({
+      send: (bytes) => setTimeout(() => sender.receive(bytes)),
+      file: (name, size) => got.push(current = { name, size, chunks: [] }),
+      data: (bytes) => current.chunks.push(bytes),
+      eof: () => { current.complete = true; },
+      done: (bytes) => {
+        rest = bytes;
+        finish();
+      },
+      fail: reject,
+    });
+    sender = new Sender({
+      send: (bytes) => {
+        const out = mangle(bytes);
+        const oo = bytes.length === 2 && bytes[0] === 0x4f && bytes[1] === 0x4f;
+        setTimeout(() => receiver.receive(oo ? new Uint8Array([...out, ...after]) : out));
+      },
+      pick: async () => pick,
+      read: async (file, start, end) => new Uint8Array(await file.slice(start, end).arrayBuffer()),
+      sent: () => {},
+      done: finish,
+      cancel: () => reject(new Error('cancelled')),
+      fail: reject,
+    });
+  });
+  return { receiver, sender, result };
+}
+
+function joined(chunks) {
+  const out = new Uint8Array(chunks.reduce((n, c) => n + c.length, 0));
+  let at = 0;
+  for (const c of chunks) {
+    out.set(c, at);
+    at += c.length;
+  }
+  return out;
+}
+
+describe('zmodem headers', () => {
+  it('encodes hex headers as lrzsz does', () => {
+    expect(hexHeader(ZRQINIT)).toEqual(szStart);
+    expect(hexHeader(ZRINIT, [0, 0, 0, 0x23])).toEqual(rzStart);
+    expect(hexHeader(ZRPOS, [0, 0, 0, 0])).toEqual(latin1('**\x18B0900000000a87c\r\x8a\x11'));
+  });
+
+  it('computes the ZMODEM CRC-32', () => {
+    expect(crc32(latin1('123456789'))).toBe(0xcbf43926);
+  });
+});
+
+describe('zmodem transfers', () => {
+  const files = [testFile('small.txt', 100), testFile('empty', 0), testFile('large.bin', 600 << 10)];
+
+  it('downloads files sent by sz', async () => {
+    const { receiver, result } = connect({ files, after: latin1('$ ') });
+    // sz opens with ZRQINIT and waits for the browser's ZRINIT.
+    receiver.receive(szStart);
+    const { got, rest } = await result;
+    expect(got.map((f) => [f.name, f.size, f.complete])).toEqual([
+      ['small.txt', 100, true], ['empty', 0, true], ['large.bin', 600 << 10, true],
+    ]);
+    got.forEach((f, i) => expect(joined(f.chunks)).toEqual(files[i].bytes));
+    expect(String.fromCharCode(...rest)).toBe('$ ');
+  });
+
+  it('uploads files to rz', async () => {
+    const { sender, result } = connect({ files });
+    sender.receive(rzStart);
+    const { got } = await result;
+    got.forEach((f, i) => expect(joined(f.chunks)).toEqual(files[i].bytes));
+  });
+
+  it('resends data from ZRPOS after a damaged subpacket', async () => {
+    let damaged = false;
+    const mangle = (bytes) => {
+      if (damaged || bytes.length < 5000) return bytes;
+      damaged = true;
+      const copy = bytes.slice();
+      copy[3000] ^= 0x01;
+      return copy;
+    };
+    const { sender, result } = connect({ files: [testFile('large.bin', 300 << 10)], mangle });
+    sender.receive(rzStart);
+    const { got } = await result;
+    expect(damaged).toBe(true);
+    expect(got).toHaveLength(1);
+    expect(joined(got[0].chunks)).toEqual(testFile('large.bin', 300 << 10).bytes);
+  });
+
+  it('cancels rz when no file is picked', async () => {
+    const { sender, result } = connect({ files: [] });
+    sender.receive(rzStart);
+    await expect(result).rejects.toThrow('cancelled');
+  });
+
+  it('fails when the other side cancels', () => {
+    const failures = [];
+    const receiver = new Receiver({ send: () => {}, fail: (message) => failures.push(message) });
+    receiver.receive(szStart);
+    receiver.receive(latin1('\x18\x18\x18\x18\x18\x08\x08\x08\x08\x08'));
+    expect(failures).toEqual(['cancelled by sz']);
+  });
+});
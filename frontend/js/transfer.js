@@ -0,0 +1,110 @@
+import { base64ToBytes, bytesToBase64 } from './utils.js';
+import { zmodemHandler } from './zmodem.js';
+import { trzszHandler } from './trzsz.js';
+
+// In-band file transfers (ZMODEM, trzsz) started by programs in the session,
+// typically on a host reached through ssh. The server detects the start and
+// switches the WebSocket into a transfer sub-protocol:
+//
+//   server → { type: 'transfer', protocol, direction }   a transfer begins
+//   server → { type: 'transfer-data', data }             protocol bytes (base64)
+//   client → { type: 'transfer-data', data }             protocol bytes (base64)
+//   client → { type: 'transfer-end' }                    back to terminal output
+//   client → { type: 'transfer-cancel' }                 abort the remote program
+//
+// The protocols themselves are implemented by handlers registered per
+// protocol name; zmodem.js and trzsz.js are built in. A handler factory is
+// called as factory(transfer, channel) and returns an object with
+// receive(bytes); channel offers send(bytes), end(rest), cancel(),
+// notice(text), pickFiles() and save(name, blob). end's rest is output the
+// remote program wrote after the transfer, shown in the terminal.
+
+const handlers = new Map([
+  ['zmodem', zmodemHandler],
+  ['trzsz', trzszHandler],
+]);
+
+export function registerTransferHandler(protocol, factory) {
+  handlers.set(protocol, factory);
+}
+
+export class TransferController {
+  constructor({ send, write }) {
+    this._send = send;   // (msg) => void, sends a WebSocket message
+    this._write = write; // (data) => void, writes text or bytes to the terminal
+    this.active = null;
+  }
+
+  start(transfer) {
+    this.active = null;
+    const factory = handlers.get(transfer.protocol);
+    if (!factory) {
+      this._notice(`${transfer.protocol} ${transfer.direction} requested, but this browser has no ${transfer.protocol} support; cancelled`);
+      this._send({ type: 'transfer-cancel' });
+      return;
+    }
+    const channel = {
+      send: (bytes) => {
+        if (this.active === handler) this._send({ type: 'transfer-data', data: bytesToBase64(bytes) });
+      },
+      end: (rest) => {
+        if (this.active !== handler) return;
+        this._finish({ type: 'transfer-end' });
+        if (rest?.length) this._write(rest);
+      },
+      cancel: () => {
+        if (this.active === handler) this._finish({ type: 'transfer-cancel' });
+      },
+      notice: (text) => this._notice(text),
+      pickFiles,
+      save: saveBlob,
+    };
+    let handler = null;
+    try {
+      handler = this.active = factory(transfer, channel);
+    } catch (err) {
+      this._notice(`${transfer.protocol} transfer failed: ${err.message}`);
+      this._send({ type: 'transfer-cancel' });
+    }
+  }
+
+  receive(data) {
+    if (!this.active) return; // late bytes after the transfer ended
+    this.active.receive(base64ToBytes(data));
+  }
+
+  // The connection dropped; the server has already cancelled the transfer.
+  reset() {
+    this.active = null;
+  }
+
+  _finish(msg) {
+    this.active = null;
+    this._send(msg);
+  }
+
+  _notice(text) {
+    this._write(`\r\n\x1b[33m[${text}]\x1b[0m\r\n`);
+  }
+}
+
+function pickFiles({ directory = false } = {}) {
+  return new Promise((resolve) => {
+    const input = document.createElement('input');
+    input.type = 'file';
+    input.multiple = true;
+    if (directory) input.webkitdirectory = true;
+    input.addEventListener('change', () => resolve([...input.files]));
+    input.addEventListener('cancel', () => resolve([]));
+    input.click();
+  });
+}
+
+function saveBlob(name, blob) {
+  const url = URL.createObjectURL(blob);
+  const a = document.createElement('a');
+  a.href = url;
+  a.download = name;
+  a.click();
+  URL.revokeObjectURL(url);
+}
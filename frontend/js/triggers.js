@@ -0,0 +1,158 @@
+import { escapeHtml } from './utils.js';
+
+// ── Trigger rules ───────────────────────────────────────────────────────────
+// A modal listing the server's trigger rules, with a form to add one. Rules
+// watch session output for a regular expression and act on matching lines.
+
+const ACTIONS = {
+  highlight: 'Highlight',
+  notify: 'Notify',
+  webhook: 'Call webhook',
+  reply: 'Reply',
+  kill: 'Kill session',
+};
+
+// describeScope returns a short label for a rule's scope.
+export function describeScope(scope = {}) {
+  if (scope.session) return `session ${scope.session.slice(0, 8)}`;
+  if (scope.tag) return `tag ${scope.tag}`;
+  return 'all sessions';
+}
+
+// parseReply turns the \n, \r and \t escapes typed in the reply field into
+// the characters they stand for, so a reply can press Enter.
+export function parseReply(text) {
+  return text.replace(/\\([nrt\\])/g, (_, c) => ({ n: '\n', r: '\r', t: '\t', '\\': '\\' })[c]);
+}
+
+export function openTriggers() {
+  const overlay = document.createElement('div');
+  overlay.className = 'modal-overlay';
+  overlay.style.display = 'flex';
+  overlay.innerHTML = `
+    <div class="modal-box triggers-box">
+      <h2 class="modal-title">Triggers</h2>
+      <ul class="triggers-list"></ul>
+      <div class="triggers-form">
+        <input class="modal-input" data-field="name" placeholder="Name" maxlength="100">
+        <input class="modal-input" data-field="pattern" placeholder="Regular expression, e.g. (?i)error" maxlength="1000">
+        <select class="modal-input" data-field="action">
+          ${Object.entries(ACTIONS).map(([v, label]) => `<option value="${v}">${label}</option>`).join('')}
+        </select>
+        <input class="modal-input" data-field="arg" placeholder="Color, e.g. #ffa000">
+        <select class="modal-input" data-field="webhook" style="display:none;"></select>
+        <select class="modal-input" data-field="scope">
+          <option value="">All sessions</option>
+          <option value="tag">Sessions tagged…</option>
+          <option value="session">One session (ID)…</option>
+        </select>
+        <input class="modal-input" data-field="scopeValue" placeholder="Tag" style="display:none;">
+      </div>
+      <p class="modal-error"></p>
+      <div class="modal-actions">
+        <button class="btn" data-act="close">Close</button>
+        <button class="btn btn-primary" data-act="add">Add trigger</button>
+      </div>
+    </div>
+  `;
+  document.body.appendChild(overlay);
+
+  const field = name => overlay.querySelector(`[data-field="${name}"]`);
+  const list = overlay.querySelector('.triggers-list');
+  const error = overlay.querySelector('.modal-error');
+  const close = () => overlay.remove();
+
+  const argPlaceholders = {
+    highlight: 'Color, e.g. #ffa000 (optional)',
+    reply: 'Reply, e.g. yes\\n',
+  };
+  const updateForm = () => {
+    const action = field('action').value;
+    field('arg').style.display = argPlaceholders[action] ? '' : 'none';
+    field('arg').placeholder = argPlaceholders[action] || '';
+    field('webhook').style.display = action === 'webhook' ? '' : 'none';
+    const scope = field('scope').value;
+    field('scopeValue').style.display = scope ? '' : 'none';
+    field('scopeValue').placeholder = scope === 'tag' ? 'Tag' : 'Session ID';
+  };
+  field('action').addEventListener('change', updateForm);
+  field('scope').addEventListener('change', updateForm);
+  updateForm();
+
+  // Webhook actions deliver to one of the server's webhooks.
+  const loadWebhooks = async () => {
+    let webhooks = [];
+    try {
+      const resp = await fetch('/api/webhooks');
+      if (resp.ok) webhooks = (await resp.json()).webhooks || [];
+    } catch {}
+    field('webhook').innerHTML = webhooks.length
+      ? webhooks.map(w => `<option value="${escapeHtml(w.id)}">${escapeHtml(w.name)}</option>`).join('')
+      : '<option value="">No webhooks yet</option>';
+  };
+  loadWebhooks();
+
+  const load = async () => {
+    let rules = [];
+    try {
+      const resp = await fetch('/api/triggers');
+      if (resp.ok) rules = (await resp.json()).rules || [];
+    } catch {}
+    list.innerHTML = rules.length ? '' : '<li class="triggers-empty">No triggers yet.</li>';
+    for (const r of rules) {
+      const li = document.createElement('li');
+      li.innerHTML = `
+        <label title="Enabled"><input type="checkbox" ${r.disabled ? '' : 'checked'}></label>
+        <span class="triggers-name">${escapeHtml(r.name)}</span>
+        <code class="triggers-pattern">${escapeHtml(r.pattern)}</code>
+        <span class="triggers-meta">${ACTIONS[r.action] || escapeHtml(r.action)} · ${escapeHtml(describeScope(r.scope))}</span>
+        <button class="btn btn-danger">Delete</button>
+      `;
+      li.querySelector('input').addEventListener('change', async (e) => {
+        await fetch(`/api/triggers/${r.id}`, {
+          method: 'PUT',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ ...r, disabled: !e.target.checked }),
+        });
+        load();
+      });
+      li.querySelector('button').addEventListener('click', async () => {
+        await fetch(`/api/triggers/${r.id}`, { method: 'DELETE' });
+        load();
+      });
+      list.appendChild(li);
+    }
+  };
+
+  overlay.querySelector('[data-act="add"]').addEventListener('click', async () => {
+    const action = field('action').value;
+    const arg = field('arg').value.trim();
+    const rule = { name: field('name').value.trim(), pattern: field('pattern').value, action, scope: {} };
+    if (action === 'highlight' && arg) rule.color = arg;
+    if (action === 'webhook') rule.webhook = field('webhook').value;
+    if (action === 'reply') rule.reply = parseReply(field('arg').value);
+    const scope = field('scope').value;
+    if (scope) rule.scope[scope] = field('scopeValue').value.trim();
+
+    const resp = await fetch('/api/triggers', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(rule),
+    });
+    if (!resp.ok) {
+      error.textContent = (await resp.text()).trim() || 'Failed to add trigger.';
+      return;
+    }
+    error.textContent = '';
+    field('name').value = '';
+    field('pattern').value = '';
+    field('arg').value = '';
+    load();
+  });
+  overlay.querySelector('[data-act="close"]').addEventListener('click', close);
+  overlay.addEventListener('click', (e) => {
+    if (e.target === overlay) close();
+  });
+
+  load();
+}
@@ -0,0 +1,317 @@
+// trzsz, for files sent to the remote host with trz (an upload) or from it
+// with tsz (a download). Both sides exchange lines "#TYPE:value"; strings,
+// file data and digests are zlib-compressed and base64-encoded, and every
+// step is acknowledged with a SUCC line. Binary mode (-b) and directories
+// (-d) are not supported: the browser declines them and the remote program
+// exits with the reason.
+
+import { base64ToBytes, bytesToBase64 } from './utils.js';
+
+const VERSION = '1.1.6';
+const BUF_SIZE = 10 << 10;    // file bytes in the first DATA line
+const MAX_BUF_SIZE = 1 << 20; // grown up to while acknowledgements are fast
+
+async function pipe(bytes, stream) {
+  const writer = stream.writable.getWriter();
+  writer.write(bytes).catch(() => {});
+  writer.close().catch(() => {});
+  return new Uint8Array(await new Response(stream.readable).arrayBuffer());
+}
+
+export async function encodeBytes(bytes) {
+  return bytesToBase64(await pipe(bytes, new CompressionStream('deflate')));
+}
+
+export async function decodeBytes(text) {
+  return pipe(base64ToBytes(text), new DecompressionStream('deflate'));
+}
+
+export async function encodeString(s) {
+  return encodeBytes(new TextEncoder().encode(s));
+}
+
+export async function decodeString(text) {
+  return new TextDecoder().decode(await decodeBytes(text));
+}
+
+// Lines splits what the other side writes into lines for next().
+export class Lines {
+  constructor() {
+    this.text = '';
+    this.lines = [];
+    this.wake = null;
+  }
+
+  push(bytes) {
+    for (let i = 0; i < bytes.length; i += 0x8000) {
+      this.text += String.fromCharCode(...bytes.subarray(i, i + 0x8000));
+    }
+    const lines = this.text.split('\n');
+    this.text = lines.pop();
+    this.lines.push(...lines);
+    if (lines.length && this.wake) {
+      this.wake();
+      this.wake = null;
+    }
+  }
+
+  async next() {
+    while (!this.lines.length) {
+      await new Promise((resolve) => { this.wake = resolve; });
+    }
+    return this.lines.shift();
+  }
+}
+
+// Md5 computes the digest trzsz checks each file against.
+const K = Int32Array.from({ length: 64 }, (_, i) => Math.floor(Math.abs(Math.sin(i + 1)) * 2 ** 32));
+const S = [7, 12, 17, 22, 5, 9, 14, 20, 4, 11, 16, 23, 6, 10, 15, 21];
+
+export class Md5 {
+  constructor() {
+    this.h = Int32Array.of(0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476);
+    this.block = new Uint8Array(64);
+    this.n = 0;
+    this.length = 0;
+  }
+
+  update(bytes) {
+    this.length += bytes.length;
+    for (let i = 0; i < bytes.length;) {
+      const take = Math.min(64 - this.n, bytes.length - i);
+      this.block.set(bytes.subarray(i, i + take), this.n);
+      this.n += take;
+      i += take;
+      if (this.n === 64) {
+        this._compress();
+        this.n = 0;
+      }
+    }
+  }
+
+  digest() {
+    const bits = this.length * 8;
+    const pad = new Uint8Array(((this.n < 56 ? 56 : 120) - this.n) + 8);
+    pad[0] = 0x80;
+    for (let i = 0; i < 8; i++) pad[pad.length - 8 + i] = Math.floor(bits / 2 ** (8 * i)) & 0xff;
+    this.update(pad);
+    const out = new Uint8Array(16);
+    for (let i = 0; i < 16; i++) out[i] = this.h[i >> 2] >>> ((i & 3) * 8);
+    return out;
+  }
+
+  _compress() {
+    const w = new Int32Array(16);
+    for (let i = 0; i < 16; i++) {
+      const b = this.block;
+      w[i] = b[i * 4] | (b[i * 4 + 1] << 8) | (b[i * 4 + 2] << 16) | (b[i * 4 + 3] << 24);
+    }
+    let [a, b, c, d] = this.h;
+    for (let i = 0; i < 64; i++) {
+      let f, g;
+      if (i < 16) {
+        f = (b & c) | (~b & d);
+        g = i;
+      } else if (i < 32) {
+        f = (d & b) | (~d & c);
+        g = (5 * i + 1) % 16;
+      } else if (i < 48) {
+        f = b ^ c ^ d;
+        g = (3 * i + 5) % 16;
+      } else {
+        f = c ^ (b | ~d);
+        g = (7 * i) % 16;
+      }
+      const x = (a + f + K[i] + w[g]) | 0;
+      const s = S[(i >> 4) * 4 + (i & 3)];
+      a = d;
+      d = c;
+      c = b;
+      b = (b + ((x << s) | (x >>> (32 - s)))) | 0;
+    }
+    this.h[0] += a;
+    this.h[1] += b;
+    this.h[2] += c;
+    this.h[3] += d;
+  }
+}
+
+const sameBytes = (a, b) => a.length === b.length && a.every((v, i) => v === b[i]);
+
+// RemoteError is a failure the remote program reported; it has already
+// given up, so there is nothing to send back.
+class RemoteError extends Error {}
+
+// Client is the browser side of a transfer. io provides the files and
+// receives the results:
+//
+//   send(bytes)          bytes for the remote program
+//   pick()               a promise of the files to upload, File-like
+//   save(name, chunks)   a downloaded file's bytes, as Uint8Arrays
+//   notice(text)         a message for the terminal
+//   end()                the transfer is over
+export class Client {
+  constructor(direction, io) {
+    this.upload = direction === 'upload';
+    this.io = io;
+    this.lines = new Lines();
+    this.newline = '\n';
+    this.started = false;
+  }
+
+  receive(bytes) {
+    this.lines.push(bytes);
+    if (!this.started) {
+      this.started = true;
+      // The first bytes are the start line, "::TRZSZ:TRANSFER:<mode>:...".
+      const mode = /:TRANSFER:(\w):/.exec(this.lines.lines.join('\n') + this.lines.text)?.[1];
+      this._run(mode);
+    }
+  }
+
+  async _run(mode) {
+    try {
+      let files = [];
+      if (mode === 'D') {
+        this.io.notice('trzsz directory uploads are not supported');
+      } else if (this.upload) {
+        files = await this.io.pick();
+      }
+      if (mode === 'D' || (this.upload && !files.length)) {
+        await this._action(false);
+        this.io.end();
+        return;
+      }
+      await this._action(true);
+      const config = JSON.parse(await decodeString(await this._recv('CFG')));
+      if (config.newline) this.newline = config.newline;
+      if (config.binary) throw new Error('binary mode (-b) is not supported');
+      const names = this.upload
+        ? await this._sendFiles(files, config.max_buf_size)
+        : await this._recvFiles();
+      await this._sendString('EXIT', `Saved ${names.join(', ')}${this.upload ? '' : " to the browser's downloads"}`);
+    } catch (err) {
+      const direction = this.upload ? 'upload' : 'download';
+      this.io.notice(`trzsz ${direction} failed: ${err.message}`);
+      if (!(err instanceof RemoteError)) {
+        try {
+          await this._sendString('FAIL', err.message);
+        } catch {}
+      }
+    }
+    this.io.end();
+  }
+
+  _action(confirm) {
+    return this._sendString('ACT', JSON.stringify({ lang: 'js', confirm, version: VERSION, support_dir: false }));
+  }
+
+  async _sendFiles(files, maxBufSize = MAX_BUF_SIZE) {
+    this._send('NUM', files.length);
+    await this._check(files.length);
+    const names = [];
+    for (const file of files) {
+      await this._sendString('NAME', file.name);
+      names.push(await decodeString(await this._recv('SUCC')));
+      this._send('SIZE', file.size);
+      await this._check(file.size);
+      const md5 = new Md5();
+      let size = BUF_SIZE;
+      for (let at = 0; at < file.size;) {
+        const start = Date.now();
+        const data = new Uint8Array(await file.slice(at, at + size).arrayBuffer());
+        md5.update(data);
+        this._send('DATA', await encodeBytes(data));
+        await this._check(data.length);
+        at += data.length;
+        if (Date.now() - start < 500) size = Math.min(size * 2, MAX_BUF_SIZE, maxBufSize);
+      }
+      const digest = md5.digest();
+      this._send('MD5', await encodeBytes(digest));
+      if (!sameBytes(await decodeBytes(await this._recv('SUCC')), digest)) {
+        throw new Error(`${file.name}: MD5 mismatch`);
+      }
+      this.io.notice(`sent ${file.name} (${file.size} bytes)`);
+    }
+    return names;
+  }
+
+  async _recvFiles() {
+    const num = await this._recvInteger('NUM');
+    this._send('SUCC', num);
+    const names = [];
+    for (let i = 0; i < num; i++) {
+      const name = (await decodeString(await this._recv('NAME'))).split(/[\\/]/).pop() || 'download';
+      await this._sendString('SUCC', name);
+      const size = await this._recvInteger('SIZE');
+      this._send('SUCC', size);
+      const md5 = new Md5();
+      const chunks = [];
+      for (let got = 0; got < size;) {
+        const data = await decodeBytes(await this._recv('DATA'));
+        md5.update(data);
+        chunks.push(data);
+        got += data.length;
+        this._send('SUCC', data.length);
+      }
+      const expected = await decodeBytes(await this._recv('MD5'));
+      const digest = md5.digest();
+      if (!sameBytes(expected, digest)) throw new Error(`${name}: MD5 mismatch`);
+      this._send('SUCC', await encodeBytes(digest));
+      this.io.save(name, chunks);
+      names.push(name);
+    }
+    return names;
+  }
+
+  // _recv returns the value of the next line of the given type. Anything
+  // before the last "#" of a line is junk, such as the start line.
+  async _recv(type) {
+    for (;;) {
+      let line = (await this.lines.next()).replace(/\r/g, '');
+      if (line.endsWith('!')) line = line.slice(0, -1);
+      let at = line.lastIndexOf(`#${type}:`);
+      if (at < 0) at = line.lastIndexOf('#');
+      if (at < 0) continue;
+      line = line.slice(at + 1);
+      const colon = line.indexOf(':');
+      const got = colon < 0 ? line : line.slice(0, colon);
+      const value = line.slice(colon + 1);
+      if (got === type) return value;
+      if (got.toUpperCase() === 'FAIL') {
+        throw new RemoteError(await decodeString(value).catch(() => value));
+      }
+      throw new Error(`expected ${type}, got ${got || 'junk'}`);
+    }
+  }
+
+  async _recvInteger(type) {
+    const n = parseInt(await this._recv(type), 10);
+    if (Number.isNaN(n)) throw new Error(`bad ${type}`);
+    return n;
+  }
+
+  async _check(expected) {
+    const n = await this._recvInteger('SUCC');
+    if (n !== expected) throw new Error(`expected ${expected}, got ${n}`);
+  }
+
+  _send(type, value) {
+    this.io.send(new TextEncoder().encode(`#${type}:${value}${this.newline}`));
+  }
+
+  async _sendString(type, s) {
+    this._send(type, await encodeString(s));
+  }
+}
+
+// trzszHandler is the transfer handler for protocol 'trzsz'.
+export function trzszHandler(transfer, channel) {
+  return new Client(transfer.direction, {
+    send: channel.send,
+    pick: () => channel.pickFiles(),
+    save: (name, chunks) => channel.save(name, new Blob(chunks)),
+    notice: channel.notice,
+    end: () => channel.end(),
+  });
+}
@@ -18,3 +18,44 @@ export function formatRelative(isoString) {
   const diffDay = Math.floor(diffHr / 24);
   return `${diffDay} day${diffDay !== 1 ? 's' : ''} ago`;
 }
+
+// notificationText returns the title and body shown for a session
+// notification from the server.
+export function notificationText(n) {
+  if (n.kind === 'command') {
+    const secs = Math.round((n.command?.durationMs ?? 0) / 1000);
+    const status = n.command?.exitCode === 0 ? 'finished' : `failed (exit ${n.command?.exitCode ?? '?'})`;
+    return { title: `${n.sessionName}: command ${status}`, body: `${n.command?.command || 'command'} — ${secs}s` };
+  }
+  if (n.kind === 'bell') {
+    return { title: n.sessionName, body: 'Bell' };
+  }
+  return { title: n.title ? `${n.sessionName}: ${n.title}` : n.sessionName, body: n.body || '' };
+}
+
+// showNotification shows a session notification through the browser, if the
+// user has allowed it. Pages that receive the same notification share a tag,
+// so it is shown once.
+export function showNotification(n, onClick) {
+  if (typeof Notification === 'undefined' || Notification.permission !== 'granted') return;
+  const { title, body } = notificationText(n);
+  const shown = new Notification(title, { body, tag: `${n.sessionId}:${n.time}` });
+  if (onClick) shown.onclick = onClick;
+}
+
+export function base64ToBytes(data) {
+  const binary = atob(data);
+  const bytes = new Uint8Array(binary.length);
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i);
+  }
+  return bytes;
+}
+
+export function bytesToBase64(bytes) {
+  let binary = '';
+  for (let i = 0; i < bytes.length; i += 0x8000) {
+    binary += String.fromCharCode(...bytes.subarray(i, i + 0x8000));
+  }
+  return btoa(binary);
+}
@@ -0,0 +1,654 @@
+// ZMODEM, for files sent by sz (a download) or received by rz (an upload) in
+// the session. This covers what lrzsz and compatible programs use: hex and
+// binary headers with 16- or 32-bit CRCs, streamed data subpackets, and ZRPOS
+// to resume after a damaged one. Remote commands (ZCOMMAND) and file
+// conversion are not supported.
+
+const ZPAD = 0x2a;
+const ZDLE = 0x18;
+const ZBIN = 0x41;
+const ZHEX = 0x42;
+const ZBIN32 = 0x43;
+const XON = 0x11;
+
+// Frame types.
+export const ZRQINIT = 0;
+export const ZRINIT = 1;
+export const ZSINIT = 2;
+export const ZACK = 3;
+export const ZFILE = 4;
+export const ZSKIP = 5;
+export const ZNAK = 6;
+export const ZABORT = 7;
+export const ZFIN = 8;
+export const ZRPOS = 9;
+export const ZDATA = 10;
+export const ZEOF = 11;
+export const ZFERR = 12;
+export const ZCAN = 16;
+export const ZCOMMAND = 18;
+
+// Subpacket ends: ZCRCE ends the frame, ZCRCG continues it, ZCRCQ continues
+// it and asks for a ZACK, ZCRCW ends it and asks for a ZACK.
+const ZCRCE = 0x68;
+const ZCRCG = 0x69;
+const ZCRCQ = 0x6a;
+const ZCRCW = 0x6b;
+const ZRUB0 = 0x6c;
+const ZRUB1 = 0x6d;
+
+// ZRINIT capabilities.
+const CANFDX = 0x01;
+const CANOVIO = 0x02;
+const CANFC32 = 0x20;
+
+// ZFILE conversion option: binary, as is.
+const ZCBIN = 1;
+
+const SUBPACKET = 1024;     // data bytes per subpacket we send
+const READ_SIZE = 64 << 10; // file bytes read at a time, each ending in ZCRCQ
+const WINDOW = 256 << 10;   // bytes sent ahead of the receiver's last ZACK
+const FIN_TIMEOUT = 1000;   // ms to wait for sz's "OO" after ZFIN
+
+const CRC16 = new Uint16Array(256);
+const CRC32 = new Uint32Array(256);
+for (let i = 0; i < 256; i++) {
+  let c = i << 8;
+  for (let k = 0; k < 8; k++) c = c & 0x8000 ? (c << 1) ^ 0x1021 : c << 1;
+  CRC16[i] = c;
+  c = i;
+  for (let k = 0; k < 8; k++) c = c & 1 ? 0xedb88320 ^ (c >>> 1) : c >>> 1;
+  CRC32[i] = c;
+}
+
+export function crc16(...parts) {
+  let crc = 0;
+  for (const part of parts) {
+    for (const b of part) crc = (CRC16[((crc >> 8) ^ b) & 0xff] ^ (crc << 8)) & 0xffff;
+  }
+  return crc;
+}
+
+export function crc32(...parts) {
+  let crc = 0xffffffff;
+  for (const part of parts) {
+    for (const b of part) crc = CRC32[(crc ^ b) & 0xff] ^ (crc >>> 8);
+  }
+  return ~crc >>> 0;
+}
+
+const be16 = (n) => [n >> 8, n & 0xff];
+const le32 = (n) => [n & 0xff, (n >>> 8) & 0xff, (n >>> 16) & 0xff, n >>> 24];
+const position = (hdr) => (hdr[0] | (hdr[1] << 8) | (hdr[2] << 16) | (hdr[3] << 24)) >>> 0;
+const flags = (f0) => [0, 0, 0, f0];
+
+// Bytes that are sent ZDLE-escaped: ZDLE itself and XON/XOFF in either
+// parity, which a terminal line may swallow.
+const ESCAPED = new Uint8Array(256);
+for (const b of [ZDLE, 0x10, 0x11, 0x13, 0x90, 0x91, 0x93]) ESCAPED[b] = 1;
+
+function escape(out, bytes) {
+  for (const b of bytes) {
+    // CR after '@' is escaped too: telnet would treat "@\r" specially.
+    const last = out.length ? out[out.length - 1] & 0x7f : 0;
+    if (ESCAPED[b] || ((b & 0x7f) === 0x0d && last === 0x40)) {
+      out.push(ZDLE, b ^ 0x40);
+    } else {
+      out.push(b);
+    }
+  }
+  return out;
+}
+
+export function hexHeader(type, hdr = [0, 0, 0, 0]) {
+  const bytes = [type, ...hdr];
+  const hex = [...bytes, ...be16(crc16(bytes))].map((b) => b.toString(16).padStart(2, '0')).join('');
+  // sz and rz follow each hex header except ZACK and ZFIN with an XON.
+  const tail = type === ZACK || type === ZFIN ? '' : '\x11';
+  return Uint8Array.from(`**\x18B${hex}\r\x8a${tail}`, (c) => c.charCodeAt(0));
+}
+
+export function binaryHeader(type, hdr, use32) {
+  const bytes = [type, ...hdr];
+  const out = [ZPAD, ZDLE, use32 ? ZBIN32 : ZBIN];
+  escape(out, bytes);
+  escape(out, use32 ? le32(crc32(bytes)) : be16(crc16(bytes)));
+  return Uint8Array.from(out);
+}
+
+export function subpacket(data, end, use32) {
+  const out = escape([], data);
+  out.push(ZDLE, end);
+  escape(out, use32 ? le32(crc32(data, [end])) : be16(crc16(data, [end])));
+  if (end === ZCRCW) out.push(XON);
+  return Uint8Array.from(out);
+}
+
+function concat(...parts) {
+  const out = new Uint8Array(parts.reduce((n, p) => n + p.length, 0));
+  let at = 0;
+  for (const p of parts) {
+    out.set(p, at);
+    at += p.length;
+  }
+  return out;
+}
+
+const NEED = -1;        // more bytes are needed
+const CANCELLED = -2;   // ZDLE CAN: the other side cancelled
+const FRAME_END = 0x100; // ORed with the ZCRCx byte that ended a subpacket
+
+// Reader parses the other side's bytes as they arrive: headers, and the data
+// subpackets following ZSINIT, ZFILE and ZDATA. Its methods return null
+// until a whole header or subpacket has arrived.
+class Reader {
+  constructor() {
+    this.buf = new Uint8Array(0);
+    this.at = 0;   // start of the unparsed bytes
+    this.i = 0;    // read position within a header or subpacket
+    this.cans = 0; // CANs in a row between headers
+  }
+
+  push(bytes) {
+    this.buf = concat(this.buf.subarray(this.at), bytes);
+    this.at = 0;
+  }
+
+  // rest returns the unparsed bytes.
+  rest() {
+    return this.buf.slice(this.at);
+  }
+
+  // header returns { type, hdr, use32 } for the next header, skipping
+  // anything else before it, or { type: ZCAN } once five CANs in a row say
+  // the other side gave up.
+  header() {
+    const buf = this.buf;
+    while (this.at < buf.length) {
+      const start = this.at;
+      if (buf[start] === ZDLE) {
+        this.at++;
+        if (++this.cans >= 5) return { type: ZCAN };
+        continue;
+      }
+      this.cans = 0;
+      if (buf[start] !== ZPAD) {
+        this.at++;
+        continue;
+      }
+      let i = start;
+      while (i < buf.length && buf[i] === ZPAD) i++;
+      if (i + 2 > buf.length) return null;
+      if (buf[i] !== ZDLE) {
+        this.at = i;
+        continue;
+      }
+      const format = buf[i + 1];
+      this.i = i + 2;
+      let h;
+      if (format === ZHEX) h = this._hexHeader();
+      else if (format === ZBIN || format === ZBIN32) h = this._binaryHeader(format === ZBIN32);
+      else h = undefined;
+      if (h === null) return null;
+      if (h === undefined) {
+        this.at = i + 1;
+        continue;
+      }
+      this.at = this.i;
+      if (h.ok) return h;
+    }
+    return null;
+  }
+
+  _hexHeader() {
+    const buf = this.buf;
+    if (this.i + 14 > buf.length) return null;
+    const text = String.fromCharCode(...buf.subarray(this.i, this.i + 14));
+    if (!/^[0-9a-fA-F]{14}$/.test(text)) return undefined;
+    const bytes = text.match(/../g).map((h) => parseInt(h, 16));
+    this.i += 14;
+    // CR, LF and the XON after them, as far as they have arrived.
+    for (const b of [0x0d, 0x0a, XON]) {
+      if (this.i < buf.length && (buf[this.i] & 0x7f) === b) this.i++;
+    }
+    const ok = crc16(bytes.slice(0, 5)) === ((bytes[5] << 8) | bytes[6]);
+    return { type: bytes[0], hdr: bytes.slice(1, 5), use32: false, ok };
+  }
+
+  _binaryHeader(use32) {
+    const bytes = [];
+    for (let n = use32 ? 9 : 7; n > 0; n--) {
+      const c = this._decode();
+      if (c === NEED) return null;
+      if (c < 0 || c & FRAME_END) return undefined;
+      bytes.push(c);
+    }
+    const ok = use32
+      ? crc32(bytes.slice(0, 5)) === position(bytes.slice(5))
+      : crc16(bytes.slice(0, 5)) === ((bytes[5] << 8) | bytes[6]);
+    return { type: bytes[0], hdr: bytes.slice(1, 5), use32, ok };
+  }
+
+  // subpacket returns { data, end, ok } for the next data subpacket, or
+  // { cancelled: true }.
+  subpacket(use32) {
+    this.i = this.at;
+    let data = new Uint8Array(SUBPACKET);
+    let n = 0;
+    let end;
+    for (;;) {
+      const c = this._decode();
+      if (c === NEED) return null;
+      if (c === CANCELLED) return this._cancelled();
+      if (c & FRAME_END) {
+        end = c & 0xff;
+        break;
+      }
+      if (n === data.length) {
+        const grown = new Uint8Array(n * 2);
+        grown.set(data);
+        data = grown;
+      }
+      data[n++] = c;
+    }
+    data = data.slice(0, n);
+    const crc = [];
+    for (let k = use32 ? 4 : 2; k > 0; k--) {
+      const c = this._decode();
+      if (c === NEED) return null;
+      if (c === CANCELLED) return this._cancelled();
+      crc.push(c & 0xff);
+    }
+    this.at = this.i;
+    const ok = use32
+      ? crc32(data, [end]) === position(crc)
+      : crc16(data, [end]) === ((crc[0] << 8) | crc[1]);
+    return { data, end, ok };
+  }
+
+  _cancelled() {
+    this.at = this.i;
+    return { cancelled: true };
+  }
+
+  // _decode reads one byte at this.i, undoing ZDLE escapes and dropping
+  // XON/XOFF, which are never sent unescaped.
+  _decode() {
+    const buf = this.buf;
+    for (;;) {
+      if (this.i >= buf.length) return NEED;
+      const b = buf[this.i++];
+      if (b === 0x11 || b === 0x13 || b === 0x91 || b === 0x93) continue;
+      if (b !== ZDLE) return b;
+      if (this.i >= buf.length) return NEED;
+      const c = buf[this.i++];
+      if (c === ZDLE) return CANCELLED;
+      if (c >= ZCRCE && c <= ZCRCW) return FRAME_END | c;
+      if (c === ZRUB0) return 0x7f;
+      if (c === ZRUB1) return 0xff;
+      return c ^ 0x40;
+    }
+  }
+}
+
+// parseFileInfo reads the ZFILE subpacket: the file name, a NUL, then the
+// size and other fields separated by spaces.
+function parseFileInfo(data) {
+  const nul = data.indexOf(0);
+  const path = new TextDecoder().decode(data.subarray(0, nul < 0 ? data.length : nul));
+  const fields = nul < 0 ? '' : new TextDecoder().decode(data.subarray(nul + 1)).replace(/\0.*$/s, '');
+  const size = parseInt(fields.split(' ')[0], 10);
+  return { name: path.split('/').pop() || 'download', size: Number.isNaN(size) ? undefined : size };
+}
+
+// Receiver takes files from sz. io receives the results:
+//
+//   send(bytes)       bytes for sz
+//   file(name, size)  a file begins; size may be undefined
+//   data(bytes)       the file's next bytes
+//   eof()             the file is complete
+//   done(rest)        sz has finished; rest is what followed its "OO"
+//   fail(message)     the transfer failed or sz cancelled it
+export class Receiver {
+  constructor(io) {
+    this.io = io;
+    this.reader = new Reader();
+    this.state = 'header';
+    this.use32 = false;
+    this.pos = 0;
+    this.receiving = false;
+    this.timer = null;
+  }
+
+  receive(bytes) {
+    this.reader.push(bytes);
+    while (this.state !== 'done' && this._step()) {
+      // keep going while whole frames are buffered
+    }
+  }
+
+  _step() {
+    if (this.state === 'fin') {
+      const rest = this.reader.rest();
+      for (let i = 0; i + 1 < rest.length; i++) {
+        if (rest[i] === 0x4f && rest[i + 1] === 0x4f) {
+          this._done(rest.slice(i + 2));
+          break;
+        }
+      }
+      return false;
+    }
+    if (this.state === 'header') {
+      const h = this.reader.header();
+      if (!h) return false;
+      this._header(h);
+      return true;
+    }
+    const p = this.reader.subpacket(this.use32);
+    if (!p) return false;
+    if (p.cancelled) return this._fail('cancelled by sz');
+    this._subpacket(p);
+    return true;
+  }
+
+  _header(h) {
+    switch (h.type) {
+      case ZRQINIT:
+        this._zrinit();
+        break;
+      case ZSINIT:
+      case ZFILE:
+      case ZDATA:
+        this.use32 = h.use32;
+        if (h.type === ZSINIT) {
+          this.state = 'sinit';
+        } else if (h.type === ZFILE) {
+          this.state = 'file';
+        } else if (!this.receiving) {
+          // ZDATA without ZFILE: ask for the file again.
+          this._zrinit();
+        } else if (position(h.hdr) !== this.pos) {
+          this.io.send(hexHeader(ZRPOS, le32(this.pos)));
+        } else {
+          this.state = 'data';
+        }
+        break;
+      case ZEOF:
+        // A ZEOF at another position is stale: the data resent after our
+        // ZRPOS is still on its way.
+        if (this.receiving && position(h.hdr) === this.pos) {
+          this.receiving = false;
+          this.io.eof();
+          this._zrinit();
+        }
+        break;
+      case ZFIN:
+        this.io.send(hexHeader(ZFIN));
+        this.state = 'fin';
+        this.timer = setTimeout(() => this._done(this.reader.rest()), FIN_TIMEOUT);
+        break;
+      case ZCOMMAND:
+        this._fail('sz sent a command, which is not supported');
+        break;
+      case ZCAN:
+      case ZABORT:
+      case ZFERR:
+        this._fail('cancelled by sz');
+        break;
+    }
+  }
+
+  _subpacket(p) {
+    const state = this.state;
+    this.state = 'header';
+    if (state === 'sinit' || state === 'file') {
+      if (!p.ok) {
+        this.io.send(hexHeader(ZNAK));
+        return;
+      }
+      if (state === 'sinit') {
+        this.io.send(hexHeader(ZACK, le32(1)));
+        return;
+      }
+      const { name, size } = parseFileInfo(p.data);
+      this.receiving = true;
+      this.pos = 0;
+      this.io.file(name, size);
+      this.io.send(hexHeader(ZRPOS, le32(0)));
+      return;
+    }
+    if (!p.ok) {
+      // Skip to sz's next header, which will resend from here.
+      this.io.send(hexHeader(ZRPOS, le32(this.pos)));
+      return;
+    }
+    this.io.data(p.data);
+    this.pos += p.data.length;
+    if (p.end === ZCRCQ || p.end === ZCRCW) this.io.send(hexHeader(ZACK, le32(this.pos)));
+    if (p.end === ZCRCG || p.end === ZCRCQ) this.state = 'data';
+  }
+
+  _zrinit() {
+    this.io.send(hexHeader(ZRINIT, flags(CANFDX | CANOVIO | CANFC32)));
+  }
+
+  _done(rest) {
+    if (this.state === 'done') return;
+    clearTimeout(this.timer);
+    this.state = 'done';
+    this.io.done(rest);
+  }
+
+  _fail(message) {
+    this.state = 'done';
+    this.io.fail(message);
+    return false;
+  }
+}
+
+// Sender sends files to rz. io provides the files and receives the results:
+//
+//   send(bytes)              bytes for rz
+//   pick()                   a promise of the files to send, File-like
+//   read(file, start, end)   a promise of the file's bytes in that range
+//   sent(file)               rz has the whole file
+//   done(rest)               rz has finished; rest is what followed its ZFIN
+//   cancel()                 nothing was picked
+//   fail(message)            the transfer failed or rz cancelled it
+export class Sender {
+  constructor(io) {
+    this.io = io;
+    this.reader = new Reader();
+    this.state = 'init';
+    this.use32 = false;
+    this.files = [];
+    this.file = null;
+    this.run = 0;    // counts streams of file data; a ZRPOS starts another
+    this.acked = 0;  // the receiver's position from its last ZACK
+    this.wake = null;
+  }
+
+  receive(bytes) {
+    this.reader.push(bytes);
+    for (let h; this.state !== 'done' && (h = this.reader.header());) {
+      this._header(h);
+    }
+  }
+
+  _header(h) {
+    if (h.type === ZCAN || h.type === ZABORT || h.type === ZFERR) {
+      this._fail('cancelled by rz');
+      return;
+    }
+    switch (this.state) {
+      case 'init':
+        if (h.type === ZRINIT) {
+          this.use32 = (h.hdr[3] & CANFC32) !== 0;
+          this.state = 'picking';
+          this._pick();
+        }
+        break;
+      case 'zfile':
+        if (h.type === ZRPOS) this._stream(position(h.hdr));
+        else if (h.type === ZSKIP) this._next();
+        else if (h.type === ZRINIT || h.type === ZNAK) this._sendFile();
+        break;
+      case 'data':
+      case 'eof':
+        if (h.type === ZACK) {
+          this.acked = Math.max(this.acked, position(h.hdr));
+          this._wake();
+        } else if (h.type === ZRPOS) {
+          this._stream(position(h.hdr));
+        } else if (h.type === ZSKIP) {
+          this._next();
+        } else if (h.type === ZRINIT && this.state === 'eof') {
+          this.io.sent(this.file);
+          this._next();
+        }
+        break;
+      case 'fin':
+        if (h.type === ZRINIT) {
+          this.io.send(hexHeader(ZFIN));
+        } else if (h.type === ZFIN) {
+          this.io.send(Uint8Array.from('OO', (c) => c.charCodeAt(0)));
+          this.state = 'done';
+          this.io.done(this.reader.rest());
+        }
+        break;
+    }
+  }
+
+  async _pick() {
+    let files;
+    try {
+      files = await this.io.pick();
+    } catch (err) {
+      this._fail(err.message);
+      return;
+    }
+    if (this.state !== 'picking') return;
+    if (!files.length) {
+      this.state = 'done';
+      this.io.cancel();
+      return;
+    }
+    this.files = [...files];
+    this._next();
+  }
+
+  _next() {
+    this.run++;
+    this._wake();
+    this.file = this.files.shift();
+    if (!this.file) {
+      this.state = 'fin';
+      this.io.send(hexHeader(ZFIN));
+      return;
+    }
+    this._sendFile();
+  }
+
+  _sendFile() {
+    const { name, size, lastModified = Date.now() } = this.file;
+    const left = this.files.reduce((n, f) => n + f.size, size);
+    const info = `${name}\0${size} ${Math.floor(lastModified / 1000).toString(8)} 0 0 ${this.files.length + 1} ${left}\0`;
+    this.state = 'zfile';
+    this.io.send(concat(
+      binaryHeader(ZFILE, flags(ZCBIN), this.use32),
+      subpacket(new TextEncoder().encode(info), ZCRCW, this.use32),
+    ));
+  }
+
+  // _stream sends the file from offset as one ZDATA frame, then ZEOF. Every
+  // read ends in a ZCRCQ, and it waits for rz's ZACKs to stay within WINDOW.
+  async _stream(offset) {
+    const run = ++this.run;
+    this._wake();
+    const file = this.file;
+    this.state = 'data';
+    this.acked = offset;
+    this.io.send(binaryHeader(ZDATA, le32(offset), this.use32));
+    let at = offset;
+    for (let last = false; !last;) {
+      let chunk;
+      try {
+        chunk = await this.io.read(file, at, Math.min(at + READ_SIZE, file.size));
+      } catch (err) {
+        if (run === this.run) this._fail(err.message);
+        return;
+      }
+      if (run !== this.run) return;
+      last = at + chunk.length >= file.size;
+      const packets = [];
+      for (let i = 0; i < chunk.length || i === 0; i += SUBPACKET) {
+        const data = chunk.subarray(i, i + SUBPACKET);
+        const end = i + SUBPACKET < chunk.length ? ZCRCG : last ? ZCRCE : ZCRCQ;
+        packets.push(subpacket(data, end, this.use32));
+      }
+      this.io.send(concat(...packets));
+      at += chunk.length;
+      while (!last && at - this.acked > WINDOW && run === this.run) {
+        await new Promise((resolve) => { this.wake = resolve; });
+      }
+      if (run !== this.run) return;
+    }
+    this.state = 'eof';
+    this.io.send(binaryHeader(ZEOF, le32(file.size), this.use32));
+  }
+
+  _wake() {
+    const wake = this.wake;
+    this.wake = null;
+    if (wake) wake();
+  }
+
+  _fail(message) {
+    this.state = 'done';
+    this.run++;
+    this._wake();
+    this.io.fail(message);
+  }
+}
+
+// zmodemHandler is the transfer handler for protocol 'zmodem'.
+export function zmodemHandler(transfer, channel) {
+  if (transfer.direction === 'download') {
+    let name;
+    let chunks = [];
+    return new Receiver({
+      send: channel.send,
+      file(fileName, size) {
+        name = fileName;
+        chunks = [];
+        channel.notice(`receiving ${name}${size === undefined ? '' : ` (${size} bytes)`}`);
+      },
+      data(bytes) {
+        chunks.push(bytes);
+      },
+      eof() {
+        channel.save(name, new Blob(chunks));
+        chunks = [];
+      },
+      done: channel.end,
+      fail(message) {
+        channel.notice(`zmodem download failed: ${message}`);
+        channel.cancel();
+      },
+    });
+  }
+  return new Sender({
+    send: channel.send,
+    pick: () => channel.pickFiles(),
+    read: async (file, start, end) => new Uint8Array(await file.slice(start, end).arrayBuffer()),
+    sent(file) {
+      channel.notice(`sent ${file.name} (${file.size} bytes)`);
+    },
+    done: channel.end,
+    cancel: channel.cancel,
+    fail(message) {
+      channel.notice(`zmodem upload failed: ${message}`);
+      channel.cancel();
+    },
+  });
+}